@@ -7,9 +7,12 @@ import (
 	"encoding/base64"
 	"io"
 	"os"
+	"path/filepath"
+	"sync"
 )
 
 type LicenseHandler struct {
+	mu            sync.RWMutex
 	filePath      string
 	encryptionKey []byte // 32 bytes for AES-256
 }
@@ -25,6 +28,20 @@ func NewLicenseHandler(path string) *LicenseHandler {
 	}
 }
 
+// SetPath switches to another license file, e.g. the one of the company
+// that was just opened.
+func (h *LicenseHandler) SetPath(path string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.filePath = path
+}
+
+func (h *LicenseHandler) path() string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.filePath
+}
+
 func (h *LicenseHandler) SaveLicense(licenseKey string) error {
 	// Encrypt
 	block, err := aes.NewCipher(h.encryptionKey)
@@ -47,11 +64,15 @@ func (h *LicenseHandler) SaveLicense(licenseKey string) error {
 	encoded := base64.StdEncoding.EncodeToString(ciphertext)
 
 	// Save to file
-	return os.WriteFile(h.filePath, []byte(encoded), 0644)
+	path := h.path()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(encoded), 0644)
 }
 
 func (h *LicenseHandler) ReadLicense() (string, error) {
-	data, err := os.ReadFile(h.filePath)
+	data, err := os.ReadFile(h.path())
 	if err != nil {
 		return "", err
 	}
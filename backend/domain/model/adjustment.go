@@ -0,0 +1,47 @@
+package model
+
+import "time"
+
+// Adjustment statuses
+const (
+	AdjustmentPending  = "pending"
+	AdjustmentApproved = "approved"
+	AdjustmentRejected = "rejected"
+)
+
+// Directions a reason code allows
+const (
+	DirectionDecrease = "decrease"
+	DirectionIncrease = "increase"
+	DirectionEither   = "either"
+)
+
+// AdjustmentReason is a configurable reason code for stock adjustments.
+type AdjustmentReason struct {
+	Code      string    `gorm:"primaryKey"`
+	Label     string    `gorm:"not null"`
+	Direction string    `gorm:"not null;default:'either'"`
+	Active    bool      `gorm:"not null"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+// StockAdjustment is a manual stock correction such as a write-off. It only
+// reaches the stock ledger once approved.
+type StockAdjustment struct {
+	ID          uint   `gorm:"primaryKey"`
+	InventoryID uint   `gorm:"not null;index"`
+	ReasonCode  string `gorm:"not null;index"`
+	// Quantity is the signed change in stock
+	Quantity float64 `gorm:"not null"`
+	// UnitCost values the adjustment at the time it was requested
+	UnitCost     float64 `gorm:"not null"`
+	Note         string  `gorm:"not null;default:''"`
+	PhotoPath    string  `gorm:"not null;default:''"`
+	Status       string  `gorm:"not null;index"`
+	RequestedBy  uint    `gorm:"not null"`
+	ApprovedBy   *uint
+	DecisionNote string `gorm:"not null;default:''"`
+	MovementID   *uint
+	CreatedAt    time.Time  `gorm:"autoCreateTime"`
+	DecidedAt    *time.Time `gorm:"index"`
+}
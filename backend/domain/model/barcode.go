@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// Barcode is one of the codes an item can be scanned by. Codes are stored
+// normalized, see package barcode.
+type Barcode struct {
+	ID          uint   `gorm:"primaryKey"`
+	InventoryID uint   `gorm:"not null;index"`
+	Code        string `gorm:"not null;uniqueIndex"`
+	Symbology   string `gorm:"not null"`
+	// UnitID is set on the code of a pack; scanning it counts the whole pack
+	UnitID    *uint     `gorm:"index"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
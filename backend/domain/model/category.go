@@ -0,0 +1,44 @@
+package model
+
+import "time"
+
+// Category groups items in a tree of any depth.
+type Category struct {
+	ID       uint   `gorm:"primaryKey"`
+	ParentID *uint  `gorm:"index"`
+	Name     string `gorm:"not null"`
+	// Path lists the IDs from the root down to the category, as "/1/4/", so
+	// a subtree is every category whose path starts with its root's
+	Path  string `gorm:"not null;index"`
+	Depth int    `gorm:"not null"`
+	// TaxClass and the reorder levels are defaults for the items below the
+	// category; empty or nil inherits them from the parent
+	TaxClass        string `gorm:"not null;default:''"`
+	ReorderPoint    *float64
+	ReorderQuantity *float64
+	MinLevel        *float64
+	MaxLevel        *float64
+	LeadTimeDays    *int
+	CreatedAt       time.Time `gorm:"autoCreateTime"`
+	UpdatedAt       time.Time `gorm:"autoUpdateTime"`
+}
+
+// Brand is the make of an item.
+type Brand struct {
+	ID        uint      `gorm:"primaryKey"`
+	Name      string    `gorm:"not null"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// Tag is a free label; items can carry any number of them.
+type Tag struct {
+	ID        uint      `gorm:"primaryKey"`
+	Name      string    `gorm:"not null"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// InventoryTag puts a tag on an item.
+type InventoryTag struct {
+	InventoryID uint `gorm:"primaryKey"`
+	TagID       uint `gorm:"primaryKey;index"`
+}
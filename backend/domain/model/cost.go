@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+// Costing methods value the stock that leaves the shop
+const (
+	CostingFIFO     = "fifo"
+	CostingAverage  = "average"
+	CostingStandard = "standard"
+)
+
+// CostLayer is stock that came in at one unit cost. FIFO costing consumes
+// the oldest layers first.
+type CostLayer struct {
+	ID          uint `gorm:"primaryKey"`
+	InventoryID uint `gorm:"not null;index"`
+	// MovementID is the inbound movement that opened the layer; nil for
+	// stock held before costs were kept
+	MovementID *uint   `gorm:"index"`
+	Quantity   float64 `gorm:"not null"`
+	Remaining  float64 `gorm:"not null"`
+	// UnitCost is per base unit of the item
+	UnitCost  float64   `gorm:"not null"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
@@ -0,0 +1,36 @@
+package model
+
+import "time"
+
+// GoodsReceipt is one delivery against a purchase order.
+type GoodsReceipt struct {
+	ID      uint `gorm:"primaryKey"`
+	OrderID uint `gorm:"not null;index"`
+	// Reference is the supplier's delivery note number
+	Reference string `gorm:"not null;default:''"`
+	Notes     string `gorm:"not null;default:''"`
+	// LocationID is where the goods were received
+	LocationID uint      `gorm:"not null;default:1"`
+	ReceivedBy uint      `gorm:"not null"`
+	ReceivedAt time.Time `gorm:"not null;index"`
+	CreatedAt  time.Time `gorm:"autoCreateTime"`
+}
+
+// GoodsReceiptLine is what a delivery brought of one order line, in the
+// line's unit. Received goods went into stock; damaged ones were refused
+// and stay outstanding on the order.
+type GoodsReceiptLine struct {
+	ID          uint `gorm:"primaryKey"`
+	ReceiptID   uint `gorm:"not null;index"`
+	OrderLineID uint `gorm:"not null;index"`
+	InventoryID uint `gorm:"not null;index"`
+	// Expected is what was outstanding on the line before the delivery
+	Expected float64 `gorm:"not null"`
+	Received float64 `gorm:"not null"`
+	Damaged  float64 `gorm:"not null;default:0"`
+	// MovementID is the receipt in the stock ledger, nil when nothing
+	// usable arrived
+	MovementID *uint
+	// LotID is the lot lot-tracked goods went into
+	LotID *uint
+}
@@ -3,10 +3,45 @@ package model
 import "time"
 
 type Inventory struct {
-	ID        uint      `gorm:"primaryKey"`
-	Name      string    `gorm:"not null"`
-	Quantity  int       `gorm:"not null"`
-	Price     float64   `gorm:"not null"`
-	CreatedAt time.Time `gorm:"autoCreateTime"`
-	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+	ID uint `gorm:"primaryKey"`
+	// ParentID is set on variants. A parent is not sold or stocked itself;
+	// its variants are
+	ParentID *uint  `gorm:"index"`
+	Name     string `gorm:"not null"`
+	// SKU is unique among items that have one, ignoring case
+	SKU string `gorm:"not null;default:''"`
+	// Barcode mirrors the primary entry in barcodes, for listing and search
+	Barcode    string `gorm:"not null;default:'';index"`
+	CategoryID *uint  `gorm:"index"`
+	BrandID    *uint  `gorm:"index"`
+	// Unit is the code of the base unit quantities and prices refer to
+	Unit     string  `gorm:"not null;default:'pc'"`
+	Quantity float64 `gorm:"not null"`
+	Price    float64 `gorm:"not null"`
+	// TrackLots keeps the item's stock in lots with expiry dates
+	TrackLots bool `gorm:"not null"`
+	// TrackSerials books every unit of the item by its serial number.
+	// WarrantyMonths is how long a unit is under warranty after its sale
+	TrackSerials   bool `gorm:"not null"`
+	WarrantyMonths int  `gorm:"not null;default:0"`
+	// Costs are per base unit: LastCost is what the last purchase paid,
+	// AverageCost the moving average of stock taken in and StandardCost the
+	// fixed cost standard costing books at
+	LastCost     float64 `gorm:"not null;default:0"`
+	AverageCost  float64 `gorm:"not null;default:0"`
+	StandardCost float64 `gorm:"not null;default:0"`
+	// TaxClass and the reorder levels override the category's defaults;
+	// empty or nil inherits them. MinLevel is the safety stock, MaxLevel the
+	// level an order fills up to and LeadTimeDays how long an order takes
+	TaxClass        string `gorm:"not null;default:''"`
+	ReorderPoint    *float64
+	ReorderQuantity *float64
+	MinLevel        *float64
+	MaxLevel        *float64
+	LeadTimeDays    *int
+	// ArchivedAt hides an item from listings and sales without losing its
+	// history
+	ArchivedAt *time.Time `gorm:"index"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime"`
+	UpdatedAt  time.Time  `gorm:"autoUpdateTime"`
 }
@@ -0,0 +1,30 @@
+package model
+
+import "time"
+
+// Job run statuses
+const (
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+)
+
+// ScheduledJob is the persisted state of a background job, so schedules
+// survive restarts.
+type ScheduledJob struct {
+	Name           string `gorm:"primaryKey"`
+	LastRunAt      *time.Time
+	NextRunAt      *time.Time
+	LastError      string
+	LastDurationMs int64
+}
+
+// JobRun is one execution of a job.
+type JobRun struct {
+	ID         uint      `gorm:"primaryKey"`
+	Job        string    `gorm:"not null;index"`
+	StartedAt  time.Time `gorm:"not null"`
+	FinishedAt *time.Time
+	Status     string `gorm:"not null"`
+	Error      string
+}
@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// MainLocation is the location the stock held before locations existed
+// was put in. Ledger rows that name no location belong to it.
+const MainLocation = 1
+
+// Location is a place stock is kept, such as the shop floor, a back room
+// or another branch. Names are unique, ignoring case. The one transit
+// location holds goods sent between locations until they arrive; nothing
+// is sold or received there. Reorder levels stay company-wide: suppliers
+// deliver against one order for the company, and transfers spread the
+// stock over the locations.
+type Location struct {
+	ID      uint   `gorm:"primaryKey"`
+	Name    string `gorm:"not null"`
+	Transit bool   `gorm:"not null"`
+	// ArchivedAt hides an empty location from new movements
+	ArchivedAt *time.Time `gorm:"index"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime"`
+	UpdatedAt  time.Time  `gorm:"autoUpdateTime"`
+}
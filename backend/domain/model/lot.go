@@ -0,0 +1,29 @@
+package model
+
+import "time"
+
+// Lot is a batch of a lot-tracked item received together under one lot
+// number, at one location. Quantity is what is left of it, in the item's
+// base unit. A lot moved to another location continues there as a lot of
+// the same number. Stock the item holds outside lots, e.g. from before it
+// was tracked, is its on-hand quantity less its lots.
+type Lot struct {
+	ID          uint   `gorm:"primaryKey"`
+	InventoryID uint   `gorm:"not null;index"`
+	LocationID  uint   `gorm:"not null;default:1;index"`
+	Number      string `gorm:"not null"`
+	// ExpiresAt is the last day the lot may be sold, at midnight UTC; nil
+	// for goods that keep
+	ExpiresAt *time.Time `gorm:"index"`
+	Quantity  float64    `gorm:"not null;default:0"`
+	CreatedAt time.Time  `gorm:"autoCreateTime"`
+}
+
+// LotAllocation is the part of a stock movement that went into or came out
+// of a lot, signed like the movement.
+type LotAllocation struct {
+	ID         uint    `gorm:"primaryKey"`
+	LotID      uint    `gorm:"not null;index"`
+	MovementID uint    `gorm:"not null;index"`
+	Quantity   float64 `gorm:"not null"`
+}
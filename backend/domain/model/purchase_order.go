@@ -0,0 +1,48 @@
+package model
+
+import "time"
+
+// Purchase order statuses. An order is drafted, sent to the supplier, and
+// received in one or more deliveries; closing it gives up on what is still
+// outstanding.
+const (
+	PurchaseDraft    = "draft"
+	PurchaseSent     = "sent"
+	PurchasePartial  = "partial"
+	PurchaseReceived = "received"
+	PurchaseClosed   = "closed"
+)
+
+type PurchaseOrder struct {
+	ID uint `gorm:"primaryKey"`
+	// Number is what the supplier sees, e.g. "PO-000042"
+	Number     string `gorm:"not null;index"`
+	SupplierID uint   `gorm:"not null;index"`
+	Status     string `gorm:"not null;index"`
+	Notes      string `gorm:"not null;default:''"`
+	// ExpectedAt is when the goods should arrive
+	ExpectedAt *time.Time
+	CreatedBy  uint `gorm:"not null"`
+	SentAt     *time.Time
+	ClosedAt   *time.Time
+	CreatedAt  time.Time `gorm:"autoCreateTime"`
+	UpdatedAt  time.Time `gorm:"autoUpdateTime"`
+}
+
+// PurchaseOrderLine is one item on an order. Quantities and the cost are per
+// the line's unit, a pack of the item or its base unit.
+type PurchaseOrderLine struct {
+	ID          uint `gorm:"primaryKey"`
+	OrderID     uint `gorm:"not null;index"`
+	InventoryID uint `gorm:"not null;index"`
+	UnitID      *uint
+	// Name and SupplierSKU are copied when the line is written, so a sent
+	// order keeps reading the same
+	Name        string  `gorm:"not null"`
+	SupplierSKU string  `gorm:"not null;default:''"`
+	Quantity    float64 `gorm:"not null"`
+	// Received counts what deliveries brought so far
+	Received float64 `gorm:"not null;default:0"`
+	UnitCost float64 `gorm:"not null"`
+	Position int     `gorm:"not null"`
+}
@@ -3,10 +3,16 @@ package model
 import "time"
 
 type Sale struct {
-	ID          uint      `gorm:"primaryKey"`
-	InventoryID uint      `gorm:"not null"`
-	Quantity    int       `gorm:"not null"`
-	TotalPrice  float64   `gorm:"not null"`
-	CreatedAt   time.Time `gorm:"autoCreateTime"`
-	UpdatedAt   time.Time `gorm:"autoUpdateTime"`
+	ID          uint    `gorm:"primaryKey"`
+	InventoryID uint    `gorm:"not null"`
+	Quantity    float64 `gorm:"not null"`
+	TotalPrice  float64 `gorm:"not null"`
+	// LocationID is where the goods were sold from
+	LocationID uint `gorm:"not null;default:1;index"`
+	// Cost is the cost of the goods sold, valued when the sale was made
+	Cost      float64   `gorm:"not null;default:0"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+	// ArchivedAt is set once the sale was exported by the retention job
+	ArchivedAt *time.Time `gorm:"index"`
 }
@@ -0,0 +1,44 @@
+package model
+
+import "time"
+
+// Serial number statuses
+const (
+	SerialInStock = "in_stock"
+	SerialSold    = "sold"
+	// SerialRemoved is a unit booked out other than by a sale, e.g. written
+	// off
+	SerialRemoved = "removed"
+)
+
+// SerialNumber is one unit of a serialized item, such as a phone by its
+// IMEI. Serials are unique per item, ignoring case.
+type SerialNumber struct {
+	ID          uint   `gorm:"primaryKey"`
+	InventoryID uint   `gorm:"not null;index"`
+	Serial      string `gorm:"not null"`
+	Status      string `gorm:"not null"`
+	// LocationID is where the unit is while it is in stock
+	LocationID *uint
+	// SoldAt and WarrantyEndsAt are set while the unit is sold. The warranty
+	// covers the day it ends, kept at midnight UTC; nil for items sold
+	// without one
+	SoldAt         *time.Time
+	WarrantyEndsAt *time.Time
+	CreatedAt      time.Time `gorm:"autoCreateTime"`
+	UpdatedAt      time.Time `gorm:"autoUpdateTime"`
+}
+
+// SerialEvent is a stock movement a serialized unit took part in. Party is
+// who it came from or went to, such as the supplier of a receipt or the
+// customer of a sale.
+type SerialEvent struct {
+	ID         uint   `gorm:"primaryKey"`
+	SerialID   uint   `gorm:"not null;index"`
+	MovementID uint   `gorm:"not null;index"`
+	Type       string `gorm:"not null"`
+	Party      string `gorm:"not null;default:''"`
+	// Reference is the movement's, naming the document behind it
+	Reference string    `gorm:"not null;default:''"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
@@ -0,0 +1,10 @@
+package model
+
+import "time"
+
+// Setting is a per-company preference stored in the company database.
+type Setting struct {
+	Key       string    `gorm:"primaryKey"`
+	Value     string    `gorm:"not null"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
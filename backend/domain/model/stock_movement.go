@@ -0,0 +1,39 @@
+package model
+
+import "time"
+
+// Stock movement types
+const (
+	MovementOpening    = "opening"
+	MovementSale       = "sale"
+	MovementReturn     = "return"
+	MovementReceipt    = "receipt"
+	MovementAdjustment = "adjustment"
+	MovementTransfer   = "transfer"
+	MovementStocktake  = "stocktake"
+	// MovementRevaluation changes the value of stock, not its quantity
+	MovementRevaluation = "revaluation"
+)
+
+// StockMovement is one entry of the append-only stock ledger. The on-hand
+// quantity of an item is the sum of its movements; Inventory.Quantity caches
+// it.
+type StockMovement struct {
+	ID          uint   `gorm:"primaryKey"`
+	InventoryID uint   `gorm:"not null;index"`
+	Type        string `gorm:"not null;index"`
+	// Quantity is the signed change in stock, in the item's base unit
+	Quantity float64 `gorm:"not null"`
+	// Balance is the on-hand quantity after this movement
+	Balance float64 `gorm:"not null"`
+	// Cost is the signed change in stock value at cost
+	Cost float64 `gorm:"not null;default:0"`
+	// Reference names the document behind the movement, e.g. "sale:42"
+	Reference string `gorm:"not null;default:'';index"`
+	// LocationID is where the stock moved
+	LocationID uint `gorm:"not null;default:1;index"`
+	// UserID is nil for movements the application made on its own
+	UserID    *uint
+	Reason    string    `gorm:"not null;default:''"`
+	CreatedAt time.Time `gorm:"autoCreateTime;index"`
+}
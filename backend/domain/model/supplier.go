@@ -0,0 +1,37 @@
+package model
+
+import "time"
+
+// Supplier is a business the shop buys stock from.
+type Supplier struct {
+	ID          uint   `gorm:"primaryKey"`
+	Name        string `gorm:"not null"`
+	ContactName string `gorm:"not null;default:''"`
+	Email       string `gorm:"not null;default:''"`
+	Phone       string `gorm:"not null;default:''"`
+	Address     string `gorm:"not null;default:''"`
+	// PaymentTermsDays is how many days after delivery payment is due
+	PaymentTermsDays int    `gorm:"not null;default:0"`
+	Notes            string `gorm:"not null;default:''"`
+	// ArchivedAt hides a supplier that is no longer used but has orders
+	ArchivedAt *time.Time `gorm:"index"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime"`
+	UpdatedAt  time.Time  `gorm:"autoUpdateTime"`
+}
+
+// SupplierItem is an item as a supplier sells it: under its own code, in a
+// pack of the item or its base unit, at a cost per that unit.
+type SupplierItem struct {
+	ID          uint   `gorm:"primaryKey"`
+	SupplierID  uint   `gorm:"not null;index"`
+	InventoryID uint   `gorm:"not null;index"`
+	SupplierSKU string `gorm:"not null;default:''"`
+	// UnitID is the pack the supplier sells; nil is the base unit
+	UnitID *uint
+	Cost   float64 `gorm:"not null"`
+	// LeadTimeDays overrides the item's lead time for this supplier
+	LeadTimeDays *int
+	// Preferred marks the supplier to order an item from
+	Preferred bool      `gorm:"not null"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
@@ -0,0 +1,43 @@
+package model
+
+import "time"
+
+// Transfer statuses. A transfer is sent, which puts its goods in transit,
+// and received at its destination in one go.
+const (
+	TransferInTransit = "in_transit"
+	TransferReceived  = "received"
+)
+
+// Transfer moves stock from one location to another.
+type Transfer struct {
+	ID uint `gorm:"primaryKey"`
+	// Number is what the paperwork shows, e.g. "TR-000042"
+	Number     string `gorm:"not null;index"`
+	FromID     uint   `gorm:"not null;index"`
+	ToID       uint   `gorm:"not null;index"`
+	Status     string `gorm:"not null;index"`
+	Notes      string `gorm:"not null;default:''"`
+	SentBy     uint   `gorm:"not null"`
+	SentAt     time.Time
+	ReceivedBy *uint
+	ReceivedAt *time.Time
+	CreatedAt  time.Time `gorm:"autoCreateTime"`
+	UpdatedAt  time.Time `gorm:"autoUpdateTime"`
+}
+
+// TransferLine is one item of a transfer, in the item's base unit.
+type TransferLine struct {
+	ID          uint    `gorm:"primaryKey"`
+	TransferID  uint    `gorm:"not null;index"`
+	InventoryID uint    `gorm:"not null;index"`
+	Sent        float64 `gorm:"not null"`
+	// Received is what arrived; anything else is a discrepancy, booked
+	// when the transfer is received
+	Received float64 `gorm:"not null;default:0"`
+	// Serials lists the serial numbers of serialized goods sent, one per
+	// line
+	Serials string `gorm:"not null;default:''"`
+	// TransitMovementID is the ledger entry that put the goods in transit
+	TransitMovementID uint `gorm:"not null"`
+}
@@ -0,0 +1,52 @@
+package model
+
+import (
+	"math"
+	"time"
+)
+
+// MaxPrecision is the most decimal places a unit can keep quantities to.
+const MaxPrecision = 6
+
+// UnitPiece is the base unit of items that are counted.
+const UnitPiece = "pc"
+
+// Unit is a base unit items are stocked in, such as pieces or kilograms.
+type Unit struct {
+	Code string `gorm:"primaryKey"`
+	Name string `gorm:"not null"`
+	// Precision is the number of decimal places quantities are kept to
+	Precision int       `gorm:"not null"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+// Round rounds a quantity to the unit's precision.
+func (u *Unit) Round(quantity float64) float64 {
+	return RoundQuantity(quantity, u.Precision)
+}
+
+// Fits reports whether a quantity needs no more decimals than the unit
+// keeps.
+func (u *Unit) Fits(quantity float64) bool {
+	return math.Abs(u.Round(quantity)-quantity) < 1e-9
+}
+
+// RoundQuantity rounds a quantity to the given number of decimal places.
+func RoundQuantity(quantity float64, precision int) float64 {
+	scale := math.Pow10(precision)
+	return math.Round(quantity*scale) / scale
+}
+
+// ItemUnit is a pack an item is bought or sold in, such as a case of 24.
+type ItemUnit struct {
+	ID          uint   `gorm:"primaryKey"`
+	InventoryID uint   `gorm:"not null;index"`
+	Name        string `gorm:"not null"`
+	// Factor is the number of base units in one pack
+	Factor float64 `gorm:"not null"`
+	// DefaultPurchase and DefaultSale preselect the pack when receiving and
+	// selling the item
+	DefaultPurchase bool      `gorm:"not null"`
+	DefaultSale     bool      `gorm:"not null"`
+	CreatedAt       time.Time `gorm:"autoCreateTime"`
+}
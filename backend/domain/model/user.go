@@ -4,11 +4,24 @@ import (
 	"gorm.io/gorm"
 )
 
+// User roles, from most to least privileged
+const (
+	RoleOwner   = "owner"
+	RoleManager = "manager"
+	RoleStaff   = "staff"
+)
+
 // User represents a user profile in the system.
 type User struct {
 	ID           uint   `gorm:"primaryKey"`
 	Username     string `gorm:"unique;not null"`
 	PasswordHash string `gorm:"not null"`
+	Role         string `gorm:"not null;default:'staff'"`
+}
+
+// IsManager reports whether the user may approve what staff request.
+func (u *User) IsManager() bool {
+	return u.Role == RoleOwner || u.Role == RoleManager
 }
 
 // CreateUser creates a new user in the database.
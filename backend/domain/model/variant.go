@@ -0,0 +1,25 @@
+package model
+
+// VariantAttribute is a dimension along which the variants of an item
+// differ, such as size or colour.
+type VariantAttribute struct {
+	ID uint `gorm:"primaryKey"`
+	// InventoryID is the parent item
+	InventoryID uint   `gorm:"not null;index"`
+	Name        string `gorm:"not null"`
+	Position    int    `gorm:"not null"`
+}
+
+// VariantOption is one value of an attribute, such as "XL".
+type VariantOption struct {
+	ID          uint   `gorm:"primaryKey"`
+	AttributeID uint   `gorm:"not null;index"`
+	Value       string `gorm:"not null"`
+	Position    int    `gorm:"not null"`
+}
+
+// VariantValue gives a variant its option for one attribute.
+type VariantValue struct {
+	InventoryID uint `gorm:"primaryKey"`
+	OptionID    uint `gorm:"primaryKey;index"`
+}
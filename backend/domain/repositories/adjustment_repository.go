@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"blizzflow/backend/domain/model"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ShrinkageRow is the total of approved adjustments for one reason in one
+// period.
+type ShrinkageRow struct {
+	Period     string
+	ReasonCode string
+	Quantity   float64
+	// Cost is the signed value of the stock change
+	Cost float64
+}
+
+type AdjustmentRepository struct {
+	db *gorm.DB
+}
+
+func NewAdjustmentRepository(db *gorm.DB) *AdjustmentRepository {
+	return &AdjustmentRepository{db: db}
+}
+
+func (r *AdjustmentRepository) Create(adjustment *model.StockAdjustment) error {
+	return r.db.Create(adjustment).Error
+}
+
+func (r *AdjustmentRepository) Update(adjustment *model.StockAdjustment) error {
+	return r.db.Save(adjustment).Error
+}
+
+func (r *AdjustmentRepository) GetByID(id uint) (*model.StockAdjustment, error) {
+	var adjustment model.StockAdjustment
+	err := r.db.First(&adjustment, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &adjustment, nil
+}
+
+// List returns the latest adjustments, newest first. An empty status lists
+// all of them.
+func (r *AdjustmentRepository) List(status string, limit int) ([]model.StockAdjustment, error) {
+	query := r.db.Order("id DESC").Limit(limit)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var adjustments []model.StockAdjustment
+	err := query.Find(&adjustments).Error
+	return adjustments, err
+}
+
+// Shrinkage totals the approved adjustments decided in [from, to) by
+// reason and by period, where periodFormat is a strftime format applied to
+// the decision time.
+func (r *AdjustmentRepository) Shrinkage(from, to time.Time, periodFormat string) ([]ShrinkageRow, error) {
+	var rows []ShrinkageRow
+	err := r.db.Model(&model.StockAdjustment{}).
+		Select("strftime(?, decided_at) AS period, reason_code, SUM(quantity) AS quantity, SUM(quantity * unit_cost) AS cost", periodFormat).
+		Where("status = ? AND decided_at >= ? AND decided_at < ?", model.AdjustmentApproved, from, to).
+		Group("period, reason_code").
+		Order("period, reason_code").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// GetReason returns a reason code, or nil.
+func (r *AdjustmentRepository) GetReason(code string) (*model.AdjustmentReason, error) {
+	var reasons []model.AdjustmentReason
+	if err := r.db.Where("code = ?", code).Limit(1).Find(&reasons).Error; err != nil {
+		return nil, err
+	}
+	if len(reasons) == 0 {
+		return nil, nil
+	}
+	return &reasons[0], nil
+}
+
+func (r *AdjustmentRepository) ListReasons(includeInactive bool) ([]model.AdjustmentReason, error) {
+	query := r.db.Order("label")
+	if !includeInactive {
+		query = query.Where("active = ?", true)
+	}
+	var reasons []model.AdjustmentReason
+	err := query.Find(&reasons).Error
+	return reasons, err
+}
+
+// SaveReason creates or replaces a reason code.
+func (r *AdjustmentRepository) SaveReason(reason *model.AdjustmentReason) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "code"}},
+		DoUpdates: clause.AssignmentColumns([]string{"label", "direction", "active", "updated_at"}),
+	}).Create(reason).Error
+}
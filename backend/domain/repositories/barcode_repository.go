@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"blizzflow/backend/domain/model"
+
+	"gorm.io/gorm"
+)
+
+type BarcodeRepository struct {
+	db *gorm.DB
+}
+
+func NewBarcodeRepository(db *gorm.DB) *BarcodeRepository {
+	return &BarcodeRepository{db: db}
+}
+
+func (r *BarcodeRepository) Create(barcode *model.Barcode) error {
+	return r.db.Create(barcode).Error
+}
+
+func (r *BarcodeRepository) Delete(id uint) error {
+	return r.db.Delete(&model.Barcode{}, id).Error
+}
+
+func (r *BarcodeRepository) DeleteByInventory(inventoryID uint) error {
+	return r.db.Where("inventory_id = ?", inventoryID).Delete(&model.Barcode{}).Error
+}
+
+// DeleteByUnit removes the barcodes of a pack.
+func (r *BarcodeRepository) DeleteByUnit(unitID uint) error {
+	return r.db.Where("unit_id = ?", unitID).Delete(&model.Barcode{}).Error
+}
+
+func (r *BarcodeRepository) GetByID(id uint) (*model.Barcode, error) {
+	var barcode model.Barcode
+	err := r.db.First(&barcode, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &barcode, nil
+}
+
+// GetByCode returns the barcode with the given normalized code, or nil.
+func (r *BarcodeRepository) GetByCode(code string) (*model.Barcode, error) {
+	var barcodes []model.Barcode
+	if err := r.db.Where("code = ?", code).Limit(1).Find(&barcodes).Error; err != nil {
+		return nil, err
+	}
+	if len(barcodes) == 0 {
+		return nil, nil
+	}
+	return &barcodes[0], nil
+}
+
+// ListByInventory returns the barcodes of an item, oldest first.
+func (r *BarcodeRepository) ListByInventory(inventoryID uint) ([]model.Barcode, error) {
+	var barcodes []model.Barcode
+	err := r.db.Where("inventory_id = ?", inventoryID).Order("id").Find(&barcodes).Error
+	return barcodes, err
+}
@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"blizzflow/backend/domain/model"
+
+	"gorm.io/gorm"
+)
+
+type BrandRepository struct {
+	db *gorm.DB
+}
+
+func NewBrandRepository(db *gorm.DB) *BrandRepository {
+	return &BrandRepository{db: db}
+}
+
+func (r *BrandRepository) Create(brand *model.Brand) error {
+	return r.db.Create(brand).Error
+}
+
+func (r *BrandRepository) Update(brand *model.Brand) error {
+	return r.db.Save(brand).Error
+}
+
+func (r *BrandRepository) Delete(id uint) error {
+	return r.db.Delete(&model.Brand{}, id).Error
+}
+
+func (r *BrandRepository) GetByID(id uint) (*model.Brand, error) {
+	var brand model.Brand
+	if err := r.db.First(&brand, id).Error; err != nil {
+		return nil, err
+	}
+	return &brand, nil
+}
+
+// GetByName returns the brand with the given name, ignoring case, or nil.
+func (r *BrandRepository) GetByName(name string) (*model.Brand, error) {
+	var brands []model.Brand
+	if err := r.db.Where("name = ? COLLATE NOCASE", name).Limit(1).Find(&brands).Error; err != nil {
+		return nil, err
+	}
+	if len(brands) == 0 {
+		return nil, nil
+	}
+	return &brands[0], nil
+}
+
+func (r *BrandRepository) List() ([]model.Brand, error) {
+	var brands []model.Brand
+	err := r.db.Order("name COLLATE NOCASE").Find(&brands).Error
+	return brands, err
+}
@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"blizzflow/backend/domain/model"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+type CategoryRepository struct {
+	db *gorm.DB
+}
+
+func NewCategoryRepository(db *gorm.DB) *CategoryRepository {
+	return &CategoryRepository{db: db}
+}
+
+func (r *CategoryRepository) Create(category *model.Category) error {
+	return r.db.Create(category).Error
+}
+
+func (r *CategoryRepository) Update(category *model.Category) error {
+	return r.db.Save(category).Error
+}
+
+func (r *CategoryRepository) Delete(id uint) error {
+	return r.db.Delete(&model.Category{}, id).Error
+}
+
+func (r *CategoryRepository) GetByID(id uint) (*model.Category, error) {
+	var category model.Category
+	if err := r.db.First(&category, id).Error; err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+// GetByName returns the child of parentID with the given name, ignoring
+// case, or nil. A nil parentID looks among the top-level categories.
+func (r *CategoryRepository) GetByName(parentID *uint, name string) (*model.Category, error) {
+	query := r.db.Where("name = ? COLLATE NOCASE", name)
+	if parentID == nil {
+		query = query.Where("parent_id IS NULL")
+	} else {
+		query = query.Where("parent_id = ?", *parentID)
+	}
+	var categories []model.Category
+	if err := query.Limit(1).Find(&categories).Error; err != nil {
+		return nil, err
+	}
+	if len(categories) == 0 {
+		return nil, nil
+	}
+	return &categories[0], nil
+}
+
+// List returns every category, parents before their children.
+func (r *CategoryRepository) List() ([]model.Category, error) {
+	var categories []model.Category
+	err := r.db.Order("depth").Order("name COLLATE NOCASE").Find(&categories).Error
+	return categories, err
+}
+
+// Ancestors returns the categories on path, from the root down, including
+// the last one.
+func (r *CategoryRepository) Ancestors(path string) ([]model.Category, error) {
+	var ids []uint
+	for _, part := range strings.Split(strings.Trim(path, "/"), "/") {
+		id, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, uint(id))
+	}
+	var categories []model.Category
+	if len(ids) == 0 {
+		return categories, nil
+	}
+	err := r.db.Where("id IN ?", ids).Order("depth").Find(&categories).Error
+	return categories, err
+}
+
+// CountChildren counts the direct children of a category.
+func (r *CategoryRepository) CountChildren(id uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&model.Category{}).Where("parent_id = ?", id).Count(&count).Error
+	return count, err
+}
+
+// MoveSubtree rewrites the paths below oldPath, the category itself
+// included, to start with newPath instead.
+func (r *CategoryRepository) MoveSubtree(oldPath, newPath string, depthDelta int) error {
+	return r.db.Model(&model.Category{}).
+		Where("substr(path, 1, ?) = ?", len(oldPath), oldPath).
+		Updates(map[string]interface{}{
+			"path":  gorm.Expr("? || substr(path, ?)", newPath, len(oldPath)+1),
+			"depth": gorm.Expr("depth + ?", depthDelta),
+		}).Error
+}
+
+// RebuildPaths derives every path and depth from the parent IDs, e.g. after
+// an import gave the categories new IDs.
+func (r *CategoryRepository) RebuildPaths() error {
+	return r.db.Exec(`WITH RECURSIVE tree(id, path, depth) AS (
+		SELECT id, '/' || id || '/', 0 FROM categories WHERE parent_id IS NULL
+		UNION ALL
+		SELECT categories.id, tree.path || categories.id || '/', tree.depth + 1
+		FROM categories JOIN tree ON categories.parent_id = tree.id
+	)
+	UPDATE categories SET
+		path = (SELECT path FROM tree WHERE tree.id = categories.id),
+		depth = (SELECT depth FROM tree WHERE tree.id = categories.id)
+	WHERE id IN (SELECT id FROM tree)`).Error
+}
+
+// ItemCounts counts the items filed directly under each category.
+func (r *CategoryRepository) ItemCounts() (map[uint]int64, error) {
+	var rows []struct {
+		CategoryID uint
+		Count      int64
+	}
+	err := r.db.Model(&model.Inventory{}).
+		Select("category_id, count(*) AS count").
+		Where("category_id IS NOT NULL").
+		Group("category_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[uint]int64, len(rows))
+	for _, row := range rows {
+		counts[row.CategoryID] = row.Count
+	}
+	return counts, nil
+}
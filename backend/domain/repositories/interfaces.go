@@ -0,0 +1,265 @@
+package repository
+
+import (
+	"blizzflow/backend/domain/model"
+	"time"
+)
+
+// Services depend on these interfaces rather than the gorm-backed structs, so
+// they can be handed transaction-scoped repositories by a UnitOfWork.
+
+type UserRepo interface {
+	CreateUser(user *model.User) error
+	GetUserByID(id uint) (*model.User, error)
+	GetUserByUsername(username string) (*model.User, error)
+	UpdateUser(user *model.User) error
+	DeleteUser(id uint) error
+	CountByRole(role string) (int64, error)
+}
+
+type SessionRepo interface {
+	CreateSession(session *model.Session) error
+	GetSession(id uint) (*model.Session, error)
+	GetSessionByID(sessionID uint) (*model.Session, error)
+	GetSessionByUserID(userID uint) (*model.Session, error)
+	DeleteSession(sessionID uint) error
+	DeleteAllSessions() error
+	CleanupExpiredSessions(maxAge time.Duration) (int64, error)
+}
+
+type SecurityQuestionRepo interface {
+	CreateSecurityQuestion(question *model.SecurityQuestion) error
+	GetSecurityQuestion(id uint) (*model.SecurityQuestion, error)
+	GetSecurityQuestionsByUserID(userID uint) ([]model.SecurityQuestion, error)
+	UpdateSecurityQuestion(question *model.SecurityQuestion) error
+	DeleteSecurityQuestion(id uint) error
+	DeleteUserSecurityQuestions(userID uint) error
+	ValidateSecurityQuestion(userID uint, question string, answer string) (bool, error)
+}
+
+type LicenseRepo interface {
+	Create(license *model.License) error
+	GetByKey(key string) (*model.License, error)
+}
+
+type InventoryRepo interface {
+	Create(inventory *model.Inventory) error
+	Update(inventory *model.Inventory) error
+	Delete(id uint) error
+	GetByID(id uint) (*model.Inventory, error)
+	GetBySKU(sku string) (*model.Inventory, error)
+	List(filter InventoryFilter) ([]model.Inventory, int64, error)
+	ListVariants(parentID uint) ([]model.Inventory, error)
+	CountVariants(parentID uint) (int64, error)
+	VariantSummaries(parentIDs []uint) (map[uint]VariantSummary, error)
+	RepriceVariants(parentID uint, oldPrice, newPrice float64) error
+	ArchiveVariants(parentID uint, archivedAt *time.Time) error
+	AssignCategory(ids []uint, categoryID *uint) error
+	AssignBrand(ids []uint, brandID *uint) error
+	Recategorize(from uint, to *uint) error
+	ClearBrand(brandID uint) error
+	SetQuantity(id uint, quantity float64) error
+	SetCosts(id uint, lastCost, averageCost float64) error
+	All() ([]model.Inventory, error)
+}
+
+type VariantRepo interface {
+	CreateAttribute(attribute *model.VariantAttribute) error
+	CreateOption(option *model.VariantOption) error
+	ListAttributes(parentID uint) ([]model.VariantAttribute, error)
+	ListOptions(attributeIDs []uint) ([]model.VariantOption, error)
+	SetValues(variantID uint, optionIDs []uint) error
+	ListValues(variantIDs []uint) ([]model.VariantValue, error)
+	DeleteValues(variantID uint) error
+	DeleteByParent(parentID uint) error
+}
+
+type UnitRepo interface {
+	Get(code string) (*model.Unit, error)
+	List() ([]model.Unit, error)
+	Save(unit *model.Unit) error
+	Delete(code string) error
+	CountItems(code string) (int64, error)
+	CreatePack(pack *model.ItemUnit) error
+	UpdatePack(pack *model.ItemUnit) error
+	DeletePack(id uint) error
+	GetPack(id uint) (*model.ItemUnit, error)
+	ListPacks(inventoryID uint) ([]model.ItemUnit, error)
+	ClearDefaults(inventoryID, exceptID uint, purchase, sale bool) error
+	DeletePacksByInventory(inventoryID uint) error
+}
+
+type CategoryRepo interface {
+	Create(category *model.Category) error
+	Update(category *model.Category) error
+	Delete(id uint) error
+	GetByID(id uint) (*model.Category, error)
+	GetByName(parentID *uint, name string) (*model.Category, error)
+	List() ([]model.Category, error)
+	Ancestors(path string) ([]model.Category, error)
+	CountChildren(id uint) (int64, error)
+	MoveSubtree(oldPath, newPath string, depthDelta int) error
+	RebuildPaths() error
+	ItemCounts() (map[uint]int64, error)
+}
+
+type BrandRepo interface {
+	Create(brand *model.Brand) error
+	Update(brand *model.Brand) error
+	Delete(id uint) error
+	GetByID(id uint) (*model.Brand, error)
+	GetByName(name string) (*model.Brand, error)
+	List() ([]model.Brand, error)
+}
+
+type TagRepo interface {
+	Create(tag *model.Tag) error
+	Delete(id uint) error
+	GetByID(id uint) (*model.Tag, error)
+	GetByName(name string) (*model.Tag, error)
+	List() ([]model.Tag, error)
+	ListByInventory(inventoryID uint) ([]model.Tag, error)
+	Tag(inventoryIDs []uint, tagID uint) error
+	Untag(inventoryIDs []uint, tagID uint) error
+	DeleteByInventory(inventoryID uint) error
+}
+
+type BarcodeRepo interface {
+	Create(barcode *model.Barcode) error
+	Delete(id uint) error
+	DeleteByInventory(inventoryID uint) error
+	DeleteByUnit(unitID uint) error
+	GetByID(id uint) (*model.Barcode, error)
+	GetByCode(code string) (*model.Barcode, error)
+	ListByInventory(inventoryID uint) ([]model.Barcode, error)
+}
+
+type StockMovementRepo interface {
+	Create(movement *model.StockMovement) error
+	ListByInventory(inventoryID uint, offset, limit int) ([]model.StockMovement, int64, error)
+	ListByReference(reference string) ([]model.StockMovement, error)
+	Sum(inventoryID uint) (float64, error)
+	SumAt(inventoryID, locationID uint) (float64, error)
+	OnHand() ([]OnHand, error)
+	Valuation(asOf time.Time, locationID *uint) ([]Valuation, error)
+	CreateLayer(layer *model.CostLayer) error
+	OpenLayers(inventoryID uint) ([]model.CostLayer, error)
+	ConsumeLayer(id uint, remaining float64) error
+	CountByInventory(inventoryID uint) (int64, error)
+}
+
+type AdjustmentRepo interface {
+	Create(adjustment *model.StockAdjustment) error
+	Update(adjustment *model.StockAdjustment) error
+	GetByID(id uint) (*model.StockAdjustment, error)
+	List(status string, limit int) ([]model.StockAdjustment, error)
+	Shrinkage(from, to time.Time, periodFormat string) ([]ShrinkageRow, error)
+	GetReason(code string) (*model.AdjustmentReason, error)
+	ListReasons(includeInactive bool) ([]model.AdjustmentReason, error)
+	SaveReason(reason *model.AdjustmentReason) error
+}
+
+type SaleRepo interface {
+	Create(sale *model.Sale) error
+	SetCost(id uint, cost float64) error
+	GetByID(id uint) (*model.Sale, error)
+	CountByInventory(inventoryID uint) (int64, error)
+	Totals(from, to time.Time, byParent bool, locationID *uint) ([]SaleTotal, error)
+	Sold(from time.Time) (map[uint]float64, error)
+}
+
+type SupplierRepo interface {
+	Create(supplier *model.Supplier) error
+	Update(supplier *model.Supplier) error
+	Delete(id uint) error
+	GetByID(id uint) (*model.Supplier, error)
+	GetByName(name string) (*model.Supplier, error)
+	List(includeArchived bool) ([]model.Supplier, error)
+	SaveItem(item *model.SupplierItem) error
+	DeleteItem(id uint) error
+	GetItem(id uint) (*model.SupplierItem, error)
+	FindItem(supplierID, inventoryID uint) (*model.SupplierItem, error)
+	ListItems(supplierID uint) ([]model.SupplierItem, error)
+	ListByInventory(inventoryID uint) ([]model.SupplierItem, error)
+	ClearPreferred(inventoryID, exceptID uint) error
+	DeleteItemsByInventory(inventoryID uint) error
+}
+
+type PurchaseOrderRepo interface {
+	Create(order *model.PurchaseOrder) error
+	Update(order *model.PurchaseOrder) error
+	Delete(id uint) error
+	GetByID(id uint) (*model.PurchaseOrder, error)
+	List(filter PurchaseOrderFilter) ([]model.PurchaseOrder, error)
+	SetLines(orderID uint, lines []model.PurchaseOrderLine) error
+	ListLines(orderIDs []uint) ([]model.PurchaseOrderLine, error)
+	UpdateLine(line *model.PurchaseOrderLine) error
+	CountBySupplier(supplierID uint) (int64, error)
+	CountByInventory(inventoryID uint) (int64, error)
+	OnOrder() (map[uint]float64, error)
+	ListSent(from, to time.Time) ([]model.PurchaseOrder, error)
+	CreateReceipt(receipt *model.GoodsReceipt) error
+	CreateReceiptLine(line *model.GoodsReceiptLine) error
+	ListReceipts(orderIDs []uint) ([]model.GoodsReceipt, error)
+	ListReceiptLines(receiptIDs []uint) ([]model.GoodsReceiptLine, error)
+}
+
+type LotRepo interface {
+	Create(lot *model.Lot) error
+	GetByID(id uint) (*model.Lot, error)
+	FindByNumber(inventoryID, locationID uint, number string) (*model.Lot, error)
+	ListByInventory(inventoryID uint, locationID *uint, includeEmpty bool) ([]model.Lot, error)
+	ListExpiring(before time.Time) ([]model.Lot, error)
+	SetQuantity(id uint, quantity float64) error
+	CreateAllocation(allocation *model.LotAllocation) error
+	ListAllocations(movementIDs []uint) ([]model.LotAllocation, error)
+	DeleteByInventory(inventoryID uint) error
+}
+
+type LocationRepo interface {
+	Create(location *model.Location) error
+	Update(location *model.Location) error
+	GetByID(id uint) (*model.Location, error)
+	GetByName(name string) (*model.Location, error)
+	List(includeArchived bool) ([]model.Location, error)
+	First() (*model.Location, error)
+	Transit() (*model.Location, error)
+	Quantities(inventoryIDs []uint) ([]LocationQuantity, error)
+	HoldsStock(locationID uint) (bool, error)
+}
+
+type TransferRepo interface {
+	Create(transfer *model.Transfer) error
+	Update(transfer *model.Transfer) error
+	GetByID(id uint) (*model.Transfer, error)
+	List(status string) ([]model.Transfer, error)
+	CreateLine(line *model.TransferLine) error
+	UpdateLine(line *model.TransferLine) error
+	ListLines(transferIDs []uint) ([]model.TransferLine, error)
+}
+
+type SerialRepo interface {
+	Create(serial *model.SerialNumber) error
+	Update(serial *model.SerialNumber) error
+	Find(inventoryID uint, serial string) (*model.SerialNumber, error)
+	FindAll(serial string) ([]model.SerialNumber, error)
+	ListByInventory(inventoryID uint, status string) ([]model.SerialNumber, error)
+	CreateEvent(event *model.SerialEvent) error
+	ListEvents(serialID uint) ([]model.SerialEvent, error)
+	DeleteByInventory(inventoryID uint) error
+}
+
+type SettingRepo interface {
+	Get(key string) (string, error)
+	Set(key, value string) error
+	All() ([]model.Setting, error)
+}
+
+type JobRepo interface {
+	GetState(name string) (*model.ScheduledJob, error)
+	SaveState(job *model.ScheduledJob) error
+	CreateRun(run *model.JobRun) error
+	UpdateRun(run *model.JobRun) error
+	ListRuns(name string, limit int) ([]model.JobRun, error)
+	PruneRuns(name string, keep int) error
+}
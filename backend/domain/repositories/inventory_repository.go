@@ -2,10 +2,67 @@ package repository
 
 import (
 	"blizzflow/backend/domain/model"
+	"fmt"
+	"strings"
+	"time"
 
 	"gorm.io/gorm"
 )
 
+// inventorySearchTable is the FTS5 index over name, SKU and barcode. It only
+// exists when the linked SQLite has FTS5, see migration 5.
+const inventorySearchTable = "inventory_search"
+
+// InventoryOnHand is the quantity of an item, or the total of its variants
+// for a parent, as a column expression.
+const InventoryOnHand = `ifnull((SELECT sum(variants.quantity) FROM inventories variants
+	WHERE variants.parent_id = inventories.id), inventories.quantity)`
+
+// InventoryOnHandAt is InventoryOnHand at one location, from the stock
+// ledger.
+func InventoryOnHandAt(locationID uint) string {
+	return fmt.Sprintf(`round(ifnull((SELECT sum(stock_movements.quantity) FROM stock_movements
+	JOIN inventories held ON held.id = stock_movements.inventory_id
+	WHERE stock_movements.location_id = %d AND ifnull(held.parent_id, held.id) = inventories.id), 0), 6)`, locationID)
+}
+
+// VariantSummary aggregates the variants of a parent item.
+type VariantSummary struct {
+	ParentID uint    `json:"-"`
+	Count    int     `json:"count"`
+	Quantity float64 `json:"quantity"`
+	MinPrice float64 `json:"minPrice"`
+	MaxPrice float64 `json:"maxPrice"`
+}
+
+// InventoryFilter narrows and orders an inventory listing. Nil bounds are
+// not applied. Listings hold the top-level items, i.e. parents but not their
+// variants, unless ParentID or Sellable is set.
+type InventoryFilter struct {
+	// ParentID lists the variants of one parent
+	ParentID *uint
+	// Sellable lists the items that can be sold: variants but not parents
+	Sellable bool
+	Search   string
+	// CategoryPath keeps the items anywhere below a category
+	CategoryPath string
+	BrandID      *uint
+	TagID        *uint
+	// LocationID applies the quantity bounds to the stock at one location
+	LocationID *uint
+	// AboveQuantity excludes items with this quantity or less
+	AboveQuantity   *float64
+	MaxQuantity     *float64
+	MinPrice        *float64
+	MaxPrice        *float64
+	IncludeArchived bool
+	// SortColumn must be a trusted column expression
+	SortColumn string
+	SortDesc   bool
+	Offset     int
+	Limit      int
+}
+
 type InventoryRepository struct {
 	db *gorm.DB
 }
@@ -22,6 +79,10 @@ func (r *InventoryRepository) Update(inventory *model.Inventory) error {
 	return r.db.Save(inventory).Error
 }
 
+func (r *InventoryRepository) Delete(id uint) error {
+	return r.db.Delete(&model.Inventory{}, id).Error
+}
+
 func (r *InventoryRepository) GetByID(id uint) (*model.Inventory, error) {
 	var inventory model.Inventory
 	err := r.db.First(&inventory, id).Error
@@ -30,3 +91,212 @@ func (r *InventoryRepository) GetByID(id uint) (*model.Inventory, error) {
 	}
 	return &inventory, nil
 }
+
+// GetBySKU returns the item with the given SKU, ignoring case, or nil.
+func (r *InventoryRepository) GetBySKU(sku string) (*model.Inventory, error) {
+	var items []model.Inventory
+	if err := r.db.Where("sku = ? COLLATE NOCASE", sku).Limit(1).Find(&items).Error; err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, nil
+	}
+	return &items[0], nil
+}
+
+// List returns one page of items matching filter and the number of matches
+// across all pages.
+func (r *InventoryRepository) List(filter InventoryFilter) ([]model.Inventory, int64, error) {
+	query := r.db.Model(&model.Inventory{})
+	if !filter.IncludeArchived {
+		query = query.Where("archived_at IS NULL")
+	}
+	if filter.CategoryPath != "" {
+		subtree := r.db.Model(&model.Category{}).Select("id").
+			Where("substr(path, 1, ?) = ?", len(filter.CategoryPath), filter.CategoryPath)
+		query = query.Where("category_id IN (?)", subtree)
+	}
+	if filter.BrandID != nil {
+		query = query.Where("brand_id = ?", *filter.BrandID)
+	}
+	if filter.TagID != nil {
+		tagged := r.db.Model(&model.InventoryTag{}).Select("inventory_id").Where("tag_id = ?", *filter.TagID)
+		query = query.Where("id IN (?)", tagged)
+	}
+	switch {
+	case filter.ParentID != nil:
+		query = query.Where("parent_id = ?", *filter.ParentID)
+	case filter.Sellable:
+		query = query.Where("NOT EXISTS (SELECT 1 FROM inventories variants WHERE variants.parent_id = inventories.id)")
+	default:
+		query = query.Where("parent_id IS NULL")
+	}
+	onHand := InventoryOnHand
+	if filter.LocationID != nil {
+		onHand = InventoryOnHandAt(*filter.LocationID)
+	}
+	if filter.AboveQuantity != nil {
+		query = query.Where(onHand+" > ?", *filter.AboveQuantity)
+	}
+	if filter.MaxQuantity != nil {
+		query = query.Where(onHand+" <= ?", *filter.MaxQuantity)
+	}
+	if filter.MinPrice != nil {
+		query = query.Where("price >= ?", *filter.MinPrice)
+	}
+	if filter.MaxPrice != nil {
+		query = query.Where("price <= ?", *filter.MaxPrice)
+	}
+	if words := strings.Fields(filter.Search); len(words) > 0 {
+		// A parent is found by what its variants match, too
+		matches := r.search(r.db.Model(&model.Inventory{}).Select("id"), words)
+		parents := r.db.Model(&model.Inventory{}).Select("parent_id").Where("id IN (?)", matches)
+		query = query.Where("(id IN (?) OR id IN (?))", matches, parents)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	order := filter.SortColumn
+	if order == "" {
+		order = "name COLLATE NOCASE"
+	}
+	if filter.SortDesc {
+		order += " DESC"
+	}
+	// Ties keep a stable order across pages
+	query = query.Order(order).Order("id")
+	if filter.Limit > 0 {
+		query = query.Offset(filter.Offset).Limit(filter.Limit)
+	}
+
+	var items []model.Inventory
+	if err := query.Find(&items).Error; err != nil {
+		return nil, 0, err
+	}
+	return items, total, nil
+}
+
+// search matches items containing every word as a prefix of a name, SKU or
+// barcode token, or as a substring where there is no FTS5 index.
+func (r *InventoryRepository) search(query *gorm.DB, words []string) *gorm.DB {
+	// A whole code also finds items by their other barcodes. UPC-A codes
+	// are stored widened to EAN-13.
+	var byBarcode *gorm.DB
+	if len(words) == 1 {
+		byBarcode = r.db.Model(&model.Barcode{}).Select("inventory_id").Where("code IN ?", []string{words[0], "0" + words[0]})
+	}
+
+	if r.db.Migrator().HasTable(inventorySearchTable) {
+		terms := make([]string, len(words))
+		for i, word := range words {
+			terms[i] = `"` + strings.ReplaceAll(word, `"`, `""`) + `"*`
+		}
+		match := r.db.Raw("SELECT rowid FROM "+inventorySearchTable+" WHERE "+inventorySearchTable+" MATCH ?", strings.Join(terms, " "))
+		if byBarcode != nil {
+			return query.Where("(id IN (?) OR id IN (?))", match, byBarcode)
+		}
+		return query.Where("id IN (?)", match)
+	}
+
+	escaper := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	for _, word := range words {
+		pattern := "%" + escaper.Replace(word) + "%"
+		condition := r.db.Where(`name LIKE ? ESCAPE '\' OR sku LIKE ? ESCAPE '\' OR barcode LIKE ? ESCAPE '\'`,
+			pattern, pattern, pattern)
+		if byBarcode != nil {
+			condition = condition.Or("id IN (?)", byBarcode)
+		}
+		query = query.Where(condition)
+	}
+	return query
+}
+
+// ListVariants returns the variants of a parent item in creation order.
+func (r *InventoryRepository) ListVariants(parentID uint) ([]model.Inventory, error) {
+	var variants []model.Inventory
+	err := r.db.Where("parent_id = ?", parentID).Order("id").Find(&variants).Error
+	return variants, err
+}
+
+// CountVariants counts the variants of an item.
+func (r *InventoryRepository) CountVariants(parentID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&model.Inventory{}).Where("parent_id = ?", parentID).Count(&count).Error
+	return count, err
+}
+
+// VariantSummaries aggregates the variants of the given items. Items without
+// variants are left out.
+func (r *InventoryRepository) VariantSummaries(parentIDs []uint) (map[uint]VariantSummary, error) {
+	var rows []VariantSummary
+	if len(parentIDs) > 0 {
+		err := r.db.Model(&model.Inventory{}).
+			Select("parent_id, count(*) AS count, sum(quantity) AS quantity, min(price) AS min_price, max(price) AS max_price").
+			Where("parent_id IN ?", parentIDs).
+			Group("parent_id").
+			Scan(&rows).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+	summaries := make(map[uint]VariantSummary, len(rows))
+	for _, row := range rows {
+		summaries[row.ParentID] = row
+	}
+	return summaries, nil
+}
+
+// RepriceVariants moves the variants of a parent that sell at its old price
+// to its new one. Variants with a price of their own keep it.
+func (r *InventoryRepository) RepriceVariants(parentID uint, oldPrice, newPrice float64) error {
+	return r.db.Model(&model.Inventory{}).
+		Where("parent_id = ? AND price = ?", parentID, oldPrice).
+		Update("price", newPrice).Error
+}
+
+// ArchiveVariants archives or, for nil, restores the variants of a parent.
+func (r *InventoryRepository) ArchiveVariants(parentID uint, archivedAt *time.Time) error {
+	return r.db.Model(&model.Inventory{}).Where("parent_id = ?", parentID).Update("archived_at", archivedAt).Error
+}
+
+// AssignCategory files items under a category, or under none for nil.
+func (r *InventoryRepository) AssignCategory(ids []uint, categoryID *uint) error {
+	return r.db.Model(&model.Inventory{}).Where("id IN ?", ids).Update("category_id", categoryID).Error
+}
+
+// AssignBrand sets the brand of items, or clears it for nil.
+func (r *InventoryRepository) AssignBrand(ids []uint, brandID *uint) error {
+	return r.db.Model(&model.Inventory{}).Where("id IN ?", ids).Update("brand_id", brandID).Error
+}
+
+// Recategorize moves every item of one category to another, or to none for
+// nil.
+func (r *InventoryRepository) Recategorize(from uint, to *uint) error {
+	return r.db.Model(&model.Inventory{}).Where("category_id = ?", from).Update("category_id", to).Error
+}
+
+// ClearBrand removes a brand from every item that has it.
+func (r *InventoryRepository) ClearBrand(brandID uint) error {
+	return r.db.Model(&model.Inventory{}).Where("brand_id = ?", brandID).Update("brand_id", nil).Error
+}
+
+// SetQuantity updates the cached on-hand quantity only.
+func (r *InventoryRepository) SetQuantity(id uint, quantity float64) error {
+	return r.db.Model(&model.Inventory{}).Where("id = ?", id).Update("quantity", quantity).Error
+}
+
+// SetCosts stores the costs stock movements keep up to date.
+func (r *InventoryRepository) SetCosts(id uint, lastCost, averageCost float64) error {
+	return r.db.Model(&model.Inventory{}).Where("id = ?", id).
+		Updates(map[string]any{"last_cost": lastCost, "average_cost": averageCost}).Error
+}
+
+// All returns every item, archived ones included.
+func (r *InventoryRepository) All() ([]model.Inventory, error) {
+	var items []model.Inventory
+	err := r.db.Order("id").Find(&items).Error
+	return items, err
+}
@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"blizzflow/backend/domain/model"
+
+	"gorm.io/gorm"
+)
+
+type JobRepository struct {
+	db *gorm.DB
+}
+
+func NewJobRepository(db *gorm.DB) *JobRepository {
+	return &JobRepository{db: db}
+}
+
+// GetState returns the stored state of a job, or nil if it never ran.
+func (r *JobRepository) GetState(name string) (*model.ScheduledJob, error) {
+	var jobs []model.ScheduledJob
+	if err := r.db.Where("name = ?", name).Limit(1).Find(&jobs).Error; err != nil {
+		return nil, err
+	}
+	if len(jobs) == 0 {
+		return nil, nil
+	}
+	return &jobs[0], nil
+}
+
+func (r *JobRepository) SaveState(job *model.ScheduledJob) error {
+	return r.db.Save(job).Error
+}
+
+func (r *JobRepository) CreateRun(run *model.JobRun) error {
+	return r.db.Create(run).Error
+}
+
+func (r *JobRepository) UpdateRun(run *model.JobRun) error {
+	return r.db.Save(run).Error
+}
+
+// ListRuns returns the latest runs of a job, newest first.
+func (r *JobRepository) ListRuns(name string, limit int) ([]model.JobRun, error) {
+	var runs []model.JobRun
+	err := r.db.Where("job = ?", name).Order("started_at DESC, id DESC").Limit(limit).Find(&runs).Error
+	return runs, err
+}
+
+// PruneRuns keeps only the latest keep runs of a job.
+func (r *JobRepository) PruneRuns(name string, keep int) error {
+	return r.db.Where("job = ? AND id NOT IN (?)", name,
+		r.db.Model(&model.JobRun{}).Select("id").Where("job = ?", name).Order("started_at DESC, id DESC").Limit(keep),
+	).Delete(&model.JobRun{}).Error
+}
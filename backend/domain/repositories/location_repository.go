@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"blizzflow/backend/domain/model"
+
+	"gorm.io/gorm"
+)
+
+// LocationQuantity is what one item, or the variants of a parent, hold at
+// one location.
+type LocationQuantity struct {
+	InventoryID uint    `json:"-"`
+	LocationID  uint    `json:"locationId"`
+	Name        string  `json:"name"`
+	Quantity    float64 `json:"quantity"`
+}
+
+type LocationRepository struct {
+	db *gorm.DB
+}
+
+func NewLocationRepository(db *gorm.DB) *LocationRepository {
+	return &LocationRepository{db: db}
+}
+
+func (r *LocationRepository) Create(location *model.Location) error {
+	return r.db.Create(location).Error
+}
+
+func (r *LocationRepository) Update(location *model.Location) error {
+	return r.db.Save(location).Error
+}
+
+func (r *LocationRepository) GetByID(id uint) (*model.Location, error) {
+	var location model.Location
+	if err := r.db.First(&location, id).Error; err != nil {
+		return nil, err
+	}
+	return &location, nil
+}
+
+// GetByName returns the location with the given name, ignoring case, or nil.
+func (r *LocationRepository) GetByName(name string) (*model.Location, error) {
+	var locations []model.Location
+	if err := r.db.Where("name = ? COLLATE NOCASE", name).Limit(1).Find(&locations).Error; err != nil {
+		return nil, err
+	}
+	if len(locations) == 0 {
+		return nil, nil
+	}
+	return &locations[0], nil
+}
+
+// List returns the locations stock is kept at by name, archived ones only
+// when asked for. The transit location is left out.
+func (r *LocationRepository) List(includeArchived bool) ([]model.Location, error) {
+	query := r.db.Where("transit = ?", false).Order("name COLLATE NOCASE")
+	if !includeArchived {
+		query = query.Where("archived_at IS NULL")
+	}
+	var locations []model.Location
+	err := query.Find(&locations).Error
+	return locations, err
+}
+
+// First returns the oldest active location that is not the transit one,
+// or nil.
+func (r *LocationRepository) First() (*model.Location, error) {
+	var locations []model.Location
+	err := r.db.Where("transit = ? AND archived_at IS NULL", false).Order("id").Limit(1).Find(&locations).Error
+	if err != nil || len(locations) == 0 {
+		return nil, err
+	}
+	return &locations[0], nil
+}
+
+func (r *LocationRepository) Transit() (*model.Location, error) {
+	var location model.Location
+	if err := r.db.Where("transit = ?", true).First(&location).Error; err != nil {
+		return nil, err
+	}
+	return &location, nil
+}
+
+// Quantities returns what the given items hold per location, parents
+// holding the total of their variants. Locations an item holds nothing at
+// are left out.
+func (r *LocationRepository) Quantities(inventoryIDs []uint) ([]LocationQuantity, error) {
+	var rows []LocationQuantity
+	if len(inventoryIDs) == 0 {
+		return rows, nil
+	}
+	err := r.db.Table("stock_movements").
+		Select("ifnull(inventories.parent_id, inventories.id) AS inventory_id, locations.id AS location_id, "+
+			"locations.name, sum(stock_movements.quantity) AS quantity").
+		Joins("JOIN inventories ON inventories.id = stock_movements.inventory_id").
+		Joins("JOIN locations ON locations.id = stock_movements.location_id").
+		Where("ifnull(inventories.parent_id, inventories.id) IN ?", inventoryIDs).
+		Group("ifnull(inventories.parent_id, inventories.id), locations.id").
+		Having("round(sum(stock_movements.quantity), 6) <> 0").
+		Order("locations.transit, locations.name COLLATE NOCASE").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// HoldsStock reports whether any item has stock at a location.
+func (r *LocationRepository) HoldsStock(locationID uint) (bool, error) {
+	var count int64
+	held := r.db.Table("stock_movements").Select("inventory_id").
+		Where("location_id = ?", locationID).
+		Group("inventory_id").
+		Having("round(sum(quantity), 6) <> 0")
+	err := r.db.Table("(?) AS held", held).Count(&count).Error
+	return count > 0, err
+}
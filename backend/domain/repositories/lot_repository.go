@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"blizzflow/backend/domain/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type LotRepository struct {
+	db *gorm.DB
+}
+
+func NewLotRepository(db *gorm.DB) *LotRepository {
+	return &LotRepository{db: db}
+}
+
+func (r *LotRepository) Create(lot *model.Lot) error {
+	return r.db.Create(lot).Error
+}
+
+func (r *LotRepository) GetByID(id uint) (*model.Lot, error) {
+	var lot model.Lot
+	if err := r.db.First(&lot, id).Error; err != nil {
+		return nil, err
+	}
+	return &lot, nil
+}
+
+// FindByNumber returns an item's lot at a location with the given number,
+// ignoring case, or nil.
+func (r *LotRepository) FindByNumber(inventoryID, locationID uint, number string) (*model.Lot, error) {
+	var lots []model.Lot
+	err := r.db.Where("inventory_id = ? AND location_id = ? AND number = ? COLLATE NOCASE", inventoryID, locationID, number).
+		Limit(1).Find(&lots).Error
+	if err != nil || len(lots) == 0 {
+		return nil, err
+	}
+	return &lots[0], nil
+}
+
+// ListByInventory returns an item's lots at one location or, for nil, all
+// of them, first to expire first. Empty lots are left out unless asked for.
+func (r *LotRepository) ListByInventory(inventoryID uint, locationID *uint, includeEmpty bool) ([]model.Lot, error) {
+	query := r.db.Where("inventory_id = ?", inventoryID)
+	if locationID != nil {
+		query = query.Where("location_id = ?", *locationID)
+	}
+	if !includeEmpty {
+		query = query.Where("quantity > 0")
+	}
+	var lots []model.Lot
+	err := query.Order("expires_at IS NULL, expires_at, id").Find(&lots).Error
+	return lots, err
+}
+
+// ListExpiring returns the lots with stock left that expire before the
+// given time, first to expire first.
+func (r *LotRepository) ListExpiring(before time.Time) ([]model.Lot, error) {
+	var lots []model.Lot
+	err := r.db.Where("quantity > 0 AND expires_at IS NOT NULL AND expires_at < ?", before).
+		Order("expires_at, id").Find(&lots).Error
+	return lots, err
+}
+
+func (r *LotRepository) SetQuantity(id uint, quantity float64) error {
+	return r.db.Model(&model.Lot{}).Where("id = ?", id).Update("quantity", quantity).Error
+}
+
+func (r *LotRepository) CreateAllocation(allocation *model.LotAllocation) error {
+	return r.db.Create(allocation).Error
+}
+
+// ListAllocations returns how the given movements were spread over lots.
+func (r *LotRepository) ListAllocations(movementIDs []uint) ([]model.LotAllocation, error) {
+	var allocations []model.LotAllocation
+	if len(movementIDs) == 0 {
+		return allocations, nil
+	}
+	err := r.db.Where("movement_id IN ?", movementIDs).Order("id").Find(&allocations).Error
+	return allocations, err
+}
+
+// DeleteByInventory removes an item's lots and their allocations.
+func (r *LotRepository) DeleteByInventory(inventoryID uint) error {
+	lots := r.db.Model(&model.Lot{}).Select("id").Where("inventory_id = ?", inventoryID)
+	if err := r.db.Where("lot_id IN (?)", lots).Delete(&model.LotAllocation{}).Error; err != nil {
+		return err
+	}
+	return r.db.Where("inventory_id = ?", inventoryID).Delete(&model.Lot{}).Error
+}
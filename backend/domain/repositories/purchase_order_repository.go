@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"blizzflow/backend/domain/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PurchaseOrderFilter selects orders; zero fields select all.
+type PurchaseOrderFilter struct {
+	SupplierID uint
+	Status     string
+	Limit      int
+}
+
+type PurchaseOrderRepository struct {
+	db *gorm.DB
+}
+
+func NewPurchaseOrderRepository(db *gorm.DB) *PurchaseOrderRepository {
+	return &PurchaseOrderRepository{db: db}
+}
+
+func (r *PurchaseOrderRepository) Create(order *model.PurchaseOrder) error {
+	return r.db.Create(order).Error
+}
+
+func (r *PurchaseOrderRepository) Update(order *model.PurchaseOrder) error {
+	return r.db.Save(order).Error
+}
+
+// Delete removes an order with its lines.
+func (r *PurchaseOrderRepository) Delete(id uint) error {
+	if err := r.db.Where("order_id = ?", id).Delete(&model.PurchaseOrderLine{}).Error; err != nil {
+		return err
+	}
+	return r.db.Delete(&model.PurchaseOrder{}, id).Error
+}
+
+func (r *PurchaseOrderRepository) GetByID(id uint) (*model.PurchaseOrder, error) {
+	var order model.PurchaseOrder
+	if err := r.db.First(&order, id).Error; err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// List returns the orders filter selects, newest first.
+func (r *PurchaseOrderRepository) List(filter PurchaseOrderFilter) ([]model.PurchaseOrder, error) {
+	query := r.db.Order("id DESC")
+	if filter.SupplierID != 0 {
+		query = query.Where("supplier_id = ?", filter.SupplierID)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+	var orders []model.PurchaseOrder
+	err := query.Find(&orders).Error
+	return orders, err
+}
+
+// SetLines replaces the lines of an order.
+func (r *PurchaseOrderRepository) SetLines(orderID uint, lines []model.PurchaseOrderLine) error {
+	if err := r.db.Where("order_id = ?", orderID).Delete(&model.PurchaseOrderLine{}).Error; err != nil {
+		return err
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	for i := range lines {
+		lines[i].OrderID = orderID
+	}
+	return r.db.Create(&lines).Error
+}
+
+// ListLines returns the lines of the given orders in order.
+func (r *PurchaseOrderRepository) ListLines(orderIDs []uint) ([]model.PurchaseOrderLine, error) {
+	var lines []model.PurchaseOrderLine
+	if len(orderIDs) == 0 {
+		return lines, nil
+	}
+	err := r.db.Where("order_id IN ?", orderIDs).Order("order_id, position, id").Find(&lines).Error
+	return lines, err
+}
+
+func (r *PurchaseOrderRepository) UpdateLine(line *model.PurchaseOrderLine) error {
+	return r.db.Save(line).Error
+}
+
+// CountBySupplier returns how many orders went to a supplier.
+func (r *PurchaseOrderRepository) CountBySupplier(supplierID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&model.PurchaseOrder{}).Where("supplier_id = ?", supplierID).Count(&count).Error
+	return count, err
+}
+
+// CountByInventory returns how many order lines name an item.
+func (r *PurchaseOrderRepository) CountByInventory(inventoryID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&model.PurchaseOrderLine{}).Where("inventory_id = ?", inventoryID).Count(&count).Error
+	return count, err
+}
+
+// OnOrder returns what open orders still expect per item, in base units.
+// Drafts count, so a drafted order is not suggested again; lines that
+// brought more than ordered count as nothing outstanding.
+func (r *PurchaseOrderRepository) OnOrder() (map[uint]float64, error) {
+	var rows []struct {
+		InventoryID uint
+		Quantity    float64
+	}
+	err := r.db.Table("purchase_order_lines AS l").
+		Select("l.inventory_id, sum(max(l.quantity - l.received, 0) * coalesce(u.factor, 1)) AS quantity").
+		Joins("JOIN purchase_orders AS o ON o.id = l.order_id").
+		Joins("LEFT JOIN item_units AS u ON u.id = l.unit_id").
+		Where("o.status IN ?", []string{model.PurchaseDraft, model.PurchaseSent, model.PurchasePartial}).
+		Group("l.inventory_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	onOrder := make(map[uint]float64, len(rows))
+	for _, row := range rows {
+		onOrder[row.InventoryID] = row.Quantity
+	}
+	return onOrder, nil
+}
+
+// ListSent returns the orders sent to suppliers in [from, to).
+func (r *PurchaseOrderRepository) ListSent(from, to time.Time) ([]model.PurchaseOrder, error) {
+	var orders []model.PurchaseOrder
+	err := r.db.Where("sent_at >= ? AND sent_at < ?", from, to).Order("id").Find(&orders).Error
+	return orders, err
+}
+
+func (r *PurchaseOrderRepository) CreateReceipt(receipt *model.GoodsReceipt) error {
+	return r.db.Create(receipt).Error
+}
+
+func (r *PurchaseOrderRepository) CreateReceiptLine(line *model.GoodsReceiptLine) error {
+	return r.db.Create(line).Error
+}
+
+// ListReceipts returns the deliveries against the given orders, oldest
+// first.
+func (r *PurchaseOrderRepository) ListReceipts(orderIDs []uint) ([]model.GoodsReceipt, error) {
+	var receipts []model.GoodsReceipt
+	if len(orderIDs) == 0 {
+		return receipts, nil
+	}
+	err := r.db.Where("order_id IN ?", orderIDs).Order("received_at, id").Find(&receipts).Error
+	return receipts, err
+}
+
+func (r *PurchaseOrderRepository) ListReceiptLines(receiptIDs []uint) ([]model.GoodsReceiptLine, error) {
+	var lines []model.GoodsReceiptLine
+	if len(receiptIDs) == 0 {
+		return lines, nil
+	}
+	err := r.db.Where("receipt_id IN ?", receiptIDs).Order("receipt_id, id").Find(&lines).Error
+	return lines, err
+}
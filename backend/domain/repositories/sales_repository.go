@@ -2,6 +2,7 @@ package repository
 
 import (
 	"blizzflow/backend/domain/model"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -18,6 +19,11 @@ func (r *SaleRepository) Create(sale *model.Sale) error {
 	return r.db.Create(sale).Error
 }
 
+// SetCost stores the cost of the goods sold once the stock was booked out.
+func (r *SaleRepository) SetCost(id uint, cost float64) error {
+	return r.db.Model(&model.Sale{}).Where("id = ?", id).Update("cost", cost).Error
+}
+
 func (r *SaleRepository) GetByID(id uint) (*model.Sale, error) {
 	var sale model.Sale
 	err := r.db.First(&sale, id).Error
@@ -26,3 +32,67 @@ func (r *SaleRepository) GetByID(id uint) (*model.Sale, error) {
 	}
 	return &sale, nil
 }
+
+// CountByInventory returns how many sales reference an inventory item.
+func (r *SaleRepository) CountByInventory(inventoryID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&model.Sale{}).Where("inventory_id = ?", inventoryID).Count(&count).Error
+	return count, err
+}
+
+// SaleTotal is what one item sold over a period.
+type SaleTotal struct {
+	InventoryID uint    `json:"inventoryId"`
+	Name        string  `json:"name"`
+	Quantity    float64 `json:"quantity"`
+	Revenue     float64 `json:"revenue"`
+	// Cost is the cost of the goods sold; Margin is what is left of revenue
+	Cost   float64 `json:"cost"`
+	Margin float64 `json:"margin"`
+}
+
+// Totals sums the sales in [from, to) per item, best sellers first. With
+// byParent, variants count towards their parent. A location only counts
+// the sales made there.
+func (r *SaleRepository) Totals(from, to time.Time, byParent bool, locationID *uint) ([]SaleTotal, error) {
+	item := "inventories.id"
+	if byParent {
+		item = "ifnull(inventories.parent_id, inventories.id)"
+	}
+	query := r.db.Table("sales")
+	if locationID != nil {
+		query = query.Where("sales.location_id = ?", *locationID)
+	}
+	var totals []SaleTotal
+	err := query.
+		Select("items.id AS inventory_id, items.name, sum(sales.quantity) AS quantity, sum(sales.total_price) AS revenue, "+
+			"sum(sales.cost) AS cost, sum(sales.total_price) - sum(sales.cost) AS margin").
+		Joins("JOIN inventories ON inventories.id = sales.inventory_id").
+		Joins("JOIN inventories items ON items.id = "+item).
+		Where("sales.created_at >= ? AND sales.created_at < ?", from, to).
+		Group("items.id").
+		Order("revenue DESC, items.name").
+		Scan(&totals).Error
+	return totals, err
+}
+
+// Sold returns the quantity sold per item since from.
+func (r *SaleRepository) Sold(from time.Time) (map[uint]float64, error) {
+	var rows []struct {
+		InventoryID uint
+		Quantity    float64
+	}
+	err := r.db.Model(&model.Sale{}).
+		Select("inventory_id, sum(quantity) AS quantity").
+		Where("created_at >= ?", from).
+		Group("inventory_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	sold := make(map[uint]float64, len(rows))
+	for _, row := range rows {
+		sold[row.InventoryID] = row.Quantity
+	}
+	return sold, nil
+}
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"blizzflow/backend/domain/model"
+
+	"gorm.io/gorm"
+)
+
+type SerialRepository struct {
+	db *gorm.DB
+}
+
+func NewSerialRepository(db *gorm.DB) *SerialRepository {
+	return &SerialRepository{db: db}
+}
+
+func (r *SerialRepository) Create(serial *model.SerialNumber) error {
+	return r.db.Create(serial).Error
+}
+
+func (r *SerialRepository) Update(serial *model.SerialNumber) error {
+	return r.db.Save(serial).Error
+}
+
+// Find returns an item's unit with the given serial, ignoring case, or nil.
+func (r *SerialRepository) Find(inventoryID uint, serial string) (*model.SerialNumber, error) {
+	var serials []model.SerialNumber
+	err := r.db.Where("inventory_id = ? AND serial = ? COLLATE NOCASE", inventoryID, serial).Limit(1).Find(&serials).Error
+	if err != nil || len(serials) == 0 {
+		return nil, err
+	}
+	return &serials[0], nil
+}
+
+// FindAll returns the units of any item with the given serial, ignoring
+// case.
+func (r *SerialRepository) FindAll(serial string) ([]model.SerialNumber, error) {
+	var serials []model.SerialNumber
+	err := r.db.Where("serial = ? COLLATE NOCASE", serial).Order("id").Find(&serials).Error
+	return serials, err
+}
+
+// ListByInventory returns an item's units in the given status, or all of
+// them for an empty one, in serial order.
+func (r *SerialRepository) ListByInventory(inventoryID uint, status string) ([]model.SerialNumber, error) {
+	query := r.db.Where("inventory_id = ?", inventoryID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var serials []model.SerialNumber
+	err := query.Order("serial COLLATE NOCASE").Find(&serials).Error
+	return serials, err
+}
+
+func (r *SerialRepository) CreateEvent(event *model.SerialEvent) error {
+	return r.db.Create(event).Error
+}
+
+// ListEvents returns what happened to a unit, oldest first.
+func (r *SerialRepository) ListEvents(serialID uint) ([]model.SerialEvent, error) {
+	var events []model.SerialEvent
+	err := r.db.Where("serial_id = ?", serialID).Order("id").Find(&events).Error
+	return events, err
+}
+
+// DeleteByInventory removes an item's units and their events.
+func (r *SerialRepository) DeleteByInventory(inventoryID uint) error {
+	serials := r.db.Model(&model.SerialNumber{}).Select("id").Where("inventory_id = ?", inventoryID)
+	if err := r.db.Where("serial_id IN (?)", serials).Delete(&model.SerialEvent{}).Error; err != nil {
+		return err
+	}
+	return r.db.Where("inventory_id = ?", inventoryID).Delete(&model.SerialNumber{}).Error
+}
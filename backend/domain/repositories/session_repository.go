@@ -34,6 +34,11 @@ func (r *SessionRepository) DeleteSession(sessionID uint) error {
 	return r.db.Delete(&model.Session{}, sessionID).Error
 }
 
+// DeleteAllSessions logs everyone out, e.g. before switching company.
+func (r *SessionRepository) DeleteAllSessions() error {
+	return r.db.Where("1 = 1").Delete(&model.Session{}).Error
+}
+
 func (r *SessionRepository) GetSessionByUserID(userID uint) (*model.Session, error) {
 	var session model.Session
 	result := r.db.Where("user_id = ?", userID).First(&session)
@@ -52,8 +57,10 @@ func (r *SessionRepository) GetSessionByID(sessionID uint) (*model.Session, erro
 	return &session, nil
 }
 
-func (r *SessionRepository) CleanupExpiredSessions() error {
-	expirationTime := time.Now().Add(-24 * time.Hour) // Example: 24 hours expiration
+// CleanupExpiredSessions deletes sessions older than maxAge and returns how
+// many were removed.
+func (r *SessionRepository) CleanupExpiredSessions(maxAge time.Duration) (int64, error) {
+	expirationTime := time.Now().Add(-maxAge)
 	result := r.db.Where("created_at < ?", expirationTime).Delete(&model.Session{})
-	return result.Error
+	return result.RowsAffected, result.Error
 }
@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"blizzflow/backend/domain/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type SettingRepository struct {
+	db *gorm.DB
+}
+
+func NewSettingRepository(db *gorm.DB) *SettingRepository {
+	return &SettingRepository{db: db}
+}
+
+// Get returns the value of key, or "" when it was never set.
+func (r *SettingRepository) Get(key string) (string, error) {
+	var setting model.Setting
+	err := r.db.Where("key = ?", key).Limit(1).Find(&setting).Error
+	return setting.Value, err
+}
+
+func (r *SettingRepository) Set(key, value string) error {
+	return r.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&model.Setting{Key: key, Value: value}).Error
+}
+
+func (r *SettingRepository) All() ([]model.Setting, error) {
+	var settings []model.Setting
+	err := r.db.Order("key").Find(&settings).Error
+	return settings, err
+}
@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"blizzflow/backend/domain/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// OnHand is the ledger balance of one item.
+type OnHand struct {
+	InventoryID uint
+	Quantity    float64
+}
+
+// Valuation is the stock and its value at cost of one item.
+type Valuation struct {
+	InventoryID uint    `json:"inventoryId"`
+	Name        string  `json:"name"`
+	Unit        string  `json:"unit"`
+	Quantity    float64 `json:"quantity"`
+	Value       float64 `json:"value"`
+}
+
+// StockMovementRepository only appends; the ledger is never edited.
+type StockMovementRepository struct {
+	db *gorm.DB
+}
+
+func NewStockMovementRepository(db *gorm.DB) *StockMovementRepository {
+	return &StockMovementRepository{db: db}
+}
+
+func (r *StockMovementRepository) Create(movement *model.StockMovement) error {
+	return r.db.Create(movement).Error
+}
+
+// ListByInventory returns one page of an item's movements, newest first, and
+// how many there are in total.
+func (r *StockMovementRepository) ListByInventory(inventoryID uint, offset, limit int) ([]model.StockMovement, int64, error) {
+	query := r.db.Model(&model.StockMovement{}).Where("inventory_id = ?", inventoryID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var movements []model.StockMovement
+	err := query.Order("id DESC").Offset(offset).Limit(limit).Find(&movements).Error
+	return movements, total, err
+}
+
+// ListByReference returns the movements recorded for a document.
+func (r *StockMovementRepository) ListByReference(reference string) ([]model.StockMovement, error) {
+	var movements []model.StockMovement
+	err := r.db.Where("reference = ?", reference).Order("id").Find(&movements).Error
+	return movements, err
+}
+
+// Sum returns the ledger balance of an item.
+func (r *StockMovementRepository) Sum(inventoryID uint) (float64, error) {
+	var sum float64
+	err := r.db.Model(&model.StockMovement{}).
+		Where("inventory_id = ?", inventoryID).
+		Select("COALESCE(SUM(quantity), 0)").
+		Scan(&sum).Error
+	return sum, err
+}
+
+// SumAt returns the ledger balance of an item at one location.
+func (r *StockMovementRepository) SumAt(inventoryID, locationID uint) (float64, error) {
+	var sum float64
+	err := r.db.Model(&model.StockMovement{}).
+		Where("inventory_id = ? AND location_id = ?", inventoryID, locationID).
+		Select("COALESCE(SUM(quantity), 0)").
+		Scan(&sum).Error
+	return sum, err
+}
+
+// OnHand returns the ledger balance of every item that has movements.
+func (r *StockMovementRepository) OnHand() ([]OnHand, error) {
+	var balances []OnHand
+	err := r.db.Model(&model.StockMovement{}).
+		Select("inventory_id, SUM(quantity) AS quantity").
+		Group("inventory_id").
+		Scan(&balances).Error
+	return balances, err
+}
+
+// Valuation sums the ledger up to and including asOf per item, at one
+// location or, for nil, all of them, skipping items that held no stock and
+// no value then.
+func (r *StockMovementRepository) Valuation(asOf time.Time, locationID *uint) ([]Valuation, error) {
+	var rows []Valuation
+	query := r.db.Table("stock_movements").
+		Select("inventories.id AS inventory_id, inventories.name, inventories.unit, "+
+			"sum(stock_movements.quantity) AS quantity, sum(stock_movements.cost) AS value").
+		Joins("JOIN inventories ON inventories.id = stock_movements.inventory_id").
+		Where("stock_movements.created_at <= ?", asOf)
+	if locationID != nil {
+		query = query.Where("stock_movements.location_id = ?", *locationID)
+	}
+	err := query.Group("inventories.id").
+		Having("round(sum(stock_movements.quantity), 6) <> 0 OR round(sum(stock_movements.cost), 2) <> 0").
+		Order("inventories.name, inventories.id").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// CreateLayer opens a cost layer for stock that came in.
+func (r *StockMovementRepository) CreateLayer(layer *model.CostLayer) error {
+	return r.db.Create(layer).Error
+}
+
+// OpenLayers returns the layers of an item that still hold stock, oldest
+// first.
+func (r *StockMovementRepository) OpenLayers(inventoryID uint) ([]model.CostLayer, error) {
+	var layers []model.CostLayer
+	err := r.db.Where("inventory_id = ? AND remaining > 0", inventoryID).Order("id").Find(&layers).Error
+	return layers, err
+}
+
+// ConsumeLayer sets what is left of a layer.
+func (r *StockMovementRepository) ConsumeLayer(id uint, remaining float64) error {
+	return r.db.Model(&model.CostLayer{}).Where("id = ?", id).Update("remaining", remaining).Error
+}
+
+func (r *StockMovementRepository) CountByInventory(inventoryID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&model.StockMovement{}).Where("inventory_id = ?", inventoryID).Count(&count).Error
+	return count, err
+}
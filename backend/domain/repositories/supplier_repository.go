@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"blizzflow/backend/domain/model"
+
+	"gorm.io/gorm"
+)
+
+type SupplierRepository struct {
+	db *gorm.DB
+}
+
+func NewSupplierRepository(db *gorm.DB) *SupplierRepository {
+	return &SupplierRepository{db: db}
+}
+
+func (r *SupplierRepository) Create(supplier *model.Supplier) error {
+	return r.db.Create(supplier).Error
+}
+
+func (r *SupplierRepository) Update(supplier *model.Supplier) error {
+	return r.db.Save(supplier).Error
+}
+
+// Delete removes a supplier and the items it sells.
+func (r *SupplierRepository) Delete(id uint) error {
+	if err := r.db.Where("supplier_id = ?", id).Delete(&model.SupplierItem{}).Error; err != nil {
+		return err
+	}
+	return r.db.Delete(&model.Supplier{}, id).Error
+}
+
+func (r *SupplierRepository) GetByID(id uint) (*model.Supplier, error) {
+	var supplier model.Supplier
+	if err := r.db.First(&supplier, id).Error; err != nil {
+		return nil, err
+	}
+	return &supplier, nil
+}
+
+// GetByName returns the supplier with the given name, ignoring case, or nil.
+func (r *SupplierRepository) GetByName(name string) (*model.Supplier, error) {
+	var suppliers []model.Supplier
+	if err := r.db.Where("name = ? COLLATE NOCASE", name).Limit(1).Find(&suppliers).Error; err != nil {
+		return nil, err
+	}
+	if len(suppliers) == 0 {
+		return nil, nil
+	}
+	return &suppliers[0], nil
+}
+
+// List returns the suppliers by name, archived ones only when asked for.
+func (r *SupplierRepository) List(includeArchived bool) ([]model.Supplier, error) {
+	query := r.db.Order("name COLLATE NOCASE")
+	if !includeArchived {
+		query = query.Where("archived_at IS NULL")
+	}
+	var suppliers []model.Supplier
+	err := query.Find(&suppliers).Error
+	return suppliers, err
+}
+
+// SaveItem creates or updates what a supplier sells of an item.
+func (r *SupplierRepository) SaveItem(item *model.SupplierItem) error {
+	return r.db.Save(item).Error
+}
+
+func (r *SupplierRepository) DeleteItem(id uint) error {
+	return r.db.Delete(&model.SupplierItem{}, id).Error
+}
+
+func (r *SupplierRepository) GetItem(id uint) (*model.SupplierItem, error) {
+	var item model.SupplierItem
+	if err := r.db.First(&item, id).Error; err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// FindItem returns what a supplier sells of an item, or nil.
+func (r *SupplierRepository) FindItem(supplierID, inventoryID uint) (*model.SupplierItem, error) {
+	var items []model.SupplierItem
+	err := r.db.Where("supplier_id = ? AND inventory_id = ?", supplierID, inventoryID).Limit(1).Find(&items).Error
+	if err != nil || len(items) == 0 {
+		return nil, err
+	}
+	return &items[0], nil
+}
+
+// ListItems returns the items a supplier sells.
+func (r *SupplierRepository) ListItems(supplierID uint) ([]model.SupplierItem, error) {
+	var items []model.SupplierItem
+	err := r.db.Where("supplier_id = ?", supplierID).Order("id").Find(&items).Error
+	return items, err
+}
+
+// ListByInventory returns who sells an item, the preferred supplier first.
+func (r *SupplierRepository) ListByInventory(inventoryID uint) ([]model.SupplierItem, error) {
+	var items []model.SupplierItem
+	err := r.db.Where("inventory_id = ?", inventoryID).Order("preferred DESC, id").Find(&items).Error
+	return items, err
+}
+
+// ClearPreferred drops the preferred mark from an item's other suppliers.
+func (r *SupplierRepository) ClearPreferred(inventoryID, exceptID uint) error {
+	return r.db.Model(&model.SupplierItem{}).
+		Where("inventory_id = ? AND id <> ?", inventoryID, exceptID).
+		Update("preferred", false).Error
+}
+
+// DeleteItemsByInventory forgets who sells an item that is being deleted.
+func (r *SupplierRepository) DeleteItemsByInventory(inventoryID uint) error {
+	return r.db.Where("inventory_id = ?", inventoryID).Delete(&model.SupplierItem{}).Error
+}
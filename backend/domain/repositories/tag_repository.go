@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"blizzflow/backend/domain/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TagRepository struct {
+	db *gorm.DB
+}
+
+func NewTagRepository(db *gorm.DB) *TagRepository {
+	return &TagRepository{db: db}
+}
+
+func (r *TagRepository) Create(tag *model.Tag) error {
+	return r.db.Create(tag).Error
+}
+
+// Delete removes a tag from every item and then the tag itself.
+func (r *TagRepository) Delete(id uint) error {
+	if err := r.db.Where("tag_id = ?", id).Delete(&model.InventoryTag{}).Error; err != nil {
+		return err
+	}
+	return r.db.Delete(&model.Tag{}, id).Error
+}
+
+func (r *TagRepository) GetByID(id uint) (*model.Tag, error) {
+	var tag model.Tag
+	if err := r.db.First(&tag, id).Error; err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+// GetByName returns the tag with the given name, ignoring case, or nil.
+func (r *TagRepository) GetByName(name string) (*model.Tag, error) {
+	var tags []model.Tag
+	if err := r.db.Where("name = ? COLLATE NOCASE", name).Limit(1).Find(&tags).Error; err != nil {
+		return nil, err
+	}
+	if len(tags) == 0 {
+		return nil, nil
+	}
+	return &tags[0], nil
+}
+
+func (r *TagRepository) List() ([]model.Tag, error) {
+	var tags []model.Tag
+	err := r.db.Order("name COLLATE NOCASE").Find(&tags).Error
+	return tags, err
+}
+
+// ListByInventory returns the tags on an item.
+func (r *TagRepository) ListByInventory(inventoryID uint) ([]model.Tag, error) {
+	var tags []model.Tag
+	err := r.db.Where("id IN (?)", r.db.Model(&model.InventoryTag{}).Select("tag_id").Where("inventory_id = ?", inventoryID)).
+		Order("name COLLATE NOCASE").
+		Find(&tags).Error
+	return tags, err
+}
+
+// Tag puts a tag on items; items that already carry it are skipped.
+func (r *TagRepository) Tag(inventoryIDs []uint, tagID uint) error {
+	links := make([]model.InventoryTag, len(inventoryIDs))
+	for i, id := range inventoryIDs {
+		links[i] = model.InventoryTag{InventoryID: id, TagID: tagID}
+	}
+	if len(links) == 0 {
+		return nil
+	}
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(links, 100).Error
+}
+
+// Untag takes a tag off items.
+func (r *TagRepository) Untag(inventoryIDs []uint, tagID uint) error {
+	return r.db.Where("inventory_id IN ? AND tag_id = ?", inventoryIDs, tagID).Delete(&model.InventoryTag{}).Error
+}
+
+// DeleteByInventory takes every tag off an item.
+func (r *TagRepository) DeleteByInventory(inventoryID uint) error {
+	return r.db.Where("inventory_id = ?", inventoryID).Delete(&model.InventoryTag{}).Error
+}
@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"blizzflow/backend/domain/model"
+
+	"gorm.io/gorm"
+)
+
+type TransferRepository struct {
+	db *gorm.DB
+}
+
+func NewTransferRepository(db *gorm.DB) *TransferRepository {
+	return &TransferRepository{db: db}
+}
+
+func (r *TransferRepository) Create(transfer *model.Transfer) error {
+	return r.db.Create(transfer).Error
+}
+
+func (r *TransferRepository) Update(transfer *model.Transfer) error {
+	return r.db.Save(transfer).Error
+}
+
+func (r *TransferRepository) GetByID(id uint) (*model.Transfer, error) {
+	var transfer model.Transfer
+	if err := r.db.First(&transfer, id).Error; err != nil {
+		return nil, err
+	}
+	return &transfer, nil
+}
+
+// List returns the transfers in a status, or all for an empty one, newest
+// first.
+func (r *TransferRepository) List(status string) ([]model.Transfer, error) {
+	query := r.db.Order("id DESC")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var transfers []model.Transfer
+	err := query.Find(&transfers).Error
+	return transfers, err
+}
+
+func (r *TransferRepository) CreateLine(line *model.TransferLine) error {
+	return r.db.Create(line).Error
+}
+
+func (r *TransferRepository) UpdateLine(line *model.TransferLine) error {
+	return r.db.Save(line).Error
+}
+
+// ListLines returns the lines of the given transfers in the order they
+// were written.
+func (r *TransferRepository) ListLines(transferIDs []uint) ([]model.TransferLine, error) {
+	var lines []model.TransferLine
+	if len(transferIDs) == 0 {
+		return lines, nil
+	}
+	err := r.db.Where("transfer_id IN ?", transferIDs).Order("id").Find(&lines).Error
+	return lines, err
+}
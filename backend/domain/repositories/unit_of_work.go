@@ -0,0 +1,75 @@
+package repository
+
+import "gorm.io/gorm"
+
+// Repositories groups repositories that share one database handle.
+type Repositories struct {
+	Users             UserRepo
+	Sessions          SessionRepo
+	SecurityQuestions SecurityQuestionRepo
+	Licenses          LicenseRepo
+	Inventory         InventoryRepo
+	Barcodes          BarcodeRepo
+	Variants          VariantRepo
+	Units             UnitRepo
+	Categories        CategoryRepo
+	Brands            BrandRepo
+	Tags              TagRepo
+	Locations         LocationRepo
+	StockMovements    StockMovementRepo
+	Adjustments       AdjustmentRepo
+	Lots              LotRepo
+	Transfers         TransferRepo
+	Serials           SerialRepo
+	Sales             SaleRepo
+	Suppliers         SupplierRepo
+	PurchaseOrders    PurchaseOrderRepo
+	Settings          SettingRepo
+}
+
+// NewRepositories builds every repository on db, which may be a transaction.
+func NewRepositories(db *gorm.DB) *Repositories {
+	return &Repositories{
+		Users:             NewUserRepository(db),
+		Sessions:          NewSessionRepository(db),
+		SecurityQuestions: NewSecurityQuestionRepository(db),
+		Licenses:          NewLicenseRepository(db),
+		Inventory:         NewInventoryRepository(db),
+		Barcodes:          NewBarcodeRepository(db),
+		Variants:          NewVariantRepository(db),
+		Units:             NewUnitRepository(db),
+		Categories:        NewCategoryRepository(db),
+		Brands:            NewBrandRepository(db),
+		Tags:              NewTagRepository(db),
+		Locations:         NewLocationRepository(db),
+		StockMovements:    NewStockMovementRepository(db),
+		Adjustments:       NewAdjustmentRepository(db),
+		Lots:              NewLotRepository(db),
+		Transfers:         NewTransferRepository(db),
+		Serials:           NewSerialRepository(db),
+		Sales:             NewSaleRepository(db),
+		Suppliers:         NewSupplierRepository(db),
+		PurchaseOrders:    NewPurchaseOrderRepository(db),
+		Settings:          NewSettingRepository(db),
+	}
+}
+
+// UnitOfWork runs fn with repositories bound to a single transaction. The
+// transaction commits when fn returns nil and rolls back otherwise.
+type UnitOfWork interface {
+	Do(fn func(repos *Repositories) error) error
+}
+
+type GormUnitOfWork struct {
+	db *gorm.DB
+}
+
+func NewUnitOfWork(db *gorm.DB) *GormUnitOfWork {
+	return &GormUnitOfWork{db: db}
+}
+
+func (u *GormUnitOfWork) Do(fn func(repos *Repositories) error) error {
+	return u.db.Transaction(func(tx *gorm.DB) error {
+		return fn(NewRepositories(tx))
+	})
+}
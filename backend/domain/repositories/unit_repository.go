@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"blizzflow/backend/domain/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type UnitRepository struct {
+	db *gorm.DB
+}
+
+func NewUnitRepository(db *gorm.DB) *UnitRepository {
+	return &UnitRepository{db: db}
+}
+
+// Get returns the unit with the given code, or nil.
+func (r *UnitRepository) Get(code string) (*model.Unit, error) {
+	var units []model.Unit
+	if err := r.db.Where("code = ?", code).Limit(1).Find(&units).Error; err != nil {
+		return nil, err
+	}
+	if len(units) == 0 {
+		return nil, nil
+	}
+	return &units[0], nil
+}
+
+func (r *UnitRepository) List() ([]model.Unit, error) {
+	var units []model.Unit
+	err := r.db.Order("code").Find(&units).Error
+	return units, err
+}
+
+// Save creates a unit or updates the one with the same code.
+func (r *UnitRepository) Save(unit *model.Unit) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "code"}},
+		DoUpdates: clause.AssignmentColumns([]string{"name", "precision", "updated_at"}),
+	}).Create(unit).Error
+}
+
+func (r *UnitRepository) Delete(code string) error {
+	return r.db.Where("code = ?", code).Delete(&model.Unit{}).Error
+}
+
+// CountItems counts the items stocked in a unit.
+func (r *UnitRepository) CountItems(code string) (int64, error) {
+	var count int64
+	err := r.db.Model(&model.Inventory{}).Where("unit = ?", code).Count(&count).Error
+	return count, err
+}
+
+func (r *UnitRepository) CreatePack(pack *model.ItemUnit) error {
+	return r.db.Create(pack).Error
+}
+
+func (r *UnitRepository) UpdatePack(pack *model.ItemUnit) error {
+	return r.db.Save(pack).Error
+}
+
+func (r *UnitRepository) DeletePack(id uint) error {
+	return r.db.Delete(&model.ItemUnit{}, id).Error
+}
+
+func (r *UnitRepository) GetPack(id uint) (*model.ItemUnit, error) {
+	var pack model.ItemUnit
+	if err := r.db.First(&pack, id).Error; err != nil {
+		return nil, err
+	}
+	return &pack, nil
+}
+
+// ListPacks returns the packs of an item, smallest first.
+func (r *UnitRepository) ListPacks(inventoryID uint) ([]model.ItemUnit, error) {
+	var packs []model.ItemUnit
+	err := r.db.Where("inventory_id = ?", inventoryID).Order("factor, id").Find(&packs).Error
+	return packs, err
+}
+
+// ClearDefaults takes the purchase or sale default off every pack of an
+// item except one.
+func (r *UnitRepository) ClearDefaults(inventoryID, exceptID uint, purchase, sale bool) error {
+	query := r.db.Model(&model.ItemUnit{}).Where("inventory_id = ? AND id <> ?", inventoryID, exceptID)
+	updates := map[string]interface{}{}
+	if purchase {
+		updates["default_purchase"] = false
+	}
+	if sale {
+		updates["default_sale"] = false
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	return query.Updates(updates).Error
+}
+
+func (r *UnitRepository) DeletePacksByInventory(inventoryID uint) error {
+	return r.db.Where("inventory_id = ?", inventoryID).Delete(&model.ItemUnit{}).Error
+}
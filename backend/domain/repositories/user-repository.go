@@ -9,27 +9,27 @@ import (
 )
 
 type UserRepository struct {
-	DB *gorm.DB
+	db *gorm.DB
 }
 
 func NewUserRepository(db *gorm.DB) *UserRepository {
-	return &UserRepository{DB: db}
+	return &UserRepository{db: db}
 }
 
 func (r *UserRepository) CreateUser(user *model.User) error {
-	if err := r.DB.Create(user).Error; err != nil {
+	if err := r.db.Create(user).Error; err != nil {
 		return err
 	}
 	return nil
 }
 
 func (r *UserRepository) GetUserByUsername(username string) (*model.User, error) {
-	if r.DB == nil {
+	if r.db == nil {
 		return nil, fmt.Errorf("database connection is nil")
 	}
 
 	var user model.User
-	result := r.DB.Where("username = ?", username).First(&user)
+	result := r.db.Where("username = ?", username).First(&user)
 
 	if result.Error != nil {
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
@@ -41,9 +41,32 @@ func (r *UserRepository) GetUserByUsername(username string) (*model.User, error)
 	return &user, nil
 }
 
+func (r *UserRepository) GetUserByID(id uint) (*model.User, error) {
+	var user model.User
+	if err := r.db.First(&user, id).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
 func (r *UserRepository) UpdateUser(user *model.User) error {
-	if err := r.DB.Save(user).Error; err != nil {
+	if err := r.db.Save(user).Error; err != nil {
 		return err
 	}
 	return nil
 }
+
+func (r *UserRepository) DeleteUser(id uint) error {
+	return r.db.Delete(&model.User{}, id).Error
+}
+
+// CountByRole counts the users with a role, or all users for an empty role.
+func (r *UserRepository) CountByRole(role string) (int64, error) {
+	query := r.db.Model(&model.User{})
+	if role != "" {
+		query = query.Where("role = ?", role)
+	}
+	var count int64
+	err := query.Count(&count).Error
+	return count, err
+}
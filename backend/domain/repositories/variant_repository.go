@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"blizzflow/backend/domain/model"
+
+	"gorm.io/gorm"
+)
+
+type VariantRepository struct {
+	db *gorm.DB
+}
+
+func NewVariantRepository(db *gorm.DB) *VariantRepository {
+	return &VariantRepository{db: db}
+}
+
+func (r *VariantRepository) CreateAttribute(attribute *model.VariantAttribute) error {
+	return r.db.Create(attribute).Error
+}
+
+func (r *VariantRepository) CreateOption(option *model.VariantOption) error {
+	return r.db.Create(option).Error
+}
+
+// ListAttributes returns the attributes of a parent item in order.
+func (r *VariantRepository) ListAttributes(parentID uint) ([]model.VariantAttribute, error) {
+	var attributes []model.VariantAttribute
+	err := r.db.Where("inventory_id = ?", parentID).Order("position").Find(&attributes).Error
+	return attributes, err
+}
+
+// ListOptions returns the options of the given attributes in order.
+func (r *VariantRepository) ListOptions(attributeIDs []uint) ([]model.VariantOption, error) {
+	var options []model.VariantOption
+	if len(attributeIDs) == 0 {
+		return options, nil
+	}
+	err := r.db.Where("attribute_id IN ?", attributeIDs).Order("position").Find(&options).Error
+	return options, err
+}
+
+// SetValues gives a variant its options.
+func (r *VariantRepository) SetValues(variantID uint, optionIDs []uint) error {
+	if err := r.DeleteValues(variantID); err != nil {
+		return err
+	}
+	values := make([]model.VariantValue, len(optionIDs))
+	for i, id := range optionIDs {
+		values[i] = model.VariantValue{InventoryID: variantID, OptionID: id}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	return r.db.Create(&values).Error
+}
+
+// ListValues returns the options of the given variants.
+func (r *VariantRepository) ListValues(variantIDs []uint) ([]model.VariantValue, error) {
+	var values []model.VariantValue
+	if len(variantIDs) == 0 {
+		return values, nil
+	}
+	err := r.db.Where("inventory_id IN ?", variantIDs).Find(&values).Error
+	return values, err
+}
+
+func (r *VariantRepository) DeleteValues(variantID uint) error {
+	return r.db.Where("inventory_id = ?", variantID).Delete(&model.VariantValue{}).Error
+}
+
+// DeleteByParent removes the attributes of a parent item and their options.
+func (r *VariantRepository) DeleteByParent(parentID uint) error {
+	attributes := r.db.Model(&model.VariantAttribute{}).Select("id").Where("inventory_id = ?", parentID)
+	if err := r.db.Where("attribute_id IN (?)", attributes).Delete(&model.VariantOption{}).Error; err != nil {
+		return err
+	}
+	return r.db.Where("inventory_id = ?", parentID).Delete(&model.VariantAttribute{}).Error
+}
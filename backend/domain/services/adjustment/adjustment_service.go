@@ -0,0 +1,493 @@
+package adjustment_service
+
+import (
+	"blizzflow/backend/domain/model"
+	repository "blizzflow/backend/domain/repositories"
+	stock_service "blizzflow/backend/domain/services/stock"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Settings holding the approval thresholds
+const (
+	ApprovalQuantityKey = "adjustments.approval_quantity"
+	ApprovalValueKey    = "adjustments.approval_value"
+)
+
+const (
+	DefaultApprovalQuantity = 10
+	DefaultApprovalValue    = 100.0
+	DefaultListLimit        = 100
+)
+
+// Reason codes the application books transfer discrepancies under. They
+// stay in use when deactivated, which only hides them from manual
+// adjustments.
+const (
+	ReasonTransitLoss    = "transit_loss"
+	ReasonTransitSurplus = "transit_surplus"
+)
+
+var systemReasons = map[string]bool{
+	ReasonTransitLoss:    true,
+	ReasonTransitSurplus: true,
+}
+
+// Periods a shrinkage report can be grouped by
+const (
+	PeriodDay   = "day"
+	PeriodWeek  = "week"
+	PeriodMonth = "month"
+)
+
+var periodFormats = map[string]string{
+	PeriodDay:   "%Y-%m-%d",
+	PeriodWeek:  "%Y-W%W",
+	PeriodMonth: "%Y-%m",
+}
+
+// Custom errors
+var (
+	ErrInvalidReason      = fmt.Errorf("invalid or inactive reason code")
+	ErrInvalidQuantity    = stock_service.ErrInvalidQuantity
+	ErrWrongDirection     = fmt.Errorf("quantity does not match the reason's direction")
+	ErrEvidenceRequired   = fmt.Errorf("a note or a photo is required")
+	ErrPhotoNotFound      = fmt.Errorf("photo not found")
+	ErrInvalidThreshold   = fmt.Errorf("invalid approval threshold")
+	ErrInvalidPeriod      = fmt.Errorf("invalid report period")
+	ErrNotManager         = fmt.Errorf("only a manager can do this")
+	ErrNotPending         = fmt.Errorf("adjustment has already been decided")
+	ErrAdjustmentNotFound = fmt.Errorf("adjustment not found")
+	ErrInventoryNotFound  = fmt.Errorf("inventory not found")
+	ErrSessionNotFound    = fmt.Errorf("session not found")
+	ErrDatabaseOperation  = fmt.Errorf("database operation failed")
+	ErrInsufficientStock  = stock_service.ErrInsufficientStock
+	ErrUnitNotFound       = stock_service.ErrUnitNotFound
+	ErrVariantRequired    = stock_service.ErrVariantRequired
+)
+
+// AdjustmentInput is a stock adjustment as entered by a user.
+type AdjustmentInput struct {
+	InventoryID uint   `json:"inventoryId"`
+	ReasonCode  string `json:"reasonCode"`
+	// Quantity is the signed change in stock, in the pack UnitID or, for
+	// nil, the item's base unit
+	Quantity  float64 `json:"quantity"`
+	UnitID    *uint   `json:"unitId"`
+	Note      string  `json:"note"`
+	PhotoPath string  `json:"photoPath"`
+}
+
+// Thresholds above which an adjustment waits for a manager. Zero disables a
+// threshold.
+type Thresholds struct {
+	Quantity float64 `json:"quantity"`
+	Value    float64 `json:"value"`
+}
+
+func (t Thresholds) exceeded(adjustment *model.StockAdjustment) bool {
+	quantity := math.Abs(adjustment.Quantity)
+	if t.Quantity > 0 && quantity > t.Quantity {
+		return true
+	}
+	return t.Value > 0 && quantity*adjustment.UnitCost > t.Value
+}
+
+// ShrinkageLine totals one reason in one period. Cost is negative for stock
+// that was lost.
+type ShrinkageLine struct {
+	Period     string  `json:"period"`
+	ReasonCode string  `json:"reasonCode"`
+	Label      string  `json:"label"`
+	Quantity   float64 `json:"quantity"`
+	Cost       float64 `json:"cost"`
+}
+
+// ShrinkageReport aggregates the cost of approved adjustments.
+type ShrinkageReport struct {
+	From   time.Time       `json:"from"`
+	To     time.Time       `json:"to"`
+	Period string          `json:"period"`
+	Lines  []ShrinkageLine `json:"lines"`
+	Total  float64         `json:"total"`
+}
+
+type AdjustmentService struct {
+	adjustmentRepo repository.AdjustmentRepo
+	userRepo       repository.UserRepo
+	sessionRepo    repository.SessionRepo
+	settingRepo    repository.SettingRepo
+	uow            repository.UnitOfWork
+}
+
+func NewAdjustmentService(
+	adjustmentRepo repository.AdjustmentRepo,
+	userRepo repository.UserRepo,
+	sessionRepo repository.SessionRepo,
+	settingRepo repository.SettingRepo,
+	uow repository.UnitOfWork,
+) *AdjustmentService {
+	return &AdjustmentService{
+		adjustmentRepo: adjustmentRepo,
+		userRepo:       userRepo,
+		sessionRepo:    sessionRepo,
+		settingRepo:    settingRepo,
+		uow:            uow,
+	}
+}
+
+// ListReasonCodes returns the reason codes, optionally with inactive ones.
+func (s *AdjustmentService) ListReasonCodes(includeInactive bool) ([]model.AdjustmentReason, error) {
+	reasons, err := s.adjustmentRepo.ListReasons(includeInactive)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reason codes: %w", ErrDatabaseOperation)
+	}
+	return reasons, nil
+}
+
+// SaveReasonCode creates or changes a reason code. Reason codes are never
+// deleted, as past adjustments refer to them; deactivate them instead.
+func (s *AdjustmentService) SaveReasonCode(sessionID uint, reason model.AdjustmentReason) (*model.AdjustmentReason, error) {
+	if _, err := s.manager(sessionID); err != nil {
+		return nil, err
+	}
+
+	reason.Code = strings.ToLower(strings.TrimSpace(reason.Code))
+	reason.Label = strings.TrimSpace(reason.Label)
+	if reason.Code == "" || reason.Label == "" {
+		return nil, ErrInvalidReason
+	}
+	switch reason.Direction {
+	case "":
+		reason.Direction = model.DirectionEither
+	case model.DirectionDecrease, model.DirectionIncrease, model.DirectionEither:
+	default:
+		return nil, ErrInvalidReason
+	}
+
+	if err := s.adjustmentRepo.SaveReason(&reason); err != nil {
+		return nil, fmt.Errorf("failed to save reason code: %w", ErrDatabaseOperation)
+	}
+	return &reason, nil
+}
+
+// ApprovalThresholds returns the limits above which adjustments need a
+// manager's approval.
+func (s *AdjustmentService) ApprovalThresholds() (*Thresholds, error) {
+	thresholds := &Thresholds{Quantity: DefaultApprovalQuantity, Value: DefaultApprovalValue}
+
+	quantity, err := s.settingRepo.Get(ApprovalQuantityKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load settings: %w", ErrDatabaseOperation)
+	}
+	if n, err := strconv.ParseFloat(quantity, 64); err == nil {
+		thresholds.Quantity = n
+	}
+	value, err := s.settingRepo.Get(ApprovalValueKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load settings: %w", ErrDatabaseOperation)
+	}
+	if v, err := strconv.ParseFloat(value, 64); err == nil {
+		thresholds.Value = v
+	}
+	return thresholds, nil
+}
+
+// SetApprovalThresholds changes the approval limits.
+func (s *AdjustmentService) SetApprovalThresholds(sessionID uint, thresholds Thresholds) error {
+	if _, err := s.manager(sessionID); err != nil {
+		return err
+	}
+	if !(thresholds.Quantity >= 0) || !(thresholds.Value >= 0) || math.IsInf(thresholds.Quantity+thresholds.Value, 0) {
+		return ErrInvalidThreshold
+	}
+
+	return s.uow.Do(func(repos *repository.Repositories) error {
+		if err := repos.Settings.Set(ApprovalQuantityKey, strconv.FormatFloat(thresholds.Quantity, 'f', -1, 64)); err != nil {
+			return fmt.Errorf("failed to save settings: %w", ErrDatabaseOperation)
+		}
+		if err := repos.Settings.Set(ApprovalValueKey, strconv.FormatFloat(thresholds.Value, 'f', -1, 64)); err != nil {
+			return fmt.Errorf("failed to save settings: %w", ErrDatabaseOperation)
+		}
+		return nil
+	})
+}
+
+// RequestAdjustment records a manual stock adjustment. It is applied at once
+// when a manager requests it or when it stays within the approval
+// thresholds; otherwise it waits for a manager.
+func (s *AdjustmentService) RequestAdjustment(sessionID uint, input AdjustmentInput) (*model.StockAdjustment, error) {
+	user, err := s.user(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	input.Note = strings.TrimSpace(input.Note)
+	input.PhotoPath = strings.TrimSpace(input.PhotoPath)
+	if input.Quantity == 0 || math.IsNaN(input.Quantity) {
+		return nil, ErrInvalidQuantity
+	}
+	if input.Note == "" && input.PhotoPath == "" {
+		return nil, ErrEvidenceRequired
+	}
+	if input.PhotoPath != "" {
+		if info, err := os.Stat(input.PhotoPath); err != nil || info.IsDir() {
+			return nil, ErrPhotoNotFound
+		}
+	}
+
+	reason, err := s.adjustmentRepo.GetReason(strings.ToLower(strings.TrimSpace(input.ReasonCode)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch reason code: %w", ErrDatabaseOperation)
+	}
+	if reason == nil || !reason.Active {
+		return nil, ErrInvalidReason
+	}
+	if (reason.Direction == model.DirectionDecrease && input.Quantity > 0) ||
+		(reason.Direction == model.DirectionIncrease && input.Quantity < 0) {
+		return nil, ErrWrongDirection
+	}
+
+	thresholds, err := s.ApprovalThresholds()
+	if err != nil {
+		return nil, err
+	}
+
+	adjustment := &model.StockAdjustment{
+		InventoryID: input.InventoryID,
+		ReasonCode:  reason.Code,
+		Note:        input.Note,
+		PhotoPath:   input.PhotoPath,
+		Status:      model.AdjustmentPending,
+		RequestedBy: user.ID,
+	}
+	err = s.uow.Do(func(repos *repository.Repositories) error {
+		item, err := repos.Inventory.GetByID(input.InventoryID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInventoryNotFound
+		} else if err != nil {
+			return fmt.Errorf("failed to fetch inventory: %w", ErrDatabaseOperation)
+		}
+		if adjustment.UnitCost, err = stock_service.UnitCost(repos, item); err != nil {
+			return err
+		}
+		if adjustment.Quantity, err = stock_service.Convert(repos, item, input.Quantity, input.UnitID); err != nil {
+			return err
+		}
+
+		if err := repos.Adjustments.Create(adjustment); err != nil {
+			return fmt.Errorf("failed to create adjustment: %w", ErrDatabaseOperation)
+		}
+		switch {
+		case user.IsManager():
+			return apply(repos, adjustment, &user.ID)
+		case !thresholds.exceeded(adjustment):
+			return apply(repos, adjustment, nil)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return adjustment, nil
+}
+
+// ApproveAdjustment books a pending adjustment into the stock ledger.
+func (s *AdjustmentService) ApproveAdjustment(sessionID, adjustmentID uint, note string) (*model.StockAdjustment, error) {
+	return s.decide(sessionID, adjustmentID, note, true)
+}
+
+// RejectAdjustment closes a pending adjustment without touching stock.
+func (s *AdjustmentService) RejectAdjustment(sessionID, adjustmentID uint, note string) (*model.StockAdjustment, error) {
+	return s.decide(sessionID, adjustmentID, note, false)
+}
+
+// ListAdjustments returns the latest adjustments with a status, or all of
+// them for an empty status.
+func (s *AdjustmentService) ListAdjustments(status string, limit int) ([]model.StockAdjustment, error) {
+	if limit <= 0 {
+		limit = DefaultListLimit
+	}
+	adjustments, err := s.adjustmentRepo.List(status, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list adjustments: %w", ErrDatabaseOperation)
+	}
+	if adjustments == nil {
+		adjustments = []model.StockAdjustment{}
+	}
+	return adjustments, nil
+}
+
+// ShrinkageReport totals the cost of adjustments approved in [from, to) by
+// reason and by day, week or month.
+func (s *AdjustmentService) ShrinkageReport(from, to time.Time, period string) (*ShrinkageReport, error) {
+	format, ok := periodFormats[period]
+	if !ok {
+		return nil, ErrInvalidPeriod
+	}
+
+	rows, err := s.adjustmentRepo.Shrinkage(from, to, format)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate adjustments: %w", ErrDatabaseOperation)
+	}
+	reasons, err := s.adjustmentRepo.ListReasons(true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reason codes: %w", ErrDatabaseOperation)
+	}
+	labels := make(map[string]string, len(reasons))
+	for _, reason := range reasons {
+		labels[reason.Code] = reason.Label
+	}
+
+	report := &ShrinkageReport{From: from, To: to, Period: period, Lines: []ShrinkageLine{}}
+	for _, row := range rows {
+		report.Lines = append(report.Lines, ShrinkageLine{
+			Period:     row.Period,
+			ReasonCode: row.ReasonCode,
+			Label:      labels[row.ReasonCode],
+			Quantity:   row.Quantity,
+			Cost:       row.Cost,
+		})
+		report.Total += row.Cost
+	}
+	return report, nil
+}
+
+func (s *AdjustmentService) decide(sessionID, adjustmentID uint, note string, approve bool) (*model.StockAdjustment, error) {
+	user, err := s.manager(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	var adjustment *model.StockAdjustment
+	err = s.uow.Do(func(repos *repository.Repositories) error {
+		adjustment, err = repos.Adjustments.GetByID(adjustmentID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrAdjustmentNotFound
+		} else if err != nil {
+			return fmt.Errorf("failed to fetch adjustment: %w", ErrDatabaseOperation)
+		}
+		if adjustment.Status != model.AdjustmentPending {
+			return ErrNotPending
+		}
+
+		adjustment.DecisionNote = strings.TrimSpace(note)
+		if approve {
+			return apply(repos, adjustment, &user.ID)
+		}
+		now := time.Now()
+		adjustment.Status = model.AdjustmentRejected
+		adjustment.ApprovedBy = &user.ID
+		adjustment.DecidedAt = &now
+		if err := repos.Adjustments.Update(adjustment); err != nil {
+			return fmt.Errorf("failed to update adjustment: %w", ErrDatabaseOperation)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return adjustment, nil
+}
+
+// apply books an adjustment into the stock ledger and marks it approved.
+// approver is nil when the adjustment needed no approval.
+func apply(repos *repository.Repositories, adjustment *model.StockAdjustment, approver *uint) error {
+	movement := &model.StockMovement{
+		InventoryID: adjustment.InventoryID,
+		Type:        model.MovementAdjustment,
+		Quantity:    adjustment.Quantity,
+		Reference:   stock_service.Reference("adjustment", adjustment.ID),
+		UserID:      &adjustment.RequestedBy,
+		Reason:      adjustment.ReasonCode,
+	}
+	if err := stock_service.Record(repos, movement); err != nil {
+		switch {
+		case errors.Is(err, stock_service.ErrInsufficientStock),
+			errors.Is(err, stock_service.ErrInvalidQuantity),
+			errors.Is(err, stock_service.ErrVariantRequired),
+			errors.Is(err, stock_service.ErrSerialRequired):
+			return err
+		case errors.Is(err, stock_service.ErrInventoryNotFound):
+			return ErrInventoryNotFound
+		}
+		return fmt.Errorf("failed to record stock movement: %w", ErrDatabaseOperation)
+	}
+
+	now := time.Now()
+	adjustment.Status = model.AdjustmentApproved
+	adjustment.ApprovedBy = approver
+	adjustment.MovementID = &movement.ID
+	adjustment.DecidedAt = &now
+	if err := repos.Adjustments.Update(adjustment); err != nil {
+		return fmt.Errorf("failed to update adjustment: %w", ErrDatabaseOperation)
+	}
+	return nil
+}
+
+// Book files an approved adjustment for a movement another document has
+// recorded, such as goods lost on a transfer, so it counts as shrinkage
+// under the movement's reason code. The reason must be active unless it is
+// one of the system's own. It runs in the caller's unit of work.
+func Book(repos *repository.Repositories, m *model.StockMovement, note string) (*model.StockAdjustment, error) {
+	reason, err := repos.Adjustments.GetReason(m.Reason)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch reason code: %w", ErrDatabaseOperation)
+	}
+	if reason == nil || (!reason.Active && !systemReasons[reason.Code]) || m.Quantity == 0 || m.UserID == nil {
+		return nil, ErrInvalidReason
+	}
+
+	now := time.Now()
+	adjustment := &model.StockAdjustment{
+		InventoryID: m.InventoryID,
+		ReasonCode:  reason.Code,
+		Quantity:    m.Quantity,
+		UnitCost:    m.Cost / m.Quantity,
+		Note:        note,
+		Status:      model.AdjustmentApproved,
+		RequestedBy: *m.UserID,
+		MovementID:  &m.ID,
+		DecidedAt:   &now,
+	}
+	if err := repos.Adjustments.Create(adjustment); err != nil {
+		return nil, fmt.Errorf("failed to create adjustment: %w", ErrDatabaseOperation)
+	}
+	return adjustment, nil
+}
+
+// user resolves the user a frontend session belongs to.
+func (s *AdjustmentService) user(sessionID uint) (*model.User, error) {
+	session, err := s.sessionRepo.GetSession(sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch session: %w", ErrDatabaseOperation)
+	}
+	if session == nil {
+		return nil, ErrSessionNotFound
+	}
+	user, err := s.userRepo.GetUserByID(session.UserID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrSessionNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to fetch user: %w", ErrDatabaseOperation)
+	}
+	return user, nil
+}
+
+func (s *AdjustmentService) manager(sessionID uint) (*model.User, error) {
+	user, err := s.user(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.IsManager() {
+		return nil, ErrNotManager
+	}
+	return user, nil
+}
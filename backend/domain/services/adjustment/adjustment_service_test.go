@@ -0,0 +1,269 @@
+package adjustment_service
+
+import (
+	"blizzflow/backend/domain/model"
+	repository "blizzflow/backend/domain/repositories"
+	"blizzflow/backend/infrastructure/database"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+	"gorm.io/gorm"
+)
+
+func TestAdjustmentServiceSuite(t *testing.T) {
+	gomega.RegisterFailHandler(ginkgo.Fail)
+	ginkgo.RunSpecs(t, "Adjustment Service Test Suite")
+}
+
+const testDBPath = "test.db"
+
+var (
+	DB                *gorm.DB
+	adjustmentService *AdjustmentService
+)
+
+var _ = ginkgo.BeforeSuite(func() {
+	os.Remove(testDBPath)
+	store, err := database.Open(database.DefaultOptions(testDBPath))
+	gomega.Expect(err).To(gomega.BeNil())
+	DB = store.DB()
+	adjustmentService = NewAdjustmentService(
+		repository.NewAdjustmentRepository(DB),
+		repository.NewUserRepository(DB),
+		repository.NewSessionRepository(DB),
+		repository.NewSettingRepository(DB),
+		repository.NewUnitOfWork(DB),
+	)
+})
+
+var _ = ginkgo.AfterSuite(func() {
+	if DB != nil {
+		sqlDB, err := DB.DB()
+		if err == nil {
+			sqlDB.Close()
+		}
+	}
+	os.Remove(testDBPath)
+})
+
+var _ = ginkgo.Describe("Adjustment Service", func() {
+	var (
+		item    *model.Inventory
+		staff   *model.Session
+		manager *model.Session
+	)
+
+	login := func(username, role string) *model.Session {
+		user := &model.User{Username: username, PasswordHash: "x", Role: role}
+		DB.Create(user)
+		session := &model.Session{UserID: user.ID}
+		DB.Create(session)
+		return session
+	}
+
+	quantity := func() float64 {
+		var current model.Inventory
+		DB.First(&current, item.ID)
+		return current.Quantity
+	}
+
+	ginkgo.BeforeEach(func() {
+		DB.Exec("DELETE FROM stock_adjustments")
+		DB.Exec("DELETE FROM stock_movements")
+		DB.Exec("DELETE FROM inventories")
+		DB.Exec("DELETE FROM sessions")
+		DB.Exec("DELETE FROM users")
+		DB.Exec("DELETE FROM settings")
+
+		item = &model.Inventory{Name: "Milk", Quantity: 40, Price: 3.9, AverageCost: 2.5}
+		DB.Create(item)
+		DB.Create(&model.StockMovement{InventoryID: item.ID, Type: model.MovementOpening, Quantity: 40, Balance: 40})
+		staff = login("clerk", model.RoleStaff)
+		manager = login("boss", model.RoleManager)
+	})
+
+	ginkgo.It("should apply small adjustments at once", func() {
+		adjustment, err := adjustmentService.RequestAdjustment(staff.ID, AdjustmentInput{
+			InventoryID: item.ID,
+			ReasonCode:  "damage",
+			Quantity:    -2,
+			Note:        "carton dropped",
+		})
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(adjustment.Status).To(gomega.Equal(model.AdjustmentApproved))
+		gomega.Expect(adjustment.ApprovedBy).To(gomega.BeNil())
+		gomega.Expect(adjustment.UnitCost).To(gomega.Equal(2.5))
+		gomega.Expect(adjustment.MovementID).ToNot(gomega.BeNil())
+		gomega.Expect(quantity()).To(gomega.Equal(38.0))
+
+		var movement model.StockMovement
+		DB.First(&movement, *adjustment.MovementID)
+		gomega.Expect(movement.Type).To(gomega.Equal(model.MovementAdjustment))
+		gomega.Expect(movement.Reference).To(gomega.Equal("adjustment:" + itoa(adjustment.ID)))
+	})
+
+	ginkgo.It("should hold adjustments above a threshold for a manager", func() {
+		adjustment, err := adjustmentService.RequestAdjustment(staff.ID, AdjustmentInput{
+			InventoryID: item.ID,
+			ReasonCode:  "theft",
+			Quantity:    -12,
+			Note:        "shelf emptied overnight",
+		})
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(adjustment.Status).To(gomega.Equal(model.AdjustmentPending))
+		gomega.Expect(quantity()).To(gomega.Equal(40.0))
+
+		_, err = adjustmentService.ApproveAdjustment(staff.ID, adjustment.ID, "")
+		gomega.Expect(err).To(gomega.Equal(ErrNotManager))
+
+		approved, err := adjustmentService.ApproveAdjustment(manager.ID, adjustment.ID, "police report filed")
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(approved.Status).To(gomega.Equal(model.AdjustmentApproved))
+		gomega.Expect(*approved.ApprovedBy).ToNot(gomega.BeZero())
+		gomega.Expect(quantity()).To(gomega.Equal(28.0))
+
+		_, err = adjustmentService.RejectAdjustment(manager.ID, adjustment.ID, "")
+		gomega.Expect(err).To(gomega.Equal(ErrNotPending))
+	})
+
+	ginkgo.It("should apply the value threshold and leave rejected adjustments out of stock", func() {
+		err := adjustmentService.SetApprovalThresholds(manager.ID, Thresholds{Quantity: 0, Value: 4})
+		gomega.Expect(err).To(gomega.BeNil())
+
+		adjustment, err := adjustmentService.RequestAdjustment(staff.ID, AdjustmentInput{
+			InventoryID: item.ID,
+			ReasonCode:  "expiry",
+			Quantity:    -2,
+			Note:        "past date",
+		})
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(adjustment.Status).To(gomega.Equal(model.AdjustmentPending))
+
+		rejected, err := adjustmentService.RejectAdjustment(manager.ID, adjustment.ID, "sell at discount")
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(rejected.Status).To(gomega.Equal(model.AdjustmentRejected))
+		gomega.Expect(quantity()).To(gomega.Equal(40.0))
+
+		pending, err := adjustmentService.ListAdjustments(model.AdjustmentPending, 0)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(pending).To(gomega.BeEmpty())
+	})
+
+	ginkgo.It("should validate reason, direction and evidence", func() {
+		input := AdjustmentInput{InventoryID: item.ID, ReasonCode: "damage", Quantity: -1}
+		_, err := adjustmentService.RequestAdjustment(staff.ID, input)
+		gomega.Expect(err).To(gomega.Equal(ErrEvidenceRequired))
+
+		input.PhotoPath = filepath.Join(os.TempDir(), "no-such-photo.jpg")
+		_, err = adjustmentService.RequestAdjustment(staff.ID, input)
+		gomega.Expect(err).To(gomega.Equal(ErrPhotoNotFound))
+
+		photo := filepath.Join(ginkgo.GinkgoT().TempDir(), "damage.jpg")
+		os.WriteFile(photo, []byte("jpeg"), 0o644)
+		input.PhotoPath = photo
+		_, err = adjustmentService.RequestAdjustment(staff.ID, input)
+		gomega.Expect(err).To(gomega.BeNil())
+
+		input.Quantity = 1
+		_, err = adjustmentService.RequestAdjustment(staff.ID, input)
+		gomega.Expect(err).To(gomega.Equal(ErrWrongDirection))
+
+		input.ReasonCode = "unknown"
+		_, err = adjustmentService.RequestAdjustment(staff.ID, input)
+		gomega.Expect(err).To(gomega.Equal(ErrInvalidReason))
+	})
+
+	ginkgo.It("should let managers configure reason codes", func() {
+		reason := model.AdjustmentReason{Code: " Sample ", Label: "Tasting samples", Direction: model.DirectionDecrease, Active: true}
+		_, err := adjustmentService.SaveReasonCode(staff.ID, reason)
+		gomega.Expect(err).To(gomega.Equal(ErrNotManager))
+
+		saved, err := adjustmentService.SaveReasonCode(manager.ID, reason)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(saved.Code).To(gomega.Equal("sample"))
+
+		saved.Active = false
+		_, err = adjustmentService.SaveReasonCode(manager.ID, *saved)
+		gomega.Expect(err).To(gomega.BeNil())
+
+		active, _ := adjustmentService.ListReasonCodes(false)
+		all, _ := adjustmentService.ListReasonCodes(true)
+		gomega.Expect(len(all)).To(gomega.Equal(len(active) + 1))
+
+		_, err = adjustmentService.RequestAdjustment(manager.ID, AdjustmentInput{
+			InventoryID: item.ID,
+			ReasonCode:  "sample",
+			Quantity:    -1,
+			Note:        "demo",
+		})
+		gomega.Expect(err).To(gomega.Equal(ErrInvalidReason))
+	})
+
+	ginkgo.It("should only book active or system reasons for other documents", func() {
+		DB.Model(&model.AdjustmentReason{}).Where("code IN ?", []string{"expiry", ReasonTransitLoss}).Update("active", false)
+		ginkgo.DeferCleanup(func() {
+			DB.Model(&model.AdjustmentReason{}).Where("code IN ?", []string{"expiry", ReasonTransitLoss}).Update("active", true)
+		})
+		var user model.Session
+		DB.First(&user, staff.ID)
+
+		repos := repository.NewRepositories(DB)
+		expired := &model.StockMovement{ID: 1, InventoryID: item.ID, Quantity: -1, Cost: -2.5, Reason: "expiry", UserID: &user.UserID}
+		_, err := Book(repos, expired, "")
+		gomega.Expect(err).To(gomega.Equal(ErrInvalidReason))
+
+		lost := &model.StockMovement{ID: 1, InventoryID: item.ID, Quantity: -1, Cost: -2.5, Reason: ReasonTransitLoss, UserID: &user.UserID}
+		adjustment, err := Book(repos, lost, "TR-000001")
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(adjustment.Status).To(gomega.Equal(model.AdjustmentApproved))
+		gomega.Expect(adjustment.UnitCost).To(gomega.Equal(2.5))
+		gomega.Expect(*adjustment.MovementID).To(gomega.Equal(uint(1)))
+	})
+
+	ginkgo.It("should never take stock below zero", func() {
+		_, err := adjustmentService.RequestAdjustment(manager.ID, AdjustmentInput{
+			InventoryID: item.ID,
+			ReasonCode:  "correction",
+			Quantity:    -41,
+			Note:        "miscount",
+		})
+		gomega.Expect(err).To(gomega.MatchError(ErrInsufficientStock))
+
+		all, _ := adjustmentService.ListAdjustments("", 0)
+		gomega.Expect(all).To(gomega.BeEmpty())
+	})
+
+	ginkgo.It("should report shrinkage cost by reason and period", func() {
+		for _, input := range []AdjustmentInput{
+			{InventoryID: item.ID, ReasonCode: "damage", Quantity: -2, Note: "dropped"},
+			{InventoryID: item.ID, ReasonCode: "damage", Quantity: -1, Note: "leaking"},
+			{InventoryID: item.ID, ReasonCode: "found", Quantity: 4, Note: "back room"},
+		} {
+			_, err := adjustmentService.RequestAdjustment(manager.ID, input)
+			gomega.Expect(err).To(gomega.BeNil())
+		}
+
+		now := time.Now()
+		_, err := adjustmentService.ShrinkageReport(now.Add(-time.Hour), now.Add(time.Hour), "year")
+		gomega.Expect(err).To(gomega.Equal(ErrInvalidPeriod))
+
+		report, err := adjustmentService.ShrinkageReport(now.Add(-time.Hour), now.Add(time.Hour), PeriodMonth)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(report.Lines).To(gomega.HaveLen(2))
+		gomega.Expect(report.Lines[0].ReasonCode).To(gomega.Equal("damage"))
+		gomega.Expect(report.Lines[0].Label).To(gomega.Equal("Damaged"))
+		gomega.Expect(report.Lines[0].Quantity).To(gomega.Equal(-3.0))
+		gomega.Expect(report.Lines[0].Cost).To(gomega.Equal(-7.5))
+		gomega.Expect(report.Lines[0].Period).To(gomega.Equal(now.Format("2006-01")))
+		gomega.Expect(report.Total).To(gomega.Equal(2.5))
+	})
+})
+
+func itoa(id uint) string {
+	return strconv.FormatUint(uint64(id), 10)
+}
@@ -0,0 +1,616 @@
+package archive_service
+
+import (
+	"archive/zip"
+	repository "blizzflow/backend/domain/repositories"
+	"blizzflow/backend/infrastructure/database"
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	archiveFormat  = "blizzflow-archive"
+	archiveVersion = 1
+	manifestName   = "manifest.json"
+
+	// unusablePassword is stored for imported users, whose hashes are never
+	// exported. No bcrypt hash matches it, so the owner has to set a new
+	// password before they can log in.
+	unusablePassword = "!imported"
+)
+
+// Custom errors
+var (
+	ErrInvalidArchive     = fmt.Errorf("not a blizzflow archive")
+	ErrUnsupportedVersion = fmt.Errorf("archive version is not supported")
+	ErrSchemaTooNew       = fmt.Errorf("archive was exported by a newer version of blizzflow")
+	ErrChecksumMismatch   = fmt.Errorf("archive file is damaged")
+	ErrDanglingReference  = fmt.Errorf("archive row references a missing record")
+	ErrInvalidImportMode  = fmt.Errorf("invalid import mode")
+	ErrDatabaseOperation  = fmt.Errorf("database operation failed")
+
+	// errDryRun rolls back the import transaction of a dry run
+	errDryRun = errors.New("dry run")
+)
+
+// ImportMode decides what happens to data already in the database.
+type ImportMode string
+
+const (
+	// ImportMerge keeps existing rows and adds the archive's rows. Rows with a
+	// natural key, e.g. a username, that already exists are matched instead.
+	ImportMerge ImportMode = "merge"
+	// ImportReplace deletes existing data first. Users are always merged so
+	// nobody is locked out by an import.
+	ImportReplace ImportMode = "replace"
+)
+
+// tableSpec describes how one table is exported and imported. Tables are
+// listed parents first so references can be remapped in a single pass.
+type tableSpec struct {
+	Name string
+	// Omit lists secret columns that never leave the database
+	Omit []string
+	// Defaults fills NOT NULL columns that were omitted on export
+	Defaults map[string]interface{}
+	// NaturalKey matches an archive row to an existing row when merging
+	NaturalKey []string
+	// Refs maps a column to the table whose ID it holds
+	Refs map[string]string
+	// Keep preserves existing rows in replace mode
+	Keep bool
+	// OrderBy overrides the export order, e.g. to list parents before
+	// children within a table
+	OrderBy string
+	// After fixes up derived columns once the table is imported
+	After func(tx *gorm.DB) error
+}
+
+var tables = []tableSpec{
+	{
+		Name:       "users",
+		Omit:       []string{"password_hash"},
+		Defaults:   map[string]interface{}{"password_hash": unusablePassword},
+		NaturalKey: []string{"username"},
+		Keep:       true,
+	},
+	{Name: "settings", NaturalKey: []string{"key"}},
+	{
+		Name:       "categories",
+		NaturalKey: []string{"parent_id", "name"},
+		Refs:       map[string]string{"parent_id": "categories"},
+		OrderBy:    "depth, id",
+		// Paths are made of IDs, which the import changes
+		After: func(tx *gorm.DB) error {
+			return repository.NewCategoryRepository(tx).RebuildPaths()
+		},
+	},
+	{Name: "units", NaturalKey: []string{"code"}},
+	{Name: "brands", NaturalKey: []string{"name"}},
+	{Name: "tags", NaturalKey: []string{"name"}},
+	{Name: "locations", NaturalKey: []string{"name"}},
+	{
+		Name:    "inventories",
+		Refs:    map[string]string{"parent_id": "inventories", "category_id": "categories", "brand_id": "brands"},
+		OrderBy: "parent_id IS NOT NULL, id",
+	},
+	{Name: "item_units", Refs: map[string]string{"inventory_id": "inventories"}},
+	{
+		Name:       "barcodes",
+		NaturalKey: []string{"code"},
+		Refs:       map[string]string{"inventory_id": "inventories", "unit_id": "item_units"},
+	},
+	{Name: "variant_attributes", Refs: map[string]string{"inventory_id": "inventories"}},
+	{Name: "variant_options", Refs: map[string]string{"attribute_id": "variant_attributes"}},
+	{
+		Name:       "variant_values",
+		NaturalKey: []string{"inventory_id", "option_id"},
+		Refs:       map[string]string{"inventory_id": "inventories", "option_id": "variant_options"},
+	},
+	{
+		Name:       "inventory_tags",
+		NaturalKey: []string{"inventory_id", "tag_id"},
+		Refs:       map[string]string{"inventory_id": "inventories", "tag_id": "tags"},
+	},
+	{Name: "sales", Refs: map[string]string{"inventory_id": "inventories", "location_id": "locations"}},
+	{Name: "stock_movements", Refs: map[string]string{"inventory_id": "inventories", "location_id": "locations", "user_id": "users"}},
+	{Name: "cost_layers", Refs: map[string]string{"inventory_id": "inventories", "movement_id": "stock_movements"}},
+	{
+		Name:       "lots",
+		NaturalKey: []string{"inventory_id", "location_id", "number"},
+		Refs:       map[string]string{"inventory_id": "inventories", "location_id": "locations"},
+	},
+	{Name: "lot_allocations", Refs: map[string]string{"lot_id": "lots", "movement_id": "stock_movements"}},
+	{
+		Name:       "serial_numbers",
+		NaturalKey: []string{"inventory_id", "serial"},
+		Refs:       map[string]string{"inventory_id": "inventories", "location_id": "locations"},
+	},
+	{Name: "serial_events", Refs: map[string]string{"serial_id": "serial_numbers", "movement_id": "stock_movements"}},
+	{Name: "adjustment_reasons", NaturalKey: []string{"code"}},
+	{Name: "stock_adjustments", Refs: map[string]string{
+		"inventory_id": "inventories",
+		"requested_by": "users",
+		"approved_by":  "users",
+		"movement_id":  "stock_movements",
+	}},
+	{Name: "suppliers", NaturalKey: []string{"name"}},
+	{
+		Name:       "supplier_items",
+		NaturalKey: []string{"supplier_id", "inventory_id"},
+		Refs:       map[string]string{"supplier_id": "suppliers", "inventory_id": "inventories", "unit_id": "item_units"},
+	},
+	{Name: "purchase_orders", Refs: map[string]string{"supplier_id": "suppliers", "created_by": "users"}},
+	{
+		Name: "purchase_order_lines",
+		Refs: map[string]string{"order_id": "purchase_orders", "inventory_id": "inventories", "unit_id": "item_units"},
+	},
+	{Name: "goods_receipts", Refs: map[string]string{
+		"order_id":    "purchase_orders",
+		"location_id": "locations",
+		"received_by": "users",
+	}},
+	{Name: "goods_receipt_lines", Refs: map[string]string{
+		"receipt_id":    "goods_receipts",
+		"order_line_id": "purchase_order_lines",
+		"inventory_id":  "inventories",
+		"movement_id":   "stock_movements",
+		"lot_id":        "lots",
+	}},
+	{Name: "transfers", Refs: map[string]string{
+		"from_id":     "locations",
+		"to_id":       "locations",
+		"sent_by":     "users",
+		"received_by": "users",
+	}},
+	{Name: "transfer_lines", Refs: map[string]string{
+		"transfer_id":         "transfers",
+		"inventory_id":        "inventories",
+		"transit_movement_id": "stock_movements",
+	}},
+}
+
+// Manifest is stored as manifest.json at the root of the archive.
+type Manifest struct {
+	Format        string         `json:"format"`
+	Version       int            `json:"version"`
+	SchemaVersion int            `json:"schema_version"`
+	CreatedAt     time.Time      `json:"created_at"`
+	Files         []ManifestFile `json:"files"`
+}
+
+type ManifestFile struct {
+	Name   string `json:"name"`
+	Table  string `json:"table"`
+	Rows   int    `json:"rows"`
+	SHA256 string `json:"sha256"`
+}
+
+// ImportOptions controls ImportArchive.
+type ImportOptions struct {
+	Mode ImportMode `json:"mode"`
+	// DryRun validates the archive and runs the whole import, then rolls it
+	// back
+	DryRun bool `json:"dryRun"`
+}
+
+// TableResult counts what happened to the rows of one table.
+type TableResult struct {
+	Table    string `json:"table"`
+	Inserted int    `json:"inserted"`
+	Matched  int    `json:"matched"`
+	Deleted  int64  `json:"deleted"`
+}
+
+type ImportResult struct {
+	DryRun        bool          `json:"dryRun"`
+	SchemaVersion int           `json:"schemaVersion"`
+	Tables        []TableResult `json:"tables"`
+}
+
+type ArchiveService struct {
+	db  *gorm.DB
+	now func() time.Time
+}
+
+func NewArchiveService(db *gorm.DB) *ArchiveService {
+	return &ArchiveService{db: db, now: time.Now}
+}
+
+// ExportArchive writes every table to a zip of JSON Lines files at path. The
+// tables are read in one transaction, so the archive is consistent.
+func (s *ArchiveService) ExportArchive(path string) (*Manifest, error) {
+	version, err := database.SchemaVersion(s.db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema version: %w", ErrDatabaseOperation)
+	}
+	manifest := &Manifest{
+		Format:        archiveFormat,
+		Version:       archiveVersion,
+		SchemaVersion: version,
+		CreatedAt:     s.now(),
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp)
+
+	zw := zip.NewWriter(file)
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		for _, spec := range tables {
+			entry, err := exportTable(tx, zw, spec)
+			if err != nil {
+				return err
+			}
+			manifest.Files = append(manifest.Files, *entry)
+		}
+		return nil
+	})
+	if err == nil {
+		err = writeManifest(zw, manifest)
+	}
+	if closeErr := zw.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// ImportArchive loads an archive made by ExportArchive. Every row gets a new
+// ID and references between rows are rewritten to match. The import runs in
+// one transaction, so a failure leaves the database untouched.
+func (s *ArchiveService) ImportArchive(path string, opts ImportOptions) (*ImportResult, error) {
+	if opts.Mode == "" {
+		opts.Mode = ImportMerge
+	}
+	if opts.Mode != ImportMerge && opts.Mode != ImportReplace {
+		return nil, fmt.Errorf("%q: %w", opts.Mode, ErrInvalidImportMode)
+	}
+
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", err, ErrInvalidArchive)
+	}
+	defer zr.Close()
+
+	manifest, err := readManifest(&zr.Reader)
+	if err != nil {
+		return nil, err
+	}
+	version, err := database.SchemaVersion(s.db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema version: %w", ErrDatabaseOperation)
+	}
+	if manifest.SchemaVersion > version {
+		return nil, fmt.Errorf("archive schema v%d, database v%d: %w", manifest.SchemaVersion, version, ErrSchemaTooNew)
+	}
+
+	files := make(map[string]ManifestFile, len(manifest.Files))
+	for _, f := range manifest.Files {
+		files[f.Table] = f
+	}
+
+	results := map[string]*TableResult{}
+	for _, spec := range tables {
+		results[spec.Name] = &TableResult{Table: spec.Name}
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if opts.Mode == ImportReplace {
+			for i := len(tables) - 1; i >= 0; i-- {
+				if tables[i].Keep {
+					continue
+				}
+				res := tx.Exec(fmt.Sprintf("DELETE FROM %q", tables[i].Name))
+				if res.Error != nil {
+					return fmt.Errorf("failed to clear %s: %w", tables[i].Name, ErrDatabaseOperation)
+				}
+				results[tables[i].Name].Deleted = res.RowsAffected
+			}
+		}
+
+		imp := &importer{tx: tx, ids: map[string]map[int64]int64{}}
+		for _, spec := range tables {
+			entry, ok := files[spec.Name]
+			if !ok {
+				continue
+			}
+			rows, err := readRows(&zr.Reader, entry)
+			if err != nil {
+				return err
+			}
+			if err := imp.importTable(spec, rows, results[spec.Name]); err != nil {
+				return fmt.Errorf("%s: %w", spec.Name, err)
+			}
+			if spec.After != nil {
+				if err := spec.After(tx); err != nil {
+					return fmt.Errorf("%s: %w", spec.Name, err)
+				}
+			}
+		}
+
+		if opts.DryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		return nil, err
+	}
+
+	result := &ImportResult{DryRun: opts.DryRun, SchemaVersion: manifest.SchemaVersion}
+	for _, spec := range tables {
+		result.Tables = append(result.Tables, *results[spec.Name])
+	}
+	return result, nil
+}
+
+func exportTable(tx *gorm.DB, zw *zip.Writer, spec tableSpec) (*ManifestFile, error) {
+	var rows []map[string]interface{}
+	query := tx.Table(spec.Name)
+	if spec.OrderBy != "" {
+		query = query.Order(spec.OrderBy)
+	} else if tx.Migrator().HasColumn(spec.Name, "id") {
+		query = query.Order("id")
+	}
+	if err := query.Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", spec.Name, ErrDatabaseOperation)
+	}
+
+	entry := &ManifestFile{Name: spec.Name + ".jsonl", Table: spec.Name, Rows: len(rows)}
+	w, err := zw.Create(entry.Name)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.New()
+	enc := json.NewEncoder(io.MultiWriter(w, hash))
+	for _, row := range rows {
+		for _, column := range spec.Omit {
+			delete(row, column)
+		}
+		if err := enc.Encode(row); err != nil {
+			return nil, err
+		}
+	}
+	entry.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	return entry, nil
+}
+
+func writeManifest(zw *zip.Writer, manifest *Manifest) error {
+	w, err := zw.Create(manifestName)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(manifest)
+}
+
+func readManifest(zr *zip.Reader) (*Manifest, error) {
+	f, err := zr.Open(manifestName)
+	if err != nil {
+		return nil, fmt.Errorf("missing manifest: %w", ErrInvalidArchive)
+	}
+	defer f.Close()
+
+	var manifest Manifest
+	if err := json.NewDecoder(f).Decode(&manifest); err != nil || manifest.Format != archiveFormat {
+		return nil, ErrInvalidArchive
+	}
+	if manifest.Version > archiveVersion {
+		return nil, fmt.Errorf("archive v%d: %w", manifest.Version, ErrUnsupportedVersion)
+	}
+	return &manifest, nil
+}
+
+// readRows checks the file against the manifest before decoding it.
+func readRows(zr *zip.Reader, entry ManifestFile) ([]map[string]interface{}, error) {
+	f, err := zr.Open(entry.Name)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", entry.Name, ErrInvalidArchive)
+	}
+	data, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != entry.SHA256 {
+		return nil, fmt.Errorf("%s: %w", entry.Name, ErrChecksumMismatch)
+	}
+
+	var rows []map[string]interface{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		dec := json.NewDecoder(bytes.NewReader(scanner.Bytes()))
+		dec.UseNumber()
+		var row map[string]interface{}
+		if err := dec.Decode(&row); err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", entry.Name, line, ErrInvalidArchive)
+		}
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(rows) != entry.Rows {
+		return nil, fmt.Errorf("%s has %d rows, manifest says %d: %w", entry.Name, len(rows), entry.Rows, ErrChecksumMismatch)
+	}
+	return rows, nil
+}
+
+type importer struct {
+	tx *gorm.DB
+	// ids maps archive IDs to database IDs per table
+	ids map[string]map[int64]int64
+}
+
+func (imp *importer) importTable(spec tableSpec, rows []map[string]interface{}, result *TableResult) error {
+	columnTypes, err := imp.tx.Migrator().ColumnTypes(spec.Name)
+	if err != nil {
+		return err
+	}
+	columns := make(map[string]string, len(columnTypes))
+	for _, ct := range columnTypes {
+		columns[ct.Name()] = strings.ToLower(ct.DatabaseTypeName())
+	}
+
+	ids := map[int64]int64{}
+	imp.ids[spec.Name] = ids
+
+	for _, row := range rows {
+		oldID, hasID := toInt64(row["id"])
+		delete(row, "id")
+
+		values, err := imp.values(spec, columns, row)
+		if err != nil {
+			return err
+		}
+
+		if existing, ok, err := imp.match(spec, values); err != nil {
+			return err
+		} else if ok {
+			if hasID {
+				ids[oldID] = existing
+			}
+			result.Matched++
+			continue
+		}
+
+		if err := imp.tx.Table(spec.Name).Create(values).Error; err != nil {
+			return fmt.Errorf("failed to insert row: %w", err)
+		}
+		if hasID {
+			// The transaction holds a single connection, so this is our row
+			var newID int64
+			if err := imp.tx.Raw("SELECT last_insert_rowid()").Scan(&newID).Error; err != nil {
+				return err
+			}
+			ids[oldID] = newID
+		}
+		result.Inserted++
+	}
+	return nil
+}
+
+// values converts a decoded row to column values of the current schema.
+// Columns the schema no longer has are dropped.
+func (imp *importer) values(spec tableSpec, columns map[string]string, row map[string]interface{}) (map[string]interface{}, error) {
+	values := make(map[string]interface{}, len(row))
+	for column, value := range spec.Defaults {
+		values[column] = value
+	}
+	for column, value := range row {
+		columnType, ok := columns[column]
+		if !ok {
+			continue
+		}
+		if table, ok := spec.Refs[column]; ok {
+			mapped, err := imp.remap(table, value)
+			if err != nil {
+				return nil, fmt.Errorf("%s=%v: %w", column, value, err)
+			}
+			values[column] = mapped
+			continue
+		}
+		values[column] = convert(columnType, value)
+	}
+	return values, nil
+}
+
+func (imp *importer) remap(table string, value interface{}) (interface{}, error) {
+	id, ok := toInt64(value)
+	if !ok || id == 0 {
+		return value, nil
+	}
+	newID, ok := imp.ids[table][id]
+	if !ok {
+		return nil, ErrDanglingReference
+	}
+	return newID, nil
+}
+
+// match finds an existing row with the same natural key.
+func (imp *importer) match(spec tableSpec, values map[string]interface{}) (int64, bool, error) {
+	if len(spec.NaturalKey) == 0 {
+		return 0, false, nil
+	}
+	query := imp.tx.Table(spec.Name)
+	for _, column := range spec.NaturalKey {
+		// IS also matches NULL, e.g. a top-level category's parent
+		query = query.Where(fmt.Sprintf("%q IS ?", column), values[column])
+	}
+
+	var found []map[string]interface{}
+	if err := query.Limit(1).Find(&found).Error; err != nil {
+		return 0, false, err
+	}
+	if len(found) == 0 {
+		return 0, false, nil
+	}
+	id, _ := toInt64(found[0]["id"])
+	return id, true, nil
+}
+
+func convert(columnType string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case string:
+		if columnType == "datetime" {
+			if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+				return t
+			}
+		}
+	}
+	return value
+}
+
+func toInt64(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case json.Number:
+		i, err := v.Int64()
+		return i, err == nil
+	case int64:
+		return v, true
+	case int:
+		return int64(v), true
+	case uint:
+		return int64(v), true
+	case float64:
+		return int64(v), true
+	}
+	return 0, false
+}
@@ -0,0 +1,211 @@
+package archive_service
+
+import (
+	"archive/zip"
+	"blizzflow/backend/domain/model"
+	"blizzflow/backend/infrastructure/database"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+	"gorm.io/gorm"
+)
+
+func TestArchiveServiceSuite(t *testing.T) {
+	gomega.RegisterFailHandler(ginkgo.Fail)
+	ginkgo.RunSpecs(t, "Archive Service Test Suite")
+}
+
+const (
+	testDBPath   = "test.db"
+	targetDBPath = "target.db"
+)
+
+var (
+	DB       *gorm.DB
+	targetDB *gorm.DB
+	stores   []*database.Store
+)
+
+func openStore(path string) *gorm.DB {
+	os.Remove(path)
+	store, err := database.Open(database.DefaultOptions(path))
+	gomega.Expect(err).To(gomega.BeNil())
+	stores = append(stores, store)
+	return store.DB()
+}
+
+var _ = ginkgo.BeforeSuite(func() {
+	DB = openStore(testDBPath)
+	targetDB = openStore(targetDBPath)
+})
+
+var _ = ginkgo.AfterSuite(func() {
+	for _, store := range stores {
+		store.Close()
+	}
+	os.Remove(testDBPath)
+	os.Remove(targetDBPath)
+})
+
+var _ = ginkgo.Describe("Archive Service", func() {
+	var (
+		archivePath string
+		item        *model.Inventory
+	)
+
+	ginkgo.BeforeEach(func() {
+		archivePath = filepath.Join(ginkgo.GinkgoT().TempDir(), "export.zip")
+
+		for _, db := range []*gorm.DB{DB, targetDB} {
+			for _, table := range []string{"sales", "inventories", "categories", "settings", "users"} {
+				db.Exec("DELETE FROM " + table)
+			}
+		}
+
+		DB.Create(&model.User{Username: "owner", PasswordHash: "secret-hash"})
+		DB.Create(&model.Setting{Key: "currency", Value: "LKR"})
+		drinks := &model.Category{Name: "Drinks", Path: "/"}
+		DB.Create(drinks)
+		DB.Model(drinks).Update("path", fmt.Sprintf("/%d/", drinks.ID))
+		tea := &model.Category{Name: "Tea", ParentID: &drinks.ID, Path: "/", Depth: 1}
+		DB.Create(tea)
+		DB.Model(tea).Update("path", fmt.Sprintf("/%d/%d/", drinks.ID, tea.ID))
+		item = &model.Inventory{Name: "Tea 100g", CategoryID: &tea.ID, Quantity: 10, Price: 3.5}
+		DB.Create(item)
+		DB.Create(&model.Sale{InventoryID: item.ID, Quantity: 2, TotalPrice: 7})
+
+		// Give the target different IDs so remapping is observable
+		targetDB.Create(&model.Inventory{Name: "Existing", Quantity: 1, Price: 1})
+		targetDB.Create(&model.Category{Name: "Existing", Path: "/"})
+	})
+
+	ginkgo.It("should export every table without secrets", func() {
+		manifest, err := NewArchiveService(DB).ExportArchive(archivePath)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(manifest.Files).To(gomega.HaveLen(len(tables)))
+
+		zr, err := zip.OpenReader(archivePath)
+		gomega.Expect(err).To(gomega.BeNil())
+		defer zr.Close()
+
+		f, err := zr.Open("users.jsonl")
+		gomega.Expect(err).To(gomega.BeNil())
+		data, _ := io.ReadAll(f)
+		f.Close()
+		gomega.Expect(string(data)).To(gomega.ContainSubstring("owner"))
+		gomega.Expect(string(data)).NotTo(gomega.ContainSubstring("secret-hash"))
+	})
+
+	ginkgo.It("should merge an archive and remap references", func() {
+		_, err := NewArchiveService(DB).ExportArchive(archivePath)
+		gomega.Expect(err).To(gomega.BeNil())
+
+		result, err := NewArchiveService(targetDB).ImportArchive(archivePath, ImportOptions{Mode: ImportMerge})
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(result.DryRun).To(gomega.BeFalse())
+
+		var imported model.Inventory
+		gomega.Expect(targetDB.Where("name = ?", "Tea 100g").First(&imported).Error).To(gomega.Succeed())
+		gomega.Expect(imported.ID).NotTo(gomega.Equal(item.ID))
+
+		var sale model.Sale
+		gomega.Expect(targetDB.First(&sale).Error).To(gomega.Succeed())
+		gomega.Expect(sale.InventoryID).To(gomega.Equal(imported.ID))
+
+		var user model.User
+		gomega.Expect(targetDB.Where("username = ?", "owner").First(&user).Error).To(gomega.Succeed())
+		gomega.Expect(user.PasswordHash).To(gomega.Equal(unusablePassword))
+
+		// Category paths are rebuilt from the new IDs
+		var tea, drinks model.Category
+		gomega.Expect(targetDB.First(&tea, *imported.CategoryID).Error).To(gomega.Succeed())
+		gomega.Expect(targetDB.First(&drinks, *tea.ParentID).Error).To(gomega.Succeed())
+		gomega.Expect(tea.Path).To(gomega.Equal(fmt.Sprintf("/%d/%d/", drinks.ID, tea.ID)))
+
+		// Importing again matches the user, setting and categories instead of
+		// duplicating them
+		result, err = NewArchiveService(targetDB).ImportArchive(archivePath, ImportOptions{Mode: ImportMerge})
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(tableResult(result, "users").Matched).To(gomega.Equal(1))
+		gomega.Expect(tableResult(result, "settings").Matched).To(gomega.Equal(1))
+		gomega.Expect(tableResult(result, "categories").Matched).To(gomega.Equal(2))
+	})
+
+	ginkgo.It("should leave the database untouched on a dry run", func() {
+		_, err := NewArchiveService(DB).ExportArchive(archivePath)
+		gomega.Expect(err).To(gomega.BeNil())
+
+		result, err := NewArchiveService(targetDB).ImportArchive(archivePath, ImportOptions{Mode: ImportReplace, DryRun: true})
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(result.DryRun).To(gomega.BeTrue())
+		gomega.Expect(tableResult(result, "inventories").Deleted).To(gomega.Equal(int64(1)))
+		gomega.Expect(tableResult(result, "inventories").Inserted).To(gomega.Equal(1))
+
+		var count int64
+		targetDB.Model(&model.Inventory{}).Count(&count)
+		gomega.Expect(count).To(gomega.Equal(int64(1)))
+		targetDB.Model(&model.Sale{}).Count(&count)
+		gomega.Expect(count).To(gomega.BeZero())
+	})
+
+	ginkgo.It("should replace existing data", func() {
+		_, err := NewArchiveService(DB).ExportArchive(archivePath)
+		gomega.Expect(err).To(gomega.BeNil())
+
+		_, err = NewArchiveService(targetDB).ImportArchive(archivePath, ImportOptions{Mode: ImportReplace})
+		gomega.Expect(err).To(gomega.BeNil())
+
+		var names []string
+		targetDB.Model(&model.Inventory{}).Pluck("name", &names)
+		gomega.Expect(names).To(gomega.Equal([]string{"Tea 100g"}))
+	})
+
+	ginkgo.It("should reject a tampered archive", func() {
+		manifest, err := NewArchiveService(DB).ExportArchive(archivePath)
+		gomega.Expect(err).To(gomega.BeNil())
+
+		// Rewrite the archive with an altered sales file
+		tampered := filepath.Join(filepath.Dir(archivePath), "tampered.zip")
+		out, err := os.Create(tampered)
+		gomega.Expect(err).To(gomega.BeNil())
+		zw := zip.NewWriter(out)
+		zr, err := zip.OpenReader(archivePath)
+		gomega.Expect(err).To(gomega.BeNil())
+		for _, f := range zr.File {
+			w, _ := zw.Create(f.Name)
+			r, _ := f.Open()
+			data, _ := io.ReadAll(r)
+			r.Close()
+			if f.Name == "sales.jsonl" {
+				data = append(data, []byte("{\"id\":99}\n")...)
+			}
+			w.Write(data)
+		}
+		zr.Close()
+		zw.Close()
+		out.Close()
+
+		gomega.Expect(manifest.Files).NotTo(gomega.BeEmpty())
+		_, err = NewArchiveService(targetDB).ImportArchive(tampered, ImportOptions{DryRun: true})
+		gomega.Expect(err).To(gomega.MatchError(ErrChecksumMismatch))
+	})
+
+	ginkgo.It("should reject an unknown import mode", func() {
+		_, err := NewArchiveService(targetDB).ImportArchive(archivePath, ImportOptions{Mode: "upsert"})
+		gomega.Expect(err).To(gomega.MatchError(ErrInvalidImportMode))
+	})
+})
+
+func tableResult(result *ImportResult, table string) TableResult {
+	for _, t := range result.Tables {
+		if t.Table == table {
+			return t
+		}
+	}
+	return TableResult{}
+}
@@ -20,23 +20,29 @@ var (
 	ErrPasswordHash       = fmt.Errorf("password hashing failed")
 	ErrSecurityQuestions  = fmt.Errorf("security questions validation failed")
 	ErrInvalidAnswers     = fmt.Errorf("incorrect security answers provided")
+	ErrInvalidRole        = fmt.Errorf("invalid role")
+	ErrNotPermitted       = fmt.Errorf("only the owner can change roles")
+	ErrLastOwner          = fmt.Errorf("the last owner cannot be demoted")
 )
 
 type AuthService struct {
-	userRepo              *repository.UserRepository
-	sessionRepo           *repository.SessionRepository
-	securityQuestionsRepo *repository.SecurityQuestionRepository
+	userRepo              repository.UserRepo
+	sessionRepo           repository.SessionRepo
+	securityQuestionsRepo repository.SecurityQuestionRepo
+	uow                   repository.UnitOfWork
 }
 
 func NewAuthService(
-	userRepo *repository.UserRepository,
-	sessionRepo *repository.SessionRepository,
-	securityQuestionsRepo *repository.SecurityQuestionRepository,
+	userRepo repository.UserRepo,
+	sessionRepo repository.SessionRepo,
+	securityQuestionsRepo repository.SecurityQuestionRepo,
+	uow repository.UnitOfWork,
 ) *AuthService {
 	return &AuthService{
 		userRepo:              userRepo,
 		sessionRepo:           sessionRepo,
 		securityQuestionsRepo: securityQuestionsRepo,
+		uow:                   uow,
 	}
 }
 
@@ -53,12 +59,73 @@ func (s *AuthService) Register(username, password string) error {
 	user := &model.User{
 		Username:     username,
 		PasswordHash: string(passwordHash),
+		Role:         model.RoleStaff,
 	}
 
-	if err := s.userRepo.CreateUser(user); err != nil {
-		return fmt.Errorf("failed to create user: %w", ErrDatabaseOperation)
+	return s.uow.Do(func(repos *repository.Repositories) error {
+		// Whoever sets the application up owns it
+		users, err := repos.Users.CountByRole("")
+		if err != nil {
+			return fmt.Errorf("failed to count users: %w", ErrDatabaseOperation)
+		}
+		if users == 0 {
+			user.Role = model.RoleOwner
+		}
+		if err := repos.Users.CreateUser(user); err != nil {
+			return fmt.Errorf("failed to create user: %w", ErrDatabaseOperation)
+		}
+		return nil
+	})
+}
+
+// SetUserRole changes a user's role. Only owners may do so, and there is
+// always at least one owner left.
+func (s *AuthService) SetUserRole(sessionID, userID uint, role string) error {
+	switch role {
+	case model.RoleOwner, model.RoleManager, model.RoleStaff:
+	default:
+		return ErrInvalidRole
 	}
-	return nil
+
+	session, err := s.sessionRepo.GetSession(sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to get session: %w", ErrDatabaseOperation)
+	}
+	if session == nil {
+		return ErrSessionNotFound
+	}
+
+	return s.uow.Do(func(repos *repository.Repositories) error {
+		caller, err := repos.Users.GetUserByID(session.UserID)
+		if err != nil {
+			return fmt.Errorf("failed to get user: %w", ErrDatabaseOperation)
+		}
+		if caller.Role != model.RoleOwner {
+			return ErrNotPermitted
+		}
+
+		user, err := repos.Users.GetUserByID(userID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUserNotFound
+		} else if err != nil {
+			return fmt.Errorf("failed to get user: %w", ErrDatabaseOperation)
+		}
+		if user.Role == model.RoleOwner && role != model.RoleOwner {
+			owners, err := repos.Users.CountByRole(model.RoleOwner)
+			if err != nil {
+				return fmt.Errorf("failed to count owners: %w", ErrDatabaseOperation)
+			}
+			if owners <= 1 {
+				return ErrLastOwner
+			}
+		}
+
+		user.Role = role
+		if err := repos.Users.UpdateUser(user); err != nil {
+			return fmt.Errorf("failed to update user: %w", ErrDatabaseOperation)
+		}
+		return nil
+	})
 }
 
 func (s *AuthService) Login(username, password string) (*model.Session, error) {
@@ -102,36 +169,39 @@ func (s *AuthService) SetSecurityQuestions(username string, questions map[string
 		return fmt.Errorf("failed to get user: %w", ErrDatabaseOperation)
 	}
 
-	// Delete existing questions if any
-	if err := s.userRepo.DB.Where("user_id = ?", user.ID).Delete(&model.SecurityQuestion{}).Error; err != nil {
-		return fmt.Errorf("failed to delete existing questions: %w", ErrDatabaseOperation)
-	}
-
-	// Store new questions
+	// Hash outside the transaction, bcrypt is slow
+	hashed := make(map[string]string, len(questions))
 	for question, answer := range questions {
 		hashedAnswer, err := bcrypt.GenerateFromPassword([]byte(answer), bcrypt.DefaultCost)
 		if err != nil {
 			return fmt.Errorf("failed to hash answer: %w", ErrPasswordHash)
 		}
+		hashed[question] = string(hashedAnswer)
+	}
 
-		securityQuestion := &model.SecurityQuestion{
-			UserID:   user.ID,
-			Question: question,
-			Answer:   string(hashedAnswer),
+	// Replace the questions atomically so a failure keeps the old set
+	return s.uow.Do(func(repos *repository.Repositories) error {
+		if err := repos.SecurityQuestions.DeleteUserSecurityQuestions(user.ID); err != nil {
+			return fmt.Errorf("failed to delete existing questions: %w", ErrDatabaseOperation)
 		}
-		if err := s.securityQuestionsRepo.CreateSecurityQuestion(securityQuestion); err != nil {
-			return fmt.Errorf("failed to create security question: %w", ErrDatabaseOperation)
+
+		for question, answer := range hashed {
+			securityQuestion := &model.SecurityQuestion{
+				UserID:   user.ID,
+				Question: question,
+				Answer:   answer,
+			}
+			if err := repos.SecurityQuestions.CreateSecurityQuestion(securityQuestion); err != nil {
+				return fmt.Errorf("failed to create security question: %w", ErrDatabaseOperation)
+			}
 		}
-	}
-	return nil
+		return nil
+	})
 }
 
 func (s *AuthService) verifySecurityAnswers(user *model.User, answers map[string]string) error {
-	var questions []model.SecurityQuestion
-	if err := s.userRepo.DB.Where("user_id = ?", user.ID).Find(&questions).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return ErrSecurityQuestions
-		}
+	questions, err := s.securityQuestionsRepo.GetSecurityQuestionsByUserID(user.ID)
+	if err != nil {
 		return fmt.Errorf("failed to get security questions: %w", ErrDatabaseOperation)
 	}
 
@@ -1,6 +1,7 @@
 package auth_service
 
 import (
+	"blizzflow/backend/domain/model"
 	repository "blizzflow/backend/domain/repositories"
 	"blizzflow/backend/infrastructure/database"
 	"os"
@@ -28,8 +29,9 @@ var (
 
 var _ = ginkgo.BeforeSuite(func() {
 	os.Remove(testDBPath)
-	database.InitDB(testDBPath)
-	DB = database.DB
+	store, err := database.Open(database.DefaultOptions(testDBPath))
+	gomega.Expect(err).To(gomega.BeNil())
+	DB = store.DB()
 
 	userRepo = repository.NewUserRepository(DB)
 	sessionRepo = repository.NewSessionRepository(DB)
@@ -39,6 +41,7 @@ var _ = ginkgo.BeforeSuite(func() {
 		userRepo,
 		sessionRepo,
 		securityQuestionsRepo,
+		repository.NewUnitOfWork(DB),
 	)
 })
 
@@ -142,4 +145,34 @@ var _ = ginkgo.Describe("Auth Service", func() {
 		gomega.Expect(err).To(gomega.Equal(ErrInvalidAnswers))
 	})
 
+	ginkgo.It("should make the first user the owner", func() {
+		gomega.Expect(authService.Register("owner", "password123")).To(gomega.Succeed())
+		gomega.Expect(authService.Register("clerk", "password123")).To(gomega.Succeed())
+
+		owner, _ := userRepo.GetUserByUsername("owner")
+		clerk, _ := userRepo.GetUserByUsername("clerk")
+		gomega.Expect(owner.Role).To(gomega.Equal(model.RoleOwner))
+		gomega.Expect(clerk.Role).To(gomega.Equal(model.RoleStaff))
+	})
+
+	ginkgo.It("should let only owners change roles", func() {
+		authService.Register("owner", "password123")
+		authService.Register("clerk", "password123")
+		owner, _ := userRepo.GetUserByUsername("owner")
+		clerk, _ := userRepo.GetUserByUsername("clerk")
+		ownerSession, _ := authService.Login("owner", "password123")
+		clerkSession, _ := authService.Login("clerk", "password123")
+
+		err := authService.SetUserRole(clerkSession.ID, clerk.ID, model.RoleOwner)
+		gomega.Expect(err).To(gomega.Equal(ErrNotPermitted))
+		err = authService.SetUserRole(ownerSession.ID, clerk.ID, "admin")
+		gomega.Expect(err).To(gomega.Equal(ErrInvalidRole))
+		err = authService.SetUserRole(ownerSession.ID, owner.ID, model.RoleManager)
+		gomega.Expect(err).To(gomega.Equal(ErrLastOwner))
+
+		err = authService.SetUserRole(ownerSession.ID, clerk.ID, model.RoleManager)
+		gomega.Expect(err).To(gomega.BeNil())
+		clerk, _ = userRepo.GetUserByID(clerk.ID)
+		gomega.Expect(clerk.IsManager()).To(gomega.BeTrue())
+	})
 })
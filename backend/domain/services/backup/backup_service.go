@@ -0,0 +1,523 @@
+package backup_service
+
+import (
+	"blizzflow/backend/infrastructure/database"
+	"blizzflow/backend/infrastructure/database/migrations"
+	"blizzflow/backend/internal/secure"
+	"blizzflow/config"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Custom errors
+var (
+	ErrBackupNotFound     = fmt.Errorf("backup not found")
+	ErrInvalidBackupName  = fmt.Errorf("invalid backup name")
+	ErrPassphraseRequired = fmt.Errorf("a backup passphrase is required")
+	ErrIntegrityCheck     = fmt.Errorf("backup failed the integrity check")
+	ErrSchemaTooNew       = fmt.Errorf("backup was made by a newer version of blizzflow")
+	ErrDatabaseOperation  = fmt.Errorf("database operation failed")
+)
+
+// Kind tells why a backup was taken. Scheduled kinds are rotated separately.
+type Kind string
+
+const (
+	KindManual     Kind = "manual"
+	KindHourly     Kind = "hourly"
+	KindDaily      Kind = "daily"
+	KindWeekly     Kind = "weekly"
+	KindPreRestore Kind = "pre-restore"
+)
+
+const (
+	filePrefix    = "blizzflow"
+	timeLayout    = "20060102T150405"
+	extDB         = ".db"
+	extGzip       = ".gz"
+	extSealed     = ".enc"
+	pendingSuffix = ".restore"
+	replaced      = ".replaced"
+)
+
+var backupName = regexp.MustCompile(`^blizzflow-(manual|hourly|daily|weekly|pre-restore)-(\d{8}T\d{6})\.db(\.gz)?(\.enc)?$`)
+
+// tier is a scheduled backup cadence. A backup of a longer tier also counts
+// as a recent backup for the shorter ones.
+type tier struct {
+	kind   Kind
+	period time.Duration
+	keep   func(Options) int
+}
+
+var tiers = []tier{
+	{KindWeekly, 7 * 24 * time.Hour, func(o Options) int { return o.KeepWeekly }},
+	{KindDaily, 24 * time.Hour, func(o Options) int { return o.KeepDaily }},
+	{KindHourly, time.Hour, func(o Options) int { return o.KeepHourly }},
+}
+
+// Options configures where backups go and how they are stored.
+type Options struct {
+	Dir        string
+	MirrorDir  string
+	Compress   bool
+	Encrypt    bool
+	KeepHourly int
+	KeepDaily  int
+	KeepWeekly int
+	// DatabaseKey unlocks snapshots of an encrypted database when validating
+	// a restore. VACUUM INTO keeps the SQLCipher encryption of the source.
+	DatabaseKey []byte
+}
+
+// OptionsFromConfig fills Options from the config file, defaulting the
+// backup directory to the backups folder next to the database.
+func OptionsFromConfig(cfg config.BackupConfig, dbFile string) Options {
+	dir := cfg.Dir
+	if dir == "" {
+		dir = filepath.Join(filepath.Dir(dbFile), database.BackupDirName)
+	}
+	return Options{
+		Dir:        dir,
+		MirrorDir:  cfg.MirrorDir,
+		Compress:   cfg.Compress,
+		Encrypt:    cfg.Encrypt,
+		KeepHourly: cfg.KeepHourly,
+		KeepDaily:  cfg.KeepDaily,
+		KeepWeekly: cfg.KeepWeekly,
+	}
+}
+
+// Backup describes a backup file.
+type Backup struct {
+	Name       string    `json:"name"`
+	Kind       Kind      `json:"kind"`
+	CreatedAt  time.Time `json:"createdAt"`
+	Size       int64     `json:"size"`
+	Compressed bool      `json:"compressed"`
+	Encrypted  bool      `json:"encrypted"`
+}
+
+// RestoreResult is returned once a backup has been validated and staged.
+type RestoreResult struct {
+	Backup          string `json:"backup"`
+	SchemaVersion   int    `json:"schemaVersion"`
+	RestartRequired bool   `json:"restartRequired"`
+}
+
+type BackupService struct {
+	db         *gorm.DB
+	dbFile     string
+	opts       Options
+	mu         sync.Mutex
+	passphrase string
+	now        func() time.Time
+}
+
+func NewBackupService(db *gorm.DB, dbFile string, opts Options) *BackupService {
+	return &BackupService{
+		db:     db,
+		dbFile: dbFile,
+		opts:   opts,
+		now:    time.Now,
+	}
+}
+
+// SetPassphrase sets the passphrase used to encrypt new backups. It is kept in
+// memory only; an empty passphrase clears it.
+func (s *BackupService) SetPassphrase(passphrase string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.passphrase = passphrase
+}
+
+// CreateBackup takes a manual backup while the application keeps running.
+func (s *BackupService) CreateBackup() (*Backup, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.snapshot(KindManual)
+}
+
+// ListBackups returns the backups in the backup directory, newest first.
+func (s *BackupService) ListBackups() ([]Backup, error) {
+	s.mu.Lock()
+	dir := s.opts.Dir
+	s.mu.Unlock()
+	return listBackups(dir)
+}
+
+// Retarget points the service at another database file, e.g. after the open
+// company changed. The database handle itself follows the store.
+func (s *BackupService) Retarget(dbFile string, opts Options) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dbFile = dbFile
+	s.opts = opts
+}
+
+// RunScheduled takes a backup if one of the hourly, daily or weekly tiers is
+// due and rotates old backups. It returns nil when nothing was due.
+func (s *BackupService) RunScheduled() (*Backup, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	backups, err := listBackups(s.opts.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	var due Kind
+	for i, t := range tiers {
+		if t.keep(s.opts) <= 0 {
+			continue
+		}
+		if latest := latestOf(backups, tiers[:i+1]); latest.IsZero() || now.Sub(latest) >= t.period {
+			due = t.kind
+			break
+		}
+	}
+	if due == "" {
+		return nil, nil
+	}
+
+	backup, err := s.snapshot(due)
+	if err != nil {
+		return nil, err
+	}
+	s.rotate(s.opts.Dir)
+	if s.opts.MirrorDir != "" {
+		s.rotate(s.opts.MirrorDir)
+	}
+	return backup, nil
+}
+
+// Restore validates a backup and stages it to replace the database on the next
+// start. The current database is backed up first. If passphrase is empty the
+// passphrase set with SetPassphrase is used.
+func (s *BackupService) Restore(name, passphrase string) (*RestoreResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !backupName.MatchString(name) {
+		return nil, ErrInvalidBackupName
+	}
+	if passphrase == "" {
+		passphrase = s.passphrase
+	}
+
+	path, err := s.locate(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err = decode(name, data, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	staged := s.dbFile + pendingSuffix + ".tmp"
+	if err := os.WriteFile(staged, data, 0644); err != nil {
+		return nil, err
+	}
+	version, err := validate(staged, s.opts.DatabaseKey)
+	if err != nil {
+		os.Remove(staged)
+		return nil, err
+	}
+
+	if _, err := s.snapshot(KindPreRestore); err != nil {
+		os.Remove(staged)
+		return nil, fmt.Errorf("failed to back up current database: %w", err)
+	}
+	if err := os.Rename(staged, s.dbFile+pendingSuffix); err != nil {
+		os.Remove(staged)
+		return nil, err
+	}
+
+	log.Printf("Restore of %s staged, it will be applied on the next start", name)
+	return &RestoreResult{Backup: name, SchemaVersion: version, RestartRequired: true}, nil
+}
+
+// ApplyPendingRestore swaps in a database staged by Restore. It must run
+// before the database is opened. The replaced database is kept next to it.
+func ApplyPendingRestore(dbFile string) (bool, error) {
+	pending := dbFile + pendingSuffix
+	if _, err := os.Stat(pending); err != nil {
+		return false, nil
+	}
+
+	// Move the journal files along so the replaced copy stays consistent
+	for _, suffix := range []string{"", "-journal", "-wal", "-shm"} {
+		if _, err := os.Stat(dbFile + suffix); err != nil {
+			continue
+		}
+		if err := os.Rename(dbFile+suffix, dbFile+replaced+suffix); err != nil {
+			return false, err
+		}
+	}
+	if err := os.Rename(pending, dbFile); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// snapshot writes a consistent copy of the live database with VACUUM INTO,
+// then compresses, encrypts and mirrors it as configured.
+func (s *BackupService) snapshot(kind Kind) (*Backup, error) {
+	if s.opts.Encrypt && s.passphrase == "" {
+		return nil, ErrPassphraseRequired
+	}
+	if err := os.MkdirAll(s.opts.Dir, 0755); err != nil {
+		return nil, err
+	}
+
+	createdAt := s.now()
+	name := fmt.Sprintf("%s-%s-%s%s", filePrefix, kind, createdAt.Format(timeLayout), extDB)
+	if s.opts.Compress {
+		name += extGzip
+	}
+	if s.opts.Encrypt {
+		name += extSealed
+	}
+
+	raw := filepath.Join(s.opts.Dir, "."+name+".tmp")
+	os.Remove(raw)
+	defer os.Remove(raw)
+	if err := s.db.Exec("VACUUM INTO ?", raw).Error; err != nil {
+		return nil, fmt.Errorf("failed to snapshot database: %w", ErrDatabaseOperation)
+	}
+
+	data, err := os.ReadFile(raw)
+	if err != nil {
+		return nil, err
+	}
+	if data, err = s.encode(data); err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(s.opts.Dir, name)
+	if err := writeFileAtomic(path, data); err != nil {
+		return nil, err
+	}
+
+	if s.opts.MirrorDir != "" {
+		// A missing USB drive must not fail the primary backup
+		if err := os.MkdirAll(s.opts.MirrorDir, 0755); err != nil {
+			log.Printf("Failed to mirror backup %s: %v", name, err)
+		} else if err := writeFileAtomic(filepath.Join(s.opts.MirrorDir, name), data); err != nil {
+			log.Printf("Failed to mirror backup %s: %v", name, err)
+		}
+	}
+
+	return &Backup{
+		Name:       name,
+		Kind:       kind,
+		CreatedAt:  createdAt.Truncate(time.Second),
+		Size:       int64(len(data)),
+		Compressed: s.opts.Compress,
+		Encrypted:  s.opts.Encrypt,
+	}, nil
+}
+
+func (s *BackupService) encode(data []byte) ([]byte, error) {
+	if s.opts.Compress {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(data); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		data = buf.Bytes()
+	}
+	if s.opts.Encrypt {
+		return secure.SealWithPassphrase(s.passphrase, data)
+	}
+	return data, nil
+}
+
+func decode(name string, data []byte, passphrase string) ([]byte, error) {
+	var err error
+	if strings.HasSuffix(name, extSealed) {
+		if passphrase == "" {
+			return nil, ErrPassphraseRequired
+		}
+		if data, err = secure.OpenWithPassphrase(passphrase, data); err != nil {
+			return nil, err
+		}
+		name = strings.TrimSuffix(name, extSealed)
+	}
+	if strings.HasSuffix(name, extGzip) {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", err, ErrIntegrityCheck)
+		}
+		defer zr.Close()
+		if data, err = io.ReadAll(zr); err != nil {
+			return nil, fmt.Errorf("%v: %w", err, ErrIntegrityCheck)
+		}
+	}
+	return data, nil
+}
+
+// validate opens a restored database on its own connection, runs the SQLite
+// integrity check and makes sure this binary can migrate it.
+func validate(path string, key []byte) (int, error) {
+	db, err := database.OpenFile(database.Options{Path: path, Key: key})
+	if err != nil {
+		return 0, fmt.Errorf("%v: %w", err, ErrIntegrityCheck)
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		defer sqlDB.Close()
+	}
+
+	var result string
+	if err := db.Raw("PRAGMA integrity_check").Scan(&result).Error; err != nil {
+		return 0, fmt.Errorf("%v: %w", err, ErrIntegrityCheck)
+	}
+	if result != "ok" {
+		return 0, fmt.Errorf("%s: %w", result, ErrIntegrityCheck)
+	}
+
+	version, err := database.SchemaVersion(db)
+	if err != nil {
+		return 0, fmt.Errorf("%v: %w", err, ErrIntegrityCheck)
+	}
+	if version > migrations.Latest() {
+		return 0, fmt.Errorf("schema version %d: %w", version, ErrSchemaTooNew)
+	}
+	return version, nil
+}
+
+func (s *BackupService) locate(name string) (string, error) {
+	for _, dir := range []string{s.opts.Dir, s.opts.MirrorDir} {
+		if dir == "" {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", ErrBackupNotFound
+}
+
+// rotate deletes scheduled backups beyond the configured count per tier.
+func (s *BackupService) rotate(dir string) {
+	backups, err := listBackups(dir)
+	if err != nil {
+		log.Printf("Failed to list backups for rotation: %v", err)
+		return
+	}
+
+	for _, t := range tiers {
+		keep := t.keep(s.opts)
+		if keep <= 0 {
+			continue
+		}
+		kept := 0
+		for _, b := range backups {
+			if b.Kind != t.kind {
+				continue
+			}
+			kept++
+			if kept > keep {
+				if err := os.Remove(filepath.Join(dir, b.Name)); err != nil {
+					log.Printf("Failed to remove old backup %s: %v", b.Name, err)
+				}
+			}
+		}
+	}
+}
+
+func listBackups(dir string) ([]Backup, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var backups []Backup
+	for _, entry := range entries {
+		match := backupName.FindStringSubmatch(entry.Name())
+		if match == nil || entry.IsDir() {
+			continue
+		}
+		createdAt, err := time.ParseInLocation(timeLayout, match[2], time.Local)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, Backup{
+			Name:       entry.Name(),
+			Kind:       Kind(match[1]),
+			CreatedAt:  createdAt,
+			Size:       info.Size(),
+			Compressed: match[3] != "",
+			Encrypted:  match[4] != "",
+		})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].CreatedAt.After(backups[j].CreatedAt)
+	})
+	return backups, nil
+}
+
+// latestOf returns the creation time of the newest backup of any of the tiers.
+func latestOf(backups []Backup, ts []tier) time.Time {
+	for _, b := range backups {
+		for _, t := range ts {
+			if b.Kind == t.kind {
+				return b.CreatedAt
+			}
+		}
+	}
+	return time.Time{}
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,191 @@
+package backup_service
+
+import (
+	"blizzflow/backend/infrastructure/database"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+	"gorm.io/gorm"
+)
+
+func TestBackupServiceSuite(t *testing.T) {
+	gomega.RegisterFailHandler(ginkgo.Fail)
+	ginkgo.RunSpecs(t, "Backup Service Test Suite")
+}
+
+const testDBPath = "test.db"
+
+var (
+	DB        *gorm.DB
+	backupDir string
+	mirrorDir string
+)
+
+var _ = ginkgo.BeforeSuite(func() {
+	os.Remove(testDBPath)
+	store, err := database.Open(database.DefaultOptions(testDBPath))
+	gomega.Expect(err).To(gomega.BeNil())
+	DB = store.DB()
+})
+
+var _ = ginkgo.AfterSuite(func() {
+	if DB != nil {
+		sqlDB, err := DB.DB()
+		if err == nil {
+			sqlDB.Close()
+		}
+	}
+	os.Remove(testDBPath)
+	os.Remove(testDBPath + pendingSuffix)
+	os.Remove(testDBPath + replaced)
+})
+
+var _ = ginkgo.Describe("Backup Service", func() {
+	var (
+		opts  Options
+		clock time.Time
+	)
+
+	newService := func() *BackupService {
+		s := NewBackupService(DB, testDBPath, opts)
+		s.now = func() time.Time { return clock }
+		return s
+	}
+
+	ginkgo.BeforeEach(func() {
+		var err error
+		backupDir, err = os.MkdirTemp("", "blizzflow-backups-*")
+		gomega.Expect(err).To(gomega.BeNil())
+		mirrorDir, err = os.MkdirTemp("", "blizzflow-mirror-*")
+		gomega.Expect(err).To(gomega.BeNil())
+
+		opts = Options{Dir: backupDir, KeepHourly: 2, KeepDaily: 2, KeepWeekly: 1}
+		clock = time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local)
+
+		DB.Exec("DELETE FROM users")
+		DB.Exec("INSERT INTO users (username, password_hash) VALUES ('owner', 'hash')")
+	})
+
+	ginkgo.AfterEach(func() {
+		os.RemoveAll(backupDir)
+		os.RemoveAll(mirrorDir)
+		os.Remove(testDBPath + pendingSuffix)
+	})
+
+	ginkgo.It("should take a manual backup and mirror it", func() {
+		opts.MirrorDir = mirrorDir
+		backup, err := newService().CreateBackup()
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(backup.Kind).To(gomega.Equal(KindManual))
+
+		_, err = os.Stat(filepath.Join(backupDir, backup.Name))
+		gomega.Expect(err).To(gomega.BeNil())
+		_, err = os.Stat(filepath.Join(mirrorDir, backup.Name))
+		gomega.Expect(err).To(gomega.BeNil())
+	})
+
+	ginkgo.It("should require a passphrase for encrypted backups", func() {
+		opts.Encrypt = true
+		_, err := newService().CreateBackup()
+		gomega.Expect(err).To(gomega.Equal(ErrPassphraseRequired))
+	})
+
+	ginkgo.It("should restore a compressed, encrypted backup", func() {
+		opts.Compress = true
+		opts.Encrypt = true
+		service := newService()
+		service.SetPassphrase("correct horse")
+
+		backup, err := service.CreateBackup()
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(backup.Compressed).To(gomega.BeTrue())
+		gomega.Expect(backup.Encrypted).To(gomega.BeTrue())
+
+		_, err = service.Restore(backup.Name, "wrong")
+		gomega.Expect(err).NotTo(gomega.BeNil())
+
+		clock = clock.Add(time.Minute)
+		result, err := service.Restore(backup.Name, "correct horse")
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(result.RestartRequired).To(gomega.BeTrue())
+		gomega.Expect(result.SchemaVersion).To(gomega.BeNumerically(">", 0))
+
+		backups, err := service.ListBackups()
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(backups[0].Kind).To(gomega.Equal(KindPreRestore))
+	})
+
+	ginkgo.It("should reject a corrupted backup", func() {
+		name := "blizzflow-manual-20240101T120000.db"
+		gomega.Expect(os.WriteFile(filepath.Join(backupDir, name), []byte("not a database"), 0644)).To(gomega.Succeed())
+
+		_, err := newService().Restore(name, "")
+		gomega.Expect(err).To(gomega.MatchError(ErrIntegrityCheck))
+
+		_, err = os.Stat(testDBPath + pendingSuffix)
+		gomega.Expect(os.IsNotExist(err)).To(gomega.BeTrue())
+	})
+
+	ginkgo.It("should apply a staged restore", func() {
+		tempDir, err := os.MkdirTemp("", "blizzflow-restore-*")
+		gomega.Expect(err).To(gomega.BeNil())
+		defer os.RemoveAll(tempDir)
+
+		dbFile := filepath.Join(tempDir, "data.db")
+		gomega.Expect(os.WriteFile(dbFile, []byte("old"), 0644)).To(gomega.Succeed())
+		gomega.Expect(os.WriteFile(dbFile+pendingSuffix, []byte("new"), 0644)).To(gomega.Succeed())
+
+		applied, err := ApplyPendingRestore(dbFile)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(applied).To(gomega.BeTrue())
+
+		data, _ := os.ReadFile(dbFile)
+		gomega.Expect(string(data)).To(gomega.Equal("new"))
+		data, _ = os.ReadFile(dbFile + replaced)
+		gomega.Expect(string(data)).To(gomega.Equal("old"))
+
+		applied, err = ApplyPendingRestore(dbFile)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(applied).To(gomega.BeFalse())
+	})
+
+	ginkgo.It("should schedule and rotate backups per tier", func() {
+		service := newService()
+
+		backup, err := service.RunScheduled()
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(backup.Kind).To(gomega.Equal(KindWeekly))
+
+		// The weekly backup also satisfies the daily and hourly tiers
+		backup, err = service.RunScheduled()
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(backup).To(gomega.BeNil())
+
+		for i := 0; i < 4; i++ {
+			clock = clock.Add(time.Hour)
+			backup, err = service.RunScheduled()
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(backup.Kind).To(gomega.Equal(KindHourly))
+		}
+
+		clock = clock.Add(24 * time.Hour)
+		backup, err = service.RunScheduled()
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(backup.Kind).To(gomega.Equal(KindDaily))
+
+		backups, err := service.ListBackups()
+		gomega.Expect(err).To(gomega.BeNil())
+
+		counts := map[Kind]int{}
+		for _, b := range backups {
+			counts[b.Kind]++
+		}
+		gomega.Expect(counts[KindWeekly]).To(gomega.Equal(1))
+		gomega.Expect(counts[KindDaily]).To(gomega.Equal(1))
+		gomega.Expect(counts[KindHourly]).To(gomega.Equal(2))
+	})
+})
@@ -0,0 +1,629 @@
+package catalog_service
+
+import (
+	"blizzflow/backend/domain/model"
+	repository "blizzflow/backend/domain/repositories"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+const maxNameLength = 100
+
+// Custom errors
+var (
+	ErrInvalidName         = fmt.Errorf("invalid name")
+	ErrDuplicateName       = fmt.Errorf("name already in use")
+	ErrInvalidReorderLevel = fmt.Errorf("invalid reorder level")
+	ErrCategoryNotFound    = fmt.Errorf("category not found")
+	ErrCategoryCycle       = fmt.Errorf("a category cannot be moved below itself")
+	ErrCategoryHasChildren = fmt.Errorf("category has subcategories")
+	ErrBrandNotFound       = fmt.Errorf("brand not found")
+	ErrTagNotFound         = fmt.Errorf("tag not found")
+	ErrInventoryNotFound   = fmt.Errorf("inventory not found")
+	ErrDatabaseOperation   = fmt.Errorf("database operation failed")
+)
+
+// CategoryInput holds the editable fields of a category. Empty or nil
+// defaults are inherited from the parent.
+type CategoryInput struct {
+	Name            string   `json:"name"`
+	TaxClass        string   `json:"taxClass"`
+	ReorderPoint    *float64 `json:"reorderPoint"`
+	ReorderQuantity *float64 `json:"reorderQuantity"`
+	MinLevel        *float64 `json:"minLevel"`
+	MaxLevel        *float64 `json:"maxLevel"`
+	LeadTimeDays    *int     `json:"leadTimeDays"`
+}
+
+// CategoryNode is a category with its subcategories, for the tree view.
+type CategoryNode struct {
+	Category model.Category `json:"category"`
+	// Items counts the items filed directly under the category, Total those
+	// in its whole subtree
+	Items    int64           `json:"items"`
+	Total    int64           `json:"total"`
+	Children []*CategoryNode `json:"children"`
+}
+
+// Defaults are the settings an item ends up with after inheritance.
+type Defaults struct {
+	TaxClass        string   `json:"taxClass"`
+	ReorderPoint    *float64 `json:"reorderPoint"`
+	ReorderQuantity *float64 `json:"reorderQuantity"`
+	MinLevel        *float64 `json:"minLevel"`
+	MaxLevel        *float64 `json:"maxLevel"`
+	LeadTimeDays    *int     `json:"leadTimeDays"`
+}
+
+// ResolveDefaults fills in the settings an item leaves empty from its
+// category, then from that category's ancestors, nearest first.
+func ResolveDefaults(repos *repository.Repositories, item *model.Inventory) (Defaults, error) {
+	defaults := Defaults{
+		TaxClass:        item.TaxClass,
+		ReorderPoint:    item.ReorderPoint,
+		ReorderQuantity: item.ReorderQuantity,
+		MinLevel:        item.MinLevel,
+		MaxLevel:        item.MaxLevel,
+		LeadTimeDays:    item.LeadTimeDays,
+	}
+	if item.CategoryID == nil {
+		return defaults, nil
+	}
+
+	category, err := repos.Categories.GetByID(*item.CategoryID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return defaults, nil
+	} else if err != nil {
+		return defaults, fmt.Errorf("failed to fetch category: %w", ErrDatabaseOperation)
+	}
+	ancestors, err := repos.Categories.Ancestors(category.Path)
+	if err != nil {
+		return defaults, fmt.Errorf("failed to fetch categories: %w", ErrDatabaseOperation)
+	}
+	for i := len(ancestors) - 1; i >= 0; i-- {
+		if defaults.TaxClass == "" {
+			defaults.TaxClass = ancestors[i].TaxClass
+		}
+		if defaults.ReorderPoint == nil {
+			defaults.ReorderPoint = ancestors[i].ReorderPoint
+		}
+		if defaults.ReorderQuantity == nil {
+			defaults.ReorderQuantity = ancestors[i].ReorderQuantity
+		}
+		if defaults.MinLevel == nil {
+			defaults.MinLevel = ancestors[i].MinLevel
+		}
+		if defaults.MaxLevel == nil {
+			defaults.MaxLevel = ancestors[i].MaxLevel
+		}
+		if defaults.LeadTimeDays == nil {
+			defaults.LeadTimeDays = ancestors[i].LeadTimeDays
+		}
+	}
+	return defaults, nil
+}
+
+type CatalogService struct {
+	categoryRepo repository.CategoryRepo
+	brandRepo    repository.BrandRepo
+	tagRepo      repository.TagRepo
+	uow          repository.UnitOfWork
+}
+
+func NewCatalogService(
+	categoryRepo repository.CategoryRepo,
+	brandRepo repository.BrandRepo,
+	tagRepo repository.TagRepo,
+	uow repository.UnitOfWork,
+) *CatalogService {
+	return &CatalogService{
+		categoryRepo: categoryRepo,
+		brandRepo:    brandRepo,
+		tagRepo:      tagRepo,
+		uow:          uow,
+	}
+}
+
+// CreateCategory adds a category below parentID, or at the top level for
+// nil.
+func (s *CatalogService) CreateCategory(parentID *uint, input CategoryInput) (*model.Category, error) {
+	input, err := normalizeCategory(input)
+	if err != nil {
+		return nil, err
+	}
+
+	category := &model.Category{ParentID: parentID, Path: "/"}
+	err = s.uow.Do(func(repos *repository.Repositories) error {
+		if parentID != nil {
+			parent, err := getCategory(repos, *parentID)
+			if err != nil {
+				return err
+			}
+			category.Path = parent.Path
+			category.Depth = parent.Depth + 1
+		}
+		if err := checkCategoryName(repos, parentID, input.Name, 0); err != nil {
+			return err
+		}
+
+		applyCategory(category, input)
+		if err := repos.Categories.Create(category); err != nil {
+			return fmt.Errorf("failed to create category: %w", ErrDatabaseOperation)
+		}
+		// The path ends with the category's own ID, known only now
+		category.Path = fmt.Sprintf("%s%d/", category.Path, category.ID)
+		if err := repos.Categories.Update(category); err != nil {
+			return fmt.Errorf("failed to update category: %w", ErrDatabaseOperation)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return category, nil
+}
+
+// UpdateCategory renames a category and changes its defaults.
+func (s *CatalogService) UpdateCategory(id uint, input CategoryInput) (*model.Category, error) {
+	input, err := normalizeCategory(input)
+	if err != nil {
+		return nil, err
+	}
+
+	var category *model.Category
+	err = s.uow.Do(func(repos *repository.Repositories) error {
+		if category, err = getCategory(repos, id); err != nil {
+			return err
+		}
+		if err := checkCategoryName(repos, category.ParentID, input.Name, id); err != nil {
+			return err
+		}
+
+		applyCategory(category, input)
+		if err := repos.Categories.Update(category); err != nil {
+			return fmt.Errorf("failed to update category: %w", ErrDatabaseOperation)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return category, nil
+}
+
+// MoveCategory puts a category, with its whole subtree, below parentID, or
+// at the top level for nil. Items stay in their categories.
+func (s *CatalogService) MoveCategory(id uint, parentID *uint) (*model.Category, error) {
+	var category *model.Category
+	err := s.uow.Do(func(repos *repository.Repositories) error {
+		var err error
+		if category, err = getCategory(repos, id); err != nil {
+			return err
+		}
+
+		path, depth := "/", 0
+		if parentID != nil {
+			parent, err := getCategory(repos, *parentID)
+			if err != nil {
+				return err
+			}
+			if strings.HasPrefix(parent.Path, category.Path) {
+				return ErrCategoryCycle
+			}
+			path, depth = parent.Path, parent.Depth+1
+		}
+		if err := checkCategoryName(repos, parentID, category.Name, id); err != nil {
+			return err
+		}
+
+		oldPath := category.Path
+		category.ParentID = parentID
+		category.Path = fmt.Sprintf("%s%d/", path, category.ID)
+		if err := repos.Categories.MoveSubtree(oldPath, category.Path, depth-category.Depth); err != nil {
+			return fmt.Errorf("failed to move category: %w", ErrDatabaseOperation)
+		}
+		category.Depth = depth
+		if err := repos.Categories.Update(category); err != nil {
+			return fmt.Errorf("failed to update category: %w", ErrDatabaseOperation)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return category, nil
+}
+
+// DeleteCategory removes a category without subcategories. Its items move
+// up to its parent.
+func (s *CatalogService) DeleteCategory(id uint) error {
+	return s.uow.Do(func(repos *repository.Repositories) error {
+		category, err := getCategory(repos, id)
+		if err != nil {
+			return err
+		}
+		children, err := repos.Categories.CountChildren(id)
+		if err != nil {
+			return fmt.Errorf("failed to count subcategories: %w", ErrDatabaseOperation)
+		}
+		if children > 0 {
+			return ErrCategoryHasChildren
+		}
+
+		if err := repos.Inventory.Recategorize(id, category.ParentID); err != nil {
+			return fmt.Errorf("failed to move items: %w", ErrDatabaseOperation)
+		}
+		if err := repos.Categories.Delete(id); err != nil {
+			return fmt.Errorf("failed to delete category: %w", ErrDatabaseOperation)
+		}
+		return nil
+	})
+}
+
+// CategoryTree returns the top-level categories with their subtrees, sorted
+// by name at every level.
+func (s *CatalogService) CategoryTree() ([]*CategoryNode, error) {
+	categories, err := s.categoryRepo.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list categories: %w", ErrDatabaseOperation)
+	}
+	counts, err := s.categoryRepo.ItemCounts()
+	if err != nil {
+		return nil, fmt.Errorf("failed to count items: %w", ErrDatabaseOperation)
+	}
+
+	// Parents are listed before their children
+	roots := []*CategoryNode{}
+	nodes := make(map[uint]*CategoryNode, len(categories))
+	for _, category := range categories {
+		node := &CategoryNode{Category: category, Items: counts[category.ID], Children: []*CategoryNode{}}
+		nodes[category.ID] = node
+		if category.ParentID == nil {
+			roots = append(roots, node)
+		} else if parent, ok := nodes[*category.ParentID]; ok {
+			parent.Children = append(parent.Children, node)
+		}
+	}
+	for _, root := range roots {
+		total(root)
+	}
+	return roots, nil
+}
+
+func total(node *CategoryNode) int64 {
+	node.Total = node.Items
+	for _, child := range node.Children {
+		node.Total += total(child)
+	}
+	return node.Total
+}
+
+// AssignCategory files items under a category, or under none for nil.
+func (s *CatalogService) AssignCategory(inventoryIDs []uint, categoryID *uint) error {
+	return s.uow.Do(func(repos *repository.Repositories) error {
+		if err := checkItems(repos, inventoryIDs); err != nil {
+			return err
+		}
+		if categoryID != nil {
+			if _, err := getCategory(repos, *categoryID); err != nil {
+				return err
+			}
+		}
+		if err := repos.Inventory.AssignCategory(inventoryIDs, categoryID); err != nil {
+			return fmt.Errorf("failed to assign category: %w", ErrDatabaseOperation)
+		}
+		return nil
+	})
+}
+
+// ItemDefaults returns an item's tax class and reorder levels after
+// inheritance from its categories.
+func (s *CatalogService) ItemDefaults(inventoryID uint) (*Defaults, error) {
+	var defaults Defaults
+	err := s.uow.Do(func(repos *repository.Repositories) error {
+		item, err := repos.Inventory.GetByID(inventoryID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInventoryNotFound
+		} else if err != nil {
+			return fmt.Errorf("failed to fetch inventory: %w", ErrDatabaseOperation)
+		}
+		defaults, err = ResolveDefaults(repos, item)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &defaults, nil
+}
+
+func (s *CatalogService) CreateBrand(name string) (*model.Brand, error) {
+	name, err := normalizeName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	brand := &model.Brand{Name: name}
+	err = s.uow.Do(func(repos *repository.Repositories) error {
+		if err := checkBrandName(repos, name, 0); err != nil {
+			return err
+		}
+		if err := repos.Brands.Create(brand); err != nil {
+			return fmt.Errorf("failed to create brand: %w", ErrDatabaseOperation)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return brand, nil
+}
+
+func (s *CatalogService) RenameBrand(id uint, name string) (*model.Brand, error) {
+	name, err := normalizeName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var brand *model.Brand
+	err = s.uow.Do(func(repos *repository.Repositories) error {
+		if brand, err = getBrand(repos, id); err != nil {
+			return err
+		}
+		if err := checkBrandName(repos, name, id); err != nil {
+			return err
+		}
+		brand.Name = name
+		if err := repos.Brands.Update(brand); err != nil {
+			return fmt.Errorf("failed to update brand: %w", ErrDatabaseOperation)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return brand, nil
+}
+
+// DeleteBrand removes a brand; its items are left without one.
+func (s *CatalogService) DeleteBrand(id uint) error {
+	return s.uow.Do(func(repos *repository.Repositories) error {
+		if _, err := getBrand(repos, id); err != nil {
+			return err
+		}
+		if err := repos.Inventory.ClearBrand(id); err != nil {
+			return fmt.Errorf("failed to update items: %w", ErrDatabaseOperation)
+		}
+		if err := repos.Brands.Delete(id); err != nil {
+			return fmt.Errorf("failed to delete brand: %w", ErrDatabaseOperation)
+		}
+		return nil
+	})
+}
+
+func (s *CatalogService) ListBrands() ([]model.Brand, error) {
+	brands, err := s.brandRepo.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list brands: %w", ErrDatabaseOperation)
+	}
+	return brands, nil
+}
+
+// AssignBrand sets the brand of items, or clears it for nil.
+func (s *CatalogService) AssignBrand(inventoryIDs []uint, brandID *uint) error {
+	return s.uow.Do(func(repos *repository.Repositories) error {
+		if err := checkItems(repos, inventoryIDs); err != nil {
+			return err
+		}
+		if brandID != nil {
+			if _, err := getBrand(repos, *brandID); err != nil {
+				return err
+			}
+		}
+		if err := repos.Inventory.AssignBrand(inventoryIDs, brandID); err != nil {
+			return fmt.Errorf("failed to assign brand: %w", ErrDatabaseOperation)
+		}
+		return nil
+	})
+}
+
+func (s *CatalogService) CreateTag(name string) (*model.Tag, error) {
+	name, err := normalizeName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	tag := &model.Tag{Name: name}
+	err = s.uow.Do(func(repos *repository.Repositories) error {
+		existing, err := repos.Tags.GetByName(name)
+		if err != nil {
+			return fmt.Errorf("failed to check tag: %w", ErrDatabaseOperation)
+		}
+		if existing != nil {
+			return ErrDuplicateName
+		}
+		if err := repos.Tags.Create(tag); err != nil {
+			return fmt.Errorf("failed to create tag: %w", ErrDatabaseOperation)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+// DeleteTag removes a tag from every item and deletes it.
+func (s *CatalogService) DeleteTag(id uint) error {
+	return s.uow.Do(func(repos *repository.Repositories) error {
+		if _, err := getTag(repos, id); err != nil {
+			return err
+		}
+		if err := repos.Tags.Delete(id); err != nil {
+			return fmt.Errorf("failed to delete tag: %w", ErrDatabaseOperation)
+		}
+		return nil
+	})
+}
+
+func (s *CatalogService) ListTags() ([]model.Tag, error) {
+	tags, err := s.tagRepo.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", ErrDatabaseOperation)
+	}
+	return tags, nil
+}
+
+// ItemTags returns the tags on an item.
+func (s *CatalogService) ItemTags(inventoryID uint) ([]model.Tag, error) {
+	tags, err := s.tagRepo.ListByInventory(inventoryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", ErrDatabaseOperation)
+	}
+	return tags, nil
+}
+
+// TagItems puts a tag on items.
+func (s *CatalogService) TagItems(inventoryIDs []uint, tagID uint) error {
+	return s.uow.Do(func(repos *repository.Repositories) error {
+		if err := checkItems(repos, inventoryIDs); err != nil {
+			return err
+		}
+		if _, err := getTag(repos, tagID); err != nil {
+			return err
+		}
+		if err := repos.Tags.Tag(inventoryIDs, tagID); err != nil {
+			return fmt.Errorf("failed to tag items: %w", ErrDatabaseOperation)
+		}
+		return nil
+	})
+}
+
+// UntagItems takes a tag off items.
+func (s *CatalogService) UntagItems(inventoryIDs []uint, tagID uint) error {
+	if err := s.tagRepo.Untag(inventoryIDs, tagID); err != nil {
+		return fmt.Errorf("failed to untag items: %w", ErrDatabaseOperation)
+	}
+	return nil
+}
+
+func getCategory(repos *repository.Repositories, id uint) (*model.Category, error) {
+	category, err := repos.Categories.GetByID(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrCategoryNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch category: %w", ErrDatabaseOperation)
+	}
+	return category, nil
+}
+
+func getBrand(repos *repository.Repositories, id uint) (*model.Brand, error) {
+	brand, err := repos.Brands.GetByID(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrBrandNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch brand: %w", ErrDatabaseOperation)
+	}
+	return brand, nil
+}
+
+func getTag(repos *repository.Repositories, id uint) (*model.Tag, error) {
+	tag, err := repos.Tags.GetByID(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrTagNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch tag: %w", ErrDatabaseOperation)
+	}
+	return tag, nil
+}
+
+// checkItems makes sure every item of a bulk operation exists.
+func checkItems(repos *repository.Repositories, inventoryIDs []uint) error {
+	for _, id := range inventoryIDs {
+		_, err := repos.Inventory.GetByID(id)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("item %d: %w", id, ErrInventoryNotFound)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to fetch inventory: %w", ErrDatabaseOperation)
+		}
+	}
+	return nil
+}
+
+// checkCategoryName rejects a name a sibling already uses.
+func checkCategoryName(repos *repository.Repositories, parentID *uint, name string, id uint) error {
+	existing, err := repos.Categories.GetByName(parentID, name)
+	if err != nil {
+		return fmt.Errorf("failed to check category: %w", ErrDatabaseOperation)
+	}
+	if existing != nil && existing.ID != id {
+		return ErrDuplicateName
+	}
+	return nil
+}
+
+func checkBrandName(repos *repository.Repositories, name string, id uint) error {
+	existing, err := repos.Brands.GetByName(name)
+	if err != nil {
+		return fmt.Errorf("failed to check brand: %w", ErrDatabaseOperation)
+	}
+	if existing != nil && existing.ID != id {
+		return ErrDuplicateName
+	}
+	return nil
+}
+
+func normalizeName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxNameLength {
+		return name, ErrInvalidName
+	}
+	return name, nil
+}
+
+func normalizeCategory(input CategoryInput) (CategoryInput, error) {
+	var err error
+	if input.Name, err = normalizeName(input.Name); err != nil {
+		return input, err
+	}
+	input.TaxClass = strings.TrimSpace(input.TaxClass)
+	if len(input.TaxClass) > maxNameLength {
+		return input, ErrInvalidName
+	}
+	if !ValidLevels(input.ReorderPoint, input.ReorderQuantity, input.MinLevel, input.MaxLevel, input.LeadTimeDays) {
+		return input, ErrInvalidReorderLevel
+	}
+	return input, nil
+}
+
+func applyCategory(category *model.Category, input CategoryInput) {
+	category.Name = input.Name
+	category.TaxClass = input.TaxClass
+	category.ReorderPoint = input.ReorderPoint
+	category.ReorderQuantity = input.ReorderQuantity
+	category.MinLevel = input.MinLevel
+	category.MaxLevel = input.MaxLevel
+	category.LeadTimeDays = input.LeadTimeDays
+}
+
+// ValidLevels reports whether a set of reorder levels makes sense: none is
+// negative and the minimum does not exceed the maximum. Nil levels are
+// inherited and not checked.
+func ValidLevels(reorderPoint, reorderQuantity, minLevel, maxLevel *float64, leadTimeDays *int) bool {
+	for _, level := range []*float64{reorderPoint, reorderQuantity, minLevel, maxLevel} {
+		if level != nil && (!(*level >= 0) || math.IsInf(*level, 0)) {
+			return false
+		}
+	}
+	if minLevel != nil && maxLevel != nil && *minLevel > *maxLevel {
+		return false
+	}
+	return leadTimeDays == nil || *leadTimeDays >= 0
+}
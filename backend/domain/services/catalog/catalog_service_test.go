@@ -0,0 +1,199 @@
+package catalog_service
+
+import (
+	"blizzflow/backend/domain/model"
+	repository "blizzflow/backend/domain/repositories"
+	"blizzflow/backend/infrastructure/database"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+	"gorm.io/gorm"
+)
+
+func TestCatalogServiceSuite(t *testing.T) {
+	gomega.RegisterFailHandler(ginkgo.Fail)
+	ginkgo.RunSpecs(t, "Catalog Service Test Suite")
+}
+
+const testDBPath = "test.db"
+
+var (
+	DB             *gorm.DB
+	catalogService *CatalogService
+)
+
+var _ = ginkgo.BeforeSuite(func() {
+	os.Remove(testDBPath)
+	store, err := database.Open(database.DefaultOptions(testDBPath))
+	gomega.Expect(err).To(gomega.BeNil())
+	DB = store.DB()
+	catalogService = NewCatalogService(
+		repository.NewCategoryRepository(DB),
+		repository.NewBrandRepository(DB),
+		repository.NewTagRepository(DB),
+		repository.NewUnitOfWork(DB),
+	)
+})
+
+var _ = ginkgo.AfterSuite(func() {
+	if DB != nil {
+		sqlDB, err := DB.DB()
+		if err == nil {
+			sqlDB.Close()
+		}
+	}
+	os.Remove(testDBPath)
+})
+
+var _ = ginkgo.Describe("Catalog Service", func() {
+	var items []*model.Inventory
+
+	reload := func(item *model.Inventory) *model.Inventory {
+		var current model.Inventory
+		DB.First(&current, item.ID)
+		return &current
+	}
+
+	ginkgo.BeforeEach(func() {
+		for _, table := range []string{"inventory_tags", "tags", "brands", "inventories", "categories"} {
+			DB.Exec("DELETE FROM " + table)
+		}
+		items = nil
+		for _, name := range []string{"Shirt", "Jeans", "Socks"} {
+			item := &model.Inventory{Name: name, Price: 10}
+			DB.Create(item)
+			items = append(items, item)
+		}
+	})
+
+	ginkgo.It("should nest categories and keep sibling names unique", func() {
+		clothing, err := catalogService.CreateCategory(nil, CategoryInput{Name: "Clothing"})
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(clothing.Path).To(gomega.Equal(fmt.Sprintf("/%d/", clothing.ID)))
+
+		tops, err := catalogService.CreateCategory(&clothing.ID, CategoryInput{Name: "Tops"})
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(tops.Path).To(gomega.Equal(fmt.Sprintf("/%d/%d/", clothing.ID, tops.ID)))
+		gomega.Expect(tops.Depth).To(gomega.Equal(1))
+
+		_, err = catalogService.CreateCategory(&clothing.ID, CategoryInput{Name: "tops"})
+		gomega.Expect(err).To(gomega.Equal(ErrDuplicateName))
+		_, err = catalogService.CreateCategory(nil, CategoryInput{Name: "Tops"})
+		gomega.Expect(err).To(gomega.BeNil())
+
+		missing := uint(9999)
+		_, err = catalogService.CreateCategory(&missing, CategoryInput{Name: "Orphan"})
+		gomega.Expect(err).To(gomega.Equal(ErrCategoryNotFound))
+	})
+
+	ginkgo.It("should move a category with its subtree", func() {
+		clothing, _ := catalogService.CreateCategory(nil, CategoryInput{Name: "Clothing"})
+		sale, _ := catalogService.CreateCategory(nil, CategoryInput{Name: "Sale"})
+		tops, _ := catalogService.CreateCategory(&clothing.ID, CategoryInput{Name: "Tops"})
+		shirts, _ := catalogService.CreateCategory(&tops.ID, CategoryInput{Name: "Shirts"})
+
+		_, err := catalogService.MoveCategory(clothing.ID, &shirts.ID)
+		gomega.Expect(err).To(gomega.Equal(ErrCategoryCycle))
+
+		moved, err := catalogService.MoveCategory(tops.ID, &sale.ID)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(moved.Path).To(gomega.Equal(fmt.Sprintf("/%d/%d/", sale.ID, tops.ID)))
+
+		var child model.Category
+		DB.First(&child, shirts.ID)
+		gomega.Expect(child.Path).To(gomega.Equal(fmt.Sprintf("/%d/%d/%d/", sale.ID, tops.ID, shirts.ID)))
+		gomega.Expect(child.Depth).To(gomega.Equal(2))
+
+		moved, err = catalogService.MoveCategory(tops.ID, nil)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(moved.Depth).To(gomega.BeZero())
+		DB.First(&child, shirts.ID)
+		gomega.Expect(child.Path).To(gomega.Equal(fmt.Sprintf("/%d/%d/", tops.ID, shirts.ID)))
+	})
+
+	ginkgo.It("should assign items in bulk and count them in the tree", func() {
+		clothing, _ := catalogService.CreateCategory(nil, CategoryInput{Name: "Clothing"})
+		tops, _ := catalogService.CreateCategory(&clothing.ID, CategoryInput{Name: "Tops"})
+
+		err := catalogService.AssignCategory([]uint{items[0].ID, items[1].ID}, &tops.ID)
+		gomega.Expect(err).To(gomega.BeNil())
+		err = catalogService.AssignCategory([]uint{items[2].ID}, &clothing.ID)
+		gomega.Expect(err).To(gomega.BeNil())
+		err = catalogService.AssignCategory([]uint{items[2].ID, 9999}, &tops.ID)
+		gomega.Expect(err).To(gomega.MatchError(ErrInventoryNotFound))
+
+		tree, err := catalogService.CategoryTree()
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(tree).To(gomega.HaveLen(1))
+		gomega.Expect(tree[0].Items).To(gomega.Equal(int64(1)))
+		gomega.Expect(tree[0].Total).To(gomega.Equal(int64(3)))
+		gomega.Expect(tree[0].Children[0].Category.Name).To(gomega.Equal("Tops"))
+
+		gomega.Expect(catalogService.DeleteCategory(clothing.ID)).To(gomega.Equal(ErrCategoryHasChildren))
+		gomega.Expect(catalogService.DeleteCategory(tops.ID)).To(gomega.Succeed())
+		gomega.Expect(*reload(items[0]).CategoryID).To(gomega.Equal(clothing.ID))
+	})
+
+	ginkgo.It("should inherit tax class and reorder levels from the nearest category", func() {
+		point, quantity, override, lead := 10.0, 50.0, 3.0, 14
+		clothing, _ := catalogService.CreateCategory(nil, CategoryInput{Name: "Clothing", TaxClass: "standard", ReorderPoint: &point, LeadTimeDays: &lead})
+		kids, _ := catalogService.CreateCategory(&clothing.ID, CategoryInput{Name: "Kids", TaxClass: "reduced", ReorderQuantity: &quantity})
+
+		catalogService.AssignCategory([]uint{items[0].ID, items[1].ID}, &kids.ID)
+		DB.Model(items[1]).Update("reorder_point", override)
+
+		defaults, err := catalogService.ItemDefaults(items[0].ID)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(defaults.TaxClass).To(gomega.Equal("reduced"))
+		gomega.Expect(*defaults.ReorderPoint).To(gomega.Equal(10.0))
+		gomega.Expect(*defaults.ReorderQuantity).To(gomega.Equal(50.0))
+		gomega.Expect(*defaults.LeadTimeDays).To(gomega.Equal(14))
+
+		defaults, err = catalogService.ItemDefaults(items[1].ID)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(*defaults.ReorderPoint).To(gomega.Equal(3.0))
+
+		defaults, err = catalogService.ItemDefaults(items[2].ID)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(defaults.TaxClass).To(gomega.BeEmpty())
+		gomega.Expect(defaults.ReorderPoint).To(gomega.BeNil())
+
+		low, high := 8.0, 2.0
+		_, err = catalogService.CreateCategory(nil, CategoryInput{Name: "Shoes", MinLevel: &low, MaxLevel: &high})
+		gomega.Expect(err).To(gomega.Equal(ErrInvalidReorderLevel))
+	})
+
+	ginkgo.It("should manage brands and tags", func() {
+		brand, err := catalogService.CreateBrand("Acme")
+		gomega.Expect(err).To(gomega.BeNil())
+		_, err = catalogService.CreateBrand(" acme ")
+		gomega.Expect(err).To(gomega.Equal(ErrDuplicateName))
+
+		gomega.Expect(catalogService.AssignBrand([]uint{items[0].ID, items[1].ID}, &brand.ID)).To(gomega.Succeed())
+		gomega.Expect(*reload(items[1]).BrandID).To(gomega.Equal(brand.ID))
+		gomega.Expect(catalogService.DeleteBrand(brand.ID)).To(gomega.Succeed())
+		gomega.Expect(reload(items[1]).BrandID).To(gomega.BeNil())
+
+		summer, err := catalogService.CreateTag("Summer")
+		gomega.Expect(err).To(gomega.BeNil())
+		organic, _ := catalogService.CreateTag("Organic")
+		gomega.Expect(catalogService.TagItems([]uint{items[0].ID, items[2].ID}, summer.ID)).To(gomega.Succeed())
+		gomega.Expect(catalogService.TagItems([]uint{items[0].ID}, summer.ID)).To(gomega.Succeed())
+		gomega.Expect(catalogService.TagItems([]uint{items[0].ID}, organic.ID)).To(gomega.Succeed())
+
+		tags, err := catalogService.ItemTags(items[0].ID)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(tags).To(gomega.HaveLen(2))
+		gomega.Expect(tags[0].Name).To(gomega.Equal("Organic"))
+
+		gomega.Expect(catalogService.UntagItems([]uint{items[0].ID}, summer.ID)).To(gomega.Succeed())
+		gomega.Expect(catalogService.DeleteTag(organic.ID)).To(gomega.Succeed())
+		tags, _ = catalogService.ItemTags(items[0].ID)
+		gomega.Expect(tags).To(gomega.BeEmpty())
+		tags, _ = catalogService.ItemTags(items[2].ID)
+		gomega.Expect(tags).To(gomega.HaveLen(1))
+	})
+})
@@ -0,0 +1,289 @@
+package company_service
+
+import (
+	repository "blizzflow/backend/domain/repositories"
+	backup_service "blizzflow/backend/domain/services/backup"
+	"blizzflow/backend/events"
+	"blizzflow/backend/infrastructure/database"
+	"blizzflow/backend/infrastructure/datadir"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// Custom errors
+var (
+	ErrDatabaseOperation = fmt.Errorf("database operation failed")
+	ErrInvalidSettingKey = fmt.Errorf("invalid setting key")
+)
+
+// catalogTables are copied when a company is created from a template. They
+// describe what the shop sells and where it keeps it; sales, sessions and
+// users are not copied. Locations come before the settings naming the
+// current one.
+var catalogTables = []string{
+	"locations",
+	"units",
+	"categories",
+	"brands",
+	"tags",
+	"inventories",
+	"item_units",
+	"barcodes",
+	"inventory_tags",
+	"variant_attributes",
+	"variant_options",
+	"variant_values",
+	"settings",
+	"adjustment_reasons",
+	"suppliers",
+	"supplier_items",
+}
+
+// stockColumns are reset after copying, as stock levels are the result of
+// transactions that stay with the template company.
+var stockColumns = map[string][]string{
+	"inventories": {"quantity", "average_cost"},
+}
+
+// SwitchFunc is called after the open company changed.
+type SwitchFunc func(company datadir.Company, opts database.Options)
+
+type CompanyService struct {
+	mu          sync.Mutex
+	store       *database.Store
+	registry    *datadir.Registry
+	lock        *datadir.Lock
+	baseOptions database.Options
+	sessionRepo repository.SessionRepo
+	settingRepo repository.SettingRepo
+	dispatcher  *events.Dispatcher
+	onSwitch    []SwitchFunc
+}
+
+// NewCompanyService manages the companies of registry. store must have the
+// current company open and lock must be held on its database; baseOptions
+// carries the connection tuning used for every company.
+func NewCompanyService(
+	store *database.Store,
+	registry *datadir.Registry,
+	lock *datadir.Lock,
+	baseOptions database.Options,
+	sessionRepo repository.SessionRepo,
+	settingRepo repository.SettingRepo,
+	dispatcher *events.Dispatcher,
+) *CompanyService {
+	return &CompanyService{
+		store:       store,
+		registry:    registry,
+		lock:        lock,
+		baseOptions: baseOptions,
+		sessionRepo: sessionRepo,
+		settingRepo: settingRepo,
+		dispatcher:  dispatcher,
+	}
+}
+
+// OnSwitch registers fn to run after every company switch, e.g. to point the
+// license handler and backups at the new company.
+func (s *CompanyService) OnSwitch(fn SwitchFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onSwitch = append(s.onSwitch, fn)
+}
+
+func (s *CompanyService) ListCompanies() []datadir.Company {
+	return s.registry.List()
+}
+
+func (s *CompanyService) CurrentCompany() datadir.Company {
+	return s.registry.CurrentCompany()
+}
+
+// SwitchCompany closes every session and reopens the store on the database of
+// company id. The passphrase is only needed for a passphrase-encrypted
+// database. On failure the current company stays open.
+func (s *CompanyService) SwitchCompany(id, passphrase string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if id == s.registry.CurrentCompany().ID {
+		return nil
+	}
+	company, err := s.registry.Get(id)
+	if err != nil {
+		return err
+	}
+
+	path := s.registry.DataPath(company)
+	if err := datadir.Ensure(filepath.Dir(path)); err != nil {
+		return err
+	}
+	lock, err := datadir.AcquireLock(path)
+	if err != nil {
+		return err
+	}
+	if _, err := backup_service.ApplyPendingRestore(path); err != nil {
+		lock.Release()
+		return fmt.Errorf("failed to apply restored backup: %w", err)
+	}
+	key, err := database.UnlockKey(path, passphrase)
+	if err != nil {
+		lock.Release()
+		return err
+	}
+
+	if err := s.sessionRepo.DeleteAllSessions(); err != nil {
+		lock.Release()
+		return fmt.Errorf("failed to close sessions: %w", ErrDatabaseOperation)
+	}
+
+	opts := s.baseOptions
+	opts.Path = path
+	opts.Key = key
+	if err := s.store.Reopen(opts); err != nil {
+		lock.Release()
+		return err
+	}
+	s.lock.Release()
+	s.lock = lock
+
+	if err := s.registry.SetCurrent(id); err != nil {
+		return err
+	}
+	for _, fn := range s.onSwitch {
+		fn(company, opts)
+	}
+	s.dispatcher.Emit(events.CompanySwitched, company)
+	return nil
+}
+
+// CreateCompany registers a new company with an empty, migrated database. If
+// templateID is set, the catalog of that company is copied into it; the
+// passphrase unlocks the template when it is encrypted.
+func (s *CompanyService) CreateCompany(name, templateID, passphrase string) (*datadir.Company, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	company, err := s.registry.Add(name)
+	if err != nil {
+		return nil, err
+	}
+	path := s.registry.DataPath(company)
+
+	opts := s.baseOptions
+	opts.Path = path
+	opts.Key = nil
+	if err := s.initCompany(opts, templateID, passphrase); err != nil {
+		s.registry.Remove(company.ID)
+		os.RemoveAll(filepath.Dir(path))
+		return nil, err
+	}
+	return &company, nil
+}
+
+// Close releases the lock on the open company's database.
+func (s *CompanyService) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lock.Release()
+}
+
+// GetSettings returns the settings of the open company.
+func (s *CompanyService) GetSettings() (map[string]string, error) {
+	settings, err := s.settingRepo.All()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load settings: %w", ErrDatabaseOperation)
+	}
+
+	values := make(map[string]string, len(settings))
+	for _, setting := range settings {
+		values[setting.Key] = setting.Value
+	}
+	return values, nil
+}
+
+// UpdateSetting stores a setting of the open company.
+func (s *CompanyService) UpdateSetting(key, value string) error {
+	if key == "" {
+		return ErrInvalidSettingKey
+	}
+	if err := s.settingRepo.Set(key, value); err != nil {
+		return fmt.Errorf("failed to save setting: %w", ErrDatabaseOperation)
+	}
+	return nil
+}
+
+func (s *CompanyService) initCompany(opts database.Options, templateID, passphrase string) error {
+	if err := datadir.Ensure(filepath.Dir(opts.Path)); err != nil {
+		return err
+	}
+	store, err := database.Open(opts)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	if templateID == "" {
+		return nil
+	}
+
+	// The open company is read through the live store; any other template is
+	// opened on the side while holding its lock
+	if templateID == s.registry.CurrentCompany().ID {
+		return copyCatalog(s.store.DB(), store.DB())
+	}
+	template, err := s.registry.Get(templateID)
+	if err != nil {
+		return err
+	}
+	templatePath := s.registry.DataPath(template)
+	lock, err := datadir.AcquireLock(templatePath)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	key, err := database.UnlockKey(templatePath, passphrase)
+	if err != nil {
+		return err
+	}
+	templateStore, err := database.Open(database.Options{Path: templatePath, Key: key})
+	if err != nil {
+		return err
+	}
+	defer templateStore.Close()
+	return copyCatalog(templateStore.DB(), store.DB())
+}
+
+// copyCatalog copies the catalog tables row by row. Both databases are on the
+// latest schema, so the columns match. Rows the migrations seeded into the new
+// database are replaced by the template's.
+func copyCatalog(src, dst *gorm.DB) error {
+	return dst.Transaction(func(tx *gorm.DB) error {
+		for _, table := range catalogTables {
+			var rows []map[string]interface{}
+			if err := src.Table(table).Find(&rows).Error; err != nil {
+				return fmt.Errorf("failed to read %s: %w", table, err)
+			}
+			if len(rows) == 0 {
+				continue
+			}
+			if err := tx.Exec("DELETE FROM " + table).Error; err != nil {
+				return fmt.Errorf("failed to clear %s: %w", table, err)
+			}
+			if err := tx.Table(table).CreateInBatches(rows, 100).Error; err != nil {
+				return fmt.Errorf("failed to copy %s: %w", table, err)
+			}
+			for _, column := range stockColumns[table] {
+				if err := tx.Table(table).Where("1 = 1").Update(column, 0).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,154 @@
+package company_service
+
+import (
+	"blizzflow/backend/domain/model"
+	repository "blizzflow/backend/domain/repositories"
+	"blizzflow/backend/events"
+	"blizzflow/backend/infrastructure/database"
+	"blizzflow/backend/infrastructure/datadir"
+	"fmt"
+	"testing"
+
+	"github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+	"gorm.io/gorm"
+)
+
+func TestCompanyServiceSuite(t *testing.T) {
+	gomega.RegisterFailHandler(ginkgo.Fail)
+	ginkgo.RunSpecs(t, "Company Service Test Suite")
+}
+
+var _ = ginkgo.Describe("Company Service", func() {
+	var (
+		DB             *gorm.DB
+		store          *database.Store
+		registry       *datadir.Registry
+		companyService *CompanyService
+		emitted        []string
+	)
+
+	ginkgo.BeforeEach(func() {
+		dataDir := ginkgo.GinkgoT().TempDir()
+
+		var err error
+		registry, err = datadir.LoadRegistry(dataDir, "")
+		gomega.Expect(err).To(gomega.BeNil())
+
+		path := registry.DataPath(registry.CurrentCompany())
+		lock, err := datadir.AcquireLock(path)
+		gomega.Expect(err).To(gomega.BeNil())
+
+		store, err = database.Open(database.DefaultOptions(path))
+		gomega.Expect(err).To(gomega.BeNil())
+		DB = store.DB()
+
+		emitted = nil
+		dispatcher := events.NewDispatcher()
+		dispatcher.Bind(func(name string, data ...any) {
+			emitted = append(emitted, name)
+		})
+
+		companyService = NewCompanyService(
+			store,
+			registry,
+			lock,
+			database.DefaultOptions(""),
+			repository.NewSessionRepository(DB),
+			repository.NewSettingRepository(DB),
+			dispatcher,
+		)
+	})
+
+	ginkgo.AfterEach(func() {
+		store.Close()
+		companyService.Close()
+	})
+
+	ginkgo.It("should register the existing database as the default company", func() {
+		companies := companyService.ListCompanies()
+		gomega.Expect(companies).To(gomega.HaveLen(1))
+		gomega.Expect(companyService.CurrentCompany().ID).To(gomega.Equal(datadir.DefaultCompanyID))
+	})
+
+	ginkgo.It("should reject a duplicate company name", func() {
+		_, err := companyService.CreateCompany("Corner Shop", "", "")
+		gomega.Expect(err).To(gomega.BeNil())
+
+		_, err = companyService.CreateCompany("corner shop", "", "")
+		gomega.Expect(err).To(gomega.Equal(datadir.ErrCompanyExists))
+	})
+
+	ginkgo.It("should copy the catalog but no transactions from a template", func() {
+		item := &model.Inventory{Name: "Rice 5kg", Quantity: 40, Price: 12.5}
+		gomega.Expect(DB.Create(item).Error).To(gomega.Succeed())
+		gomega.Expect(DB.Create(&model.Sale{InventoryID: item.ID, Quantity: 2, TotalPrice: 25}).Error).To(gomega.Succeed())
+		gomega.Expect(companyService.UpdateSetting("currency", "LKR")).To(gomega.Succeed())
+		backRoom := &model.Location{Name: "Back room"}
+		gomega.Expect(DB.Create(backRoom).Error).To(gomega.Succeed())
+		gomega.Expect(DB.Create(&model.Setting{Key: "stock.location", Value: fmt.Sprint(backRoom.ID)}).Error).To(gomega.Succeed())
+
+		company, err := companyService.CreateCompany("Second Shop", datadir.DefaultCompanyID, "")
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(companyService.SwitchCompany(company.ID, "")).To(gomega.Succeed())
+
+		var copied model.Inventory
+		gomega.Expect(DB.First(&copied, item.ID).Error).To(gomega.Succeed())
+		gomega.Expect(copied.Name).To(gomega.Equal("Rice 5kg"))
+		gomega.Expect(copied.Quantity).To(gomega.BeZero())
+
+		var sales int64
+		DB.Model(&model.Sale{}).Count(&sales)
+		gomega.Expect(sales).To(gomega.BeZero())
+
+		settings, err := companyService.GetSettings()
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(settings["currency"]).To(gomega.Equal("LKR"))
+
+		// The current location setting still names a location
+		var location model.Location
+		gomega.Expect(DB.First(&location, backRoom.ID).Error).To(gomega.Succeed())
+		gomega.Expect(location.Name).To(gomega.Equal("Back room"))
+		gomega.Expect(settings["stock.location"]).To(gomega.Equal(fmt.Sprint(backRoom.ID)))
+	})
+
+	ginkgo.It("should close sessions and reopen the store when switching", func() {
+		gomega.Expect(DB.Create(&model.Session{UserID: 1}).Error).To(gomega.Succeed())
+		gomega.Expect(companyService.UpdateSetting("receipt_header", "Main Street")).To(gomega.Succeed())
+
+		company, err := companyService.CreateCompany("Branch", "", "")
+		gomega.Expect(err).To(gomega.BeNil())
+
+		var switched datadir.Company
+		companyService.OnSwitch(func(c datadir.Company, opts database.Options) {
+			switched = c
+		})
+		gomega.Expect(companyService.SwitchCompany(company.ID, "")).To(gomega.Succeed())
+
+		gomega.Expect(switched.ID).To(gomega.Equal(company.ID))
+		gomega.Expect(emitted).To(gomega.ContainElement(events.CompanySwitched))
+		gomega.Expect(store.Path()).To(gomega.Equal(registry.DataPath(*company)))
+		gomega.Expect(companyService.CurrentCompany().ID).To(gomega.Equal(company.ID))
+
+		// Settings belong to the company that set them
+		settings, err := companyService.GetSettings()
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(settings).To(gomega.BeEmpty())
+
+		gomega.Expect(companyService.SwitchCompany(datadir.DefaultCompanyID, "")).To(gomega.Succeed())
+		var sessions int64
+		DB.Model(&model.Session{}).Count(&sessions)
+		gomega.Expect(sessions).To(gomega.BeZero())
+
+		settings, err = companyService.GetSettings()
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(settings["receipt_header"]).To(gomega.Equal("Main Street"))
+	})
+
+	ginkgo.It("should keep the current company when switching fails", func() {
+		err := companyService.SwitchCompany("missing", "")
+		gomega.Expect(err).To(gomega.MatchError(datadir.ErrCompanyNotFound))
+		gomega.Expect(companyService.CurrentCompany().ID).To(gomega.Equal(datadir.DefaultCompanyID))
+		gomega.Expect(DB.Exec("SELECT 1").Error).To(gomega.Succeed())
+	})
+})
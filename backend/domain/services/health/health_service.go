@@ -0,0 +1,101 @@
+package health_service
+
+import (
+	backup_service "blizzflow/backend/domain/services/backup"
+	"blizzflow/backend/events"
+	"blizzflow/backend/infrastructure/database"
+	"fmt"
+	"log"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// Custom errors
+var (
+	ErrDatabaseOperation = fmt.Errorf("database operation failed")
+)
+
+// BackupLister is the part of BackupService used to suggest a restore point.
+type BackupLister interface {
+	ListBackups() ([]backup_service.Backup, error)
+}
+
+// IntegrityReport is the result of a check plus what the owner should do.
+type IntegrityReport struct {
+	database.IntegrityResult
+	Guidance     []string `json:"guidance"`
+	LatestBackup string   `json:"latestBackup"`
+}
+
+type HealthService struct {
+	db         *gorm.DB
+	backups    BackupLister
+	dispatcher *events.Dispatcher
+	mu         sync.Mutex
+	last       *IntegrityReport
+}
+
+func NewHealthService(db *gorm.DB, backups BackupLister, dispatcher *events.Dispatcher) *HealthService {
+	return &HealthService{
+		db:         db,
+		backups:    backups,
+		dispatcher: dispatcher,
+	}
+}
+
+// CheckIntegrity runs a quick_check on the live database. When corruption is
+// found it emits database:corruption with recovery guidance.
+func (s *HealthService) CheckIntegrity() (*IntegrityReport, error) {
+	result, err := database.QuickCheck(s.db)
+	if err != nil {
+		// A badly damaged file can fail the check itself
+		result = &database.IntegrityResult{OK: false, Problems: []string{err.Error()}}
+	}
+
+	report := &IntegrityReport{IntegrityResult: *result}
+	if !report.OK {
+		report.LatestBackup = s.latestBackup()
+		report.Guidance = guidance(report.LatestBackup)
+
+		log.Printf("Database integrity check failed: %v", report.Problems)
+		s.dispatcher.Emit(events.DatabaseCorruption, report)
+	}
+
+	s.mu.Lock()
+	s.last = report
+	s.mu.Unlock()
+	return report, nil
+}
+
+// LastIntegrityCheck returns the most recent report, or nil before the first
+// check has run.
+func (s *HealthService) LastIntegrityCheck() *IntegrityReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.last
+}
+
+func (s *HealthService) latestBackup() string {
+	if s.backups == nil {
+		return ""
+	}
+	backups, err := s.backups.ListBackups()
+	if err != nil || len(backups) == 0 {
+		return ""
+	}
+	return backups[0].Name
+}
+
+func guidance(latestBackup string) []string {
+	steps := []string{
+		"Stop recording sales; changes made now may be lost.",
+		"Close blizzflow and copy the whole data folder to a USB drive before changing anything.",
+	}
+	if latestBackup != "" {
+		steps = append(steps, fmt.Sprintf("Restore the most recent backup (%s) from the backup screen and restart.", latestBackup))
+	} else {
+		steps = append(steps, "No backup is available. Contact support and keep the copied data folder.")
+	}
+	return append(steps, "If the problem comes back after restoring, have the computer's disk checked for errors.")
+}
@@ -0,0 +1,112 @@
+package health_service
+
+import (
+	backup_service "blizzflow/backend/domain/services/backup"
+	"blizzflow/backend/events"
+	"blizzflow/backend/infrastructure/database"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+	"gorm.io/gorm"
+)
+
+func TestHealthServiceSuite(t *testing.T) {
+	gomega.RegisterFailHandler(ginkgo.Fail)
+	ginkgo.RunSpecs(t, "Health Service Test Suite")
+}
+
+const (
+	testDBPath    = "test.db"
+	corruptDBPath = "corrupt.db"
+)
+
+var (
+	DB         *gorm.DB
+	dispatcher *events.Dispatcher
+	emitted    []string
+)
+
+type stubBackups struct{}
+
+func (stubBackups) ListBackups() ([]backup_service.Backup, error) {
+	return []backup_service.Backup{{Name: "blizzflow-daily-20240101T000000.db"}}, nil
+}
+
+var _ = ginkgo.BeforeSuite(func() {
+	os.Remove(testDBPath)
+	store, err := database.Open(database.DefaultOptions(testDBPath))
+	gomega.Expect(err).To(gomega.BeNil())
+	DB = store.DB()
+
+	dispatcher = events.NewDispatcher()
+	dispatcher.Bind(func(name string, data ...any) {
+		emitted = append(emitted, name)
+	})
+})
+
+var _ = ginkgo.AfterSuite(func() {
+	if DB != nil {
+		sqlDB, err := DB.DB()
+		if err == nil {
+			sqlDB.Close()
+		}
+	}
+	os.Remove(testDBPath)
+	os.Remove(corruptDBPath)
+})
+
+var _ = ginkgo.Describe("Health Service", func() {
+	ginkgo.BeforeEach(func() {
+		emitted = nil
+	})
+
+	ginkgo.It("should report a healthy database", func() {
+		service := NewHealthService(DB, stubBackups{}, dispatcher)
+
+		report, err := service.CheckIntegrity()
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(report.OK).To(gomega.BeTrue())
+		gomega.Expect(emitted).To(gomega.BeEmpty())
+		gomega.Expect(service.LastIntegrityCheck()).To(gomega.Equal(report))
+	})
+
+	ginkgo.It("should alert and suggest a restore when the file is corrupted", func() {
+		os.Remove(corruptDBPath)
+		db, err := database.OpenFile(database.Options{Path: corruptDBPath})
+		gomega.Expect(err).To(gomega.BeNil())
+		db.Exec("CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT)")
+		for i := 0; i < 500; i++ {
+			db.Exec("INSERT INTO items (name) VALUES (?)", fmt.Sprintf("item number %d with some padding", i))
+		}
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+
+		// Overwrite the middle of the file, past the header and schema pages
+		f, err := os.OpenFile(corruptDBPath, os.O_WRONLY, 0644)
+		gomega.Expect(err).To(gomega.BeNil())
+		garbage := make([]byte, 4096)
+		for i := range garbage {
+			garbage[i] = 0xAB
+		}
+		f.WriteAt(garbage, 3*4096)
+		f.Close()
+
+		db, err = database.OpenFile(database.Options{Path: corruptDBPath})
+		gomega.Expect(err).To(gomega.BeNil())
+		defer func() {
+			sqlDB, _ := db.DB()
+			sqlDB.Close()
+		}()
+
+		report, err := NewHealthService(db, stubBackups{}, dispatcher).CheckIntegrity()
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(report.OK).To(gomega.BeFalse())
+		gomega.Expect(report.Problems).NotTo(gomega.BeEmpty())
+		gomega.Expect(report.LatestBackup).To(gomega.Equal("blizzflow-daily-20240101T000000.db"))
+		gomega.Expect(report.Guidance).NotTo(gomega.BeEmpty())
+		gomega.Expect(emitted).To(gomega.ConsistOf(events.DatabaseCorruption))
+	})
+})
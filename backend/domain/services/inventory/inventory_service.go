@@ -3,44 +3,936 @@ package services
 import (
 	"blizzflow/backend/domain/model"
 	repository "blizzflow/backend/domain/repositories"
+	catalog_service "blizzflow/backend/domain/services/catalog"
+	stock_service "blizzflow/backend/domain/services/stock"
+	"blizzflow/backend/internal/barcode"
+	"errors"
 	"fmt"
+	"math"
+	"strings"
+	"time"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 500
+
+	// LowStockLevel is the quantity at or below which an item in stock counts
+	// as running low
+	LowStockLevel = 5
+
+	maxNameLength = 200
+	maxCodeLength = 64
+
+	// maxWarrantyMonths bounds the warranty of serialized items
+	maxWarrantyMonths = 120
+
+	// internalCodeAttempts bounds the search for a free internal barcode
+	internalCodeAttempts = 10
 )
 
+// Stock states accepted by ListQuery.Stock
+const (
+	StockAll = ""
+	StockIn  = "in_stock"
+	StockLow = "low_stock"
+	StockOut = "out_of_stock"
+)
+
+// sortColumns maps the sort keys the frontend sends to columns
+var sortColumns = map[string]string{
+	"":          "name COLLATE NOCASE",
+	"name":      "name COLLATE NOCASE",
+	"sku":       "sku COLLATE NOCASE",
+	"category":  "(SELECT name FROM categories WHERE categories.id = category_id) COLLATE NOCASE",
+	"brand":     "(SELECT name FROM brands WHERE brands.id = brand_id) COLLATE NOCASE",
+	"quantity":  repository.InventoryOnHand,
+	"price":     "price",
+	"createdAt": "created_at",
+	"updatedAt": "updated_at",
+}
+
+// kilograms holds the mass base units scale labels can weigh items in, as
+// kilograms per unit
+var kilograms = map[string]float64{"kg": 1, "g": 0.001}
+
 // Custom errors
 var (
 	ErrInvalidInventoryName = fmt.Errorf("invalid inventory name")
-	ErrInvalidQuantity      = fmt.Errorf("invalid quantity")
+	ErrInvalidQuantity      = stock_service.ErrInvalidQuantity
+	ErrInvalidUnit          = fmt.Errorf("invalid unit")
+	ErrUnitNotFound         = stock_service.ErrUnitNotFound
+	ErrUnitInUse            = fmt.Errorf("unit is in use")
 	ErrInvalidPrice         = fmt.Errorf("invalid price")
+	ErrInvalidCost          = stock_service.ErrInvalidCost
+	ErrInvalidSKU           = fmt.Errorf("invalid SKU")
+	ErrInvalidBarcode       = fmt.Errorf("invalid barcode")
+	ErrNotWeighed           = fmt.Errorf("item is not stocked by weight")
+	ErrInvalidTaxClass      = fmt.Errorf("invalid tax class")
+	ErrInvalidReorderLevel  = fmt.Errorf("invalid reorder level")
+	ErrCategoryNotFound     = fmt.Errorf("category not found")
+	ErrLocationNotFound     = stock_service.ErrLocationNotFound
+	ErrBrandNotFound        = fmt.Errorf("brand not found")
+	ErrDuplicateSKU         = fmt.Errorf("SKU already in use")
+	ErrDuplicateBarcode     = fmt.Errorf("barcode already in use")
+	ErrBarcodeNotFound      = fmt.Errorf("barcode not found")
+	ErrLastBarcode          = fmt.Errorf("an item needs at least one barcode")
+	ErrInvalidQuery         = fmt.Errorf("invalid inventory query")
 	ErrInventoryNotFound    = fmt.Errorf("inventory not found")
+	ErrInventoryInUse       = fmt.Errorf("inventory has sales; archive it instead")
+	ErrInventoryOrdered     = fmt.Errorf("inventory is on purchase orders; archive it instead")
+	ErrInventoryStocked     = fmt.Errorf("inventory has stock history; archive it instead")
+	ErrLotsInStock          = fmt.Errorf("item still has stock in lots")
+	ErrInvalidTracking      = fmt.Errorf("items are tracked by lot or by serial number, not both")
+	ErrSerialsInStock       = fmt.Errorf("item has stock; serial tracking can only change without")
+	ErrInvalidWarranty      = fmt.Errorf("invalid warranty")
+	ErrInvalidAttribute     = fmt.Errorf("invalid variant attribute")
+	ErrInvalidVariant       = fmt.Errorf("no such variant")
+	ErrNestedVariant        = fmt.Errorf("a variant cannot have variants")
+	ErrParentHasStock       = fmt.Errorf("item has stock; book it out before adding variants")
+	ErrVariantsExist        = fmt.Errorf("item has variants; new attributes cannot be added")
+	ErrTooManyVariants      = fmt.Errorf("too many variants")
+	ErrHasVariants          = fmt.Errorf("item has variants; delete them first")
 	ErrDatabaseOperation    = fmt.Errorf("database operation failed")
 )
 
+// InventoryInput holds the editable fields of an item. Barcode is the
+// primary barcode; more are added with AddBarcode. Quantity is the opening
+// stock of a new item, in its base unit; later changes go through
+// StockService. Unit is the code of the base unit, pieces if empty. An empty
+// tax class or nil reorder level is inherited from the category. An item is
+// tracked by lot or by serial number, or neither; WarrantyMonths applies
+// to serialized items.
+type InventoryInput struct {
+	Name            string   `json:"name"`
+	SKU             string   `json:"sku"`
+	Barcode         string   `json:"barcode"`
+	CategoryID      *uint    `json:"categoryId"`
+	BrandID         *uint    `json:"brandId"`
+	Unit            string   `json:"unit"`
+	Quantity        float64  `json:"quantity"`
+	Price           float64  `json:"price"`
+	StandardCost    float64  `json:"standardCost"`
+	TrackLots       bool     `json:"trackLots"`
+	TrackSerials    bool     `json:"trackSerials"`
+	WarrantyMonths  int      `json:"warrantyMonths"`
+	TaxClass        string   `json:"taxClass"`
+	ReorderPoint    *float64 `json:"reorderPoint"`
+	ReorderQuantity *float64 `json:"reorderQuantity"`
+	MinLevel        *float64 `json:"minLevel"`
+	MaxLevel        *float64 `json:"maxLevel"`
+	LeadTimeDays    *int     `json:"leadTimeDays"`
+}
+
+// ListQuery selects a page of items. Page numbers start at 1. A category
+// also selects the items of its subcategories. Listings hold parents rather
+// than their variants, unless Sellable asks for what can be sold. A
+// location makes the stock filters, the quantity sort and the quantities
+// listed those at the location; nil is the total.
+type ListQuery struct {
+	Sellable        bool     `json:"sellable"`
+	LocationID      *uint    `json:"locationId"`
+	Page            int      `json:"page"`
+	PageSize        int      `json:"pageSize"`
+	Search          string   `json:"search"`
+	CategoryID      *uint    `json:"categoryId"`
+	BrandID         *uint    `json:"brandId"`
+	TagID           *uint    `json:"tagId"`
+	Stock           string   `json:"stock"`
+	MinPrice        *float64 `json:"minPrice"`
+	MaxPrice        *float64 `json:"maxPrice"`
+	SortBy          string   `json:"sortBy"`
+	SortDesc        bool     `json:"sortDesc"`
+	IncludeArchived bool     `json:"includeArchived"`
+}
+
+// BarcodeMatch is the result of a scan.
+type BarcodeMatch struct {
+	Inventory *model.Inventory `json:"inventory"`
+	Barcode   model.Barcode    `json:"barcode"`
+	// Unit is the pack the code stands for, if any
+	Unit *model.ItemUnit `json:"unit,omitempty"`
+	// Quantity of the scanned line in base units: the pack size, the
+	// embedded weight, the embedded price over the unit price or one
+	Quantity float64 `json:"quantity"`
+	// Measure is set for variable-measure codes from scales
+	Measure barcode.Measure `json:"measure,omitempty"`
+	// Weight in kilograms, for weight-embedded codes
+	Weight *float64 `json:"weight,omitempty"`
+	// Price of the scanned line: the embedded price, or the unit price
+	// times the quantity
+	Price float64 `json:"price"`
+}
+
+// InventoryItem is an item in a listing. A parent carries the totals of its
+// variants, and its quantity is theirs. Stock is what it holds per
+// location.
+type InventoryItem struct {
+	model.Inventory
+	Variants *repository.VariantSummary    `json:"variants,omitempty"`
+	Stock    []repository.LocationQuantity `json:"stock"`
+}
+
+// InventoryPage is one page of a listing.
+type InventoryPage struct {
+	Items    []InventoryItem `json:"items"`
+	Total    int64           `json:"total"`
+	Page     int             `json:"page"`
+	PageSize int             `json:"pageSize"`
+	Pages    int             `json:"pages"`
+}
+
 type InventoryService struct {
-	inventoryRepo *repository.InventoryRepository
+	inventoryRepo repository.InventoryRepo
+	barcodeRepo   repository.BarcodeRepo
+	categoryRepo  repository.CategoryRepo
+	uow           repository.UnitOfWork
 }
 
-func NewInventoryService(repo *repository.InventoryRepository) *InventoryService {
-	return &InventoryService{inventoryRepo: repo}
+func NewInventoryService(
+	repo repository.InventoryRepo,
+	barcodeRepo repository.BarcodeRepo,
+	categoryRepo repository.CategoryRepo,
+	uow repository.UnitOfWork,
+) *InventoryService {
+	return &InventoryService{inventoryRepo: repo, barcodeRepo: barcodeRepo, categoryRepo: categoryRepo, uow: uow}
 }
 
-func (s *InventoryService) CreateInventory(name string, quantity int, price float64) (*model.Inventory, error) {
-	if name == "" {
-		return nil, ErrInvalidInventoryName
+func (s *InventoryService) CreateInventory(name string, quantity float64, price float64) (*model.Inventory, error) {
+	return s.AddInventory(InventoryInput{Name: name, Quantity: quantity, Price: price})
+}
+
+// AddInventory creates an item from every editable field. An item without a
+// barcode gets an internal one.
+func (s *InventoryService) AddInventory(input InventoryInput) (*model.Inventory, error) {
+	input, code, err := normalize(input)
+	if err != nil {
+		return nil, err
 	}
-	if quantity < 0 {
-		return nil, ErrInvalidQuantity
+
+	inventory := &model.Inventory{}
+	err = s.uow.Do(func(repos *repository.Repositories) error {
+		if err := checkSKU(repos, input.SKU, 0); err != nil {
+			return err
+		}
+		if err := checkTaxonomy(repos, input); err != nil {
+			return err
+		}
+		if err := checkUnit(repos, input.Unit); err != nil {
+			return err
+		}
+
+		apply(inventory, input)
+		if err := repos.Inventory.Create(inventory); err != nil {
+			return fmt.Errorf("failed to create inventory: %w", ErrDatabaseOperation)
+		}
+
+		if code.Value == "" {
+			if code, err = internalCode(repos, inventory.ID); err != nil {
+				return err
+			}
+		}
+		if _, err := addBarcode(repos, inventory, code, nil); err != nil {
+			return err
+		}
+
+		if input.Quantity == 0 {
+			return nil
+		}
+		movement := &model.StockMovement{
+			InventoryID: inventory.ID,
+			Type:        model.MovementOpening,
+			Quantity:    input.Quantity,
+			Cost:        input.Quantity * input.StandardCost,
+			Reason:      "opening balance",
+		}
+		err := stock_service.Record(repos, movement)
+		inventory.Quantity = movement.Balance
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
-	if price < 0 {
-		return nil, ErrInvalidPrice
+	return inventory, nil
+}
+
+func (s *InventoryService) GetInventory(id uint) (*model.Inventory, error) {
+	inventory, err := s.inventoryRepo.GetByID(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInventoryNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch inventory: %w", ErrDatabaseOperation)
 	}
+	return inventory, nil
+}
 
-	inventory := &model.Inventory{
-		Name:     name,
-		Quantity: quantity,
-		Price:    price,
+// UpdateInventory replaces the editable fields of an item, except its
+// quantity. A new barcode becomes the primary one and the old one is kept;
+// an empty one leaves the primary as it is. Variants selling at the
+// parent's price follow it, and under standard costing a new standard cost
+// revalues the stock on hand. Lot tracking turns off only once the lots are
+// empty, and serial tracking changes only while the item has no stock.
+func (s *InventoryService) UpdateInventory(id uint, input InventoryInput) (*model.Inventory, error) {
+	input, code, err := normalize(input)
+	if err != nil {
+		return nil, err
 	}
-	if err := s.inventoryRepo.Create(inventory); err != nil {
-		return nil, fmt.Errorf("failed to create inventory: %w", ErrDatabaseOperation)
+
+	var inventory *model.Inventory
+	err = s.uow.Do(func(repos *repository.Repositories) error {
+		if inventory, err = getInventory(repos, id); err != nil {
+			return err
+		}
+		if err := checkSKU(repos, input.SKU, id); err != nil {
+			return err
+		}
+		if err := checkTaxonomy(repos, input); err != nil {
+			return err
+		}
+		if input.Unit != inventory.Unit {
+			// The stock on hand and its history are counted in the old unit
+			if inventory.Quantity != 0 {
+				return ErrUnitInUse
+			}
+			if err := checkUnit(repos, input.Unit); err != nil {
+				return err
+			}
+		}
+
+		if inventory.Price != input.Price {
+			if err := repos.Inventory.RepriceVariants(id, inventory.Price, input.Price); err != nil {
+				return fmt.Errorf("failed to reprice variants: %w", ErrDatabaseOperation)
+			}
+		}
+		if err := stock_service.Revalue(repos, inventory, input.StandardCost); err != nil {
+			return err
+		}
+		if inventory.TrackLots && !input.TrackLots {
+			lots, err := repos.Lots.ListByInventory(id, nil, false)
+			if err != nil {
+				return fmt.Errorf("failed to list lots: %w", ErrDatabaseOperation)
+			}
+			if len(lots) > 0 {
+				return ErrLotsInStock
+			}
+		}
+		// Units on hand either all have serials or none do
+		if inventory.TrackSerials != input.TrackSerials && inventory.Quantity != 0 {
+			return ErrSerialsInStock
+		}
+		apply(inventory, input)
+		if code.Value != "" && code.Value != inventory.Barcode {
+			existing, err := repos.Barcodes.GetByCode(code.Value)
+			if err != nil {
+				return fmt.Errorf("failed to check barcode: %w", ErrDatabaseOperation)
+			}
+			if existing == nil {
+				if _, err := addBarcode(repos, inventory, code, nil); err != nil {
+					return err
+				}
+			} else if existing.InventoryID != id {
+				return ErrDuplicateBarcode
+			}
+			inventory.Barcode = code.Value
+		}
+
+		if err := repos.Inventory.Update(inventory); err != nil {
+			return fmt.Errorf("failed to update inventory: %w", ErrDatabaseOperation)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return inventory, nil
+}
+
+// ArchiveInventory hides an item from listings and sales. Its sales stay.
+// Archiving or restoring a parent does the same to its variants.
+func (s *InventoryService) ArchiveInventory(id uint) (*model.Inventory, error) {
+	return s.setArchived(id, true)
+}
+
+// RestoreInventory brings an archived item back.
+func (s *InventoryService) RestoreInventory(id uint) (*model.Inventory, error) {
+	return s.setArchived(id, false)
+}
+
+func (s *InventoryService) setArchived(id uint, archived bool) (*model.Inventory, error) {
+	var inventory *model.Inventory
+	err := s.uow.Do(func(repos *repository.Repositories) error {
+		var err error
+		if inventory, err = getInventory(repos, id); err != nil {
+			return err
+		}
+		if (inventory.ArchivedAt != nil) == archived {
+			return nil
+		}
+
+		inventory.ArchivedAt = nil
+		if archived {
+			now := time.Now()
+			inventory.ArchivedAt = &now
+		}
+		if err := repos.Inventory.Update(inventory); err != nil {
+			return fmt.Errorf("failed to update inventory: %w", ErrDatabaseOperation)
+		}
+		if err := repos.Inventory.ArchiveVariants(id, inventory.ArchivedAt); err != nil {
+			return fmt.Errorf("failed to update variants: %w", ErrDatabaseOperation)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 	return inventory, nil
 }
+
+// DeleteInventory removes an item that was never sold, ordered or stocked,
+// along with its barcodes. Other items can only be archived, so the
+// append-only stock ledger and reports keep adding up. A parent goes once
+// its variants are gone.
+func (s *InventoryService) DeleteInventory(id uint) error {
+	return s.uow.Do(func(repos *repository.Repositories) error {
+		if _, err := getInventory(repos, id); err != nil {
+			return err
+		}
+
+		sales, err := repos.Sales.CountByInventory(id)
+		if err != nil {
+			return fmt.Errorf("failed to count sales: %w", ErrDatabaseOperation)
+		}
+		if sales > 0 {
+			return ErrInventoryInUse
+		}
+		ordered, err := repos.PurchaseOrders.CountByInventory(id)
+		if err != nil {
+			return fmt.Errorf("failed to count purchase orders: %w", ErrDatabaseOperation)
+		}
+		if ordered > 0 {
+			return ErrInventoryOrdered
+		}
+		movements, err := repos.StockMovements.CountByInventory(id)
+		if err != nil {
+			return fmt.Errorf("failed to count stock movements: %w", ErrDatabaseOperation)
+		}
+		if movements > 0 {
+			return ErrInventoryStocked
+		}
+		variants, err := repos.Inventory.CountVariants(id)
+		if err != nil {
+			return fmt.Errorf("failed to count variants: %w", ErrDatabaseOperation)
+		}
+		if variants > 0 {
+			return ErrHasVariants
+		}
+
+		if err := repos.Barcodes.DeleteByInventory(id); err != nil {
+			return fmt.Errorf("failed to delete barcodes: %w", ErrDatabaseOperation)
+		}
+		if err := repos.Tags.DeleteByInventory(id); err != nil {
+			return fmt.Errorf("failed to delete tags: %w", ErrDatabaseOperation)
+		}
+		if err := repos.Suppliers.DeleteItemsByInventory(id); err != nil {
+			return fmt.Errorf("failed to delete supplier items: %w", ErrDatabaseOperation)
+		}
+		if err := repos.Lots.DeleteByInventory(id); err != nil {
+			return fmt.Errorf("failed to delete lots: %w", ErrDatabaseOperation)
+		}
+		if err := repos.Serials.DeleteByInventory(id); err != nil {
+			return fmt.Errorf("failed to delete serial numbers: %w", ErrDatabaseOperation)
+		}
+		if err := repos.Units.DeletePacksByInventory(id); err != nil {
+			return fmt.Errorf("failed to delete packs: %w", ErrDatabaseOperation)
+		}
+		if err := repos.Variants.DeleteValues(id); err != nil {
+			return fmt.Errorf("failed to delete variant options: %w", ErrDatabaseOperation)
+		}
+		if err := repos.Variants.DeleteByParent(id); err != nil {
+			return fmt.Errorf("failed to delete variant attributes: %w", ErrDatabaseOperation)
+		}
+		if err := repos.Inventory.Delete(id); err != nil {
+			return fmt.Errorf("failed to delete inventory: %w", ErrDatabaseOperation)
+		}
+		return nil
+	})
+}
+
+// ListBarcodes returns the barcodes of an item, oldest first.
+func (s *InventoryService) ListBarcodes(inventoryID uint) ([]model.Barcode, error) {
+	if _, err := s.GetInventory(inventoryID); err != nil {
+		return nil, err
+	}
+	barcodes, err := s.barcodeRepo.ListByInventory(inventoryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list barcodes: %w", ErrDatabaseOperation)
+	}
+	return barcodes, nil
+}
+
+// AddBarcode registers another code for an item. Variable-measure codes from
+// scales are registered without their weight or price.
+func (s *InventoryService) AddBarcode(inventoryID uint, code string) (*model.Barcode, error) {
+	parsed, err := parseBarcode(code)
+	if err != nil {
+		return nil, err
+	}
+
+	var created *model.Barcode
+	err = s.uow.Do(func(repos *repository.Repositories) error {
+		inventory, err := getInventory(repos, inventoryID)
+		if err != nil {
+			return err
+		}
+		created, err = addBarcode(repos, inventory, parsed, nil)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// RemoveBarcode removes one of an item's codes. When it was the primary
+// code, the oldest remaining one takes its place.
+func (s *InventoryService) RemoveBarcode(barcodeID uint) error {
+	return s.uow.Do(func(repos *repository.Repositories) error {
+		removed, err := repos.Barcodes.GetByID(barcodeID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrBarcodeNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to fetch barcode: %w", ErrDatabaseOperation)
+		}
+		barcodes, err := repos.Barcodes.ListByInventory(removed.InventoryID)
+		if err != nil {
+			return fmt.Errorf("failed to list barcodes: %w", ErrDatabaseOperation)
+		}
+		if len(barcodes) == 1 {
+			return ErrLastBarcode
+		}
+
+		if err := repos.Barcodes.Delete(removed.ID); err != nil {
+			return fmt.Errorf("failed to delete barcode: %w", ErrDatabaseOperation)
+		}
+
+		inventory, err := getInventory(repos, removed.InventoryID)
+		if err != nil {
+			return err
+		}
+		if inventory.Barcode != removed.Code {
+			return nil
+		}
+		for _, b := range barcodes {
+			if b.ID != removed.ID {
+				inventory.Barcode = b.Code
+				break
+			}
+		}
+		if err := repos.Inventory.Update(inventory); err != nil {
+			return fmt.Errorf("failed to update inventory: %w", ErrDatabaseOperation)
+		}
+		return nil
+	})
+}
+
+// LookupByBarcode finds the item a scanner read. Variable-measure codes are
+// matched by their template and their embedded price or weight is decoded
+// into a quantity in the item's base unit; weights only fit items stocked
+// by mass.
+func (s *InventoryService) LookupByBarcode(code string) (*BarcodeMatch, error) {
+	parsed, err := barcode.Parse(code)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", err, ErrInvalidBarcode)
+	}
+
+	key := parsed.Value
+	variable, isVariable := barcode.ParseVariable(parsed.Value)
+	if isVariable {
+		key = variable.Template
+	}
+
+	found, err := s.barcodeRepo.GetByCode(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up barcode: %w", ErrDatabaseOperation)
+	}
+	if found == nil {
+		return nil, ErrBarcodeNotFound
+	}
+	inventory, err := s.GetInventory(found.InventoryID)
+	if err != nil {
+		return nil, err
+	}
+
+	match := &BarcodeMatch{Inventory: inventory, Barcode: *found, Quantity: 1, Price: inventory.Price}
+	if found.UnitID != nil {
+		err := s.uow.Do(func(repos *repository.Repositories) error {
+			var err error
+			match.Unit, err = getPack(repos, *found.UnitID)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		match.Quantity = match.Unit.Factor
+		match.Price = math.Round(match.Unit.Factor*inventory.Price*100) / 100
+	}
+	if !isVariable {
+		return match, nil
+	}
+
+	var unit *model.Unit
+	err = s.uow.Do(func(repos *repository.Repositories) error {
+		var err error
+		if unit, err = repos.Units.Get(inventory.Unit); err != nil {
+			return fmt.Errorf("failed to fetch unit: %w", ErrDatabaseOperation)
+		}
+		if unit == nil {
+			return ErrUnitNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	match.Measure = variable.Measure
+	switch variable.Measure {
+	case barcode.MeasurePrice:
+		match.Price = float64(variable.Value) / 100
+		if inventory.Price > 0 {
+			match.Quantity = unit.Round(match.Price / inventory.Price)
+		}
+	case barcode.MeasureWeight:
+		perUnit, ok := kilograms[unit.Code]
+		if !ok {
+			return nil, ErrNotWeighed
+		}
+		weight := float64(variable.Value) / 1000
+		match.Weight = &weight
+		match.Quantity = unit.Round(weight / perUnit)
+		match.Price = math.Round(match.Quantity*inventory.Price*100) / 100
+	}
+	return match, nil
+}
+
+// ListInventory returns one page of items matching query.
+func (s *InventoryService) ListInventory(query ListQuery) (*InventoryPage, error) {
+	filter, err := s.toFilter(&query)
+	if err != nil {
+		return nil, err
+	}
+
+	found, total, err := s.inventoryRepo.List(filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list inventory: %w", ErrDatabaseOperation)
+	}
+	ids := make([]uint, len(found))
+	for i, item := range found {
+		ids[i] = item.ID
+	}
+	summaries, err := s.inventoryRepo.VariantSummaries(ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize variants: %w", ErrDatabaseOperation)
+	}
+	var stock []repository.LocationQuantity
+	err = s.uow.Do(func(repos *repository.Repositories) error {
+		if stock, err = repos.Locations.Quantities(ids); err != nil {
+			return fmt.Errorf("failed to total stock: %w", ErrDatabaseOperation)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]InventoryItem, len(found))
+	index := make(map[uint]int, len(found))
+	for i, item := range found {
+		index[item.ID] = i
+		items[i] = InventoryItem{Inventory: item, Stock: []repository.LocationQuantity{}}
+		if summary, ok := summaries[item.ID]; ok {
+			items[i].Variants = &summary
+			items[i].Quantity = summary.Quantity
+		}
+		if query.LocationID != nil {
+			items[i].Quantity = 0
+		}
+	}
+	for _, row := range stock {
+		item := &items[index[row.InventoryID]]
+		item.Stock = append(item.Stock, row)
+		if query.LocationID != nil && row.LocationID == *query.LocationID {
+			item.Quantity = row.Quantity
+		}
+	}
+	return &InventoryPage{
+		Items:    items,
+		Total:    total,
+		Page:     query.Page,
+		PageSize: query.PageSize,
+		Pages:    int((total + int64(query.PageSize) - 1) / int64(query.PageSize)),
+	}, nil
+}
+
+// toFilter validates query, fills in its defaults and translates it for the
+// repository.
+func (s *InventoryService) toFilter(query *ListQuery) (repository.InventoryFilter, error) {
+	if query.Page < 1 {
+		query.Page = 1
+	}
+	if query.PageSize <= 0 {
+		query.PageSize = DefaultPageSize
+	}
+	query.PageSize = min(query.PageSize, MaxPageSize)
+
+	column, ok := sortColumns[query.SortBy]
+	if !ok {
+		return repository.InventoryFilter{}, fmt.Errorf("sort by %q: %w", query.SortBy, ErrInvalidQuery)
+	}
+	if query.MinPrice != nil && query.MaxPrice != nil && *query.MinPrice > *query.MaxPrice {
+		return repository.InventoryFilter{}, fmt.Errorf("price range: %w", ErrInvalidQuery)
+	}
+	if query.LocationID != nil {
+		err := s.uow.Do(func(repos *repository.Repositories) error {
+			_, err := repos.Locations.GetByID(*query.LocationID)
+			return err
+		})
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return repository.InventoryFilter{}, ErrLocationNotFound
+		} else if err != nil {
+			return repository.InventoryFilter{}, fmt.Errorf("failed to fetch location: %w", ErrDatabaseOperation)
+		}
+		if column == repository.InventoryOnHand {
+			column = repository.InventoryOnHandAt(*query.LocationID)
+		}
+	}
+
+	filter := repository.InventoryFilter{
+		Sellable:        query.Sellable,
+		LocationID:      query.LocationID,
+		Search:          strings.TrimSpace(query.Search),
+		BrandID:         query.BrandID,
+		TagID:           query.TagID,
+		MinPrice:        query.MinPrice,
+		MaxPrice:        query.MaxPrice,
+		IncludeArchived: query.IncludeArchived,
+		SortColumn:      column,
+		SortDesc:        query.SortDesc,
+		Offset:          (query.Page - 1) * query.PageSize,
+		Limit:           query.PageSize,
+	}
+
+	if query.CategoryID != nil {
+		category, err := s.categoryRepo.GetByID(*query.CategoryID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return repository.InventoryFilter{}, ErrCategoryNotFound
+		} else if err != nil {
+			return repository.InventoryFilter{}, fmt.Errorf("failed to fetch category: %w", ErrDatabaseOperation)
+		}
+		filter.CategoryPath = category.Path
+	}
+
+	zero, low := 0.0, float64(LowStockLevel)
+	switch query.Stock {
+	case StockAll:
+	case StockIn:
+		filter.AboveQuantity = &zero
+	case StockLow:
+		filter.AboveQuantity, filter.MaxQuantity = &zero, &low
+	case StockOut:
+		filter.MaxQuantity = &zero
+	default:
+		return repository.InventoryFilter{}, fmt.Errorf("stock state %q: %w", query.Stock, ErrInvalidQuery)
+	}
+	return filter, nil
+}
+
+// checkSKU rejects a SKU that another item already uses.
+func checkSKU(repos *repository.Repositories, sku string, id uint) error {
+	if sku == "" {
+		return nil
+	}
+	existing, err := repos.Inventory.GetBySKU(sku)
+	if err != nil {
+		return fmt.Errorf("failed to check SKU: %w", ErrDatabaseOperation)
+	}
+	if existing != nil && existing.ID != id {
+		return ErrDuplicateSKU
+	}
+	return nil
+}
+
+// checkTaxonomy makes sure the category and brand of input exist.
+func checkTaxonomy(repos *repository.Repositories, input InventoryInput) error {
+	if input.CategoryID != nil {
+		_, err := repos.Categories.GetByID(*input.CategoryID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrCategoryNotFound
+		} else if err != nil {
+			return fmt.Errorf("failed to fetch category: %w", ErrDatabaseOperation)
+		}
+	}
+	if input.BrandID != nil {
+		_, err := repos.Brands.GetByID(*input.BrandID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrBrandNotFound
+		} else if err != nil {
+			return fmt.Errorf("failed to fetch brand: %w", ErrDatabaseOperation)
+		}
+	}
+	return nil
+}
+
+// checkUnit makes sure a base unit exists.
+func checkUnit(repos *repository.Repositories, code string) error {
+	unit, err := repos.Units.Get(code)
+	if err != nil {
+		return fmt.Errorf("failed to fetch unit: %w", ErrDatabaseOperation)
+	}
+	if unit == nil {
+		return ErrUnitNotFound
+	}
+	return nil
+}
+
+func getInventory(repos *repository.Repositories, id uint) (*model.Inventory, error) {
+	inventory, err := repos.Inventory.GetByID(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInventoryNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch inventory: %w", ErrDatabaseOperation)
+	}
+	return inventory, nil
+}
+
+// addBarcode stores code for inventory, or one of its packs, and makes it the
+// primary barcode if the item has none yet.
+func addBarcode(repos *repository.Repositories, inventory *model.Inventory, code barcode.Code, unitID *uint) (*model.Barcode, error) {
+	existing, err := repos.Barcodes.GetByCode(code.Value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check barcode: %w", ErrDatabaseOperation)
+	}
+	if existing != nil {
+		return nil, ErrDuplicateBarcode
+	}
+
+	created := &model.Barcode{InventoryID: inventory.ID, Code: code.Value, Symbology: string(code.Symbology), UnitID: unitID}
+	if err := repos.Barcodes.Create(created); err != nil {
+		return nil, fmt.Errorf("failed to create barcode: %w", ErrDatabaseOperation)
+	}
+	if inventory.Barcode == "" {
+		inventory.Barcode = created.Code
+		if err := repos.Inventory.Update(inventory); err != nil {
+			return nil, fmt.Errorf("failed to update inventory: %w", ErrDatabaseOperation)
+		}
+	}
+	return created, nil
+}
+
+// internalCode finds a free in-store code for an item, derived from its ID.
+func internalCode(repos *repository.Repositories, id uint) (barcode.Code, error) {
+	for attempt := uint64(0); attempt < internalCodeAttempts; attempt++ {
+		code := barcode.InternalCode(uint64(id) + attempt*1_000_000_000)
+		existing, err := repos.Barcodes.GetByCode(code)
+		if err != nil {
+			return barcode.Code{}, fmt.Errorf("failed to check barcode: %w", ErrDatabaseOperation)
+		}
+		if existing == nil {
+			return barcode.Code{Value: code, Symbology: barcode.Internal}, nil
+		}
+	}
+	return barcode.Code{}, fmt.Errorf("no free internal barcode: %w", ErrDuplicateBarcode)
+}
+
+// parseBarcode validates a code for registration. Scale items are registered
+// without their weight or price.
+func parseBarcode(raw string) (barcode.Code, error) {
+	code, err := barcode.Parse(raw)
+	if err != nil {
+		return code, fmt.Errorf("%v: %w", err, ErrInvalidBarcode)
+	}
+	if variable, ok := barcode.ParseVariable(code.Value); ok {
+		code.Value = variable.Template
+	}
+	return code, nil
+}
+
+// normalize trims and validates input and parses its barcode, if any.
+func normalize(input InventoryInput) (InventoryInput, barcode.Code, error) {
+	input.Name = strings.TrimSpace(input.Name)
+	input.SKU = strings.TrimSpace(input.SKU)
+	input.Barcode = strings.TrimSpace(input.Barcode)
+	input.TaxClass = strings.TrimSpace(input.TaxClass)
+
+	if input.Name == "" || len(input.Name) > maxNameLength {
+		return input, barcode.Code{}, ErrInvalidInventoryName
+	}
+	input.Unit = strings.ToLower(strings.TrimSpace(input.Unit))
+	if input.Unit == "" {
+		input.Unit = model.UnitPiece
+	}
+	if !(input.Quantity >= 0) || math.IsInf(input.Quantity, 0) {
+		return input, barcode.Code{}, ErrInvalidQuantity
+	}
+	if input.Price < 0 || math.IsNaN(input.Price) || math.IsInf(input.Price, 0) {
+		return input, barcode.Code{}, ErrInvalidPrice
+	}
+	if !(input.StandardCost >= 0) || math.IsInf(input.StandardCost, 0) {
+		return input, barcode.Code{}, ErrInvalidCost
+	}
+	if !isCode(input.SKU) {
+		return input, barcode.Code{}, ErrInvalidSKU
+	}
+	if input.TrackLots && input.TrackSerials {
+		return input, barcode.Code{}, ErrInvalidTracking
+	}
+	if input.WarrantyMonths < 0 || input.WarrantyMonths > maxWarrantyMonths {
+		return input, barcode.Code{}, ErrInvalidWarranty
+	}
+	if len(input.TaxClass) > maxNameLength {
+		return input, barcode.Code{}, ErrInvalidTaxClass
+	}
+	if !catalog_service.ValidLevels(input.ReorderPoint, input.ReorderQuantity, input.MinLevel, input.MaxLevel, input.LeadTimeDays) {
+		return input, barcode.Code{}, ErrInvalidReorderLevel
+	}
+
+	var code barcode.Code
+	if input.Barcode != "" {
+		var err error
+		if code, err = parseBarcode(input.Barcode); err != nil {
+			return input, code, err
+		}
+	}
+	input.Barcode = code.Value
+	return input, code, nil
+}
+
+// isCode accepts an empty string or a short code without spaces.
+func isCode(code string) bool {
+	return len(code) <= maxCodeLength && strings.IndexFunc(code, unicode.IsSpace) < 0
+}
+
+func apply(inventory *model.Inventory, input InventoryInput) {
+	inventory.Name = input.Name
+	inventory.SKU = input.SKU
+	inventory.CategoryID = input.CategoryID
+	inventory.BrandID = input.BrandID
+	inventory.Unit = input.Unit
+	inventory.Price = input.Price
+	inventory.StandardCost = input.StandardCost
+	inventory.TrackLots = input.TrackLots
+	inventory.TrackSerials = input.TrackSerials
+	inventory.WarrantyMonths = input.WarrantyMonths
+	inventory.TaxClass = input.TaxClass
+	inventory.ReorderPoint = input.ReorderPoint
+	inventory.ReorderQuantity = input.ReorderQuantity
+	inventory.MinLevel = input.MinLevel
+	inventory.MaxLevel = input.MaxLevel
+	inventory.LeadTimeDays = input.LeadTimeDays
+}
@@ -1,8 +1,12 @@
 package services
 
 import (
+	"blizzflow/backend/domain/model"
 	repository "blizzflow/backend/domain/repositories"
+	stock_service "blizzflow/backend/domain/services/stock"
 	"blizzflow/backend/infrastructure/database"
+	"blizzflow/backend/internal/barcode"
+	"fmt"
 	"os"
 	"testing"
 
@@ -26,10 +30,16 @@ func TestInventoryServiceSuite(t *testing.T) {
 
 var _ = ginkgo.BeforeSuite(func() {
 	os.Remove(testDBPath)
-	database.InitDB(testDBPath)
-	DB = database.DB
+	store, err := database.Open(database.DefaultOptions(testDBPath))
+	gomega.Expect(err).To(gomega.BeNil())
+	DB = store.DB()
 	inventoryRepo = repository.NewInventoryRepository(DB)
-	inventoryService = NewInventoryService(inventoryRepo)
+	inventoryService = NewInventoryService(
+		inventoryRepo,
+		repository.NewBarcodeRepository(DB),
+		repository.NewCategoryRepository(DB),
+		repository.NewUnitOfWork(DB),
+	)
 })
 
 var _ = ginkgo.AfterSuite(func() {
@@ -44,14 +54,43 @@ var _ = ginkgo.AfterSuite(func() {
 
 var _ = ginkgo.Describe("Inventory Service", func() {
 	ginkgo.BeforeEach(func() {
+		DB.Exec("DELETE FROM sales")
+		DB.Exec("DELETE FROM barcodes")
 		DB.Exec("DELETE FROM inventories")
+		DB.Exec("DELETE FROM categories")
+		DB.Exec("DELETE FROM inventory_tags")
+		DB.Exec("DELETE FROM tags")
+		DB.Exec("DELETE FROM serial_events")
+		DB.Exec("DELETE FROM serial_numbers")
+		DB.Exec("DELETE FROM lot_allocations")
+		DB.Exec("DELETE FROM lots")
+		DB.Exec("DELETE FROM cost_layers")
+		DB.Exec("DELETE FROM stock_movements")
+		DB.Exec("DELETE FROM settings")
+		DB.Exec("DELETE FROM locations WHERE id > 2")
+		DB.Exec("DELETE FROM variant_values")
+		DB.Exec("DELETE FROM variant_options")
+		DB.Exec("DELETE FROM variant_attributes")
+		DB.Exec("DELETE FROM item_units")
 	})
 
+	// category files a category under parent, or at the top level for nil
+	category := func(name string, parent *model.Category) *model.Category {
+		created := &model.Category{Name: name, Path: "/"}
+		if parent != nil {
+			created.ParentID, created.Path, created.Depth = &parent.ID, parent.Path, parent.Depth+1
+		}
+		DB.Create(created)
+		created.Path = fmt.Sprintf("%s%d/", created.Path, created.ID)
+		DB.Save(created)
+		return created
+	}
+
 	ginkgo.It("should create inventory successfully", func() {
 		inventory, err := inventoryService.CreateInventory("Test Item", 10, 99.99)
 		gomega.Expect(err).To(gomega.BeNil())
 		gomega.Expect(inventory.Name).To(gomega.Equal("Test Item"))
-		gomega.Expect(inventory.Quantity).To(gomega.Equal(10))
+		gomega.Expect(inventory.Quantity).To(gomega.Equal(10.0))
 	})
 
 	ginkgo.It("should fail with invalid inputs", func() {
@@ -61,4 +100,539 @@ var _ = ginkgo.Describe("Inventory Service", func() {
 		_, err = inventoryService.CreateInventory("Test", -1, 99.99)
 		gomega.Expect(err).To(gomega.Equal(ErrInvalidQuantity))
 	})
+
+	ginkgo.It("should update items and keep SKUs unique", func() {
+		first, err := inventoryService.AddInventory(InventoryInput{Name: "Tea", SKU: "TEA-1", Quantity: 3, Price: 2})
+		gomega.Expect(err).To(gomega.BeNil())
+		second, err := inventoryService.AddInventory(InventoryInput{Name: "Coffee", SKU: "COF-1", Quantity: 3, Price: 4})
+		gomega.Expect(err).To(gomega.BeNil())
+
+		_, err = inventoryService.AddInventory(InventoryInput{Name: "Other tea", SKU: "tea-1", Price: 1})
+		gomega.Expect(err).To(gomega.Equal(ErrDuplicateSKU))
+		_, err = inventoryService.UpdateInventory(second.ID, InventoryInput{Name: "Coffee", SKU: "TEA-1", Price: 4})
+		gomega.Expect(err).To(gomega.Equal(ErrDuplicateSKU))
+		_, err = inventoryService.AddInventory(InventoryInput{Name: "Cake", SKU: "CA KE", Price: 1})
+		gomega.Expect(err).To(gomega.Equal(ErrInvalidSKU))
+
+		drinks := category("Drinks", nil)
+		updated, err := inventoryService.UpdateInventory(first.ID, InventoryInput{Name: " Green tea ", SKU: "TEA-1", CategoryID: &drinks.ID, Quantity: 7, Price: 2.5})
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(updated.Name).To(gomega.Equal("Green tea"))
+		gomega.Expect(*updated.CategoryID).To(gomega.Equal(drinks.ID))
+
+		missing := uint(9999)
+		_, err = inventoryService.UpdateInventory(first.ID, InventoryInput{Name: "Green tea", CategoryID: &missing})
+		gomega.Expect(err).To(gomega.Equal(ErrCategoryNotFound))
+
+		_, err = inventoryService.UpdateInventory(9999, InventoryInput{Name: "Missing"})
+		gomega.Expect(err).To(gomega.Equal(ErrInventoryNotFound))
+	})
+
+	ginkgo.It("should value opening stock and revalue it at a new standard cost", func() {
+		DB.Create(&model.Setting{Key: stock_service.CostingMethodKey, Value: model.CostingStandard})
+		input := InventoryInput{Name: "Jam", Quantity: 6, Price: 4, StandardCost: 2.5}
+		jam, err := inventoryService.AddInventory(input)
+		gomega.Expect(err).To(gomega.BeNil())
+
+		input.StandardCost = 3
+		_, err = inventoryService.UpdateInventory(jam.ID, input)
+		gomega.Expect(err).To(gomega.BeNil())
+		var movements []model.StockMovement
+		DB.Where("inventory_id = ?", jam.ID).Order("id").Find(&movements)
+		gomega.Expect(movements).To(gomega.HaveLen(2))
+		gomega.Expect(movements[0].Cost).To(gomega.Equal(15.0))
+		gomega.Expect(movements[1].Type).To(gomega.Equal(model.MovementRevaluation))
+		gomega.Expect(movements[1].Cost).To(gomega.Equal(3.0))
+
+		input.StandardCost = -1
+		_, err = inventoryService.UpdateInventory(jam.ID, input)
+		gomega.Expect(err).To(gomega.Equal(ErrInvalidCost))
+	})
+
+	ginkgo.It("should keep tracking lots while they hold stock", func() {
+		milk, err := inventoryService.AddInventory(InventoryInput{Name: "Milk", Price: 1.5, TrackLots: true})
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(milk.TrackLots).To(gomega.BeTrue())
+		lot := &model.Lot{InventoryID: milk.ID, Number: "M-1", Quantity: 3}
+		DB.Create(lot)
+
+		_, err = inventoryService.UpdateInventory(milk.ID, InventoryInput{Name: "Milk", Price: 1.5})
+		gomega.Expect(err).To(gomega.Equal(ErrLotsInStock))
+
+		DB.Model(lot).Update("quantity", 0)
+		updated, err := inventoryService.UpdateInventory(milk.ID, InventoryInput{Name: "Milk", Price: 1.5})
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(updated.TrackLots).To(gomega.BeFalse())
+	})
+
+	ginkgo.It("should only switch serial tracking without stock", func() {
+		_, err := inventoryService.AddInventory(InventoryInput{Name: "Phone", Price: 300, TrackLots: true, TrackSerials: true})
+		gomega.Expect(err).To(gomega.Equal(ErrInvalidTracking))
+		_, err = inventoryService.AddInventory(InventoryInput{Name: "Phone", Price: 300, TrackSerials: true, WarrantyMonths: -1})
+		gomega.Expect(err).To(gomega.Equal(ErrInvalidWarranty))
+		_, err = inventoryService.AddInventory(InventoryInput{Name: "Phone", Quantity: 2, Price: 300, TrackSerials: true})
+		gomega.Expect(err).To(gomega.Equal(stock_service.ErrSerialRequired))
+
+		cable, err := inventoryService.AddInventory(InventoryInput{Name: "Cable", Quantity: 3, Price: 5})
+		gomega.Expect(err).To(gomega.BeNil())
+		_, err = inventoryService.UpdateInventory(cable.ID, InventoryInput{Name: "Cable", Price: 5, TrackSerials: true})
+		gomega.Expect(err).To(gomega.Equal(ErrSerialsInStock))
+
+		phone, err := inventoryService.AddInventory(InventoryInput{Name: "Phone", Price: 300, TrackSerials: true, WarrantyMonths: 12})
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(phone.TrackSerials).To(gomega.BeTrue())
+		gomega.Expect(phone.WarrantyMonths).To(gomega.Equal(12))
+		updated, err := inventoryService.UpdateInventory(phone.ID, InventoryInput{Name: "Phone", Price: 300})
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(updated.TrackSerials).To(gomega.BeFalse())
+	})
+
+	ginkgo.It("should only delete items that were never sold or stocked", func() {
+		sold, _ := inventoryService.CreateInventory("Sold", 5, 1)
+		stocked, _ := inventoryService.CreateInventory("Stocked", 5, 1)
+		unsold, _ := inventoryService.CreateInventory("Unsold", 0, 1)
+		DB.Create(&model.Sale{InventoryID: sold.ID, Quantity: 1, TotalPrice: 1})
+
+		gomega.Expect(inventoryService.DeleteInventory(sold.ID)).To(gomega.Equal(ErrInventoryInUse))
+		gomega.Expect(inventoryService.DeleteInventory(stocked.ID)).To(gomega.Equal(ErrInventoryStocked))
+		var movements int64
+		DB.Model(&model.StockMovement{}).Where("inventory_id = ?", stocked.ID).Count(&movements)
+		gomega.Expect(movements).To(gomega.Equal(int64(1)))
+		gomega.Expect(inventoryService.DeleteInventory(unsold.ID)).To(gomega.Succeed())
+		gomega.Expect(inventoryService.DeleteInventory(unsold.ID)).To(gomega.Equal(ErrInventoryNotFound))
+
+		archived, err := inventoryService.ArchiveInventory(sold.ID)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(archived.ArchivedAt).NotTo(gomega.BeNil())
+		_, err = inventoryService.ArchiveInventory(stocked.ID)
+		gomega.Expect(err).To(gomega.BeNil())
+
+		page, err := inventoryService.ListInventory(ListQuery{})
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(page.Total).To(gomega.BeZero())
+
+		page, err = inventoryService.ListInventory(ListQuery{IncludeArchived: true})
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(page.Total).To(gomega.Equal(int64(2)))
+
+		restored, err := inventoryService.RestoreInventory(sold.ID)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(restored.ArchivedAt).To(gomega.BeNil())
+	})
+
+	ginkgo.Describe("Listing", func() {
+		names := func(page *InventoryPage) []string {
+			var result []string
+			for _, item := range page.Items {
+				result = append(result, item.Name)
+			}
+			return result
+		}
+
+		var drinks *model.Category
+
+		ginkgo.BeforeEach(func() {
+			drinks = category("Drinks", nil)
+			juice := category("Juice", drinks)
+			bakery := category("Bakery", nil)
+			for _, input := range []InventoryInput{
+				{Name: "Apple juice", SKU: "JU-APL", Barcode: "4006381333931", CategoryID: &juice.ID, Quantity: 12, Price: 1.5},
+				{Name: "Orange juice", SKU: "JU-ORG", CategoryID: &juice.ID, Quantity: 3, Price: 1.8},
+				{Name: "Sparkling water", SKU: "WA-SPK", CategoryID: &drinks.ID, Quantity: 0, Price: 0.6},
+				{Name: "Apple pie", SKU: "BK-APL", CategoryID: &bakery.ID, Quantity: 4, Price: 6},
+				{Name: "Rye bread", SKU: "BK-RYE", CategoryID: &bakery.ID, Quantity: 20, Price: 3.2},
+			} {
+				_, err := inventoryService.AddInventory(input)
+				gomega.Expect(err).To(gomega.BeNil())
+			}
+		})
+
+		ginkgo.It("should paginate and sort", func() {
+			page, err := inventoryService.ListInventory(ListQuery{PageSize: 2, Page: 2, SortBy: "price", SortDesc: true})
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(page.Total).To(gomega.Equal(int64(5)))
+			gomega.Expect(page.Pages).To(gomega.Equal(3))
+			gomega.Expect(names(page)).To(gomega.Equal([]string{"Orange juice", "Apple juice"}))
+
+			_, err = inventoryService.ListInventory(ListQuery{SortBy: "name; DROP TABLE inventories"})
+			gomega.Expect(err).To(gomega.MatchError(ErrInvalidQuery))
+		})
+
+		ginkgo.It("should filter by category, stock state and price", func() {
+			minPrice, maxPrice := 1.0, 4.0
+			page, err := inventoryService.ListInventory(ListQuery{CategoryID: &drinks.ID, MinPrice: &minPrice, MaxPrice: &maxPrice})
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(names(page)).To(gomega.Equal([]string{"Apple juice", "Orange juice"}))
+
+			page, err = inventoryService.ListInventory(ListQuery{Stock: StockLow})
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(names(page)).To(gomega.Equal([]string{"Apple pie", "Orange juice"}))
+
+			page, err = inventoryService.ListInventory(ListQuery{Stock: StockOut})
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(names(page)).To(gomega.Equal([]string{"Sparkling water"}))
+
+			_, err = inventoryService.ListInventory(ListQuery{MinPrice: &maxPrice, MaxPrice: &minPrice})
+			gomega.Expect(err).To(gomega.MatchError(ErrInvalidQuery))
+
+			tag := &model.Tag{Name: "Local"}
+			DB.Create(tag)
+			bread, _ := inventoryRepo.GetBySKU("BK-RYE")
+			DB.Create(&model.InventoryTag{InventoryID: bread.ID, TagID: tag.ID})
+			page, err = inventoryService.ListInventory(ListQuery{TagID: &tag.ID})
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(names(page)).To(gomega.Equal([]string{"Rye bread"}))
+
+			page, err = inventoryService.ListInventory(ListQuery{SortBy: "category"})
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(names(page)[0:2]).To(gomega.Equal([]string{"Apple pie", "Rye bread"}))
+		})
+
+		ginkgo.It("should search names, SKUs and barcodes", func() {
+			page, err := inventoryService.ListInventory(ListQuery{Search: "apple"})
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(names(page)).To(gomega.Equal([]string{"Apple juice", "Apple pie"}))
+
+			page, err = inventoryService.ListInventory(ListQuery{Search: "apple ju"})
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(names(page)).To(gomega.Equal([]string{"Apple juice"}))
+
+			page, err = inventoryService.ListInventory(ListQuery{Search: "BK-RYE"})
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(names(page)).To(gomega.Equal([]string{"Rye bread"}))
+
+			page, err = inventoryService.ListInventory(ListQuery{Search: "4006381333931"})
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(names(page)).To(gomega.Equal([]string{"Apple juice"}))
+
+			page, err = inventoryService.ListInventory(ListQuery{Search: `"100%`})
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(page.Items).To(gomega.BeEmpty())
+		})
+
+		ginkgo.It("should list stock per location and at one location", func() {
+			branch := &model.Location{Name: "Branch"}
+			DB.Create(branch)
+			page, _ := inventoryService.ListInventory(ListQuery{Search: "BK-RYE"})
+			rye := page.Items[0]
+			DB.Create(&model.StockMovement{InventoryID: rye.ID, Type: model.MovementTransfer, Quantity: -5})
+			DB.Create(&model.StockMovement{InventoryID: rye.ID, LocationID: branch.ID, Type: model.MovementTransfer, Quantity: 5})
+
+			page, err := inventoryService.ListInventory(ListQuery{Search: "BK-RYE"})
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(page.Items[0].Quantity).To(gomega.Equal(20.0))
+			gomega.Expect(page.Items[0].Stock).To(gomega.Equal([]repository.LocationQuantity{
+				{InventoryID: rye.ID, LocationID: branch.ID, Name: "Branch", Quantity: 5},
+				{InventoryID: rye.ID, LocationID: model.MainLocation, Name: "Main", Quantity: 15},
+			}))
+
+			page, err = inventoryService.ListInventory(ListQuery{LocationID: &branch.ID, Stock: StockIn})
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(names(page)).To(gomega.Equal([]string{"Rye bread"}))
+			gomega.Expect(page.Items[0].Quantity).To(gomega.Equal(5.0))
+
+			main := uint(model.MainLocation)
+			page, err = inventoryService.ListInventory(ListQuery{LocationID: &main, SortBy: "quantity", SortDesc: true})
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(names(page)[:2]).To(gomega.Equal([]string{"Rye bread", "Apple juice"}))
+
+			missing := uint(99)
+			_, err = inventoryService.ListInventory(ListQuery{LocationID: &missing})
+			gomega.Expect(err).To(gomega.Equal(ErrLocationNotFound))
+		})
+	})
+
+	ginkgo.Describe("Barcodes", func() {
+		ginkgo.It("should validate check digits and normalize UPC-A", func() {
+			_, err := inventoryService.AddInventory(InventoryInput{Name: "Bad", Barcode: "4006381333932"})
+			gomega.Expect(err).To(gomega.MatchError(ErrInvalidBarcode))
+
+			item, err := inventoryService.AddInventory(InventoryInput{Name: "Cereal", Barcode: "036000291452"})
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(item.Barcode).To(gomega.Equal("0036000291452"))
+
+			match, err := inventoryService.LookupByBarcode("036000291452")
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(match.Inventory.ID).To(gomega.Equal(item.ID))
+			gomega.Expect(match.Barcode.Symbology).To(gomega.Equal(string(barcode.UPCA)))
+
+			_, err = inventoryService.AddInventory(InventoryInput{Name: "Copy", Barcode: "0036000291452"})
+			gomega.Expect(err).To(gomega.Equal(ErrDuplicateBarcode))
+		})
+
+		ginkgo.It("should generate internal barcodes for items without one", func() {
+			item, err := inventoryService.CreateInventory("Loose tea", 1, 3)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(item.Barcode).To(gomega.HavePrefix(barcode.InternalPrefix))
+			gomega.Expect(item.Barcode).To(gomega.HaveLen(13))
+
+			match, err := inventoryService.LookupByBarcode(item.Barcode)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(match.Barcode.Symbology).To(gomega.Equal(string(barcode.Internal)))
+		})
+
+		ginkgo.It("should keep several barcodes per item", func() {
+			item, err := inventoryService.AddInventory(InventoryInput{Name: "Soap", Barcode: "4006381333931"})
+			gomega.Expect(err).To(gomega.BeNil())
+			extra, err := inventoryService.AddBarcode(item.ID, "SOAP-BOX-12")
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(extra.Symbology).To(gomega.Equal(string(barcode.Code128)))
+
+			page, err := inventoryService.ListInventory(ListQuery{Search: "SOAP-BOX-12"})
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(page.Items).To(gomega.HaveLen(1))
+
+			barcodes, err := inventoryService.ListBarcodes(item.ID)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(barcodes).To(gomega.HaveLen(2))
+
+			gomega.Expect(inventoryService.RemoveBarcode(barcodes[0].ID)).To(gomega.Succeed())
+			updated, _ := inventoryService.GetInventory(item.ID)
+			gomega.Expect(updated.Barcode).To(gomega.Equal("SOAP-BOX-12"))
+			gomega.Expect(inventoryService.RemoveBarcode(extra.ID)).To(gomega.Equal(ErrLastBarcode))
+
+			_, err = inventoryService.LookupByBarcode("4006381333931")
+			gomega.Expect(err).To(gomega.Equal(ErrBarcodeNotFound))
+		})
+
+		ginkgo.It("should decode price and weight embedded codes", func() {
+			cheese, err := inventoryService.AddInventory(InventoryInput{Name: "Cheese", Unit: "kg", Price: 18.9, Barcode: "2312345000002"})
+			gomega.Expect(err).To(gomega.BeNil())
+			salad, err := inventoryService.AddInventory(InventoryInput{Name: "Salad bar", Price: 0, Barcode: barcode.Template("2100042000000")})
+			gomega.Expect(err).To(gomega.BeNil())
+
+			// 0.750 kg of cheese
+			label := "23123450075" + "0"
+			label += string(barcode.CheckDigit(label))
+			match, err := inventoryService.LookupByBarcode(label)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(match.Inventory.ID).To(gomega.Equal(cheese.ID))
+			gomega.Expect(match.Measure).To(gomega.Equal(barcode.MeasureWeight))
+			gomega.Expect(*match.Weight).To(gomega.BeNumerically("~", 0.75))
+			gomega.Expect(match.Price).To(gomega.BeNumerically("~", 14.18))
+
+			// A salad for 4.35
+			label = "21000420043" + "5"
+			label += string(barcode.CheckDigit(label))
+			match, err = inventoryService.LookupByBarcode(label)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(match.Inventory.ID).To(gomega.Equal(salad.ID))
+			gomega.Expect(match.Measure).To(gomega.Equal(barcode.MeasurePrice))
+			gomega.Expect(match.Price).To(gomega.BeNumerically("~", 4.35))
+
+			// Registering a printed label stores its template
+			_, err = inventoryService.AddBarcode(cheese.ID, label)
+			gomega.Expect(err).To(gomega.Equal(ErrDuplicateBarcode))
+		})
+
+		ginkgo.DescribeTable("should turn scale labels into quantities in the base unit",
+			func(unit string, price float64, prefix string, value int, quantity, total float64, want error) {
+				label := fmt.Sprintf("%s12345%05d", prefix, value)
+				label += string(barcode.CheckDigit(label))
+				_, err := inventoryService.AddInventory(InventoryInput{Name: "Deli", Unit: unit, Price: price, Barcode: barcode.Template(label)})
+				gomega.Expect(err).To(gomega.BeNil())
+
+				match, err := inventoryService.LookupByBarcode(label)
+				if want != nil {
+					gomega.Expect(err).To(gomega.Equal(want))
+					return
+				}
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(match.Quantity).To(gomega.BeNumerically("~", quantity, 1e-9))
+				gomega.Expect(match.Price).To(gomega.BeNumerically("~", total, 1e-9))
+			},
+			ginkgo.Entry("a weight in kilograms", "kg", 18.9, "23", 750, 0.75, 14.18, nil),
+			ginkgo.Entry("a weight in grams", "g", 0.02, "23", 750, 750.0, 15.0, nil),
+			ginkgo.Entry("a weight on a counted item", "pc", 1.45, "23", 750, 0.0, 0.0, ErrNotWeighed),
+			ginkgo.Entry("a price in kilograms", "kg", 12.0, "21", 300, 0.25, 3.0, nil),
+			ginkgo.Entry("a price in grams", "g", 0.05, "21", 435, 87.0, 4.35, nil),
+			ginkgo.Entry("a price in pieces", "pc", 1.45, "21", 435, 3.0, 4.35, nil),
+			ginkgo.Entry("a price without a unit price", "pc", 0.0, "21", 435, 1.0, 4.35, nil),
+		)
+	})
+
+	ginkgo.Describe("Units", func() {
+		ginkgo.It("should manage base units", func() {
+			units, err := inventoryService.ListUnits()
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(units).NotTo(gomega.BeEmpty())
+
+			_, err = inventoryService.SaveUnit(model.Unit{Code: " Box ", Name: "Box", Precision: 1})
+			gomega.Expect(err).To(gomega.BeNil())
+			_, err = inventoryService.AddInventory(InventoryInput{Name: "Nails", Unit: "box", Quantity: 2.5})
+			gomega.Expect(err).To(gomega.BeNil())
+
+			_, err = inventoryService.SaveUnit(model.Unit{Code: "box", Name: "Box", Precision: 0})
+			gomega.Expect(err).To(gomega.Equal(ErrUnitInUse))
+			gomega.Expect(inventoryService.DeleteUnit("box")).To(gomega.Equal(ErrUnitInUse))
+			gomega.Expect(inventoryService.DeleteUnit("pc")).To(gomega.Equal(ErrUnitInUse))
+			_, err = inventoryService.SaveUnit(model.Unit{Code: "x", Name: "X", Precision: 9})
+			gomega.Expect(err).To(gomega.Equal(ErrInvalidUnit))
+
+			_, err = inventoryService.AddInventory(InventoryInput{Name: "Screws", Unit: "crate"})
+			gomega.Expect(err).To(gomega.Equal(ErrUnitNotFound))
+			_, err = inventoryService.AddInventory(InventoryInput{Name: "Screws", Quantity: 2.5})
+			gomega.Expect(err).To(gomega.MatchError(ErrInvalidQuantity))
+		})
+
+		ginkgo.It("should keep the unit of an item with stock", func() {
+			item, err := inventoryService.AddInventory(InventoryInput{Name: "Rice", Unit: "kg", Quantity: 1.25, Price: 4})
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(item.Quantity).To(gomega.Equal(1.25))
+
+			_, err = inventoryService.UpdateInventory(item.ID, InventoryInput{Name: "Rice", Unit: "g", Price: 4})
+			gomega.Expect(err).To(gomega.Equal(ErrUnitInUse))
+			empty, _ := inventoryService.CreateInventory("Beans", 0, 2)
+			updated, err := inventoryService.UpdateInventory(empty.ID, InventoryInput{Name: "Beans", Unit: "KG", Price: 2})
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(updated.Unit).To(gomega.Equal("kg"))
+		})
+
+		ginkgo.It("should scan packs by their own barcode", func() {
+			item, _ := inventoryService.CreateInventory("Cola", 48, 1.2)
+			pack, err := inventoryService.AddPack(item.ID, PackInput{Name: "Case", Factor: 24, DefaultPurchase: true, Barcode: "5000112637922"})
+			gomega.Expect(err).To(gomega.BeNil())
+			_, err = inventoryService.AddPack(item.ID, PackInput{Name: "case", Factor: 12})
+			gomega.Expect(err).To(gomega.Equal(ErrInvalidUnit))
+			_, err = inventoryService.AddPack(item.ID, PackInput{Name: "Half", Factor: 0.5})
+			gomega.Expect(err).To(gomega.Equal(ErrInvalidUnit))
+
+			six, err := inventoryService.AddPack(item.ID, PackInput{Name: "Six-pack", Factor: 6, DefaultPurchase: true})
+			gomega.Expect(err).To(gomega.BeNil())
+			packs, _ := inventoryService.ListPacks(item.ID)
+			gomega.Expect(packs).To(gomega.HaveLen(2))
+			gomega.Expect(packs[0].ID).To(gomega.Equal(six.ID))
+			gomega.Expect(packs[1].DefaultPurchase).To(gomega.BeFalse())
+
+			match, err := inventoryService.LookupByBarcode("5000112637922")
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(match.Unit.ID).To(gomega.Equal(pack.ID))
+			gomega.Expect(match.Quantity).To(gomega.Equal(24.0))
+			gomega.Expect(match.Price).To(gomega.Equal(28.8))
+
+			match, _ = inventoryService.LookupByBarcode(item.Barcode)
+			gomega.Expect(match.Unit).To(gomega.BeNil())
+			gomega.Expect(match.Quantity).To(gomega.Equal(1.0))
+
+			gomega.Expect(inventoryService.RemovePack(pack.ID)).To(gomega.Succeed())
+			_, err = inventoryService.LookupByBarcode("5000112637922")
+			gomega.Expect(err).To(gomega.Equal(ErrBarcodeNotFound))
+		})
+	})
+
+	ginkgo.Describe("Variants", func() {
+		var shirt *model.Inventory
+
+		ginkgo.BeforeEach(func() {
+			var err error
+			shirt, err = inventoryService.AddInventory(InventoryInput{Name: "T-shirt", SKU: "TS", Price: 15})
+			gomega.Expect(err).To(gomega.BeNil())
+		})
+
+		ginkgo.It("should generate the option matrix", func() {
+			price := 18.0
+			variants, err := inventoryService.GenerateVariants(shirt.ID, GenerateInput{
+				Attributes: []AttributeInput{{Name: "Size", Values: []string{"S", "M"}}, {Name: "Colour", Values: []string{"Red", "Navy blue"}}},
+				Variants:   []VariantInput{{Options: []string{"m", "navy blue"}, Price: &price, Quantity: 4}},
+			})
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(variants).To(gomega.HaveLen(4))
+			gomega.Expect(variants[0].Name).To(gomega.Equal("T-shirt - S / Red"))
+			gomega.Expect(variants[0].SKU).To(gomega.Equal("TS-S-RED"))
+			gomega.Expect(variants[0].Price).To(gomega.Equal(15.0))
+			gomega.Expect(variants[0].Barcode).NotTo(gomega.BeEmpty())
+			gomega.Expect(variants[0].Options).To(gomega.Equal([]OptionValue{{"Size", "S"}, {"Colour", "Red"}}))
+			gomega.Expect(variants[3].SKU).To(gomega.Equal("TS-M-NAVYBLUE"))
+			gomega.Expect(variants[3].Price).To(gomega.Equal(18.0))
+			gomega.Expect(variants[3].Quantity).To(gomega.Equal(4.0))
+
+			variants, err = inventoryService.GenerateVariants(shirt.ID, GenerateInput{
+				Attributes: []AttributeInput{{Name: "size", Values: []string{"S", "L"}}},
+			})
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(variants).To(gomega.HaveLen(6))
+			gomega.Expect(variants[5].Name).To(gomega.Equal("T-shirt - L / Navy blue"))
+
+			_, err = inventoryService.GenerateVariants(shirt.ID, GenerateInput{
+				Attributes: []AttributeInput{{Name: "Fit", Values: []string{"Slim"}}},
+			})
+			gomega.Expect(err).To(gomega.Equal(ErrVariantsExist))
+			_, err = inventoryService.GenerateVariants(variants[0].ID, GenerateInput{
+				Attributes: []AttributeInput{{Name: "Fit", Values: []string{"Slim"}}},
+			})
+			gomega.Expect(err).To(gomega.Equal(ErrNestedVariant))
+			_, err = inventoryService.GenerateVariants(shirt.ID, GenerateInput{
+				Variants: []VariantInput{{Options: []string{"XL", "Red"}}},
+			})
+			gomega.Expect(err).To(gomega.MatchError(ErrInvalidVariant))
+
+			stocked, _ := inventoryService.CreateInventory("Socks", 5, 3)
+			_, err = inventoryService.GenerateVariants(stocked.ID, GenerateInput{
+				Attributes: []AttributeInput{{Name: "Size", Values: []string{"S"}}},
+			})
+			gomega.Expect(err).To(gomega.Equal(ErrParentHasStock))
+		})
+
+		ginkgo.It("should list parents with the totals of their variants", func() {
+			price := 20.0
+			variants, err := inventoryService.GenerateVariants(shirt.ID, GenerateInput{
+				Attributes: []AttributeInput{{Name: "Size", Values: []string{"S", "M", "L"}}},
+				Variants: []VariantInput{
+					{Options: []string{"S"}, Quantity: 2},
+					{Options: []string{"L"}, Quantity: 7, Price: &price},
+				},
+			})
+			gomega.Expect(err).To(gomega.BeNil())
+			inventoryService.CreateInventory("Cap", 3, 8)
+
+			page, err := inventoryService.ListInventory(ListQuery{SortBy: "quantity"})
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(page.Total).To(gomega.Equal(int64(2)))
+			gomega.Expect(page.Items[1].Name).To(gomega.Equal("T-shirt"))
+			gomega.Expect(page.Items[1].Quantity).To(gomega.Equal(9.0))
+			gomega.Expect(*page.Items[1].Variants).To(gomega.Equal(repository.VariantSummary{
+				ParentID: shirt.ID, Count: 3, Quantity: 9, MinPrice: 15, MaxPrice: 20,
+			}))
+			gomega.Expect(page.Items[0].Variants).To(gomega.BeNil())
+
+			page, _ = inventoryService.ListInventory(ListQuery{Search: variants[2].SKU})
+			gomega.Expect(page.Items).To(gomega.HaveLen(1))
+			gomega.Expect(page.Items[0].ID).To(gomega.Equal(shirt.ID))
+
+			page, _ = inventoryService.ListInventory(ListQuery{Sellable: true, Stock: StockIn})
+			gomega.Expect(page.Total).To(gomega.Equal(int64(3)))
+
+			input := InventoryInput{Name: "T-shirt", SKU: "TS", Price: 16}
+			_, err = inventoryService.UpdateInventory(shirt.ID, input)
+			gomega.Expect(err).To(gomega.BeNil())
+			listed, _ := inventoryService.ListVariants(shirt.ID)
+			gomega.Expect(listed[0].Price).To(gomega.Equal(16.0))
+			gomega.Expect(listed[2].Price).To(gomega.Equal(20.0))
+		})
+
+		ginkgo.It("should archive and delete variants with their parent", func() {
+			variants, err := inventoryService.GenerateVariants(shirt.ID, GenerateInput{
+				Attributes: []AttributeInput{{Name: "Size", Values: []string{"S", "M"}}},
+			})
+			gomega.Expect(err).To(gomega.BeNil())
+
+			_, err = inventoryService.ArchiveInventory(shirt.ID)
+			gomega.Expect(err).To(gomega.BeNil())
+			archived, _ := inventoryService.GetInventory(variants[1].ID)
+			gomega.Expect(archived.ArchivedAt).NotTo(gomega.BeNil())
+			_, err = inventoryService.RestoreInventory(shirt.ID)
+			gomega.Expect(err).To(gomega.BeNil())
+			restored, _ := inventoryService.GetInventory(variants[1].ID)
+			gomega.Expect(restored.ArchivedAt).To(gomega.BeNil())
+
+			gomega.Expect(inventoryService.DeleteInventory(shirt.ID)).To(gomega.Equal(ErrHasVariants))
+			for _, variant := range variants {
+				gomega.Expect(inventoryService.DeleteInventory(variant.ID)).To(gomega.Succeed())
+			}
+			gomega.Expect(inventoryService.DeleteInventory(shirt.ID)).To(gomega.Succeed())
+			var left int64
+			DB.Model(&model.VariantOption{}).Count(&left)
+			gomega.Expect(left).To(gomega.BeZero())
+		})
+	})
 })
@@ -0,0 +1,274 @@
+package services
+
+import (
+	"blizzflow/backend/domain/model"
+	repository "blizzflow/backend/domain/repositories"
+	stock_service "blizzflow/backend/domain/services/stock"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// PackInput holds the editable fields of a pack. Barcode, if set, is added
+// as a code that scans the whole pack.
+type PackInput struct {
+	Name            string  `json:"name"`
+	Factor          float64 `json:"factor"`
+	DefaultPurchase bool    `json:"defaultPurchase"`
+	DefaultSale     bool    `json:"defaultSale"`
+	Barcode         string  `json:"barcode"`
+}
+
+// ListUnits returns the base units items can be stocked in.
+func (s *InventoryService) ListUnits() ([]model.Unit, error) {
+	var units []model.Unit
+	err := s.uow.Do(func(repos *repository.Repositories) error {
+		var err error
+		if units, err = repos.Units.List(); err != nil {
+			return fmt.Errorf("failed to list units: %w", ErrDatabaseOperation)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return units, nil
+}
+
+// SaveUnit creates a base unit or updates the one with the same code. A unit
+// items are stocked in cannot lose precision, as their stock may not fit.
+func (s *InventoryService) SaveUnit(unit model.Unit) (*model.Unit, error) {
+	unit.Code = strings.ToLower(strings.TrimSpace(unit.Code))
+	unit.Name = strings.TrimSpace(unit.Name)
+	if !isCode(unit.Code) || unit.Code == "" || unit.Name == "" || len(unit.Name) > maxNameLength ||
+		unit.Precision < 0 || unit.Precision > model.MaxPrecision {
+		return nil, ErrInvalidUnit
+	}
+
+	err := s.uow.Do(func(repos *repository.Repositories) error {
+		existing, err := repos.Units.Get(unit.Code)
+		if err != nil {
+			return fmt.Errorf("failed to fetch unit: %w", ErrDatabaseOperation)
+		}
+		if existing != nil && unit.Precision < existing.Precision {
+			items, err := repos.Units.CountItems(unit.Code)
+			if err != nil {
+				return fmt.Errorf("failed to count items: %w", ErrDatabaseOperation)
+			}
+			if items > 0 {
+				return ErrUnitInUse
+			}
+		}
+		if err := repos.Units.Save(&unit); err != nil {
+			return fmt.Errorf("failed to save unit: %w", ErrDatabaseOperation)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &unit, nil
+}
+
+// DeleteUnit removes a base unit no item is stocked in. Pieces stay, as they
+// are the default.
+func (s *InventoryService) DeleteUnit(code string) error {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if code == model.UnitPiece {
+		return ErrUnitInUse
+	}
+	return s.uow.Do(func(repos *repository.Repositories) error {
+		if err := checkUnit(repos, code); err != nil {
+			return err
+		}
+		items, err := repos.Units.CountItems(code)
+		if err != nil {
+			return fmt.Errorf("failed to count items: %w", ErrDatabaseOperation)
+		}
+		if items > 0 {
+			return ErrUnitInUse
+		}
+		if err := repos.Units.Delete(code); err != nil {
+			return fmt.Errorf("failed to delete unit: %w", ErrDatabaseOperation)
+		}
+		return nil
+	})
+}
+
+// ListPacks returns the packs of an item, smallest first.
+func (s *InventoryService) ListPacks(inventoryID uint) ([]model.ItemUnit, error) {
+	var packs []model.ItemUnit
+	err := s.uow.Do(func(repos *repository.Repositories) error {
+		if _, err := getInventory(repos, inventoryID); err != nil {
+			return err
+		}
+		var err error
+		if packs, err = repos.Units.ListPacks(inventoryID); err != nil {
+			return fmt.Errorf("failed to list packs: %w", ErrDatabaseOperation)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return packs, nil
+}
+
+// AddPack defines a pack an item is bought or sold in, such as a case of 24
+// pieces or a 25 kg sack. Factor is the number of base units in a pack.
+func (s *InventoryService) AddPack(inventoryID uint, input PackInput) (*model.ItemUnit, error) {
+	input, err := normalizePack(input)
+	if err != nil {
+		return nil, err
+	}
+
+	pack := &model.ItemUnit{InventoryID: inventoryID}
+	err = s.uow.Do(func(repos *repository.Repositories) error {
+		inventory, err := getInventory(repos, inventoryID)
+		if err != nil {
+			return err
+		}
+		applyPack(pack, input)
+		if err := checkPack(repos, inventory, pack); err != nil {
+			return err
+		}
+		if err := repos.Units.CreatePack(pack); err != nil {
+			return fmt.Errorf("failed to create pack: %w", ErrDatabaseOperation)
+		}
+		return savePack(repos, inventory, pack, input.Barcode)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pack, nil
+}
+
+// UpdatePack changes a pack. A new barcode is added next to its others.
+func (s *InventoryService) UpdatePack(id uint, input PackInput) (*model.ItemUnit, error) {
+	input, err := normalizePack(input)
+	if err != nil {
+		return nil, err
+	}
+
+	var pack *model.ItemUnit
+	err = s.uow.Do(func(repos *repository.Repositories) error {
+		if pack, err = getPack(repos, id); err != nil {
+			return err
+		}
+		inventory, err := getInventory(repos, pack.InventoryID)
+		if err != nil {
+			return err
+		}
+		applyPack(pack, input)
+		if err := checkPack(repos, inventory, pack); err != nil {
+			return err
+		}
+		if err := repos.Units.UpdatePack(pack); err != nil {
+			return fmt.Errorf("failed to update pack: %w", ErrDatabaseOperation)
+		}
+		return savePack(repos, inventory, pack, input.Barcode)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pack, nil
+}
+
+// RemovePack deletes a pack and its barcodes. Stock is kept in base units,
+// so no quantity changes.
+func (s *InventoryService) RemovePack(id uint) error {
+	return s.uow.Do(func(repos *repository.Repositories) error {
+		if _, err := getPack(repos, id); err != nil {
+			return err
+		}
+		if err := repos.Barcodes.DeleteByUnit(id); err != nil {
+			return fmt.Errorf("failed to delete barcodes: %w", ErrDatabaseOperation)
+		}
+		if err := repos.Units.DeletePack(id); err != nil {
+			return fmt.Errorf("failed to delete pack: %w", ErrDatabaseOperation)
+		}
+		return nil
+	})
+}
+
+func getPack(repos *repository.Repositories, id uint) (*model.ItemUnit, error) {
+	pack, err := repos.Units.GetPack(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrUnitNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch pack: %w", ErrDatabaseOperation)
+	}
+	return pack, nil
+}
+
+// checkPack makes sure a pack holds a whole number of base units, as far as
+// the base unit counts, and that its name is unique for the item.
+func checkPack(repos *repository.Repositories, inventory *model.Inventory, pack *model.ItemUnit) error {
+	unit, err := stock_service.BaseUnit(repos, inventory)
+	if err != nil {
+		return err
+	}
+	if !unit.Fits(pack.Factor) {
+		return ErrInvalidUnit
+	}
+
+	packs, err := repos.Units.ListPacks(inventory.ID)
+	if err != nil {
+		return fmt.Errorf("failed to list packs: %w", ErrDatabaseOperation)
+	}
+	for _, other := range packs {
+		if other.ID != pack.ID && strings.EqualFold(other.Name, pack.Name) {
+			return ErrInvalidUnit
+		}
+	}
+	return nil
+}
+
+// savePack makes a pack the only default of its kind and registers its
+// barcode.
+func savePack(repos *repository.Repositories, inventory *model.Inventory, pack *model.ItemUnit, code string) error {
+	err := repos.Units.ClearDefaults(inventory.ID, pack.ID, pack.DefaultPurchase, pack.DefaultSale)
+	if err != nil {
+		return fmt.Errorf("failed to update packs: %w", ErrDatabaseOperation)
+	}
+	if code == "" {
+		return nil
+	}
+
+	parsed, err := parseBarcode(code)
+	if err != nil {
+		return err
+	}
+	existing, err := repos.Barcodes.GetByCode(parsed.Value)
+	if err != nil {
+		return fmt.Errorf("failed to check barcode: %w", ErrDatabaseOperation)
+	}
+	if existing != nil && existing.UnitID != nil && *existing.UnitID == pack.ID {
+		return nil
+	}
+	_, err = addBarcode(repos, inventory, parsed, &pack.ID)
+	return err
+}
+
+func normalizePack(input PackInput) (PackInput, error) {
+	input.Name = strings.TrimSpace(input.Name)
+	input.Barcode = strings.TrimSpace(input.Barcode)
+	if input.Name == "" || len(input.Name) > maxNameLength {
+		return input, ErrInvalidUnit
+	}
+	if !(input.Factor > 0) || math.IsInf(input.Factor, 0) {
+		return input, ErrInvalidUnit
+	}
+	return input, nil
+}
+
+func applyPack(pack *model.ItemUnit, input PackInput) {
+	pack.Name = input.Name
+	pack.Factor = input.Factor
+	pack.DefaultPurchase = input.DefaultPurchase
+	pack.DefaultSale = input.DefaultSale
+}
@@ -0,0 +1,459 @@
+package services
+
+import (
+	"blizzflow/backend/domain/model"
+	repository "blizzflow/backend/domain/repositories"
+	stock_service "blizzflow/backend/domain/services/stock"
+	"blizzflow/backend/internal/barcode"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// MaxVariants bounds the size of an item's variant matrix.
+const MaxVariants = 500
+
+// AttributeInput names an attribute and the options to offer for it.
+type AttributeInput struct {
+	Name   string   `json:"name"`
+	Values []string `json:"values"`
+}
+
+// VariantInput overrides the generated fields of one variant. Options picks
+// the variant by one value per attribute, in attribute order.
+type VariantInput struct {
+	Options  []string `json:"options"`
+	SKU      string   `json:"sku"`
+	Barcode  string   `json:"barcode"`
+	Price    *float64 `json:"price"`
+	Quantity float64  `json:"quantity"`
+}
+
+// GenerateInput extends the variant matrix of an item. Attributes the item
+// already has gain the listed values; new attributes can only be added while
+// the item has no variants yet.
+type GenerateInput struct {
+	Attributes []AttributeInput `json:"attributes"`
+	Variants   []VariantInput   `json:"variants"`
+}
+
+// OptionValue is the option of a variant for one attribute.
+type OptionValue struct {
+	Attribute string `json:"attribute"`
+	Value     string `json:"value"`
+}
+
+// Variant is an item sold under a parent, with the options that set it apart.
+type Variant struct {
+	model.Inventory
+	Options []OptionValue `json:"options"`
+}
+
+// matrixAttribute is an attribute with its options while building a matrix.
+type matrixAttribute struct {
+	model.VariantAttribute
+	options []model.VariantOption
+}
+
+// GenerateVariants creates every combination of the item's attribute
+// options that is not a variant yet. A variant is named after its parent
+// and options, takes its SKU from the parent's and gets an internal barcode.
+// It sells at the parent's price and starts with no stock unless Variants
+// says otherwise.
+func (s *InventoryService) GenerateVariants(parentID uint, input GenerateInput) ([]Variant, error) {
+	attributes, err := normalizeAttributes(input.Attributes)
+	if err != nil {
+		return nil, err
+	}
+	overrides := make(map[string]VariantInput, len(input.Variants))
+	for _, override := range input.Variants {
+		if !(override.Quantity >= 0) || math.IsInf(override.Quantity, 0) {
+			return nil, ErrInvalidQuantity
+		}
+		if override.Price != nil && (*override.Price < 0 || math.IsNaN(*override.Price) || math.IsInf(*override.Price, 0)) {
+			return nil, ErrInvalidPrice
+		}
+		override.SKU = strings.TrimSpace(override.SKU)
+		if !isCode(override.SKU) {
+			return nil, ErrInvalidSKU
+		}
+		overrides[optionKey(override.Options)] = override
+	}
+
+	var variants []Variant
+	err = s.uow.Do(func(repos *repository.Repositories) error {
+		parent, err := getInventory(repos, parentID)
+		if err != nil {
+			return err
+		}
+		if parent.ParentID != nil {
+			return ErrNestedVariant
+		}
+		existing, err := repos.Inventory.ListVariants(parentID)
+		if err != nil {
+			return fmt.Errorf("failed to list variants: %w", ErrDatabaseOperation)
+		}
+		if len(existing) == 0 && parent.Quantity != 0 {
+			return ErrParentHasStock
+		}
+
+		matrix, err := buildMatrix(repos, parentID, attributes, len(existing) > 0)
+		if err != nil {
+			return err
+		}
+		combinations := 1
+		for _, attribute := range matrix {
+			combinations *= len(attribute.options)
+			if combinations > MaxVariants {
+				return ErrTooManyVariants
+			}
+		}
+
+		taken, err := existingCombinations(repos, existing)
+		if err != nil {
+			return err
+		}
+		used := make(map[string]bool, len(overrides))
+		for _, combination := range cartesian(matrix) {
+			values := make([]string, len(combination))
+			ids := make([]uint, len(combination))
+			for i, option := range combination {
+				values[i], ids[i] = option.Value, option.ID
+			}
+			key := optionKey(values)
+			override, found := overrides[key]
+			used[key] = found
+			if taken[idKey(ids)] {
+				continue
+			}
+			if err := createVariant(repos, parent, values, ids, override); err != nil {
+				return err
+			}
+		}
+		for key := range overrides {
+			if !used[key] {
+				return fmt.Errorf("options %q: %w", key, ErrInvalidVariant)
+			}
+		}
+
+		variants, err = listVariants(repos, parentID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return variants, nil
+}
+
+// ListVariants returns the variants of an item with their options.
+func (s *InventoryService) ListVariants(parentID uint) ([]Variant, error) {
+	var variants []Variant
+	err := s.uow.Do(func(repos *repository.Repositories) error {
+		if _, err := getInventory(repos, parentID); err != nil {
+			return err
+		}
+		var err error
+		variants, err = listVariants(repos, parentID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return variants, nil
+}
+
+// ListAttributes returns the attributes of an item with their options.
+func (s *InventoryService) ListAttributes(parentID uint) ([]AttributeInput, error) {
+	var attributes []AttributeInput
+	err := s.uow.Do(func(repos *repository.Repositories) error {
+		if _, err := getInventory(repos, parentID); err != nil {
+			return err
+		}
+		matrix, err := loadMatrix(repos, parentID)
+		if err != nil {
+			return err
+		}
+		attributes = make([]AttributeInput, len(matrix))
+		for i, attribute := range matrix {
+			attributes[i] = AttributeInput{Name: attribute.Name, Values: make([]string, len(attribute.options))}
+			for j, option := range attribute.options {
+				attributes[i].Values[j] = option.Value
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return attributes, nil
+}
+
+// normalizeAttributes trims input and rejects empty or repeated names and
+// values.
+func normalizeAttributes(input []AttributeInput) ([]AttributeInput, error) {
+	attributes := make([]AttributeInput, 0, len(input))
+	names := make(map[string]bool, len(input))
+	for _, attribute := range input {
+		attribute.Name = strings.TrimSpace(attribute.Name)
+		if attribute.Name == "" || len(attribute.Name) > maxNameLength || names[strings.ToLower(attribute.Name)] {
+			return nil, ErrInvalidAttribute
+		}
+		names[strings.ToLower(attribute.Name)] = true
+
+		values := make([]string, 0, len(attribute.Values))
+		seen := make(map[string]bool, len(attribute.Values))
+		for _, value := range attribute.Values {
+			value = strings.TrimSpace(value)
+			if value == "" || len(value) > maxNameLength || seen[strings.ToLower(value)] {
+				return nil, ErrInvalidAttribute
+			}
+			seen[strings.ToLower(value)] = true
+			values = append(values, value)
+		}
+		attribute.Values = values
+		attributes = append(attributes, attribute)
+	}
+	return attributes, nil
+}
+
+// loadMatrix returns the attributes of an item with their options, in order.
+func loadMatrix(repos *repository.Repositories, parentID uint) ([]matrixAttribute, error) {
+	attributes, err := repos.Variants.ListAttributes(parentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list attributes: %w", ErrDatabaseOperation)
+	}
+	ids := make([]uint, len(attributes))
+	for i, attribute := range attributes {
+		ids[i] = attribute.ID
+	}
+	options, err := repos.Variants.ListOptions(ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list options: %w", ErrDatabaseOperation)
+	}
+
+	matrix := make([]matrixAttribute, len(attributes))
+	index := make(map[uint]int, len(attributes))
+	for i, attribute := range attributes {
+		matrix[i] = matrixAttribute{VariantAttribute: attribute}
+		index[attribute.ID] = i
+	}
+	for _, option := range options {
+		i := index[option.AttributeID]
+		matrix[i].options = append(matrix[i].options, option)
+	}
+	return matrix, nil
+}
+
+// buildMatrix adds the attributes and options of input to those the item
+// already has and returns the lot.
+func buildMatrix(repos *repository.Repositories, parentID uint, input []AttributeInput, hasVariants bool) ([]matrixAttribute, error) {
+	matrix, err := loadMatrix(repos, parentID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, in := range input {
+		i := -1
+		for j := range matrix {
+			if strings.EqualFold(matrix[j].Name, in.Name) {
+				i = j
+				break
+			}
+		}
+		if i < 0 {
+			// Existing variants would have no option for a new attribute
+			if hasVariants {
+				return nil, ErrVariantsExist
+			}
+			attribute := model.VariantAttribute{InventoryID: parentID, Name: in.Name, Position: len(matrix)}
+			if err := repos.Variants.CreateAttribute(&attribute); err != nil {
+				return nil, fmt.Errorf("failed to create attribute: %w", ErrDatabaseOperation)
+			}
+			matrix = append(matrix, matrixAttribute{VariantAttribute: attribute})
+			i = len(matrix) - 1
+		}
+
+		for _, value := range in.Values {
+			found := false
+			for _, option := range matrix[i].options {
+				found = found || strings.EqualFold(option.Value, value)
+			}
+			if found {
+				continue
+			}
+			option := model.VariantOption{AttributeID: matrix[i].ID, Value: value, Position: len(matrix[i].options)}
+			if err := repos.Variants.CreateOption(&option); err != nil {
+				return nil, fmt.Errorf("failed to create option: %w", ErrDatabaseOperation)
+			}
+			matrix[i].options = append(matrix[i].options, option)
+		}
+	}
+
+	for _, attribute := range matrix {
+		if len(attribute.options) == 0 {
+			return nil, ErrInvalidAttribute
+		}
+	}
+	return matrix, nil
+}
+
+// existingCombinations returns the option sets the variants already cover.
+func existingCombinations(repos *repository.Repositories, variants []model.Inventory) (map[string]bool, error) {
+	ids := make([]uint, len(variants))
+	for i, variant := range variants {
+		ids[i] = variant.ID
+	}
+	values, err := repos.Variants.ListValues(ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list variant options: %w", ErrDatabaseOperation)
+	}
+	options := make(map[uint][]uint, len(variants))
+	for _, value := range values {
+		options[value.InventoryID] = append(options[value.InventoryID], value.OptionID)
+	}
+	taken := make(map[string]bool, len(options))
+	for _, ids := range options {
+		taken[idKey(ids)] = true
+	}
+	return taken, nil
+}
+
+// cartesian lists every combination of one option per attribute.
+func cartesian(matrix []matrixAttribute) [][]model.VariantOption {
+	combinations := [][]model.VariantOption{{}}
+	for _, attribute := range matrix {
+		next := make([][]model.VariantOption, 0, len(combinations)*len(attribute.options))
+		for _, combination := range combinations {
+			for _, option := range attribute.options {
+				extended := append(append([]model.VariantOption{}, combination...), option)
+				next = append(next, extended)
+			}
+		}
+		combinations = next
+	}
+	return combinations
+}
+
+// createVariant stores one variant of parent with its options, barcode and
+// opening stock.
+func createVariant(repos *repository.Repositories, parent *model.Inventory, values []string, optionIDs []uint, override VariantInput) error {
+	variant := &model.Inventory{
+		ParentID:       &parent.ID,
+		Name:           parent.Name + " - " + strings.Join(values, " / "),
+		SKU:            override.SKU,
+		CategoryID:     parent.CategoryID,
+		BrandID:        parent.BrandID,
+		Unit:           parent.Unit,
+		Price:          parent.Price,
+		StandardCost:   parent.StandardCost,
+		TrackLots:      parent.TrackLots,
+		TrackSerials:   parent.TrackSerials,
+		WarrantyMonths: parent.WarrantyMonths,
+		TaxClass:       parent.TaxClass,
+	}
+	if variant.SKU == "" && parent.SKU != "" {
+		variant.SKU = strings.ToUpper(parent.SKU + "-" + strings.Join(values, "-"))
+		variant.SKU = strings.Join(strings.Fields(variant.SKU), "")
+		if !isCode(variant.SKU) {
+			return fmt.Errorf("SKU %q: %w", variant.SKU, ErrInvalidSKU)
+		}
+	}
+	if override.Price != nil {
+		variant.Price = *override.Price
+	}
+	if err := checkSKU(repos, variant.SKU, 0); err != nil {
+		return err
+	}
+	if err := repos.Inventory.Create(variant); err != nil {
+		return fmt.Errorf("failed to create variant: %w", ErrDatabaseOperation)
+	}
+	if err := repos.Variants.SetValues(variant.ID, optionIDs); err != nil {
+		return fmt.Errorf("failed to set variant options: %w", ErrDatabaseOperation)
+	}
+
+	var code barcode.Code
+	var err error
+	if override.Barcode != "" {
+		code, err = parseBarcode(strings.TrimSpace(override.Barcode))
+	} else {
+		code, err = internalCode(repos, variant.ID)
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := addBarcode(repos, variant, code, nil); err != nil {
+		return err
+	}
+
+	if override.Quantity == 0 {
+		return nil
+	}
+	return stock_service.Record(repos, &model.StockMovement{
+		InventoryID: variant.ID,
+		Type:        model.MovementOpening,
+		Quantity:    override.Quantity,
+		Cost:        override.Quantity * variant.StandardCost,
+		Reason:      "opening balance",
+	})
+}
+
+// listVariants loads the variants of an item with their options.
+func listVariants(repos *repository.Repositories, parentID uint) ([]Variant, error) {
+	items, err := repos.Inventory.ListVariants(parentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list variants: %w", ErrDatabaseOperation)
+	}
+	matrix, err := loadMatrix(repos, parentID)
+	if err != nil {
+		return nil, err
+	}
+
+	options := make(map[uint]OptionValue)
+	position := make(map[uint]int)
+	for i, attribute := range matrix {
+		for _, option := range attribute.options {
+			options[option.ID] = OptionValue{Attribute: attribute.Name, Value: option.Value}
+			position[option.ID] = i
+		}
+	}
+	ids := make([]uint, len(items))
+	for i, item := range items {
+		ids[i] = item.ID
+	}
+	values, err := repos.Variants.ListValues(ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list variant options: %w", ErrDatabaseOperation)
+	}
+	byVariant := make(map[uint][]uint, len(items))
+	for _, value := range values {
+		byVariant[value.InventoryID] = append(byVariant[value.InventoryID], value.OptionID)
+	}
+
+	variants := make([]Variant, len(items))
+	for i, item := range items {
+		optionIDs := byVariant[item.ID]
+		sort.Slice(optionIDs, func(a, b int) bool { return position[optionIDs[a]] < position[optionIDs[b]] })
+		variants[i] = Variant{Inventory: item, Options: make([]OptionValue, len(optionIDs))}
+		for j, id := range optionIDs {
+			variants[i].Options[j] = options[id]
+		}
+	}
+	return variants, nil
+}
+
+// optionKey identifies a combination by its values, ignoring case.
+func optionKey(values []string) string {
+	trimmed := make([]string, len(values))
+	for i, value := range values {
+		trimmed[i] = strings.ToLower(strings.TrimSpace(value))
+	}
+	return strings.Join(trimmed, " / ")
+}
+
+// idKey identifies a combination by its option IDs in any order.
+func idKey(ids []uint) string {
+	sorted := append([]uint{}, ids...)
+	sort.Slice(sorted, func(a, b int) bool { return sorted[a] < sorted[b] })
+	return fmt.Sprint(sorted)
+}
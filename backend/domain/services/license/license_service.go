@@ -18,10 +18,10 @@ var (
 )
 
 type LicenseService struct {
-	licenseRepo *repository.LicenseRepository
+	licenseRepo repository.LicenseRepo
 }
 
-func NewLicenseService(licenseRepo *repository.LicenseRepository) *LicenseService {
+func NewLicenseService(licenseRepo repository.LicenseRepo) *LicenseService {
 	return &LicenseService{licenseRepo: licenseRepo}
 }
 
@@ -27,8 +27,9 @@ var (
 
 var _ = ginkgo.BeforeSuite(func() {
 	os.Remove(testDBPath)
-	database.InitDB(testDBPath)
-	DB = database.DB
+	store, err := database.Open(database.DefaultOptions(testDBPath))
+	gomega.Expect(err).To(gomega.BeNil())
+	DB = store.DB()
 
 	licenseRepo = repository.NewLicenseRepository(DB)
 	licenseService = NewLicenseService(licenseRepo)
@@ -0,0 +1,287 @@
+package location_service
+
+import (
+	"blizzflow/backend/domain/model"
+	repository "blizzflow/backend/domain/repositories"
+	stock_service "blizzflow/backend/domain/services/stock"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	maxNameLength  = 100
+	maxNotesLength = 1000
+)
+
+// Custom errors
+var (
+	ErrInvalidLocation   = fmt.Errorf("invalid location")
+	ErrDuplicateLocation = fmt.Errorf("a location with this name already exists")
+	ErrLocationNotFound  = stock_service.ErrLocationNotFound
+	ErrLocationArchived  = stock_service.ErrLocationArchived
+	ErrLocationInUse     = fmt.Errorf("location still holds stock")
+	ErrLastLocation      = fmt.Errorf("the last active location cannot be archived")
+	ErrTransferNotFound  = fmt.Errorf("transfer not found")
+	ErrInvalidTransfer   = fmt.Errorf("invalid transfer")
+	ErrEmptyTransfer     = fmt.Errorf("transfer has no lines")
+	ErrTransferReceived  = fmt.Errorf("transfer was already received")
+	ErrTransferPending   = fmt.Errorf("location has transfers in transit")
+	ErrInventoryNotFound = stock_service.ErrInventoryNotFound
+	ErrInsufficientStock = stock_service.ErrInsufficientStock
+	ErrInvalidQuantity   = stock_service.ErrInvalidQuantity
+	ErrNotManager        = stock_service.ErrNotManager
+	ErrSessionNotFound   = fmt.Errorf("session not found")
+	ErrDatabaseOperation = fmt.Errorf("database operation failed")
+)
+
+type LocationService struct {
+	locationRepo repository.LocationRepo
+	sessionRepo  repository.SessionRepo
+	uow          repository.UnitOfWork
+	now          func() time.Time
+}
+
+func NewLocationService(locationRepo repository.LocationRepo, sessionRepo repository.SessionRepo, uow repository.UnitOfWork) *LocationService {
+	return &LocationService{
+		locationRepo: locationRepo,
+		sessionRepo:  sessionRepo,
+		uow:          uow,
+		now:          time.Now,
+	}
+}
+
+// ListLocations returns the locations stock is kept at by name, archived
+// ones only when asked for.
+func (s *LocationService) ListLocations(includeArchived bool) ([]model.Location, error) {
+	locations, err := s.locationRepo.List(includeArchived)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list locations: %w", ErrDatabaseOperation)
+	}
+	return locations, nil
+}
+
+func (s *LocationService) CreateLocation(name string) (*model.Location, error) {
+	location := &model.Location{}
+	err := s.uow.Do(func(repos *repository.Repositories) error {
+		if err := rename(repos, location, name); err != nil {
+			return err
+		}
+		if err := repos.Locations.Create(location); err != nil {
+			return fmt.Errorf("failed to create location: %w", ErrDatabaseOperation)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return location, nil
+}
+
+func (s *LocationService) RenameLocation(id uint, name string) (*model.Location, error) {
+	var location *model.Location
+	err := s.uow.Do(func(repos *repository.Repositories) error {
+		var err error
+		if location, err = getLocation(repos, id); err != nil {
+			return err
+		}
+		if err := rename(repos, location, name); err != nil {
+			return err
+		}
+		if err := repos.Locations.Update(location); err != nil {
+			return fmt.Errorf("failed to update location: %w", ErrDatabaseOperation)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return location, nil
+}
+
+// ArchiveLocation hides an empty location from new sales, receipts and
+// transfers while keeping its history. One location always stays active.
+func (s *LocationService) ArchiveLocation(id uint) (*model.Location, error) {
+	return s.setArchived(id, true)
+}
+
+// RestoreLocation brings an archived location back.
+func (s *LocationService) RestoreLocation(id uint) (*model.Location, error) {
+	return s.setArchived(id, false)
+}
+
+func (s *LocationService) setArchived(id uint, archived bool) (*model.Location, error) {
+	var location *model.Location
+	err := s.uow.Do(func(repos *repository.Repositories) error {
+		var err error
+		if location, err = getLocation(repos, id); err != nil {
+			return err
+		}
+		if (location.ArchivedAt != nil) == archived {
+			return nil
+		}
+		if archived {
+			if err := canArchive(repos, location); err != nil {
+				return err
+			}
+		}
+
+		location.ArchivedAt = nil
+		if archived {
+			now := s.now()
+			location.ArchivedAt = &now
+		}
+		if err := repos.Locations.Update(location); err != nil {
+			return fmt.Errorf("failed to update location: %w", ErrDatabaseOperation)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return location, nil
+}
+
+// GetCurrentLocation returns the location sales, receipts and counts that
+// name none happen at.
+func (s *LocationService) GetCurrentLocation() (*model.Location, error) {
+	var location *model.Location
+	err := s.uow.Do(func(repos *repository.Repositories) error {
+		var err error
+		location, err = stock_service.CurrentLocation(repos)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return location, nil
+}
+
+// SetCurrentLocation makes an active location the current one. Only a
+// manager can move the till.
+func (s *LocationService) SetCurrentLocation(sessionID, id uint) (*model.Location, error) {
+	userID, err := s.userID(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	var location *model.Location
+	err = s.uow.Do(func(repos *repository.Repositories) error {
+		user, err := repos.Users.GetUserByID(userID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrSessionNotFound
+		} else if err != nil {
+			return fmt.Errorf("failed to fetch user: %w", ErrDatabaseOperation)
+		}
+		if !user.IsManager() {
+			return ErrNotManager
+		}
+		if location, err = stock_service.Locate(repos, &id); err != nil {
+			return err
+		}
+		if err := repos.Settings.Set(stock_service.CurrentLocationKey, strconv.FormatUint(uint64(id), 10)); err != nil {
+			return fmt.Errorf("failed to save setting: %w", ErrDatabaseOperation)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return location, nil
+}
+
+// StockByLocation returns what an item holds at each location, goods in
+// transit included, in its base unit. A parent holds what its variants
+// do.
+func (s *LocationService) StockByLocation(inventoryID uint) ([]repository.LocationQuantity, error) {
+	stock := []repository.LocationQuantity{}
+	err := s.uow.Do(func(repos *repository.Repositories) error {
+		if _, err := repos.Inventory.GetByID(inventoryID); errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInventoryNotFound
+		} else if err != nil {
+			return fmt.Errorf("failed to fetch inventory: %w", ErrDatabaseOperation)
+		}
+		rows, err := repos.Locations.Quantities([]uint{inventoryID})
+		if err != nil {
+			return fmt.Errorf("failed to total stock: %w", ErrDatabaseOperation)
+		}
+		stock = append(stock, rows...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stock, nil
+}
+
+func (s *LocationService) userID(sessionID uint) (uint, error) {
+	session, err := s.sessionRepo.GetSession(sessionID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch session: %w", ErrDatabaseOperation)
+	}
+	if session == nil {
+		return 0, ErrSessionNotFound
+	}
+	return session.UserID, nil
+}
+
+// getLocation returns a location stock is kept at; the transit location
+// is not one.
+func getLocation(repos *repository.Repositories, id uint) (*model.Location, error) {
+	location, err := repos.Locations.GetByID(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && location.Transit) {
+		return nil, ErrLocationNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to fetch location: %w", ErrDatabaseOperation)
+	}
+	return location, nil
+}
+
+// rename gives a location a name no other location has.
+func rename(repos *repository.Repositories, location *model.Location, name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxNameLength {
+		return ErrInvalidLocation
+	}
+	existing, err := repos.Locations.GetByName(name)
+	if err != nil {
+		return fmt.Errorf("failed to fetch location: %w", ErrDatabaseOperation)
+	}
+	if existing != nil && existing.ID != location.ID {
+		return ErrDuplicateLocation
+	}
+	location.Name = name
+	return nil
+}
+
+// canArchive checks that a location holds nothing, has nothing on its way
+// to it and is not the last active one.
+func canArchive(repos *repository.Repositories, location *model.Location) error {
+	held, err := repos.Locations.HoldsStock(location.ID)
+	if err != nil {
+		return fmt.Errorf("failed to read stock ledger: %w", ErrDatabaseOperation)
+	}
+	if held {
+		return ErrLocationInUse
+	}
+	pending, err := repos.Transfers.List(model.TransferInTransit)
+	if err != nil {
+		return fmt.Errorf("failed to list transfers: %w", ErrDatabaseOperation)
+	}
+	for _, transfer := range pending {
+		if transfer.ToID == location.ID {
+			return ErrTransferPending
+		}
+	}
+	active, err := repos.Locations.List(false)
+	if err != nil {
+		return fmt.Errorf("failed to list locations: %w", ErrDatabaseOperation)
+	}
+	if len(active) <= 1 {
+		return ErrLastLocation
+	}
+	return nil
+}
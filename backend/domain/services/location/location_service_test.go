@@ -0,0 +1,328 @@
+package location_service
+
+import (
+	"blizzflow/backend/domain/model"
+	repository "blizzflow/backend/domain/repositories"
+	adjustment_service "blizzflow/backend/domain/services/adjustment"
+	stock_service "blizzflow/backend/domain/services/stock"
+	"blizzflow/backend/infrastructure/database"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+	"gorm.io/gorm"
+)
+
+func TestLocationServiceSuite(t *testing.T) {
+	gomega.RegisterFailHandler(ginkgo.Fail)
+	ginkgo.RunSpecs(t, "Location Service Test Suite")
+}
+
+const testDBPath = "test.db"
+
+var (
+	DB              *gorm.DB
+	locationService *LocationService
+)
+
+var _ = ginkgo.BeforeSuite(func() {
+	os.Remove(testDBPath)
+	store, err := database.Open(database.DefaultOptions(testDBPath))
+	gomega.Expect(err).To(gomega.BeNil())
+	DB = store.DB()
+	locationService = NewLocationService(
+		repository.NewLocationRepository(DB),
+		repository.NewSessionRepository(DB),
+		repository.NewUnitOfWork(DB),
+	)
+})
+
+var _ = ginkgo.AfterSuite(func() {
+	if DB != nil {
+		sqlDB, err := DB.DB()
+		if err == nil {
+			sqlDB.Close()
+		}
+	}
+	os.Remove(testDBPath)
+})
+
+var _ = ginkgo.Describe("Location Service", func() {
+	var (
+		session *model.Session
+		item    *model.Inventory
+		branch  *model.Location
+	)
+
+	receive := func(inventoryID, locationID uint, quantity float64) {
+		err := stock_service.Record(repository.NewRepositories(DB), &model.StockMovement{
+			InventoryID: inventoryID,
+			LocationID:  locationID,
+			Type:        model.MovementReceipt,
+			Quantity:    quantity,
+			Cost:        quantity * 2,
+		})
+		gomega.Expect(err).To(gomega.BeNil())
+	}
+	held := func(inventoryID uint) map[string]float64 {
+		stock, err := locationService.StockByLocation(inventoryID)
+		gomega.Expect(err).To(gomega.BeNil())
+		result := map[string]float64{}
+		for _, row := range stock {
+			result[row.Name] = row.Quantity
+		}
+		return result
+	}
+
+	ginkgo.BeforeEach(func() {
+		for _, table := range []string{"stock_adjustments", "transfer_lines", "transfers", "serial_events", "serial_numbers", "lot_allocations", "lots", "cost_layers", "stock_movements", "inventories", "sessions", "users", "settings"} {
+			DB.Exec("DELETE FROM " + table)
+		}
+		// Main and the transit location come with the schema
+		DB.Exec("DELETE FROM locations WHERE id > 2")
+		DB.Exec("UPDATE locations SET archived_at = NULL")
+
+		session = &model.Session{UserID: 7}
+		DB.Create(session)
+		item = &model.Inventory{Name: "Flour", Price: 3}
+		DB.Create(item)
+		var err error
+		branch, err = locationService.CreateLocation("Branch")
+		gomega.Expect(err).To(gomega.BeNil())
+	})
+
+	ginkgo.Context("Locations", func() {
+		ginkgo.It("should keep names unique and leave the transit location out", func() {
+			_, err := locationService.CreateLocation(" branch ")
+			gomega.Expect(err).To(gomega.Equal(ErrDuplicateLocation))
+			_, err = locationService.CreateLocation("In transit")
+			gomega.Expect(err).To(gomega.Equal(ErrDuplicateLocation))
+			_, err = locationService.CreateLocation("  ")
+			gomega.Expect(err).To(gomega.Equal(ErrInvalidLocation))
+
+			renamed, err := locationService.RenameLocation(branch.ID, "Back room")
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(renamed.Name).To(gomega.Equal("Back room"))
+
+			locations, err := locationService.ListLocations(false)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(locations).To(gomega.HaveLen(2))
+			gomega.Expect(locations[0].Name).To(gomega.Equal("Back room"))
+			gomega.Expect(locations[1].ID).To(gomega.Equal(uint(model.MainLocation)))
+		})
+
+		ginkgo.It("should only archive empty locations and keep one active", func() {
+			receive(item.ID, branch.ID, 2)
+			_, err := locationService.ArchiveLocation(branch.ID)
+			gomega.Expect(err).To(gomega.Equal(ErrLocationInUse))
+
+			_, err = locationService.ArchiveLocation(model.MainLocation)
+			gomega.Expect(err).To(gomega.BeNil())
+			_, err = locationService.CreateLocation("Shop")
+			gomega.Expect(err).To(gomega.BeNil())
+			err = stock_service.Record(repository.NewRepositories(DB), &model.StockMovement{
+				InventoryID: item.ID,
+				LocationID:  model.MainLocation,
+				Type:        model.MovementReceipt,
+				Quantity:    1,
+			})
+			gomega.Expect(err).To(gomega.Equal(ErrLocationArchived))
+
+			// With Main archived, the oldest active location is current
+			current, err := locationService.GetCurrentLocation()
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(current.ID).To(gomega.Equal(branch.ID))
+
+			_, err = locationService.RestoreLocation(model.MainLocation)
+			gomega.Expect(err).To(gomega.BeNil())
+		})
+
+		ginkgo.It("should let only a manager move the till", func() {
+			manager := &model.User{Username: "boss", PasswordHash: "x", Role: model.RoleManager}
+			DB.Create(manager)
+			managerSession := &model.Session{UserID: manager.ID}
+			DB.Create(managerSession)
+			clerk := &model.User{Username: "clerk", PasswordHash: "x", Role: model.RoleStaff}
+			DB.Create(clerk)
+			clerkSession := &model.Session{UserID: clerk.ID}
+			DB.Create(clerkSession)
+
+			_, err := locationService.SetCurrentLocation(clerkSession.ID, branch.ID)
+			gomega.Expect(err).To(gomega.Equal(ErrNotManager))
+			_, err = locationService.SetCurrentLocation(managerSession.ID, 2)
+			gomega.Expect(err).To(gomega.Equal(ErrLocationNotFound))
+			_, err = locationService.SetCurrentLocation(managerSession.ID, branch.ID)
+			gomega.Expect(err).To(gomega.BeNil())
+
+			// Movements that name no location now happen at the branch
+			err = stock_service.Record(repository.NewRepositories(DB), &model.StockMovement{
+				InventoryID: item.ID,
+				Type:        model.MovementReceipt,
+				Quantity:    4,
+			})
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(held(item.ID)).To(gomega.Equal(map[string]float64{"Branch": 4}))
+		})
+	})
+
+	ginkgo.Context("Transfers", func() {
+		ginkgo.It("should move stock through transit and book a shortage", func() {
+			receive(item.ID, model.MainLocation, 10)
+
+			_, err := locationService.SendTransfer(session.ID, TransferInput{
+				FromID: model.MainLocation,
+				ToID:   branch.ID,
+				Lines:  []TransferLineInput{{InventoryID: item.ID, Quantity: 11}},
+			})
+			gomega.Expect(err).To(gomega.MatchError(ErrInsufficientStock))
+			_, err = locationService.SendTransfer(session.ID, TransferInput{FromID: branch.ID, ToID: branch.ID})
+			gomega.Expect(err).To(gomega.Equal(ErrInvalidTransfer))
+
+			transfer, err := locationService.SendTransfer(session.ID, TransferInput{
+				FromID: model.MainLocation,
+				ToID:   branch.ID,
+				Notes:  "weekly restock",
+				Lines:  []TransferLineInput{{InventoryID: item.ID, Quantity: 6}},
+			})
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(transfer.Number).To(gomega.HavePrefix("TR-0"))
+			gomega.Expect(transfer.Status).To(gomega.Equal(model.TransferInTransit))
+			gomega.Expect(transfer.From).To(gomega.Equal("Main"))
+			gomega.Expect(transfer.To).To(gomega.Equal("Branch"))
+			gomega.Expect(held(item.ID)).To(gomega.Equal(map[string]float64{"Main": 4, "In transit": 6}))
+
+			// Goods in transit are valued as before; the total is unchanged
+			var current model.Inventory
+			DB.First(&current, item.ID)
+			gomega.Expect(current.Quantity).To(gomega.Equal(10.0))
+			gomega.Expect(current.AverageCost).To(gomega.Equal(2.0))
+
+			_, err = locationService.ArchiveLocation(branch.ID)
+			gomega.Expect(err).To(gomega.Equal(ErrTransferPending))
+
+			line := transfer.Lines[0]
+			transfer, err = locationService.ReceiveTransfer(session.ID, transfer.ID, []ReceiveLineInput{{LineID: line.ID, Received: 5}})
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(transfer.Status).To(gomega.Equal(model.TransferReceived))
+			gomega.Expect(transfer.ReceivedAt).NotTo(gomega.BeNil())
+			gomega.Expect(transfer.Lines[0].Received).To(gomega.Equal(5.0))
+			gomega.Expect(transfer.Lines[0].Difference).To(gomega.Equal(-1.0))
+			gomega.Expect(held(item.ID)).To(gomega.Equal(map[string]float64{"Main": 4, "Branch": 5}))
+
+			var shortage model.StockMovement
+			DB.Where("type = ? AND reason = ?", model.MovementAdjustment, adjustment_service.ReasonTransitLoss).First(&shortage)
+			gomega.Expect(shortage.Quantity).To(gomega.Equal(-1.0))
+			gomega.Expect(shortage.LocationID).To(gomega.Equal(uint(2)))
+
+			// The loss is an approved adjustment and counts as shrinkage
+			var adjustment model.StockAdjustment
+			DB.Where("movement_id = ?", shortage.ID).First(&adjustment)
+			gomega.Expect(adjustment.Status).To(gomega.Equal(model.AdjustmentApproved))
+			gomega.Expect(adjustment.Note).To(gomega.Equal(transfer.Number))
+			adjustments := adjustment_service.NewAdjustmentService(
+				repository.NewAdjustmentRepository(DB),
+				repository.NewUserRepository(DB),
+				repository.NewSessionRepository(DB),
+				repository.NewSettingRepository(DB),
+				repository.NewUnitOfWork(DB),
+			)
+			report, err := adjustments.ShrinkageReport(time.Now().Add(-time.Hour), time.Now().Add(time.Hour), adjustment_service.PeriodDay)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(report.Lines).To(gomega.HaveLen(1))
+			gomega.Expect(report.Lines[0].ReasonCode).To(gomega.Equal(adjustment_service.ReasonTransitLoss))
+			gomega.Expect(report.Lines[0].Label).To(gomega.Equal("Lost in transit"))
+			gomega.Expect(report.Lines[0].Quantity).To(gomega.Equal(-1.0))
+			gomega.Expect(report.Total).To(gomega.Equal(-2.0))
+
+			_, err = locationService.ReceiveTransfer(session.ID, transfer.ID, nil)
+			gomega.Expect(err).To(gomega.Equal(ErrTransferReceived))
+			transfers, err := locationService.ListTransfers(model.TransferInTransit)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(transfers).To(gomega.BeEmpty())
+		})
+
+		ginkgo.It("should take in an overage at the destination", func() {
+			receive(item.ID, model.MainLocation, 3)
+			transfer, err := locationService.SendTransfer(session.ID, TransferInput{
+				FromID: model.MainLocation,
+				ToID:   branch.ID,
+				Lines:  []TransferLineInput{{InventoryID: item.ID, Quantity: 3}},
+			})
+			gomega.Expect(err).To(gomega.BeNil())
+
+			transfer, err = locationService.ReceiveTransfer(session.ID, transfer.ID, []ReceiveLineInput{{LineID: transfer.Lines[0].ID, Received: 4}})
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(transfer.Lines[0].Difference).To(gomega.Equal(1.0))
+			gomega.Expect(held(item.ID)).To(gomega.Equal(map[string]float64{"Branch": 4}))
+
+			var adjustment model.StockAdjustment
+			DB.Where("reason_code = ?", adjustment_service.ReasonTransitSurplus).First(&adjustment)
+			gomega.Expect(adjustment.Quantity).To(gomega.Equal(1.0))
+			gomega.Expect(adjustment.Status).To(gomega.Equal(model.AdjustmentApproved))
+			gomega.Expect(adjustment.MovementID).NotTo(gomega.BeNil())
+		})
+
+		ginkgo.It("should move lots and serial numbers with the goods", func() {
+			expires := time.Now().AddDate(0, 1, 0)
+			milk := &model.Inventory{Name: "Milk", Price: 1, TrackLots: true}
+			DB.Create(milk)
+			repos := repository.NewRepositories(DB)
+			lot, err := stock_service.OpenLot(repos, milk, model.MainLocation, stock_service.LotInput{Number: "M-1", ExpiresAt: &expires})
+			gomega.Expect(err).To(gomega.BeNil())
+			err = stock_service.RecordLots(repos, &model.StockMovement{
+				InventoryID: milk.ID,
+				LocationID:  model.MainLocation,
+				Type:        model.MovementReceipt,
+				Quantity:    4,
+			}, []stock_service.LotPick{{LotID: lot.ID, Quantity: 4}})
+			gomega.Expect(err).To(gomega.BeNil())
+
+			phone := &model.Inventory{Name: "Phone", Price: 300, TrackSerials: true}
+			DB.Create(phone)
+			err = stock_service.RecordSerials(repos, &model.StockMovement{
+				InventoryID: phone.ID,
+				LocationID:  model.MainLocation,
+				Type:        model.MovementReceipt,
+				Quantity:    2,
+			}, []string{"SN-1", "SN-2"}, "Acme")
+			gomega.Expect(err).To(gomega.BeNil())
+
+			transfer, err := locationService.SendTransfer(session.ID, TransferInput{
+				FromID: model.MainLocation,
+				ToID:   branch.ID,
+				Lines: []TransferLineInput{
+					{InventoryID: milk.ID, Quantity: 3},
+					{InventoryID: phone.ID, Quantity: 2, Serials: []string{"SN-1", "SN-2"}},
+				},
+			})
+			gomega.Expect(err).To(gomega.BeNil())
+			_, err = locationService.ReceiveTransfer(session.ID, transfer.ID, []ReceiveLineInput{
+				{LineID: transfer.Lines[1].ID, Received: 1, Serials: []string{"SN-3"}},
+			})
+			gomega.Expect(err).To(gomega.Equal(ErrInvalidTransfer))
+			_, err = locationService.ReceiveTransfer(session.ID, transfer.ID, []ReceiveLineInput{
+				{LineID: transfer.Lines[1].ID, Received: 1, Serials: []string{"sn-2"}},
+			})
+			gomega.Expect(err).To(gomega.BeNil())
+
+			var lots []model.Lot
+			DB.Where("inventory_id = ?", milk.ID).Order("location_id").Find(&lots)
+			gomega.Expect(lots).To(gomega.HaveLen(3))
+			gomega.Expect(lots[0].Quantity).To(gomega.Equal(1.0))
+			gomega.Expect(lots[1].Quantity).To(gomega.Equal(0.0))
+			gomega.Expect(lots[2].LocationID).To(gomega.Equal(branch.ID))
+			gomega.Expect(lots[2].Number).To(gomega.Equal("M-1"))
+			gomega.Expect(lots[2].Quantity).To(gomega.Equal(3.0))
+			gomega.Expect(lots[2].ExpiresAt).NotTo(gomega.BeNil())
+
+			var units []model.SerialNumber
+			DB.Where("inventory_id = ?", phone.ID).Order("serial").Find(&units)
+			gomega.Expect(units[0].Status).To(gomega.Equal(model.SerialRemoved))
+			gomega.Expect(units[1].Status).To(gomega.Equal(model.SerialInStock))
+			gomega.Expect(*units[1].LocationID).To(gomega.Equal(branch.ID))
+			gomega.Expect(held(phone.ID)).To(gomega.Equal(map[string]float64{"Branch": 1}))
+		})
+	})
+})
@@ -0,0 +1,463 @@
+package location_service
+
+import (
+	"blizzflow/backend/domain/model"
+	repository "blizzflow/backend/domain/repositories"
+	adjustment_service "blizzflow/backend/domain/services/adjustment"
+	stock_service "blizzflow/backend/domain/services/stock"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// TransferLineInput is one item to send. Quantity is in the pack UnitID or,
+// for nil, the item's base unit. Serialized items name the units sent.
+type TransferLineInput struct {
+	InventoryID uint     `json:"inventoryId"`
+	Quantity    float64  `json:"quantity"`
+	UnitID      *uint    `json:"unitId"`
+	Serials     []string `json:"serials"`
+}
+
+type TransferInput struct {
+	FromID uint                `json:"fromId"`
+	ToID   uint                `json:"toId"`
+	Notes  string              `json:"notes"`
+	Lines  []TransferLineInput `json:"lines"`
+}
+
+// ReceiveLineInput is what arrived of a transfer line, in the item's base
+// unit. Serialized items name the units that arrived instead.
+type ReceiveLineInput struct {
+	LineID   uint     `json:"lineId"`
+	Received float64  `json:"received"`
+	Serials  []string `json:"serials"`
+}
+
+// TransferLine is a transfer line with its item. Difference is what
+// arrived beyond what was sent, negative for a shortage.
+type TransferLine struct {
+	model.TransferLine
+	Name       string  `json:"name"`
+	SKU        string  `json:"sku"`
+	Unit       string  `json:"unit"`
+	Difference float64 `json:"difference"`
+}
+
+// Transfer is a transfer with the names of its locations and its lines.
+type Transfer struct {
+	model.Transfer
+	From  string         `json:"from"`
+	To    string         `json:"to"`
+	Lines []TransferLine `json:"lines"`
+}
+
+// SendTransfer takes goods out of one location and puts them in transit to
+// another. Lot-tracked goods leave first-expiry-first-out and travel in
+// their lots. Nothing reaches the destination until the transfer is
+// received.
+func (s *LocationService) SendTransfer(sessionID uint, input TransferInput) (*Transfer, error) {
+	userID, err := s.userID(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	input.Notes = strings.TrimSpace(input.Notes)
+	if input.FromID == input.ToID || len(input.Notes) > maxNotesLength {
+		return nil, ErrInvalidTransfer
+	}
+	if len(input.Lines) == 0 {
+		return nil, ErrEmptyTransfer
+	}
+
+	var transfer *Transfer
+	err = s.uow.Do(func(repos *repository.Repositories) error {
+		if _, err := stock_service.Locate(repos, &input.FromID); err != nil {
+			return err
+		}
+		if _, err := stock_service.Locate(repos, &input.ToID); err != nil {
+			return err
+		}
+		transit, err := repos.Locations.Transit()
+		if err != nil {
+			return fmt.Errorf("failed to fetch transit location: %w", ErrDatabaseOperation)
+		}
+
+		header := &model.Transfer{
+			FromID: input.FromID,
+			ToID:   input.ToID,
+			Status: model.TransferInTransit,
+			Notes:  input.Notes,
+			SentBy: userID,
+			SentAt: s.now(),
+		}
+		if err := repos.Transfers.Create(header); err != nil {
+			return fmt.Errorf("failed to create transfer: %w", ErrDatabaseOperation)
+		}
+		header.Number = fmt.Sprintf("TR-%06d", header.ID)
+		if err := repos.Transfers.Update(header); err != nil {
+			return fmt.Errorf("failed to update transfer: %w", ErrDatabaseOperation)
+		}
+
+		seen := make(map[uint]bool, len(input.Lines))
+		for _, in := range input.Lines {
+			if seen[in.InventoryID] {
+				return ErrInvalidTransfer
+			}
+			seen[in.InventoryID] = true
+			if !(in.Quantity > 0) || math.IsInf(in.Quantity, 0) {
+				return ErrInvalidQuantity
+			}
+			item, err := repos.Inventory.GetByID(in.InventoryID)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInventoryNotFound
+			} else if err != nil {
+				return fmt.Errorf("failed to fetch inventory: %w", ErrDatabaseOperation)
+			}
+			base, err := stock_service.Convert(repos, item, in.Quantity, in.UnitID)
+			if err != nil {
+				return err
+			}
+
+			out := &model.StockMovement{
+				InventoryID: item.ID,
+				LocationID:  header.FromID,
+				Type:        model.MovementTransfer,
+				Quantity:    -base,
+				Reference:   stock_service.Reference("transfer", header.ID),
+				Reason:      header.Number,
+				UserID:      &userID,
+			}
+			moved, err := stock_service.Move(repos, out, transit.ID, nil, in.Serials)
+			if err != nil {
+				return err
+			}
+			line := &model.TransferLine{
+				TransferID:        header.ID,
+				InventoryID:       item.ID,
+				Sent:              moved.Quantity,
+				Serials:           strings.Join(trimAll(in.Serials), "\n"),
+				TransitMovementID: moved.ID,
+			}
+			if err := repos.Transfers.CreateLine(line); err != nil {
+				return fmt.Errorf("failed to save transfer line: %w", ErrDatabaseOperation)
+			}
+		}
+		transfer, err = loadTransfer(repos, header.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return transfer, nil
+}
+
+// ReceiveTransfer books the arrival of a transfer at its destination. Lines
+// not listed arrived as sent. What went missing on the way is written off
+// the transit location and what arrived beyond what was sent is taken in
+// at the destination, both as approved adjustments, so the ledger and the
+// shrinkage report show each discrepancy.
+func (s *LocationService) ReceiveTransfer(sessionID, id uint, lines []ReceiveLineInput) (*Transfer, error) {
+	userID, err := s.userID(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	var transfer *Transfer
+	err = s.uow.Do(func(repos *repository.Repositories) error {
+		header, err := getTransfer(repos, id)
+		if err != nil {
+			return err
+		}
+		if header.Status != model.TransferInTransit {
+			return ErrTransferReceived
+		}
+		if _, err := stock_service.Locate(repos, &header.ToID); err != nil {
+			return err
+		}
+		transit, err := repos.Locations.Transit()
+		if err != nil {
+			return fmt.Errorf("failed to fetch transit location: %w", ErrDatabaseOperation)
+		}
+		sent, err := repos.Transfers.ListLines([]uint{header.ID})
+		if err != nil {
+			return fmt.Errorf("failed to list transfer lines: %w", ErrDatabaseOperation)
+		}
+		arrived := make(map[uint]ReceiveLineInput, len(lines))
+		for _, in := range lines {
+			if _, ok := arrived[in.LineID]; ok {
+				return ErrInvalidTransfer
+			}
+			arrived[in.LineID] = in
+		}
+
+		reference := stock_service.Reference("transfer", header.ID)
+		for i := range sent {
+			line := &sent[i]
+			in, ok := arrived[line.ID]
+			delete(arrived, line.ID)
+			if !ok {
+				in = ReceiveLineInput{Received: line.Sent, Serials: splitSerials(line.Serials)}
+			}
+			received, missing, err := reconcile(line, in)
+			if err != nil {
+				return err
+			}
+			line.Received = received
+
+			moved, shortage, err := transitPicks(repos, line, received)
+			if err != nil {
+				return err
+			}
+			if moving := math.Min(received, line.Sent); moving > 0 {
+				out := &model.StockMovement{
+					InventoryID: line.InventoryID,
+					LocationID:  transit.ID,
+					Type:        model.MovementTransfer,
+					Quantity:    -moving,
+					Reference:   reference,
+					Reason:      header.Number,
+					UserID:      &userID,
+				}
+				if _, err := stock_service.Move(repos, out, header.ToID, moved, trimAll(in.Serials)); err != nil {
+					return err
+				}
+			}
+			if short := line.Sent - received; short > 0 {
+				m := &model.StockMovement{
+					InventoryID: line.InventoryID,
+					LocationID:  transit.ID,
+					Type:        model.MovementAdjustment,
+					Quantity:    -short,
+					Reference:   reference,
+					Reason:      adjustment_service.ReasonTransitLoss,
+					UserID:      &userID,
+				}
+				if len(missing) > 0 {
+					err = stock_service.RecordSerials(repos, m, missing, "")
+				} else {
+					err = stock_service.RecordLots(repos, m, shortage)
+				}
+				if err != nil {
+					return err
+				}
+				if _, err := adjustment_service.Book(repos, m, header.Number); err != nil {
+					return fmt.Errorf("failed to book shortage: %w", ErrDatabaseOperation)
+				}
+			}
+			if over := received - line.Sent; over > 0 {
+				m := &model.StockMovement{
+					InventoryID: line.InventoryID,
+					LocationID:  header.ToID,
+					Type:        model.MovementAdjustment,
+					Quantity:    over,
+					Reference:   reference,
+					Reason:      adjustment_service.ReasonTransitSurplus,
+					UserID:      &userID,
+				}
+				if err := stock_service.Record(repos, m); err != nil {
+					return err
+				}
+				if _, err := adjustment_service.Book(repos, m, header.Number); err != nil {
+					return fmt.Errorf("failed to book overage: %w", ErrDatabaseOperation)
+				}
+			}
+			if err := repos.Transfers.UpdateLine(line); err != nil {
+				return fmt.Errorf("failed to update transfer line: %w", ErrDatabaseOperation)
+			}
+		}
+		if len(arrived) > 0 {
+			return ErrInvalidTransfer
+		}
+
+		now := s.now()
+		header.Status = model.TransferReceived
+		header.ReceivedBy = &userID
+		header.ReceivedAt = &now
+		if err := repos.Transfers.Update(header); err != nil {
+			return fmt.Errorf("failed to update transfer: %w", ErrDatabaseOperation)
+		}
+		transfer, err = loadTransfer(repos, header.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return transfer, nil
+}
+
+func (s *LocationService) GetTransfer(id uint) (*Transfer, error) {
+	var transfer *Transfer
+	err := s.uow.Do(func(repos *repository.Repositories) error {
+		var err error
+		transfer, err = loadTransfer(repos, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return transfer, nil
+}
+
+// ListTransfers returns the transfers in a status, or all of them for an
+// empty one, newest first and without their lines.
+func (s *LocationService) ListTransfers(status string) ([]Transfer, error) {
+	switch status {
+	case "", model.TransferInTransit, model.TransferReceived:
+	default:
+		return nil, ErrInvalidTransfer
+	}
+	result := []Transfer{}
+	err := s.uow.Do(func(repos *repository.Repositories) error {
+		transfers, err := repos.Transfers.List(status)
+		if err != nil {
+			return fmt.Errorf("failed to list transfers: %w", ErrDatabaseOperation)
+		}
+		names := map[uint]string{}
+		for _, transfer := range transfers {
+			from, err := locationName(repos, names, transfer.FromID)
+			if err != nil {
+				return err
+			}
+			to, err := locationName(repos, names, transfer.ToID)
+			if err != nil {
+				return err
+			}
+			result = append(result, Transfer{Transfer: transfer, From: from, To: to, Lines: []TransferLine{}})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// reconcile checks what arrived of a line against what was sent. For
+// serialized goods the units that arrived must be among those sent; it
+// returns the ones that did not arrive.
+func reconcile(line *model.TransferLine, in ReceiveLineInput) (float64, []string, error) {
+	if !(in.Received >= 0) || math.IsInf(in.Received, 0) {
+		return 0, nil, ErrInvalidQuantity
+	}
+	if line.Serials == "" {
+		if len(in.Serials) > 0 {
+			return 0, nil, ErrInvalidTransfer
+		}
+		return in.Received, nil, nil
+	}
+
+	sent := splitSerials(line.Serials)
+	if in.Received != float64(len(in.Serials)) || len(in.Serials) > len(sent) {
+		return 0, nil, ErrInvalidQuantity
+	}
+	arrived := make(map[string]bool, len(in.Serials))
+	for _, serial := range trimAll(in.Serials) {
+		arrived[strings.ToUpper(serial)] = true
+	}
+	var missing []string
+	for _, serial := range sent {
+		if !arrived[strings.ToUpper(serial)] {
+			missing = append(missing, serial)
+		}
+	}
+	if len(missing) != len(sent)-len(in.Serials) {
+		return 0, nil, ErrInvalidTransfer
+	}
+	return in.Received, missing, nil
+}
+
+// transitPicks splits the lots a line travels in between what arrived and
+// what went missing, so each transfer takes its own goods out of transit.
+func transitPicks(repos *repository.Repositories, line *model.TransferLine, received float64) ([]stock_service.LotPick, []stock_service.LotPick, error) {
+	allocations, err := repos.Lots.ListAllocations([]uint{line.TransitMovementID})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list lot allocations: %w", ErrDatabaseOperation)
+	}
+	var moved, short []stock_service.LotPick
+	arrived := math.Min(received, line.Sent)
+	for _, allocation := range allocations {
+		quantity := allocation.Quantity
+		if take := math.Min(quantity, arrived); take > 0 {
+			moved = append(moved, stock_service.LotPick{LotID: allocation.LotID, Quantity: take})
+			arrived -= take
+			quantity -= take
+		}
+		if quantity > 0 {
+			short = append(short, stock_service.LotPick{LotID: allocation.LotID, Quantity: quantity})
+		}
+	}
+	return moved, short, nil
+}
+
+func getTransfer(repos *repository.Repositories, id uint) (*model.Transfer, error) {
+	transfer, err := repos.Transfers.GetByID(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrTransferNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to fetch transfer: %w", ErrDatabaseOperation)
+	}
+	return transfer, nil
+}
+
+func loadTransfer(repos *repository.Repositories, id uint) (*Transfer, error) {
+	header, err := getTransfer(repos, id)
+	if err != nil {
+		return nil, err
+	}
+	names := map[uint]string{}
+	transfer := &Transfer{Transfer: *header, Lines: []TransferLine{}}
+	if transfer.From, err = locationName(repos, names, header.FromID); err != nil {
+		return nil, err
+	}
+	if transfer.To, err = locationName(repos, names, header.ToID); err != nil {
+		return nil, err
+	}
+
+	lines, err := repos.Transfers.ListLines([]uint{id})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list transfer lines: %w", ErrDatabaseOperation)
+	}
+	for _, line := range lines {
+		item, err := repos.Inventory.GetByID(line.InventoryID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch inventory: %w", ErrDatabaseOperation)
+		}
+		view := TransferLine{TransferLine: line, Name: item.Name, SKU: item.SKU, Unit: item.Unit}
+		if header.Status == model.TransferReceived {
+			view.Difference = line.Received - line.Sent
+		}
+		transfer.Lines = append(transfer.Lines, view)
+	}
+	return transfer, nil
+}
+
+// locationName returns the name of a location, remembering it in names.
+func locationName(repos *repository.Repositories, names map[uint]string, id uint) (string, error) {
+	if name, ok := names[id]; ok {
+		return name, nil
+	}
+	location, err := repos.Locations.GetByID(id)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch location: %w", ErrDatabaseOperation)
+	}
+	names[id] = location.Name
+	return location.Name, nil
+}
+
+func splitSerials(serials string) []string {
+	if serials == "" {
+		return nil
+	}
+	return strings.Split(serials, "\n")
+}
+
+func trimAll(values []string) []string {
+	trimmed := make([]string, 0, len(values))
+	for _, value := range values {
+		trimmed = append(trimmed, strings.TrimSpace(value))
+	}
+	return trimmed
+}
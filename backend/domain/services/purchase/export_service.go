@@ -0,0 +1,221 @@
+package purchase_service
+
+import (
+	"blizzflow/backend/domain/model"
+	repository "blizzflow/backend/domain/repositories"
+	"blizzflow/backend/internal/pdf"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Page layout of the PDF, in points from the top left corner.
+const (
+	margin     = 50.0
+	rowHeight  = 16.0
+	fontSize   = 9.0
+	pageBottom = pdf.PageHeight - 60
+)
+
+// ExportOrderCSV writes an order's lines to path as CSV, for suppliers that
+// import orders into their own systems.
+func (s *PurchaseService) ExportOrderCSV(id uint, path string) error {
+	order, units, err := s.exportable(id)
+	if err != nil {
+		return err
+	}
+	data, err := orderCSV(order, units)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// ExportOrderPDF writes an order to path as a printable A4 document.
+func (s *PurchaseService) ExportOrderPDF(id uint, path string) error {
+	order, units, err := s.exportable(id)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, orderPDF(order, units), 0644)
+}
+
+// exportable loads an order with the name of each line's unit.
+func (s *PurchaseService) exportable(id uint) (*Order, []string, error) {
+	var order *Order
+	var units []string
+	err := s.uow.Do(func(repos *repository.Repositories) error {
+		var err error
+		if order, err = loadOrder(repos, id); err != nil {
+			return err
+		}
+		units = make([]string, len(order.Lines))
+		for i, line := range order.Lines {
+			if units[i], err = unitName(repos, line); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return order, units, nil
+}
+
+func unitName(repos *repository.Repositories, line model.PurchaseOrderLine) (string, error) {
+	if line.UnitID != nil {
+		pack, err := repos.Units.GetPack(*line.UnitID)
+		if err == nil {
+			return pack.Name, nil
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", fmt.Errorf("failed to fetch unit: %w", ErrDatabaseOperation)
+		}
+	}
+	item, err := repos.Inventory.GetByID(line.InventoryID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("failed to fetch inventory: %w", ErrDatabaseOperation)
+	}
+	return item.Unit, nil
+}
+
+func orderCSV(order *Order, units []string) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"Order", "Line", "Supplier code", "Item", "Quantity", "Unit", "Unit cost", "Amount"})
+	for i, line := range order.Lines {
+		w.Write([]string{
+			order.Number,
+			strconv.Itoa(line.Position),
+			line.SupplierSKU,
+			line.Name,
+			formatQuantity(line.Quantity),
+			units[i],
+			formatMoney(line.UnitCost),
+			formatMoney(line.Quantity * line.UnitCost),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, fmt.Errorf("failed to write CSV: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Columns of the PDF line table: the x of each column's left edge, or of
+// its right edge for the numbers.
+const (
+	colLine     = margin
+	colCode     = margin + 25
+	colItem     = margin + 110
+	colQuantity = margin + 355
+	colUnit     = margin + 362
+	colCost     = margin + 445
+	colAmount   = pdf.PageWidth - margin
+)
+
+func orderPDF(order *Order, units []string) []byte {
+	doc := pdf.New()
+	doc.AddPage()
+
+	doc.Text(margin, 60, 18, true, "Purchase order")
+	doc.TextRight(pdf.PageWidth-margin, 60, 14, true, order.Number)
+
+	y := 100.0
+	supplier := []string{order.Supplier.ContactName}
+	supplier = append(supplier, strings.Split(order.Supplier.Address, "\n")...)
+	supplier = append(supplier, order.Supplier.Email, order.Supplier.Phone)
+	doc.Text(margin, y, 11, true, order.Supplier.Name)
+	for _, text := range supplier {
+		if text = strings.TrimSpace(text); text != "" {
+			y += 13
+			doc.Text(margin, y, fontSize, false, text)
+		}
+	}
+
+	details := [][2]string{{"Date", order.CreatedAt.Format("2006-01-02")}}
+	if order.SentAt != nil {
+		details = append(details, [2]string{"Sent", order.SentAt.Format("2006-01-02")})
+	}
+	if order.ExpectedAt != nil {
+		details = append(details, [2]string{"Expected", order.ExpectedAt.Format("2006-01-02")})
+	}
+	if order.Supplier.PaymentTermsDays > 0 {
+		details = append(details, [2]string{"Payment terms", fmt.Sprintf("%d days", order.Supplier.PaymentTermsDays)})
+	}
+	for i, detail := range details {
+		row := 100 + float64(i)*13
+		doc.Text(pdf.PageWidth-margin-170, row, fontSize, true, detail[0])
+		doc.TextRight(pdf.PageWidth-margin, row, fontSize, false, detail[1])
+	}
+	if bottom := 100 + float64(len(details))*13; bottom > y {
+		y = bottom
+	}
+
+	y = tableHeader(doc, y+30)
+	for i, line := range order.Lines {
+		if y > pageBottom {
+			doc.AddPage()
+			y = tableHeader(doc, 60)
+		}
+		doc.Text(colLine, y, fontSize, false, strconv.Itoa(line.Position))
+		doc.Text(colCode, y, fontSize, false, pdf.Fit(line.SupplierSKU, fontSize, colItem-colCode-5))
+		doc.Text(colItem, y, fontSize, false, pdf.Fit(line.Name, fontSize, colQuantity-colItem-50))
+		doc.TextRight(colQuantity, y, fontSize, false, formatQuantity(line.Quantity))
+		doc.Text(colUnit, y, fontSize, false, pdf.Fit(units[i], fontSize, colCost-colUnit-50))
+		doc.TextRight(colCost, y, fontSize, false, formatMoney(line.UnitCost))
+		doc.TextRight(colAmount, y, fontSize, false, formatMoney(line.Quantity*line.UnitCost))
+		y += rowHeight
+	}
+
+	if y > pageBottom {
+		doc.AddPage()
+		y = 60
+	}
+	doc.Line(colCost-60, y-10, colAmount, y-10)
+	doc.Text(colCost-60, y+4, 10, true, "Total")
+	doc.TextRight(colAmount, y+4, 10, true, formatMoney(order.Total))
+
+	if order.Notes != "" {
+		y += 30
+		for _, text := range strings.Split(order.Notes, "\n") {
+			if y > pageBottom {
+				doc.AddPage()
+				y = 60
+			}
+			doc.Text(margin, y, fontSize, false, pdf.Fit(text, fontSize, colAmount-margin))
+			y += 13
+		}
+	}
+	return doc.Bytes()
+}
+
+// tableHeader draws the column titles at y and returns where the first row
+// goes.
+func tableHeader(doc *pdf.Document, y float64) float64 {
+	doc.Text(colLine, y, fontSize, true, "#")
+	doc.Text(colCode, y, fontSize, true, "Code")
+	doc.Text(colItem, y, fontSize, true, "Item")
+	doc.TextRight(colQuantity, y, fontSize, true, "Qty")
+	doc.Text(colUnit, y, fontSize, true, "Unit")
+	doc.TextRight(colCost, y, fontSize, true, "Unit cost")
+	doc.TextRight(colAmount, y, fontSize, true, "Amount")
+	doc.Line(margin, y+5, colAmount, y+5)
+	return y + rowHeight + 4
+}
+
+func formatQuantity(quantity float64) string {
+	return strconv.FormatFloat(quantity, 'f', -1, 64)
+}
+
+func formatMoney(amount float64) string {
+	return strconv.FormatFloat(roundCost(amount), 'f', 2, 64)
+}
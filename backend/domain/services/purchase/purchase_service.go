@@ -0,0 +1,640 @@
+package purchase_service
+
+import (
+	"blizzflow/backend/domain/model"
+	repository "blizzflow/backend/domain/repositories"
+	reorder_service "blizzflow/backend/domain/services/reorder"
+	stock_service "blizzflow/backend/domain/services/stock"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	maxNameLength = 100
+	maxTextLength = 1000
+)
+
+// Custom errors
+var (
+	ErrInvalidSupplier     = fmt.Errorf("invalid supplier")
+	ErrDuplicateSupplier   = fmt.Errorf("a supplier with this name already exists")
+	ErrSupplierNotFound    = fmt.Errorf("supplier not found")
+	ErrSupplierArchived    = fmt.Errorf("supplier is archived")
+	ErrSupplierInUse       = fmt.Errorf("supplier has orders; archive it instead")
+	ErrSupplierItemMissing = fmt.Errorf("supplier item not found")
+	ErrInvalidSupplierItem = fmt.Errorf("invalid supplier item")
+	ErrOrderNotFound       = fmt.Errorf("purchase order not found")
+	ErrInvalidOrder        = fmt.Errorf("invalid purchase order")
+	ErrEmptyOrder          = fmt.Errorf("purchase order has no lines")
+	ErrInvalidTransition   = fmt.Errorf("purchase order cannot move to this status")
+	ErrOrderNotDraft       = fmt.Errorf("only draft orders can be changed")
+	ErrNothingToOrder      = fmt.Errorf("no low-stock item has a supplier")
+	ErrOrderNotOpen        = fmt.Errorf("purchase order is not open for deliveries")
+	ErrInvalidReceipt      = fmt.Errorf("invalid goods receipt")
+	ErrEmptyReceipt        = fmt.Errorf("goods receipt has nothing on it")
+	ErrNotOnOrder          = fmt.Errorf("item is not on this order")
+	ErrInvalidPeriod       = fmt.Errorf("invalid period")
+	ErrLotRequired         = stock_service.ErrLotRequired
+	ErrSerialRequired      = stock_service.ErrSerialRequired
+	ErrLocationNotFound    = stock_service.ErrLocationNotFound
+	ErrLocationArchived    = stock_service.ErrLocationArchived
+	ErrInventoryNotFound   = stock_service.ErrInventoryNotFound
+	ErrInventoryArchived   = fmt.Errorf("inventory is archived")
+	ErrVariantRequired     = stock_service.ErrVariantRequired
+	ErrUnitNotFound        = stock_service.ErrUnitNotFound
+	ErrInvalidQuantity     = stock_service.ErrInvalidQuantity
+	ErrInvalidCost         = stock_service.ErrInvalidCost
+	ErrSessionNotFound     = fmt.Errorf("session not found")
+	ErrDatabaseOperation   = fmt.Errorf("database operation failed")
+)
+
+// transitions lists the statuses an order may move to from each status.
+// Deliveries move a sent order to partial or received; closing gives up on
+// the rest.
+var transitions = map[string][]string{
+	model.PurchaseDraft:    {model.PurchaseSent},
+	model.PurchaseSent:     {model.PurchasePartial, model.PurchaseReceived, model.PurchaseClosed},
+	model.PurchasePartial:  {model.PurchasePartial, model.PurchaseReceived, model.PurchaseClosed},
+	model.PurchaseReceived: {model.PurchaseClosed},
+}
+
+// CanMove reports whether an order in status from may move to status to.
+func CanMove(from, to string) bool {
+	for _, status := range transitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// LineInput is one item to order. Quantity and UnitCost are per UnitID, a
+// pack of the item, or per the pack the supplier sells in when UnitID is
+// nil. A nil UnitCost takes the supplier's cost, or else the item's last
+// cost.
+type LineInput struct {
+	InventoryID uint     `json:"inventoryId"`
+	UnitID      *uint    `json:"unitId"`
+	Quantity    float64  `json:"quantity"`
+	UnitCost    *float64 `json:"unitCost"`
+}
+
+type OrderInput struct {
+	SupplierID uint        `json:"supplierId"`
+	Notes      string      `json:"notes"`
+	ExpectedAt *time.Time  `json:"expectedAt"`
+	Lines      []LineInput `json:"lines"`
+}
+
+// OrderQuery filters ListOrders; zero fields select all.
+type OrderQuery struct {
+	SupplierID uint   `json:"supplierId"`
+	Status     string `json:"status"`
+	Limit      int    `json:"limit"`
+}
+
+// Order is a purchase order with its supplier and lines.
+type Order struct {
+	model.PurchaseOrder
+	Supplier model.Supplier            `json:"supplier"`
+	Lines    []model.PurchaseOrderLine `json:"lines"`
+	Total    float64                   `json:"total"`
+}
+
+// SuggestedOrders is what OrdersFromSuggestions drafted. Unsourced lists
+// the low items no supplier sells, which have to be ordered by hand.
+type SuggestedOrders struct {
+	Orders    []Order                      `json:"orders"`
+	Unsourced []reorder_service.Suggestion `json:"unsourced"`
+}
+
+// Suggester lists the items that need ordering.
+type Suggester interface {
+	LowStock() ([]reorder_service.Suggestion, error)
+}
+
+type PurchaseService struct {
+	supplierRepo repository.SupplierRepo
+	orderRepo    repository.PurchaseOrderRepo
+	sessionRepo  repository.SessionRepo
+	suggester    Suggester
+	uow          repository.UnitOfWork
+	now          func() time.Time
+}
+
+func NewPurchaseService(
+	supplierRepo repository.SupplierRepo,
+	orderRepo repository.PurchaseOrderRepo,
+	sessionRepo repository.SessionRepo,
+	suggester Suggester,
+	uow repository.UnitOfWork,
+) *PurchaseService {
+	return &PurchaseService{
+		supplierRepo: supplierRepo,
+		orderRepo:    orderRepo,
+		sessionRepo:  sessionRepo,
+		suggester:    suggester,
+		uow:          uow,
+		now:          time.Now,
+	}
+}
+
+// CreateOrder drafts an order to a supplier.
+func (s *PurchaseService) CreateOrder(sessionID uint, input OrderInput) (*Order, error) {
+	userID, err := s.userID(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	var order *Order
+	err = s.uow.Do(func(repos *repository.Repositories) error {
+		var err error
+		order, err = createOrder(repos, userID, input)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// UpdateOrder rewrites a draft order, lines included.
+func (s *PurchaseService) UpdateOrder(id uint, input OrderInput) (*Order, error) {
+	var order *Order
+	err := s.uow.Do(func(repos *repository.Repositories) error {
+		current, err := getOrder(repos, id)
+		if err != nil {
+			return err
+		}
+		if current.Status != model.PurchaseDraft {
+			return ErrOrderNotDraft
+		}
+		if err := normalizeOrder(&input); err != nil {
+			return err
+		}
+		if _, err := activeSupplier(repos, input.SupplierID); err != nil {
+			return err
+		}
+		lines, err := buildLines(repos, input.SupplierID, input.Lines)
+		if err != nil {
+			return err
+		}
+
+		current.SupplierID = input.SupplierID
+		current.Notes = input.Notes
+		current.ExpectedAt = input.ExpectedAt
+		if err := repos.PurchaseOrders.Update(current); err != nil {
+			return fmt.Errorf("failed to update purchase order: %w", ErrDatabaseOperation)
+		}
+		if err := repos.PurchaseOrders.SetLines(id, lines); err != nil {
+			return fmt.Errorf("failed to save order lines: %w", ErrDatabaseOperation)
+		}
+		order, err = loadOrder(repos, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// DeleteOrder removes a draft order. Sent orders are closed instead, so the
+// supplier's copy keeps matching ours.
+func (s *PurchaseService) DeleteOrder(id uint) error {
+	return s.uow.Do(func(repos *repository.Repositories) error {
+		order, err := getOrder(repos, id)
+		if err != nil {
+			return err
+		}
+		if order.Status != model.PurchaseDraft {
+			return ErrOrderNotDraft
+		}
+		if err := repos.PurchaseOrders.Delete(id); err != nil {
+			return fmt.Errorf("failed to delete purchase order: %w", ErrDatabaseOperation)
+		}
+		return nil
+	})
+}
+
+func (s *PurchaseService) GetOrder(id uint) (*Order, error) {
+	var order *Order
+	err := s.uow.Do(func(repos *repository.Repositories) error {
+		var err error
+		order, err = loadOrder(repos, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// ListOrders returns the orders query selects, newest first.
+func (s *PurchaseService) ListOrders(query OrderQuery) ([]Order, error) {
+	orders, err := s.orderRepo.List(repository.PurchaseOrderFilter{
+		SupplierID: query.SupplierID,
+		Status:     query.Status,
+		Limit:      query.Limit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list purchase orders: %w", ErrDatabaseOperation)
+	}
+
+	ids := make([]uint, len(orders))
+	for i, order := range orders {
+		ids[i] = order.ID
+	}
+	lines, err := s.orderRepo.ListLines(ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list order lines: %w", ErrDatabaseOperation)
+	}
+	byOrder := make(map[uint][]model.PurchaseOrderLine)
+	for _, line := range lines {
+		byOrder[line.OrderID] = append(byOrder[line.OrderID], line)
+	}
+	suppliers, err := s.supplierRepo.List(true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list suppliers: %w", ErrDatabaseOperation)
+	}
+	byID := make(map[uint]model.Supplier, len(suppliers))
+	for _, supplier := range suppliers {
+		byID[supplier.ID] = supplier
+	}
+
+	result := make([]Order, len(orders))
+	for i, order := range orders {
+		result[i] = newOrder(order, byID[order.SupplierID], byOrder[order.ID])
+	}
+	return result, nil
+}
+
+// SendOrder marks a draft as sent to the supplier. Its lines are fixed from
+// then on.
+func (s *PurchaseService) SendOrder(id uint) (*Order, error) {
+	return s.move(id, model.PurchaseSent, func(repos *repository.Repositories, order *model.PurchaseOrder) error {
+		lines, err := repos.PurchaseOrders.ListLines([]uint{id})
+		if err != nil {
+			return fmt.Errorf("failed to list order lines: %w", ErrDatabaseOperation)
+		}
+		if len(lines) == 0 {
+			return ErrEmptyOrder
+		}
+		now := s.now()
+		order.SentAt = &now
+		return nil
+	})
+}
+
+// CloseOrder ends an order that was sent, giving up on anything still
+// outstanding.
+func (s *PurchaseService) CloseOrder(id uint) (*Order, error) {
+	return s.move(id, model.PurchaseClosed, func(_ *repository.Repositories, order *model.PurchaseOrder) error {
+		now := s.now()
+		order.ClosedAt = &now
+		return nil
+	})
+}
+
+// OrdersFromSuggestions drafts orders for low-stock items, one per
+// supplier. inventoryIDs picks from the suggestions; none takes them all.
+// Each item goes to its preferred supplier, or else to the one selling it
+// cheapest per base unit, in whole packs of what that supplier sells. What
+// open orders, drafts included, still bring is taken off first unless the
+// suggestion counted it already, so items they cover are skipped and
+// asking twice drafts nothing new.
+func (s *PurchaseService) OrdersFromSuggestions(sessionID uint, inventoryIDs []uint) (*SuggestedOrders, error) {
+	userID, err := s.userID(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	suggestions, err := s.suggester.LowStock()
+	if err != nil {
+		return nil, err
+	}
+	if len(inventoryIDs) > 0 {
+		wanted := make(map[uint]bool, len(inventoryIDs))
+		for _, id := range inventoryIDs {
+			wanted[id] = true
+		}
+		picked := suggestions[:0]
+		for _, suggestion := range suggestions {
+			if wanted[suggestion.InventoryID] {
+				picked = append(picked, suggestion)
+			}
+		}
+		suggestions = picked
+	}
+
+	result := &SuggestedOrders{Orders: []Order{}, Unsourced: []reorder_service.Suggestion{}}
+	err = s.uow.Do(func(repos *repository.Repositories) error {
+		onOrder, err := repos.PurchaseOrders.OnOrder()
+		if err != nil {
+			return fmt.Errorf("failed to total open orders: %w", ErrDatabaseOperation)
+		}
+
+		inputs := make(map[uint]*OrderInput)
+		var supplierIDs []uint
+		for _, suggestion := range suggestions {
+			quantity := suggestion.Quantity - max(onOrder[suggestion.InventoryID]-suggestion.OnOrder, 0)
+			if suggestion.Quantity > 0 && quantity <= 1e-9 {
+				continue
+			}
+			source, factor, err := cheapestSource(repos, suggestion.InventoryID)
+			if err != nil {
+				return err
+			}
+			if source == nil || !(quantity > 0) {
+				result.Unsourced = append(result.Unsourced, suggestion)
+				continue
+			}
+
+			input, ok := inputs[source.SupplierID]
+			if !ok {
+				input = &OrderInput{SupplierID: source.SupplierID}
+				inputs[source.SupplierID] = input
+				supplierIDs = append(supplierIDs, source.SupplierID)
+			}
+			lead := suggestion.LeadTimeDays
+			if source.LeadTimeDays != nil {
+				lead = *source.LeadTimeDays
+			}
+			expected := s.now().AddDate(0, 0, lead)
+			if input.ExpectedAt == nil || expected.After(*input.ExpectedAt) {
+				input.ExpectedAt = &expected
+			}
+			input.Lines = append(input.Lines, LineInput{
+				InventoryID: suggestion.InventoryID,
+				UnitID:      source.UnitID,
+				Quantity:    math.Ceil(quantity/factor - 1e-9),
+			})
+		}
+		if len(inputs) == 0 {
+			return ErrNothingToOrder
+		}
+
+		sort.Slice(supplierIDs, func(i, j int) bool { return supplierIDs[i] < supplierIDs[j] })
+		for _, supplierID := range supplierIDs {
+			order, err := createOrder(repos, userID, *inputs[supplierID])
+			if err != nil {
+				return err
+			}
+			result.Orders = append(result.Orders, *order)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// move sets an order's status when the transition is allowed, after apply
+// had its say.
+func (s *PurchaseService) move(id uint, status string, apply func(*repository.Repositories, *model.PurchaseOrder) error) (*Order, error) {
+	var order *Order
+	err := s.uow.Do(func(repos *repository.Repositories) error {
+		current, err := getOrder(repos, id)
+		if err != nil {
+			return err
+		}
+		if !CanMove(current.Status, status) {
+			return fmt.Errorf("%s to %s: %w", current.Status, status, ErrInvalidTransition)
+		}
+		if err := apply(repos, current); err != nil {
+			return err
+		}
+		current.Status = status
+		if err := repos.PurchaseOrders.Update(current); err != nil {
+			return fmt.Errorf("failed to update purchase order: %w", ErrDatabaseOperation)
+		}
+		order, err = loadOrder(repos, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// userID resolves the user a frontend session belongs to.
+func (s *PurchaseService) userID(sessionID uint) (uint, error) {
+	session, err := s.sessionRepo.GetSession(sessionID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch session: %w", ErrDatabaseOperation)
+	}
+	if session == nil {
+		return 0, ErrSessionNotFound
+	}
+	return session.UserID, nil
+}
+
+func createOrder(repos *repository.Repositories, userID uint, input OrderInput) (*Order, error) {
+	if err := normalizeOrder(&input); err != nil {
+		return nil, err
+	}
+	if _, err := activeSupplier(repos, input.SupplierID); err != nil {
+		return nil, err
+	}
+	lines, err := buildLines(repos, input.SupplierID, input.Lines)
+	if err != nil {
+		return nil, err
+	}
+
+	order := &model.PurchaseOrder{
+		SupplierID: input.SupplierID,
+		Status:     model.PurchaseDraft,
+		Notes:      input.Notes,
+		ExpectedAt: input.ExpectedAt,
+		CreatedBy:  userID,
+	}
+	if err := repos.PurchaseOrders.Create(order); err != nil {
+		return nil, fmt.Errorf("failed to create purchase order: %w", ErrDatabaseOperation)
+	}
+	order.Number = fmt.Sprintf("PO-%06d", order.ID)
+	if err := repos.PurchaseOrders.Update(order); err != nil {
+		return nil, fmt.Errorf("failed to number purchase order: %w", ErrDatabaseOperation)
+	}
+	if err := repos.PurchaseOrders.SetLines(order.ID, lines); err != nil {
+		return nil, fmt.Errorf("failed to save order lines: %w", ErrDatabaseOperation)
+	}
+	return loadOrder(repos, order.ID)
+}
+
+func normalizeOrder(input *OrderInput) error {
+	input.Notes = strings.TrimSpace(input.Notes)
+	if input.SupplierID == 0 || len(input.Notes) > maxTextLength {
+		return ErrInvalidOrder
+	}
+	return nil
+}
+
+// buildLines turns inputs into order lines, filling in what the supplier
+// sells each item as.
+func buildLines(repos *repository.Repositories, supplierID uint, inputs []LineInput) ([]model.PurchaseOrderLine, error) {
+	lines := make([]model.PurchaseOrderLine, 0, len(inputs))
+	for i, input := range inputs {
+		item, err := repos.Inventory.GetByID(input.InventoryID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInventoryNotFound
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to fetch inventory: %w", ErrDatabaseOperation)
+		}
+		if item.ArchivedAt != nil {
+			return nil, fmt.Errorf("%s: %w", item.Name, ErrInventoryArchived)
+		}
+		variants, err := repos.Inventory.CountVariants(item.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count variants: %w", ErrDatabaseOperation)
+		}
+		if variants > 0 {
+			return nil, ErrVariantRequired
+		}
+
+		source, err := repos.Suppliers.FindItem(supplierID, item.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch supplier item: %w", ErrDatabaseOperation)
+		}
+		line := model.PurchaseOrderLine{
+			InventoryID: item.ID,
+			UnitID:      input.UnitID,
+			Name:        item.Name,
+			Quantity:    input.Quantity,
+			Position:    i + 1,
+		}
+		if source != nil {
+			line.SupplierSKU = source.SupplierSKU
+			if line.UnitID == nil {
+				line.UnitID = source.UnitID
+			}
+		}
+
+		if !(input.Quantity > 0) {
+			return nil, ErrInvalidQuantity
+		}
+		if _, err := stock_service.Convert(repos, item, input.Quantity, line.UnitID); err != nil {
+			return nil, err
+		}
+		switch {
+		case input.UnitCost != nil:
+			line.UnitCost = *input.UnitCost
+		case source != nil && sameUnit(source.UnitID, line.UnitID):
+			line.UnitCost = source.Cost
+		default:
+			factor, err := packFactor(repos, line.UnitID)
+			if err != nil {
+				return nil, err
+			}
+			line.UnitCost = roundCost(item.LastCost * factor)
+		}
+		if !validCost(line.UnitCost) {
+			return nil, ErrInvalidCost
+		}
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+// cheapestSource picks the supplier to order an item from: the preferred
+// one, or else the cheapest per base unit. It returns nil when nobody
+// sells the item, and the base units in one unit of what the pick sells.
+func cheapestSource(repos *repository.Repositories, inventoryID uint) (*model.SupplierItem, float64, error) {
+	sources, err := repos.Suppliers.ListByInventory(inventoryID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list supplier items: %w", ErrDatabaseOperation)
+	}
+
+	var best *model.SupplierItem
+	var bestFactor, bestCost float64
+	for i := range sources {
+		source := &sources[i]
+		supplier, err := repos.Suppliers.GetByID(source.SupplierID)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to fetch supplier: %w", ErrDatabaseOperation)
+		}
+		if supplier.ArchivedAt != nil {
+			continue
+		}
+		factor, err := packFactor(repos, source.UnitID)
+		if err != nil {
+			return nil, 0, err
+		}
+		if source.Preferred {
+			return source, factor, nil
+		}
+		if cost := source.Cost / factor; best == nil || cost < bestCost {
+			best, bestFactor, bestCost = source, factor, cost
+		}
+	}
+	return best, bestFactor, nil
+}
+
+// packFactor returns the base units in one of a pack, or 1 for the base
+// unit itself.
+func packFactor(repos *repository.Repositories, unitID *uint) (float64, error) {
+	if unitID == nil {
+		return 1, nil
+	}
+	pack, err := repos.Units.GetPack(*unitID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, ErrUnitNotFound
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to fetch unit: %w", ErrDatabaseOperation)
+	}
+	return pack.Factor, nil
+}
+
+func getOrder(repos *repository.Repositories, id uint) (*model.PurchaseOrder, error) {
+	order, err := repos.PurchaseOrders.GetByID(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrOrderNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to fetch purchase order: %w", ErrDatabaseOperation)
+	}
+	return order, nil
+}
+
+func loadOrder(repos *repository.Repositories, id uint) (*Order, error) {
+	order, err := getOrder(repos, id)
+	if err != nil {
+		return nil, err
+	}
+	supplier, err := repos.Suppliers.GetByID(order.SupplierID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch supplier: %w", ErrDatabaseOperation)
+	}
+	lines, err := repos.PurchaseOrders.ListLines([]uint{id})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list order lines: %w", ErrDatabaseOperation)
+	}
+	result := newOrder(*order, *supplier, lines)
+	return &result, nil
+}
+
+func newOrder(order model.PurchaseOrder, supplier model.Supplier, lines []model.PurchaseOrderLine) Order {
+	if lines == nil {
+		lines = []model.PurchaseOrderLine{}
+	}
+	var total float64
+	for _, line := range lines {
+		total += line.Quantity * line.UnitCost
+	}
+	return Order{PurchaseOrder: order, Supplier: supplier, Lines: lines, Total: roundCost(total)}
+}
+
+func sameUnit(a, b *uint) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
+
+func validCost(cost float64) bool {
+	return cost >= 0 && !math.IsInf(cost, 0)
+}
+
+func roundCost(cost float64) float64 {
+	return math.Round(cost*100) / 100
+}
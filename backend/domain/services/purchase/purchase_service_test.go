@@ -0,0 +1,527 @@
+package purchase_service
+
+import (
+	"blizzflow/backend/domain/model"
+	repository "blizzflow/backend/domain/repositories"
+	reorder_service "blizzflow/backend/domain/services/reorder"
+	stock_service "blizzflow/backend/domain/services/stock"
+	"blizzflow/backend/infrastructure/database"
+	"blizzflow/backend/internal/barcode"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+	"gorm.io/gorm"
+)
+
+func TestPurchaseServiceSuite(t *testing.T) {
+	gomega.RegisterFailHandler(ginkgo.Fail)
+	ginkgo.RunSpecs(t, "Purchase Service Test Suite")
+}
+
+const testDBPath = "test.db"
+
+var (
+	DB              *gorm.DB
+	purchaseService *PurchaseService
+	suggestions     stubSuggester
+)
+
+// stubSuggester hands out fixed low-stock suggestions.
+type stubSuggester []reorder_service.Suggestion
+
+func (s *stubSuggester) LowStock() ([]reorder_service.Suggestion, error) {
+	return append([]reorder_service.Suggestion(nil), (*s)...), nil
+}
+
+var _ = ginkgo.BeforeSuite(func() {
+	os.Remove(testDBPath)
+	store, err := database.Open(database.DefaultOptions(testDBPath))
+	gomega.Expect(err).To(gomega.BeNil())
+	DB = store.DB()
+	purchaseService = NewPurchaseService(
+		repository.NewSupplierRepository(DB),
+		repository.NewPurchaseOrderRepository(DB),
+		repository.NewSessionRepository(DB),
+		&suggestions,
+		repository.NewUnitOfWork(DB),
+	)
+})
+
+var _ = ginkgo.AfterSuite(func() {
+	if DB != nil {
+		sqlDB, err := DB.DB()
+		if err == nil {
+			sqlDB.Close()
+		}
+	}
+	os.Remove(testDBPath)
+})
+
+var _ = ginkgo.Describe("Purchase Service", func() {
+	var (
+		session      *model.Session
+		beans, rice  *model.Inventory
+		crate        *model.ItemUnit
+		acme, bulkCo *model.Supplier
+		cost, days   = func(v float64) *float64 { return &v }, func(v int) *int { return &v }
+	)
+
+	ginkgo.BeforeEach(func() {
+		for _, table := range []string{"goods_receipt_lines", "goods_receipts", "purchase_order_lines", "purchase_orders", "supplier_items", "suppliers", "item_units", "barcodes", "serial_events", "serial_numbers", "lot_allocations", "lots", "cost_layers", "stock_movements", "inventories", "sessions"} {
+			DB.Exec("DELETE FROM " + table)
+		}
+		DB.Exec("DELETE FROM locations WHERE id > 2")
+		suggestions = nil
+
+		session = &model.Session{UserID: 7}
+		DB.Create(session)
+		beans = &model.Inventory{Name: "Beans", Price: 2, LastCost: 1.1}
+		rice = &model.Inventory{Name: "Rice", Price: 3, LastCost: 1.5}
+		DB.Create(beans)
+		DB.Create(rice)
+		crate = &model.ItemUnit{InventoryID: beans.ID, Name: "crate", Factor: 12}
+		DB.Create(crate)
+
+		var err error
+		acme, err = purchaseService.CreateSupplier(SupplierInput{Name: "Acme", ContactName: "Ann", PaymentTermsDays: 30})
+		gomega.Expect(err).To(gomega.BeNil())
+		bulkCo, err = purchaseService.CreateSupplier(SupplierInput{Name: "Bulk Co"})
+		gomega.Expect(err).To(gomega.BeNil())
+	})
+
+	ginkgo.Context("Suppliers", func() {
+		ginkgo.It("should keep names unique and refuse bad details", func() {
+			_, err := purchaseService.CreateSupplier(SupplierInput{Name: " acme "})
+			gomega.Expect(err).To(gomega.Equal(ErrDuplicateSupplier))
+			_, err = purchaseService.CreateSupplier(SupplierInput{Name: "Other", Email: "nope"})
+			gomega.Expect(err).To(gomega.Equal(ErrInvalidSupplier))
+			_, err = purchaseService.CreateSupplier(SupplierInput{Name: "Other", PaymentTermsDays: -1})
+			gomega.Expect(err).To(gomega.Equal(ErrInvalidSupplier))
+
+			updated, err := purchaseService.UpdateSupplier(acme.ID, SupplierInput{Name: "ACME", Email: "orders@acme.test"})
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(updated.Name).To(gomega.Equal("ACME"))
+			_, err = purchaseService.UpdateSupplier(bulkCo.ID, SupplierInput{Name: "acme"})
+			gomega.Expect(err).To(gomega.Equal(ErrDuplicateSupplier))
+		})
+
+		ginkgo.It("should archive suppliers with orders rather than delete them", func() {
+			_, err := purchaseService.CreateOrder(session.ID, OrderInput{
+				SupplierID: acme.ID,
+				Lines:      []LineInput{{InventoryID: rice.ID, Quantity: 5}},
+			})
+			gomega.Expect(err).To(gomega.BeNil())
+
+			gomega.Expect(purchaseService.DeleteSupplier(acme.ID)).To(gomega.Equal(ErrSupplierInUse))
+			gomega.Expect(purchaseService.DeleteSupplier(bulkCo.ID)).To(gomega.Succeed())
+
+			_, err = purchaseService.ArchiveSupplier(acme.ID)
+			gomega.Expect(err).To(gomega.BeNil())
+			suppliers, err := purchaseService.ListSuppliers(false)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(suppliers).To(gomega.BeEmpty())
+			_, err = purchaseService.CreateOrder(session.ID, OrderInput{
+				SupplierID: acme.ID,
+				Lines:      []LineInput{{InventoryID: rice.ID, Quantity: 5}},
+			})
+			gomega.Expect(err).To(gomega.Equal(ErrSupplierArchived))
+		})
+
+		ginkgo.It("should keep one preferred supplier per item", func() {
+			first, err := purchaseService.SaveSupplierItem(SupplierItemInput{SupplierID: acme.ID, InventoryID: beans.ID, Cost: 1, Preferred: true})
+			gomega.Expect(err).To(gomega.BeNil())
+			_, err = purchaseService.SaveSupplierItem(SupplierItemInput{SupplierID: bulkCo.ID, InventoryID: beans.ID, UnitID: &crate.ID, Cost: 10, Preferred: true})
+			gomega.Expect(err).To(gomega.BeNil())
+
+			// Saving again updates rather than adds
+			again, err := purchaseService.SaveSupplierItem(SupplierItemInput{SupplierID: acme.ID, InventoryID: beans.ID, SupplierSKU: "AC-1", Cost: 0.9})
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(again.ID).To(gomega.Equal(first.ID))
+
+			sources, err := purchaseService.ItemSuppliers(beans.ID)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(sources).To(gomega.HaveLen(2))
+			gomega.Expect(sources[0].SupplierID).To(gomega.Equal(bulkCo.ID))
+			gomega.Expect(sources[1].Preferred).To(gomega.BeFalse())
+
+			_, err = purchaseService.SaveSupplierItem(SupplierItemInput{SupplierID: acme.ID, InventoryID: rice.ID, UnitID: &crate.ID, Cost: 1})
+			gomega.Expect(err).To(gomega.Equal(ErrUnitNotFound))
+			_, err = purchaseService.SaveSupplierItem(SupplierItemInput{SupplierID: acme.ID, InventoryID: rice.ID, Cost: -1})
+			gomega.Expect(err).To(gomega.Equal(ErrInvalidSupplierItem))
+		})
+	})
+
+	ginkgo.Context("Orders", func() {
+		ginkgo.It("should fill lines in from what the supplier sells", func() {
+			purchaseService.SaveSupplierItem(SupplierItemInput{SupplierID: acme.ID, InventoryID: beans.ID, SupplierSKU: "AC-B12", UnitID: &crate.ID, Cost: 12.5})
+
+			order, err := purchaseService.CreateOrder(session.ID, OrderInput{
+				SupplierID: acme.ID,
+				Lines: []LineInput{
+					{InventoryID: beans.ID, Quantity: 2},
+					{InventoryID: rice.ID, Quantity: 10},
+					{InventoryID: rice.ID, Quantity: 1, UnitCost: cost(1.25)},
+				},
+			})
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(order.Status).To(gomega.Equal(model.PurchaseDraft))
+			gomega.Expect(order.Number).To(gomega.HavePrefix("PO-0"))
+			gomega.Expect(order.CreatedBy).To(gomega.Equal(uint(7)))
+			gomega.Expect(order.Supplier.Name).To(gomega.Equal("Acme"))
+			gomega.Expect(order.Lines).To(gomega.HaveLen(3))
+			gomega.Expect(order.Lines[0].SupplierSKU).To(gomega.Equal("AC-B12"))
+			gomega.Expect(*order.Lines[0].UnitID).To(gomega.Equal(crate.ID))
+			gomega.Expect(order.Lines[0].UnitCost).To(gomega.Equal(12.5))
+			gomega.Expect(order.Lines[1].UnitCost).To(gomega.Equal(1.5))
+			gomega.Expect(order.Total).To(gomega.Equal(25 + 15 + 1.25))
+
+			_, err = purchaseService.CreateOrder(session.ID, OrderInput{SupplierID: acme.ID, Lines: []LineInput{{InventoryID: rice.ID, Quantity: 0}}})
+			gomega.Expect(err).To(gomega.Equal(ErrInvalidQuantity))
+			_, err = purchaseService.CreateOrder(session.ID, OrderInput{SupplierID: acme.ID, Lines: []LineInput{{InventoryID: rice.ID, Quantity: 1.5}}})
+			gomega.Expect(err).To(gomega.MatchError(ErrInvalidQuantity))
+			_, err = purchaseService.CreateOrder(999, OrderInput{SupplierID: acme.ID})
+			gomega.Expect(err).To(gomega.Equal(ErrSessionNotFound))
+		})
+
+		ginkgo.It("should move through its statuses", func() {
+			order, err := purchaseService.CreateOrder(session.ID, OrderInput{SupplierID: acme.ID})
+			gomega.Expect(err).To(gomega.BeNil())
+			_, err = purchaseService.SendOrder(order.ID)
+			gomega.Expect(err).To(gomega.Equal(ErrEmptyOrder))
+
+			order, err = purchaseService.UpdateOrder(order.ID, OrderInput{
+				SupplierID: bulkCo.ID,
+				Notes:      "Deliver to the back door",
+				Lines:      []LineInput{{InventoryID: rice.ID, Quantity: 20}},
+			})
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(order.Supplier.Name).To(gomega.Equal("Bulk Co"))
+
+			order, err = purchaseService.SendOrder(order.ID)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(order.Status).To(gomega.Equal(model.PurchaseSent))
+			gomega.Expect(order.SentAt).NotTo(gomega.BeNil())
+
+			_, err = purchaseService.UpdateOrder(order.ID, OrderInput{SupplierID: bulkCo.ID})
+			gomega.Expect(err).To(gomega.Equal(ErrOrderNotDraft))
+			gomega.Expect(purchaseService.DeleteOrder(order.ID)).To(gomega.Equal(ErrOrderNotDraft))
+			_, err = purchaseService.SendOrder(order.ID)
+			gomega.Expect(err).To(gomega.MatchError(ErrInvalidTransition))
+
+			order, err = purchaseService.CloseOrder(order.ID)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(order.Status).To(gomega.Equal(model.PurchaseClosed))
+			_, err = purchaseService.CloseOrder(order.ID)
+			gomega.Expect(err).To(gomega.MatchError(ErrInvalidTransition))
+
+			orders, err := purchaseService.ListOrders(OrderQuery{Status: model.PurchaseClosed})
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(orders).To(gomega.HaveLen(1))
+			gomega.Expect(orders[0].Lines).To(gomega.HaveLen(1))
+			gomega.Expect(orders[0].Total).To(gomega.Equal(30.0))
+		})
+
+		ginkgo.It("should draft one order per supplier from low-stock suggestions", func() {
+			purchaseService.SaveSupplierItem(SupplierItemInput{SupplierID: acme.ID, InventoryID: beans.ID, Cost: 1.2})
+			purchaseService.SaveSupplierItem(SupplierItemInput{SupplierID: bulkCo.ID, InventoryID: beans.ID, UnitID: &crate.ID, Cost: 12, LeadTimeDays: days(3)})
+			purchaseService.SaveSupplierItem(SupplierItemInput{SupplierID: acme.ID, InventoryID: rice.ID, Cost: 1.6, Preferred: true})
+			purchaseService.SaveSupplierItem(SupplierItemInput{SupplierID: bulkCo.ID, InventoryID: rice.ID, Cost: 1.4})
+			lentils := &model.Inventory{Name: "Lentils"}
+			DB.Create(lentils)
+
+			suggestions = stubSuggester{
+				{InventoryID: beans.ID, Name: "Beans", Quantity: 30, LeadTimeDays: 7},
+				{InventoryID: rice.ID, Name: "Rice", Quantity: 8, LeadTimeDays: 7},
+				{InventoryID: lentils.ID, Name: "Lentils", Quantity: 4, LeadTimeDays: 7},
+			}
+			result, err := purchaseService.OrdersFromSuggestions(session.ID, nil)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(result.Unsourced).To(gomega.HaveLen(1))
+			gomega.Expect(result.Unsourced[0].Name).To(gomega.Equal("Lentils"))
+			gomega.Expect(result.Orders).To(gomega.HaveLen(2))
+
+			// Rice goes to its preferred supplier, beans to the cheapest, in
+			// whole crates
+			gomega.Expect(result.Orders[0].SupplierID).To(gomega.Equal(acme.ID))
+			gomega.Expect(result.Orders[0].Lines).To(gomega.HaveLen(1))
+			gomega.Expect(result.Orders[0].Lines[0].InventoryID).To(gomega.Equal(rice.ID))
+			gomega.Expect(result.Orders[0].Lines[0].Quantity).To(gomega.Equal(8.0))
+			gomega.Expect(result.Orders[1].SupplierID).To(gomega.Equal(bulkCo.ID))
+			gomega.Expect(result.Orders[1].Lines[0].Quantity).To(gomega.Equal(3.0))
+			gomega.Expect(result.Orders[1].Lines[0].UnitCost).To(gomega.Equal(12.0))
+			gomega.Expect(*result.Orders[1].ExpectedAt).To(gomega.BeTemporally("~", time.Now().AddDate(0, 0, 3), time.Minute))
+
+			_, err = purchaseService.OrdersFromSuggestions(session.ID, []uint{lentils.ID})
+			gomega.Expect(err).To(gomega.Equal(ErrNothingToOrder))
+
+			// The drafts cover the shortfall, so asking again drafts nothing
+			_, err = purchaseService.OrdersFromSuggestions(session.ID, nil)
+			gomega.Expect(err).To(gomega.Equal(ErrNothingToOrder))
+
+			// Only what the open orders leave short is ordered
+			suggestions[1].Quantity = 10
+			result, err = purchaseService.OrdersFromSuggestions(session.ID, []uint{rice.ID})
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(result.Orders).To(gomega.HaveLen(1))
+			gomega.Expect(result.Orders[0].Lines[0].Quantity).To(gomega.Equal(2.0))
+
+			// A suggestion that counted the open orders already stands as is
+			suggestions[1].OnOrder = 10
+			result, err = purchaseService.OrdersFromSuggestions(session.ID, []uint{rice.ID})
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(result.Orders[0].Lines[0].Quantity).To(gomega.Equal(10.0))
+		})
+
+		ginkgo.It("should export to CSV and PDF", func() {
+			purchaseService.SaveSupplierItem(SupplierItemInput{SupplierID: acme.ID, InventoryID: beans.ID, SupplierSKU: "AC-B12", UnitID: &crate.ID, Cost: 12.5})
+			order, err := purchaseService.CreateOrder(session.ID, OrderInput{
+				SupplierID: acme.ID,
+				Notes:      "Deliver to the back door",
+				Lines:      []LineInput{{InventoryID: beans.ID, Quantity: 2}, {InventoryID: rice.ID, Quantity: 10}},
+			})
+			gomega.Expect(err).To(gomega.BeNil())
+			dir := ginkgo.GinkgoT().TempDir()
+
+			csvPath := filepath.Join(dir, "order.csv")
+			gomega.Expect(purchaseService.ExportOrderCSV(order.ID, csvPath)).To(gomega.Succeed())
+			data, _ := os.ReadFile(csvPath)
+			rows := strings.Split(strings.TrimSpace(string(data)), "\n")
+			gomega.Expect(rows).To(gomega.HaveLen(3))
+			gomega.Expect(rows[1]).To(gomega.Equal(order.Number + ",1,AC-B12,Beans,2,crate,12.50,25.00"))
+			gomega.Expect(rows[2]).To(gomega.HaveSuffix(",Rice,10,pc,1.50,15.00"))
+
+			pdfPath := filepath.Join(dir, "order.pdf")
+			gomega.Expect(purchaseService.ExportOrderPDF(order.ID, pdfPath)).To(gomega.Succeed())
+			data, _ = os.ReadFile(pdfPath)
+			gomega.Expect(string(data)).To(gomega.HavePrefix("%PDF-"))
+			gomega.Expect(string(data)).To(gomega.ContainSubstring("(" + order.Number + ")"))
+			gomega.Expect(string(data)).To(gomega.ContainSubstring("(40.00)"))
+
+			gomega.Expect(purchaseService.ExportOrderCSV(999, csvPath)).To(gomega.Equal(ErrOrderNotFound))
+		})
+	})
+
+	ginkgo.Context("Receipts", func() {
+		var order *Order
+
+		ginkgo.BeforeEach(func() {
+			purchaseService.SaveSupplierItem(SupplierItemInput{SupplierID: acme.ID, InventoryID: beans.ID, SupplierSKU: "AC-B12", UnitID: &crate.ID, Cost: 12.6})
+			var err error
+			order, err = purchaseService.CreateOrder(session.ID, OrderInput{
+				SupplierID: acme.ID,
+				Lines:      []LineInput{{InventoryID: beans.ID, Quantity: 2}, {InventoryID: rice.ID, Quantity: 10}},
+			})
+			gomega.Expect(err).To(gomega.BeNil())
+		})
+
+		reload := func(item *model.Inventory) *model.Inventory {
+			var current model.Inventory
+			DB.First(&current, item.ID)
+			return &current
+		}
+
+		ginkgo.It("should book deliveries into stock and keep back-orders open", func() {
+			_, err := purchaseService.ReceiveOrder(session.ID, ReceiptInput{OrderID: order.ID})
+			gomega.Expect(err).To(gomega.Equal(ErrOrderNotOpen))
+			order, _ = purchaseService.SendOrder(order.ID)
+			beansLine, riceLine := order.Lines[0], order.Lines[1]
+
+			_, err = purchaseService.ReceiveOrder(session.ID, ReceiptInput{OrderID: order.ID})
+			gomega.Expect(err).To(gomega.Equal(ErrEmptyReceipt))
+			_, err = purchaseService.ReceiveOrder(session.ID, ReceiptInput{OrderID: order.ID, Lines: []ReceiptLineInput{{OrderLineID: 999, Received: 1}}})
+			gomega.Expect(err).To(gomega.Equal(ErrInvalidReceipt))
+
+			receipt, err := purchaseService.ReceiveOrder(session.ID, ReceiptInput{
+				OrderID:   order.ID,
+				Reference: "DN-1",
+				Lines: []ReceiptLineInput{
+					{OrderLineID: beansLine.ID, Received: 1},
+					{OrderLineID: riceLine.ID, Received: 7, Damaged: 2},
+				},
+			})
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(receipt.Status).To(gomega.Equal(model.PurchasePartial))
+			gomega.Expect(receipt.Lines).To(gomega.HaveLen(2))
+			gomega.Expect(receipt.Lines[1].Expected).To(gomega.Equal(10.0))
+			gomega.Expect(receipt.Lines[1].Short).To(gomega.Equal(3.0))
+			gomega.Expect(reload(beans).Quantity).To(gomega.Equal(12.0))
+			gomega.Expect(reload(beans).LastCost).To(gomega.Equal(1.05))
+			gomega.Expect(reload(rice).Quantity).To(gomega.Equal(7.0))
+
+			var movement model.StockMovement
+			DB.First(&movement, *receipt.Lines[1].MovementID)
+			gomega.Expect(movement.Type).To(gomega.Equal(model.MovementReceipt))
+			gomega.Expect(movement.Cost).To(gomega.Equal(10.5))
+			gomega.Expect(movement.Reason).To(gomega.Equal(order.Number))
+			gomega.Expect(movement.LocationID).To(gomega.Equal(uint(model.MainLocation)))
+			var layers int64
+			DB.Model(&model.CostLayer{}).Where("inventory_id = ?", rice.ID).Count(&layers)
+			gomega.Expect(layers).To(gomega.Equal(int64(1)))
+
+			// The damaged rice stays outstanding and arrives with one extra,
+			// this time at the back room
+			backRoom := &model.Location{Name: "Back room"}
+			DB.Create(backRoom)
+			receipt, err = purchaseService.ReceiveOrder(session.ID, ReceiptInput{
+				OrderID:    order.ID,
+				LocationID: &backRoom.ID,
+				Lines: []ReceiptLineInput{
+					{OrderLineID: beansLine.ID, Received: 1},
+					{OrderLineID: riceLine.ID, Received: 4},
+				},
+			})
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(receipt.Status).To(gomega.Equal(model.PurchaseReceived))
+			gomega.Expect(receipt.LocationID).To(gomega.Equal(backRoom.ID))
+			var arrived model.StockMovement
+			DB.First(&arrived, *receipt.Lines[1].MovementID)
+			gomega.Expect(arrived.LocationID).To(gomega.Equal(backRoom.ID))
+			gomega.Expect(receipt.Lines[1].Over).To(gomega.Equal(1.0))
+			gomega.Expect(reload(rice).Quantity).To(gomega.Equal(11.0))
+
+			_, err = purchaseService.ReceiveOrder(session.ID, ReceiptInput{OrderID: order.ID, Lines: []ReceiptLineInput{{OrderLineID: riceLine.ID, Received: 1}}})
+			gomega.Expect(err).To(gomega.Equal(ErrOrderNotOpen))
+
+			receipts, err := purchaseService.ListReceipts(order.ID)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(receipts).To(gomega.HaveLen(2))
+			gomega.Expect(receipts[0].Reference).To(gomega.Equal("DN-1"))
+			gomega.Expect(receipts[0].Lines[1].Damaged).To(gomega.Equal(2.0))
+		})
+
+		ginkgo.It("should close an order that will not be delivered in full", func() {
+			order, _ = purchaseService.SendOrder(order.ID)
+			receipt, err := purchaseService.ReceiveOrder(session.ID, ReceiptInput{
+				OrderID: order.ID,
+				Lines:   []ReceiptLineInput{{OrderLineID: order.Lines[1].ID, Received: 5}},
+				Close:   true,
+			})
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(receipt.Status).To(gomega.Equal(model.PurchaseClosed))
+
+			closed, _ := purchaseService.GetOrder(order.ID)
+			gomega.Expect(closed.ClosedAt).NotTo(gomega.BeNil())
+			gomega.Expect(closed.Lines[1].Received).To(gomega.Equal(5.0))
+		})
+
+		ginkgo.It("should book lot-tracked goods into the delivered lots", func() {
+			DB.Model(rice).Update("track_lots", true)
+			order, _ = purchaseService.SendOrder(order.ID)
+			riceLine := order.Lines[1]
+
+			_, err := purchaseService.ReceiveOrder(session.ID, ReceiptInput{OrderID: order.ID, Lines: []ReceiptLineInput{{OrderLineID: riceLine.ID, Received: 4}}})
+			gomega.Expect(err).To(gomega.MatchError(ErrLotRequired))
+
+			expires := time.Now().AddDate(0, 3, 0)
+			receipt, err := purchaseService.ReceiveOrder(session.ID, ReceiptInput{
+				OrderID: order.ID,
+				Lines: []ReceiptLineInput{
+					{OrderLineID: riceLine.ID, Received: 4, Lot: &stock_service.LotInput{Number: "R-77", ExpiresAt: &expires}},
+					{OrderLineID: order.Lines[0].ID, Received: 1},
+				},
+			})
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(receipt.Lines[0].LotID).NotTo(gomega.BeNil())
+			gomega.Expect(receipt.Lines[1].LotID).To(gomega.BeNil())
+
+			var lot model.Lot
+			DB.First(&lot, *receipt.Lines[0].LotID)
+			gomega.Expect(lot.Number).To(gomega.Equal("R-77"))
+			gomega.Expect(lot.Quantity).To(gomega.Equal(4.0))
+			gomega.Expect(reload(rice).Quantity).To(gomega.Equal(4.0))
+		})
+
+		ginkgo.It("should capture the serial number of each unit received", func() {
+			DB.Model(rice).Update("track_serials", true)
+			order, _ = purchaseService.SendOrder(order.ID)
+			riceLine := order.Lines[1]
+
+			_, err := purchaseService.ReceiveOrder(session.ID, ReceiptInput{OrderID: order.ID, Lines: []ReceiptLineInput{{OrderLineID: riceLine.ID, Received: 2}}})
+			gomega.Expect(err).To(gomega.MatchError(ErrSerialRequired))
+			_, err = purchaseService.ReceiveOrder(session.ID, ReceiptInput{OrderID: order.ID, Lines: []ReceiptLineInput{{OrderLineID: riceLine.ID, Received: 2, Serials: []string{"R1"}}}})
+			gomega.Expect(err).To(gomega.MatchError(ErrInvalidQuantity))
+
+			receipt, err := purchaseService.ReceiveOrder(session.ID, ReceiptInput{
+				OrderID: order.ID,
+				Lines:   []ReceiptLineInput{{OrderLineID: riceLine.ID, Received: 2, Serials: []string{"R1", "R2"}}},
+			})
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(reload(rice).Quantity).To(gomega.Equal(2.0))
+
+			var events []model.SerialEvent
+			DB.Order("id").Find(&events)
+			gomega.Expect(events).To(gomega.HaveLen(2))
+			gomega.Expect(events[0].Party).To(gomega.Equal("Acme"))
+			gomega.Expect(events[0].MovementID).To(gomega.Equal(*receipt.Lines[0].MovementID))
+		})
+
+		ginkgo.It("should match scanned codes to order lines", func() {
+			crateCode, _ := barcode.Parse("4006381333931")
+			pieceCode, _ := barcode.Parse("036000291452")
+			DB.Create(&model.Barcode{InventoryID: beans.ID, Code: crateCode.Value, Symbology: string(crateCode.Symbology), UnitID: &crate.ID})
+			DB.Create(&model.Barcode{InventoryID: beans.ID, Code: pieceCode.Value, Symbology: string(pieceCode.Symbology)})
+			DB.Model(rice).Update("sku", "RICE-1")
+
+			match, err := purchaseService.ScanForReceipt(order.ID, "4006381333931")
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(match.Line.InventoryID).To(gomega.Equal(beans.ID))
+			gomega.Expect(match.Quantity).To(gomega.Equal(1.0))
+			match, err = purchaseService.ScanForReceipt(order.ID, "036000291452")
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(match.Quantity).To(gomega.Equal(1 / 12.0))
+			match, err = purchaseService.ScanForReceipt(order.ID, "ac-b12")
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(match.Line.InventoryID).To(gomega.Equal(beans.ID))
+			match, err = purchaseService.ScanForReceipt(order.ID, "rice-1")
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(match.Line.InventoryID).To(gomega.Equal(rice.ID))
+
+			// The supplier's code is also our SKU for an item not on the order
+			DB.Create(&model.Inventory{Name: "Oats", SKU: "AC-B12"})
+			match, err = purchaseService.ScanForReceipt(order.ID, "AC-B12")
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(match.Line.InventoryID).To(gomega.Equal(beans.ID))
+			gomega.Expect(match.Quantity).To(gomega.Equal(1.0))
+
+			_, err = purchaseService.ScanForReceipt(order.ID, "nothing")
+			gomega.Expect(err).To(gomega.Equal(ErrNotOnOrder))
+		})
+
+		ginkgo.It("should rate suppliers on lead time and fill rate", func() {
+			order, _ = purchaseService.SendOrder(order.ID)
+			sent, expected := time.Now().AddDate(0, 0, -5), time.Now().AddDate(0, 0, -1)
+			DB.Model(&model.PurchaseOrder{}).Where("id = ?", order.ID).Updates(map[string]interface{}{"sent_at": sent, "expected_at": expected})
+
+			purchaseService.ReceiveOrder(session.ID, ReceiptInput{
+				OrderID: order.ID,
+				Lines: []ReceiptLineInput{
+					{OrderLineID: order.Lines[0].ID, Received: 2},
+					{OrderLineID: order.Lines[1].ID, Received: 6, Damaged: 2},
+				},
+				Close: true,
+			})
+
+			from, to := time.Now().AddDate(0, 0, -30), time.Now().Add(time.Hour)
+			report, err := purchaseService.SupplierPerformance(from, to)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(report).To(gomega.HaveLen(1))
+			gomega.Expect(report[0].Name).To(gomega.Equal("Acme"))
+			gomega.Expect(report[0].Orders).To(gomega.Equal(1))
+			gomega.Expect(report[0].Deliveries).To(gomega.Equal(1))
+			gomega.Expect(*report[0].LeadTimeDays).To(gomega.Equal(5.0))
+			gomega.Expect(*report[0].OnTime).To(gomega.Equal(0.0))
+			// 25.20 + 9.00 of 25.20 + 15.00 ordered arrived; 3.00 of 37.20
+			// delivered was damaged
+			gomega.Expect(*report[0].FillRate).To(gomega.Equal(0.851))
+			gomega.Expect(*report[0].Damaged).To(gomega.Equal(0.081))
+
+			_, err = purchaseService.SupplierPerformance(to, from)
+			gomega.Expect(err).To(gomega.Equal(ErrInvalidPeriod))
+		})
+	})
+})
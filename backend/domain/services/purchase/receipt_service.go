@@ -0,0 +1,538 @@
+package purchase_service
+
+import (
+	"blizzflow/backend/domain/model"
+	repository "blizzflow/backend/domain/repositories"
+	stock_service "blizzflow/backend/domain/services/stock"
+	"blizzflow/backend/internal/barcode"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ReceiptLineInput is what arrived of one order line, in the line's unit.
+// Damaged goods are refused: they stay outstanding and never reach stock.
+// Lot names the lot and expiry of lot-tracked goods; Serials has the serial
+// number of each unit of serialized goods.
+type ReceiptLineInput struct {
+	OrderLineID uint                    `json:"orderLineId"`
+	Received    float64                 `json:"received"`
+	Damaged     float64                 `json:"damaged"`
+	Lot         *stock_service.LotInput `json:"lot"`
+	Serials     []string                `json:"serials"`
+}
+
+// ReceiptInput is one delivery against an order. Close gives up on what is
+// still outstanding afterwards instead of leaving it back-ordered.
+// LocationID is where the goods arrive, the current location for nil.
+type ReceiptInput struct {
+	OrderID    uint               `json:"orderId"`
+	LocationID *uint              `json:"locationId"`
+	Reference  string             `json:"reference"`
+	Notes      string             `json:"notes"`
+	Lines      []ReceiptLineInput `json:"lines"`
+	Close      bool               `json:"close"`
+}
+
+// ReceiptLine is a delivered line with how it differed from the order.
+type ReceiptLine struct {
+	model.GoodsReceiptLine
+	Name string `json:"name"`
+	// Over is what arrived beyond what was outstanding, Short what is
+	// still missing after the delivery
+	Over  float64 `json:"over"`
+	Short float64 `json:"short"`
+}
+
+// Receipt is a delivery with its lines and the order's current status.
+type Receipt struct {
+	model.GoodsReceipt
+	Lines  []ReceiptLine `json:"lines"`
+	Status string        `json:"status"`
+}
+
+// ScanMatch is the order line a scanned code belongs to. Quantity is what
+// one scan counts, in the line's unit: a whole pack for a pack's barcode.
+type ScanMatch struct {
+	Line     model.PurchaseOrderLine `json:"line"`
+	Quantity float64                 `json:"quantity"`
+}
+
+// SupplierPerformance rates a supplier on the orders sent in a period.
+type SupplierPerformance struct {
+	SupplierID uint   `json:"supplierId"`
+	Name       string `json:"name"`
+	Orders     int    `json:"orders"`
+	Deliveries int    `json:"deliveries"`
+	// LeadTimeDays averages the days from sending an order to its first
+	// delivery; nil while nothing was delivered
+	LeadTimeDays *float64 `json:"leadTimeDays"`
+	// OnTime is the share of delivered orders with an expected date whose
+	// first delivery came by that day
+	OnTime *float64 `json:"onTime"`
+	// FillRate is the share of the ordered value that arrived, over orders
+	// that are fully received or closed
+	FillRate *float64 `json:"fillRate"`
+	// Damaged is the share of the delivered value that was refused
+	Damaged *float64 `json:"damaged"`
+}
+
+// ReceiveOrder books a delivery against a sent order. Received goods go
+// into stock at the order's cost, which also feeds the cost layers;
+// damaged goods are noted and stay outstanding. The order becomes
+// received once every line is in, and partial until then unless Close is
+// set.
+func (s *PurchaseService) ReceiveOrder(sessionID uint, input ReceiptInput) (*Receipt, error) {
+	userID, err := s.userID(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	input.Reference = strings.TrimSpace(input.Reference)
+	input.Notes = strings.TrimSpace(input.Notes)
+	if len(input.Reference) > maxNameLength || len(input.Notes) > maxTextLength {
+		return nil, ErrInvalidReceipt
+	}
+
+	var receipt *Receipt
+	err = s.uow.Do(func(repos *repository.Repositories) error {
+		order, err := getOrder(repos, input.OrderID)
+		if err != nil {
+			return err
+		}
+		if order.Status != model.PurchaseSent && order.Status != model.PurchasePartial {
+			return ErrOrderNotOpen
+		}
+		lines, err := repos.PurchaseOrders.ListLines([]uint{order.ID})
+		if err != nil {
+			return fmt.Errorf("failed to list order lines: %w", ErrDatabaseOperation)
+		}
+		byID := make(map[uint]*model.PurchaseOrderLine, len(lines))
+		for i := range lines {
+			byID[lines[i].ID] = &lines[i]
+		}
+
+		seen := make(map[uint]bool, len(input.Lines))
+		var booked bool
+		for _, in := range input.Lines {
+			if byID[in.OrderLineID] == nil || seen[in.OrderLineID] {
+				return ErrInvalidReceipt
+			}
+			seen[in.OrderLineID] = true
+			if !validQuantity(in.Received) || !validQuantity(in.Damaged) {
+				return ErrInvalidQuantity
+			}
+			booked = booked || in.Received > 0 || in.Damaged > 0
+		}
+		if !booked {
+			return ErrEmptyReceipt
+		}
+		location, err := stock_service.Locate(repos, input.LocationID)
+		if err != nil {
+			return err
+		}
+
+		header := &model.GoodsReceipt{
+			OrderID:    order.ID,
+			LocationID: location.ID,
+			Reference:  input.Reference,
+			Notes:      input.Notes,
+			ReceivedBy: userID,
+			ReceivedAt: s.now(),
+		}
+		if err := repos.PurchaseOrders.CreateReceipt(header); err != nil {
+			return fmt.Errorf("failed to create goods receipt: %w", ErrDatabaseOperation)
+		}
+		receipt = &Receipt{GoodsReceipt: *header, Lines: []ReceiptLine{}}
+
+		for _, in := range input.Lines {
+			if in.Received == 0 && in.Damaged == 0 {
+				continue
+			}
+			line := byID[in.OrderLineID]
+			received := model.GoodsReceiptLine{
+				ReceiptID:   header.ID,
+				OrderLineID: line.ID,
+				InventoryID: line.InventoryID,
+				Expected:    math.Max(line.Quantity-line.Received, 0),
+				Received:    in.Received,
+				Damaged:     in.Damaged,
+			}
+			if in.Received > 0 {
+				movement, lot, err := receive(repos, order, line, header, in)
+				if err != nil {
+					return err
+				}
+				received.MovementID = &movement.ID
+				received.LotID = lot
+				line.Received += in.Received
+				if err := repos.PurchaseOrders.UpdateLine(line); err != nil {
+					return fmt.Errorf("failed to update order line: %w", ErrDatabaseOperation)
+				}
+			}
+			if err := repos.PurchaseOrders.CreateReceiptLine(&received); err != nil {
+				return fmt.Errorf("failed to save goods receipt line: %w", ErrDatabaseOperation)
+			}
+			receipt.Lines = append(receipt.Lines, newReceiptLine(received, line.Name))
+		}
+
+		status := model.PurchaseReceived
+		for _, line := range lines {
+			if outstanding(line) > 0 {
+				status = model.PurchasePartial
+				break
+			}
+		}
+		if input.Close {
+			status = model.PurchaseClosed
+			order.ClosedAt = &header.ReceivedAt
+		}
+		if !CanMove(order.Status, status) {
+			return fmt.Errorf("%s to %s: %w", order.Status, status, ErrInvalidTransition)
+		}
+		order.Status = status
+		if err := repos.PurchaseOrders.Update(order); err != nil {
+			return fmt.Errorf("failed to update purchase order: %w", ErrDatabaseOperation)
+		}
+		receipt.Status = status
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return receipt, nil
+}
+
+// ListReceipts returns the deliveries against an order, oldest first.
+func (s *PurchaseService) ListReceipts(orderID uint) ([]Receipt, error) {
+	var receipts []Receipt
+	err := s.uow.Do(func(repos *repository.Repositories) error {
+		order, err := getOrder(repos, orderID)
+		if err != nil {
+			return err
+		}
+		headers, err := repos.PurchaseOrders.ListReceipts([]uint{orderID})
+		if err != nil {
+			return fmt.Errorf("failed to list goods receipts: %w", ErrDatabaseOperation)
+		}
+		ids := make([]uint, len(headers))
+		for i, header := range headers {
+			ids[i] = header.ID
+		}
+		received, err := repos.PurchaseOrders.ListReceiptLines(ids)
+		if err != nil {
+			return fmt.Errorf("failed to list goods receipt lines: %w", ErrDatabaseOperation)
+		}
+		orderLines, err := repos.PurchaseOrders.ListLines([]uint{orderID})
+		if err != nil {
+			return fmt.Errorf("failed to list order lines: %w", ErrDatabaseOperation)
+		}
+		names := make(map[uint]string, len(orderLines))
+		for _, line := range orderLines {
+			names[line.ID] = line.Name
+		}
+
+		receipts = make([]Receipt, len(headers))
+		index := make(map[uint]int, len(headers))
+		for i, header := range headers {
+			receipts[i] = Receipt{GoodsReceipt: header, Lines: []ReceiptLine{}, Status: order.Status}
+			index[header.ID] = i
+		}
+		for _, line := range received {
+			i := index[line.ReceiptID]
+			receipts[i].Lines = append(receipts[i].Lines, newReceiptLine(line, names[line.OrderLineID]))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return receipts, nil
+}
+
+// ScanForReceipt finds the line of an order a scanned code belongs to: an
+// item or pack barcode, our SKU, or the supplier's code. Our codes win
+// when their item is on the order; lines that are still outstanding are
+// matched first.
+func (s *PurchaseService) ScanForReceipt(orderID uint, code string) (*ScanMatch, error) {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return nil, ErrNotOnOrder
+	}
+
+	var match *ScanMatch
+	err := s.uow.Do(func(repos *repository.Repositories) error {
+		if _, err := getOrder(repos, orderID); err != nil {
+			return err
+		}
+		lines, err := repos.PurchaseOrders.ListLines([]uint{orderID})
+		if err != nil {
+			return fmt.Errorf("failed to list order lines: %w", ErrDatabaseOperation)
+		}
+		sort.SliceStable(lines, func(i, j int) bool {
+			return outstanding(lines[i]) > 0 && outstanding(lines[j]) <= 0
+		})
+
+		inventoryID, scanned, err := resolveScan(repos, code)
+		if err != nil {
+			return err
+		}
+		for _, line := range lines {
+			if inventoryID != 0 && line.InventoryID == inventoryID {
+				factor, err := packFactor(repos, line.UnitID)
+				if err != nil {
+					return err
+				}
+				match = &ScanMatch{Line: line, Quantity: scanned / factor}
+				return nil
+			}
+		}
+		// One of our codes for an item not on the order may still be the
+		// supplier's code for one that is
+		for _, line := range lines {
+			if strings.EqualFold(line.SupplierSKU, code) {
+				match = &ScanMatch{Line: line, Quantity: 1}
+				return nil
+			}
+		}
+		return ErrNotOnOrder
+	})
+	if err != nil {
+		return nil, err
+	}
+	return match, nil
+}
+
+// SupplierPerformance rates each supplier on the orders sent in [from, to),
+// by name.
+func (s *PurchaseService) SupplierPerformance(from, to time.Time) ([]SupplierPerformance, error) {
+	if !from.Before(to) {
+		return nil, ErrInvalidPeriod
+	}
+
+	var report []SupplierPerformance
+	err := s.uow.Do(func(repos *repository.Repositories) error {
+		orders, err := repos.PurchaseOrders.ListSent(from, to)
+		if err != nil {
+			return fmt.Errorf("failed to list purchase orders: %w", ErrDatabaseOperation)
+		}
+		ids := make([]uint, len(orders))
+		for i, order := range orders {
+			ids[i] = order.ID
+		}
+		lines, err := repos.PurchaseOrders.ListLines(ids)
+		if err != nil {
+			return fmt.Errorf("failed to list order lines: %w", ErrDatabaseOperation)
+		}
+		receipts, err := repos.PurchaseOrders.ListReceipts(ids)
+		if err != nil {
+			return fmt.Errorf("failed to list goods receipts: %w", ErrDatabaseOperation)
+		}
+		receiptIDs := make([]uint, len(receipts))
+		for i, receipt := range receipts {
+			receiptIDs[i] = receipt.ID
+		}
+		received, err := repos.PurchaseOrders.ListReceiptLines(receiptIDs)
+		if err != nil {
+			return fmt.Errorf("failed to list goods receipt lines: %w", ErrDatabaseOperation)
+		}
+
+		// Receipts come oldest first, so the first one per order is its
+		// first delivery
+		first := make(map[uint]time.Time)
+		deliveries := make(map[uint]int)
+		for _, receipt := range receipts {
+			if _, ok := first[receipt.OrderID]; !ok {
+				first[receipt.OrderID] = receipt.ReceivedAt
+			}
+			deliveries[receipt.OrderID]++
+		}
+		linesByOrder := make(map[uint][]model.PurchaseOrderLine)
+		costs := make(map[uint]float64, len(lines))
+		for _, line := range lines {
+			linesByOrder[line.OrderID] = append(linesByOrder[line.OrderID], line)
+			costs[line.ID] = line.UnitCost
+		}
+		orderOf := make(map[uint]uint, len(receipts))
+		for _, receipt := range receipts {
+			orderOf[receipt.ID] = receipt.OrderID
+		}
+
+		type tally struct {
+			row                          SupplierPerformance
+			lead, leadCount              float64
+			onTime, dated                float64
+			ordered, filled              float64
+			deliveredValue, damagedValue float64
+		}
+		tallies := make(map[uint]*tally)
+		supplierOf := make(map[uint]uint, len(orders))
+		for _, order := range orders {
+			supplierOf[order.ID] = order.SupplierID
+			t := tallies[order.SupplierID]
+			if t == nil {
+				t = &tally{row: SupplierPerformance{SupplierID: order.SupplierID}}
+				tallies[order.SupplierID] = t
+			}
+			t.row.Orders++
+			t.row.Deliveries += deliveries[order.ID]
+
+			if at, ok := first[order.ID]; ok {
+				t.lead += at.Sub(*order.SentAt).Hours() / 24
+				t.leadCount++
+				if order.ExpectedAt != nil {
+					t.dated++
+					if !day(at).After(day(*order.ExpectedAt)) {
+						t.onTime++
+					}
+				}
+			}
+			if order.Status == model.PurchaseReceived || order.Status == model.PurchaseClosed {
+				for _, line := range linesByOrder[order.ID] {
+					t.ordered += line.Quantity * line.UnitCost
+					t.filled += math.Min(line.Received, line.Quantity) * line.UnitCost
+				}
+			}
+		}
+		for _, line := range received {
+			t := tallies[supplierOf[orderOf[line.ReceiptID]]]
+			cost := costs[line.OrderLineID]
+			t.deliveredValue += (line.Received + line.Damaged) * cost
+			t.damagedValue += line.Damaged * cost
+		}
+
+		report = make([]SupplierPerformance, 0, len(tallies))
+		for supplierID, t := range tallies {
+			supplier, err := getSupplier(repos, supplierID)
+			if err != nil {
+				return err
+			}
+			t.row.Name = supplier.Name
+			t.row.LeadTimeDays = ratio(t.lead, t.leadCount, 10)
+			t.row.OnTime = ratio(t.onTime, t.dated, 1000)
+			t.row.FillRate = ratio(t.filled, t.ordered, 1000)
+			t.row.Damaged = ratio(t.damagedValue, t.deliveredValue, 1000)
+			report = append(report, t.row)
+		}
+		sort.Slice(report, func(i, j int) bool {
+			return strings.ToLower(report[i].Name) < strings.ToLower(report[j].Name)
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// receive books what arrived of an order line, in the line's unit, into
+// stock at the receipt's location and the line's cost, into a lot for
+// lot-tracked items and by serial number for serialized ones. It returns
+// the lot's ID for lot-tracked items.
+func receive(repos *repository.Repositories, order *model.PurchaseOrder, line *model.PurchaseOrderLine, receipt *model.GoodsReceipt, in ReceiptLineInput) (*model.StockMovement, *uint, error) {
+	quantity, lot := in.Received, in.Lot
+	item, err := repos.Inventory.GetByID(line.InventoryID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, ErrInventoryNotFound
+	} else if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch inventory: %w", ErrDatabaseOperation)
+	}
+	base, err := stock_service.Convert(repos, item, quantity, line.UnitID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	movement := &model.StockMovement{
+		InventoryID: item.ID,
+		LocationID:  receipt.LocationID,
+		Type:        model.MovementReceipt,
+		Quantity:    base,
+		Cost:        quantity * line.UnitCost,
+		Reference:   stock_service.Reference("receipt", receipt.ID),
+		UserID:      &receipt.ReceivedBy,
+		Reason:      order.Number,
+	}
+	if len(in.Serials) > 0 {
+		supplier, err := repos.Suppliers.GetByID(order.SupplierID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to fetch supplier: %w", ErrDatabaseOperation)
+		}
+		return movement, nil, stock_service.RecordSerials(repos, movement, in.Serials, supplier.Name)
+	}
+	if lot == nil {
+		return movement, nil, stock_service.Record(repos, movement)
+	}
+	opened, err := stock_service.OpenLot(repos, item, receipt.LocationID, *lot)
+	if err != nil {
+		return nil, nil, err
+	}
+	picks := []stock_service.LotPick{{LotID: opened.ID, Quantity: base}}
+	return movement, &opened.ID, stock_service.RecordLots(repos, movement, picks)
+}
+
+// resolveScan finds the item a code names and how many base units one scan
+// counts. It returns no item for codes that are not ours, which may still
+// be a supplier's code.
+func resolveScan(repos *repository.Repositories, code string) (uint, float64, error) {
+	if parsed, err := barcode.Parse(code); err == nil {
+		found, err := repos.Barcodes.GetByCode(parsed.Value)
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to look up barcode: %w", ErrDatabaseOperation)
+		}
+		if found != nil {
+			factor, err := packFactor(repos, found.UnitID)
+			if err != nil {
+				return 0, 0, err
+			}
+			return found.InventoryID, factor, nil
+		}
+	}
+
+	item, err := repos.Inventory.GetBySKU(code)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to fetch inventory: %w", ErrDatabaseOperation)
+	}
+	if item == nil {
+		return 0, 0, nil
+	}
+	return item.ID, 1, nil
+}
+
+func newReceiptLine(line model.GoodsReceiptLine, name string) ReceiptLine {
+	return ReceiptLine{
+		GoodsReceiptLine: line,
+		Name:             name,
+		Over:             math.Max(line.Received-line.Expected, 0),
+		Short:            math.Max(line.Expected-line.Received, 0),
+	}
+}
+
+// outstanding is what is still to come of an order line. Quantities are
+// kept to the unit's precision, so a tiny remainder counts as none.
+func outstanding(line model.PurchaseOrderLine) float64 {
+	if rest := line.Quantity - line.Received; rest > 1e-9 {
+		return rest
+	}
+	return 0
+}
+
+func validQuantity(quantity float64) bool {
+	return quantity >= 0 && !math.IsInf(quantity, 0)
+}
+
+// ratio returns part/whole rounded to 1/precision, or nil without a whole.
+func ratio(part, whole, precision float64) *float64 {
+	if whole <= 0 {
+		return nil
+	}
+	value := math.Round(part/whole*precision) / precision
+	return &value
+}
+
+func day(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,303 @@
+package purchase_service
+
+import (
+	"blizzflow/backend/domain/model"
+	repository "blizzflow/backend/domain/repositories"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+type SupplierInput struct {
+	Name             string `json:"name"`
+	ContactName      string `json:"contactName"`
+	Email            string `json:"email"`
+	Phone            string `json:"phone"`
+	Address          string `json:"address"`
+	PaymentTermsDays int    `json:"paymentTermsDays"`
+	Notes            string `json:"notes"`
+}
+
+// SupplierItemInput is how a supplier sells an item. UnitID is one of the
+// item's packs, nil for its base unit, and Cost is per that unit.
+type SupplierItemInput struct {
+	SupplierID   uint    `json:"supplierId"`
+	InventoryID  uint    `json:"inventoryId"`
+	SupplierSKU  string  `json:"supplierSku"`
+	UnitID       *uint   `json:"unitId"`
+	Cost         float64 `json:"cost"`
+	LeadTimeDays *int    `json:"leadTimeDays"`
+	Preferred    bool    `json:"preferred"`
+}
+
+func (s *PurchaseService) ListSuppliers(includeArchived bool) ([]model.Supplier, error) {
+	suppliers, err := s.supplierRepo.List(includeArchived)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list suppliers: %w", ErrDatabaseOperation)
+	}
+	return suppliers, nil
+}
+
+func (s *PurchaseService) GetSupplier(id uint) (*model.Supplier, error) {
+	var supplier *model.Supplier
+	err := s.uow.Do(func(repos *repository.Repositories) error {
+		var err error
+		supplier, err = getSupplier(repos, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return supplier, nil
+}
+
+func (s *PurchaseService) CreateSupplier(input SupplierInput) (*model.Supplier, error) {
+	supplier := &model.Supplier{}
+	err := s.uow.Do(func(repos *repository.Repositories) error {
+		if err := applySupplier(repos, supplier, input); err != nil {
+			return err
+		}
+		if err := repos.Suppliers.Create(supplier); err != nil {
+			return fmt.Errorf("failed to create supplier: %w", ErrDatabaseOperation)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return supplier, nil
+}
+
+func (s *PurchaseService) UpdateSupplier(id uint, input SupplierInput) (*model.Supplier, error) {
+	var supplier *model.Supplier
+	err := s.uow.Do(func(repos *repository.Repositories) error {
+		var err error
+		if supplier, err = getSupplier(repos, id); err != nil {
+			return err
+		}
+		if err := applySupplier(repos, supplier, input); err != nil {
+			return err
+		}
+		if err := repos.Suppliers.Update(supplier); err != nil {
+			return fmt.Errorf("failed to update supplier: %w", ErrDatabaseOperation)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return supplier, nil
+}
+
+// ArchiveSupplier hides a supplier from new orders while keeping its old
+// ones readable.
+func (s *PurchaseService) ArchiveSupplier(id uint) (*model.Supplier, error) {
+	return s.setArchived(id, true)
+}
+
+// RestoreSupplier brings an archived supplier back.
+func (s *PurchaseService) RestoreSupplier(id uint) (*model.Supplier, error) {
+	return s.setArchived(id, false)
+}
+
+func (s *PurchaseService) setArchived(id uint, archived bool) (*model.Supplier, error) {
+	var supplier *model.Supplier
+	err := s.uow.Do(func(repos *repository.Repositories) error {
+		var err error
+		if supplier, err = getSupplier(repos, id); err != nil {
+			return err
+		}
+		if (supplier.ArchivedAt != nil) == archived {
+			return nil
+		}
+
+		supplier.ArchivedAt = nil
+		if archived {
+			now := s.now()
+			supplier.ArchivedAt = &now
+		}
+		if err := repos.Suppliers.Update(supplier); err != nil {
+			return fmt.Errorf("failed to update supplier: %w", ErrDatabaseOperation)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return supplier, nil
+}
+
+// DeleteSupplier removes a supplier nothing was ordered from, along with
+// the items it sells.
+func (s *PurchaseService) DeleteSupplier(id uint) error {
+	return s.uow.Do(func(repos *repository.Repositories) error {
+		if _, err := getSupplier(repos, id); err != nil {
+			return err
+		}
+		orders, err := repos.PurchaseOrders.CountBySupplier(id)
+		if err != nil {
+			return fmt.Errorf("failed to count purchase orders: %w", ErrDatabaseOperation)
+		}
+		if orders > 0 {
+			return ErrSupplierInUse
+		}
+		if err := repos.Suppliers.Delete(id); err != nil {
+			return fmt.Errorf("failed to delete supplier: %w", ErrDatabaseOperation)
+		}
+		return nil
+	})
+}
+
+// SaveSupplierItem records how a supplier sells an item, replacing what was
+// recorded before. Marking it preferred unmarks the item's other suppliers.
+func (s *PurchaseService) SaveSupplierItem(input SupplierItemInput) (*model.SupplierItem, error) {
+	input.SupplierSKU = strings.TrimSpace(input.SupplierSKU)
+	if len(input.SupplierSKU) > maxNameLength || !validCost(input.Cost) ||
+		(input.LeadTimeDays != nil && *input.LeadTimeDays < 0) {
+		return nil, ErrInvalidSupplierItem
+	}
+
+	var saved *model.SupplierItem
+	err := s.uow.Do(func(repos *repository.Repositories) error {
+		if _, err := getSupplier(repos, input.SupplierID); err != nil {
+			return err
+		}
+		item, err := repos.Inventory.GetByID(input.InventoryID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInventoryNotFound
+		} else if err != nil {
+			return fmt.Errorf("failed to fetch inventory: %w", ErrDatabaseOperation)
+		}
+		variants, err := repos.Inventory.CountVariants(item.ID)
+		if err != nil {
+			return fmt.Errorf("failed to count variants: %w", ErrDatabaseOperation)
+		}
+		if variants > 0 {
+			return ErrVariantRequired
+		}
+		if input.UnitID != nil {
+			pack, err := repos.Units.GetPack(*input.UnitID)
+			if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && pack.InventoryID != item.ID) {
+				return ErrUnitNotFound
+			} else if err != nil {
+				return fmt.Errorf("failed to fetch unit: %w", ErrDatabaseOperation)
+			}
+		}
+
+		if saved, err = repos.Suppliers.FindItem(input.SupplierID, item.ID); err != nil {
+			return fmt.Errorf("failed to fetch supplier item: %w", ErrDatabaseOperation)
+		}
+		if saved == nil {
+			saved = &model.SupplierItem{SupplierID: input.SupplierID, InventoryID: item.ID}
+		}
+		saved.SupplierSKU = input.SupplierSKU
+		saved.UnitID = input.UnitID
+		saved.Cost = input.Cost
+		saved.LeadTimeDays = input.LeadTimeDays
+		saved.Preferred = input.Preferred
+		if err := repos.Suppliers.SaveItem(saved); err != nil {
+			return fmt.Errorf("failed to save supplier item: %w", ErrDatabaseOperation)
+		}
+		if saved.Preferred {
+			if err := repos.Suppliers.ClearPreferred(item.ID, saved.ID); err != nil {
+				return fmt.Errorf("failed to update supplier items: %w", ErrDatabaseOperation)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
+
+func (s *PurchaseService) RemoveSupplierItem(id uint) error {
+	return s.uow.Do(func(repos *repository.Repositories) error {
+		if _, err := repos.Suppliers.GetItem(id); errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrSupplierItemMissing
+		} else if err != nil {
+			return fmt.Errorf("failed to fetch supplier item: %w", ErrDatabaseOperation)
+		}
+		if err := repos.Suppliers.DeleteItem(id); err != nil {
+			return fmt.Errorf("failed to delete supplier item: %w", ErrDatabaseOperation)
+		}
+		return nil
+	})
+}
+
+// ListSupplierItems returns the items a supplier sells.
+func (s *PurchaseService) ListSupplierItems(supplierID uint) ([]model.SupplierItem, error) {
+	items, err := s.supplierRepo.ListItems(supplierID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list supplier items: %w", ErrDatabaseOperation)
+	}
+	return items, nil
+}
+
+// ItemSuppliers returns who sells an item, the preferred supplier first.
+func (s *PurchaseService) ItemSuppliers(inventoryID uint) ([]model.SupplierItem, error) {
+	items, err := s.supplierRepo.ListByInventory(inventoryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list supplier items: %w", ErrDatabaseOperation)
+	}
+	return items, nil
+}
+
+func getSupplier(repos *repository.Repositories, id uint) (*model.Supplier, error) {
+	supplier, err := repos.Suppliers.GetByID(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrSupplierNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to fetch supplier: %w", ErrDatabaseOperation)
+	}
+	return supplier, nil
+}
+
+// activeSupplier returns a supplier that may be sent new orders.
+func activeSupplier(repos *repository.Repositories, id uint) (*model.Supplier, error) {
+	supplier, err := getSupplier(repos, id)
+	if err != nil {
+		return nil, err
+	}
+	if supplier.ArchivedAt != nil {
+		return nil, ErrSupplierArchived
+	}
+	return supplier, nil
+}
+
+// applySupplier validates input onto supplier. Names are unique, ignoring
+// case.
+func applySupplier(repos *repository.Repositories, supplier *model.Supplier, input SupplierInput) error {
+	input.Name = strings.TrimSpace(input.Name)
+	input.ContactName = strings.TrimSpace(input.ContactName)
+	input.Email = strings.TrimSpace(input.Email)
+	input.Phone = strings.TrimSpace(input.Phone)
+	input.Address = strings.TrimSpace(input.Address)
+	input.Notes = strings.TrimSpace(input.Notes)
+	if input.Name == "" || len(input.Name) > maxNameLength ||
+		len(input.ContactName) > maxNameLength || len(input.Phone) > maxNameLength ||
+		len(input.Email) > maxNameLength || (input.Email != "" && !strings.Contains(input.Email, "@")) ||
+		len(input.Address) > maxTextLength || len(input.Notes) > maxTextLength ||
+		input.PaymentTermsDays < 0 || input.PaymentTermsDays > 365 {
+		return ErrInvalidSupplier
+	}
+
+	existing, err := repos.Suppliers.GetByName(input.Name)
+	if err != nil {
+		return fmt.Errorf("failed to fetch supplier: %w", ErrDatabaseOperation)
+	}
+	if existing != nil && existing.ID != supplier.ID {
+		return ErrDuplicateSupplier
+	}
+
+	supplier.Name = input.Name
+	supplier.ContactName = input.ContactName
+	supplier.Email = input.Email
+	supplier.Phone = input.Phone
+	supplier.Address = input.Address
+	supplier.PaymentTermsDays = input.PaymentTermsDays
+	supplier.Notes = input.Notes
+	return nil
+}
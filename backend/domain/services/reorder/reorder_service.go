@@ -0,0 +1,326 @@
+package reorder_service
+
+import (
+	"blizzflow/backend/domain/model"
+	repository "blizzflow/backend/domain/repositories"
+	catalog_service "blizzflow/backend/domain/services/catalog"
+	stock_service "blizzflow/backend/domain/services/stock"
+	"blizzflow/backend/events"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	VelocityDaysKey = "reorder.velocity_days"
+	LeadTimeDaysKey = "reorder.lead_time_days"
+
+	DefaultVelocityDays = 30
+	DefaultLeadTimeDays = 7
+	MaxVelocityDays     = 365
+)
+
+// Custom errors
+var (
+	ErrInvalidPlanning   = fmt.Errorf("invalid planning settings")
+	ErrInventoryNotFound = fmt.Errorf("inventory not found")
+	ErrDatabaseOperation = fmt.Errorf("database operation failed")
+)
+
+// Planning holds the shop-wide figures suggestions rest on. VelocityDays is
+// how many days of sales make up the sales velocity; LeadTimeDays applies
+// to items whose categories do not set a lead time either.
+type Planning struct {
+	VelocityDays int `json:"velocityDays"`
+	LeadTimeDays int `json:"leadTimeDays"`
+}
+
+// Suggestion is what to order of an item and why. Quantities are in the
+// item's base unit; OnHand leaves out stock in transit between locations
+// and OnOrder is what open purchase orders, drafts included, still expect.
+type Suggestion struct {
+	InventoryID uint    `json:"inventoryId"`
+	Name        string  `json:"name"`
+	SKU         string  `json:"sku"`
+	Unit        string  `json:"unit"`
+	OnHand      float64 `json:"onHand"`
+	OnOrder     float64 `json:"onOrder"`
+	// Velocity is the average quantity sold per day
+	Velocity     float64 `json:"velocity"`
+	LeadTimeDays int     `json:"leadTimeDays"`
+	SafetyStock  float64 `json:"safetyStock"`
+	// ReorderPoint is the item's own or inherited one, or else the demand
+	// over the lead time plus the safety stock
+	ReorderPoint float64  `json:"reorderPoint"`
+	MaxLevel     *float64 `json:"maxLevel"`
+	Low          bool     `json:"low"`
+	// Quantity is the suggested order, rounded up to whole purchase packs
+	// when the item has a default one
+	Quantity float64         `json:"quantity"`
+	Pack     *model.ItemUnit `json:"pack"`
+	Packs    float64         `json:"packs"`
+}
+
+type ReorderService struct {
+	settingRepo repository.SettingRepo
+	uow         repository.UnitOfWork
+	dispatcher  *events.Dispatcher
+	now         func() time.Time
+}
+
+func NewReorderService(settingRepo repository.SettingRepo, uow repository.UnitOfWork, dispatcher *events.Dispatcher) *ReorderService {
+	return &ReorderService{
+		settingRepo: settingRepo,
+		uow:         uow,
+		dispatcher:  dispatcher,
+		now:         time.Now,
+	}
+}
+
+// GetPlanning returns the planning settings, with defaults for those never
+// set.
+func (s *ReorderService) GetPlanning() (*Planning, error) {
+	planning := &Planning{VelocityDays: DefaultVelocityDays, LeadTimeDays: DefaultLeadTimeDays}
+
+	days, err := s.settingRepo.Get(VelocityDaysKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load settings: %w", ErrDatabaseOperation)
+	}
+	if n, err := strconv.Atoi(days); err == nil {
+		planning.VelocityDays = n
+	}
+	lead, err := s.settingRepo.Get(LeadTimeDaysKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load settings: %w", ErrDatabaseOperation)
+	}
+	if n, err := strconv.Atoi(lead); err == nil {
+		planning.LeadTimeDays = n
+	}
+	return planning, nil
+}
+
+// SetPlanning changes the planning settings.
+func (s *ReorderService) SetPlanning(planning Planning) error {
+	if planning.VelocityDays < 1 || planning.VelocityDays > MaxVelocityDays || planning.LeadTimeDays < 0 {
+		return ErrInvalidPlanning
+	}
+	return s.uow.Do(func(repos *repository.Repositories) error {
+		if err := repos.Settings.Set(VelocityDaysKey, strconv.Itoa(planning.VelocityDays)); err != nil {
+			return fmt.Errorf("failed to save settings: %w", ErrDatabaseOperation)
+		}
+		if err := repos.Settings.Set(LeadTimeDaysKey, strconv.Itoa(planning.LeadTimeDays)); err != nil {
+			return fmt.Errorf("failed to save settings: %w", ErrDatabaseOperation)
+		}
+		return nil
+	})
+}
+
+// LowStock returns the items at or below their reorder point with what to
+// order of each, by name. Archived items and parents of variants are left
+// out; their variants are checked instead. Levels are company-wide, as
+// purchasing is; stock is spread over the locations by transfers.
+func (s *ReorderService) LowStock() ([]Suggestion, error) {
+	planning, err := s.GetPlanning()
+	if err != nil {
+		return nil, err
+	}
+
+	suggestions := []Suggestion{}
+	err = s.uow.Do(func(repos *repository.Repositories) error {
+		items, err := repos.Inventory.All()
+		if err != nil {
+			return fmt.Errorf("failed to list inventory: %w", ErrDatabaseOperation)
+		}
+		sold, err := repos.Sales.Sold(s.since(planning))
+		if err != nil {
+			return fmt.Errorf("failed to total sales: %w", ErrDatabaseOperation)
+		}
+		onOrder, err := repos.PurchaseOrders.OnOrder()
+		if err != nil {
+			return fmt.Errorf("failed to total open orders: %w", ErrDatabaseOperation)
+		}
+
+		parents := map[uint]bool{}
+		for _, item := range items {
+			if item.ParentID != nil {
+				parents[*item.ParentID] = true
+			}
+		}
+		for i := range items {
+			item := &items[i]
+			if item.ArchivedAt != nil || parents[item.ID] {
+				continue
+			}
+			suggestion, err := suggest(repos, item, planning, sold[item.ID], onOrder[item.ID])
+			if err != nil {
+				return err
+			}
+			if suggestion.Low {
+				suggestions = append(suggestions, *suggestion)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return suggestions[i].Name < suggestions[j].Name
+	})
+	return suggestions, nil
+}
+
+// Suggest works out the reorder point and order quantity of one item,
+// whether or not it is running low.
+func (s *ReorderService) Suggest(inventoryID uint) (*Suggestion, error) {
+	planning, err := s.GetPlanning()
+	if err != nil {
+		return nil, err
+	}
+
+	var suggestion *Suggestion
+	err = s.uow.Do(func(repos *repository.Repositories) error {
+		item, err := repos.Inventory.GetByID(inventoryID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInventoryNotFound
+		} else if err != nil {
+			return fmt.Errorf("failed to fetch inventory: %w", ErrDatabaseOperation)
+		}
+		sold, err := repos.Sales.Sold(s.since(planning))
+		if err != nil {
+			return fmt.Errorf("failed to total sales: %w", ErrDatabaseOperation)
+		}
+		onOrder, err := repos.PurchaseOrders.OnOrder()
+		if err != nil {
+			return fmt.Errorf("failed to total open orders: %w", ErrDatabaseOperation)
+		}
+		suggestion, err = suggest(repos, item, planning, sold[item.ID], onOrder[item.ID])
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return suggestion, nil
+}
+
+// Scan looks for items running low and tells the UI about them with an
+// inventory:low-stock event. It is meant to run as a background job.
+func (s *ReorderService) Scan() ([]Suggestion, error) {
+	suggestions, err := s.LowStock()
+	if err != nil {
+		return nil, err
+	}
+	if len(suggestions) > 0 {
+		s.dispatcher.Emit(events.LowStock, suggestions)
+	}
+	return suggestions, nil
+}
+
+// since is where the sales window starts.
+func (s *ReorderService) since(planning *Planning) time.Time {
+	return s.now().AddDate(0, 0, -planning.VelocityDays)
+}
+
+// suggest fills in a suggestion for item, which sold quantity over the
+// planning's sales window and has onOrder still to come. An item is low
+// once what it has and has on order is at or below a reorder point above
+// zero. The order fills the item up to its maximum level, is its reorder
+// quantity, or otherwise covers the reorder point plus another sales
+// window.
+func suggest(repos *repository.Repositories, item *model.Inventory, planning *Planning, sold, onOrder float64) (*Suggestion, error) {
+	defaults, err := catalog_service.ResolveDefaults(repos, item)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve defaults: %w", ErrDatabaseOperation)
+	}
+	unit, err := stock_service.BaseUnit(repos, item)
+	if err != nil {
+		return nil, err
+	}
+	onHand, err := stockOnHand(repos, item)
+	if err != nil {
+		return nil, err
+	}
+	onHand = unit.Round(onHand)
+	onOrder = unit.Round(onOrder)
+	position := onHand + onOrder
+
+	suggestion := &Suggestion{
+		InventoryID:  item.ID,
+		Name:         item.Name,
+		SKU:          item.SKU,
+		Unit:         item.Unit,
+		OnHand:       onHand,
+		OnOrder:      onOrder,
+		Velocity:     sold / float64(planning.VelocityDays),
+		LeadTimeDays: planning.LeadTimeDays,
+		MaxLevel:     defaults.MaxLevel,
+	}
+	if defaults.LeadTimeDays != nil {
+		suggestion.LeadTimeDays = *defaults.LeadTimeDays
+	}
+	if defaults.MinLevel != nil {
+		suggestion.SafetyStock = *defaults.MinLevel
+	}
+	if defaults.ReorderPoint != nil {
+		suggestion.ReorderPoint = *defaults.ReorderPoint
+	} else {
+		suggestion.ReorderPoint = suggestion.Velocity*float64(suggestion.LeadTimeDays) + suggestion.SafetyStock
+	}
+	suggestion.ReorderPoint = roundUp(max(suggestion.ReorderPoint, suggestion.SafetyStock), unit.Precision)
+	suggestion.Low = suggestion.ReorderPoint > 0 && position <= suggestion.ReorderPoint
+
+	var quantity float64
+	switch {
+	case defaults.MaxLevel != nil:
+		quantity = *defaults.MaxLevel - position
+	case defaults.ReorderQuantity != nil:
+		quantity = *defaults.ReorderQuantity
+	default:
+		quantity = suggestion.ReorderPoint + suggestion.Velocity*float64(planning.VelocityDays) - position
+	}
+	if quantity <= 0 {
+		return suggestion, nil
+	}
+	suggestion.Quantity = roundUp(quantity, unit.Precision)
+
+	packs, err := repos.Units.ListPacks(item.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list packs: %w", ErrDatabaseOperation)
+	}
+	for i := range packs {
+		if packs[i].DefaultPurchase {
+			suggestion.Pack = &packs[i]
+			suggestion.Packs = math.Ceil(suggestion.Quantity/packs[i].Factor - 1e-9)
+			suggestion.Quantity = unit.Round(suggestion.Packs * packs[i].Factor)
+			break
+		}
+	}
+	return suggestion, nil
+}
+
+// stockOnHand is the stock of item at its locations; what is in transit
+// between them cannot be sold until it is received.
+func stockOnHand(repos *repository.Repositories, item *model.Inventory) (float64, error) {
+	transit, err := repos.Locations.Transit()
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch transit location: %w", ErrDatabaseOperation)
+	}
+	moving, err := repos.StockMovements.SumAt(item.ID, transit.ID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to total stock in transit: %w", ErrDatabaseOperation)
+	}
+	return item.Quantity - moving, nil
+}
+
+// roundUp rounds quantity up to the given number of decimals, ignoring
+// float noise below them.
+func roundUp(quantity float64, precision int) float64 {
+	scale := math.Pow10(precision)
+	return math.Ceil(quantity*scale-1e-9) / scale
+}
@@ -0,0 +1,191 @@
+package reorder_service
+
+import (
+	"blizzflow/backend/domain/model"
+	repository "blizzflow/backend/domain/repositories"
+	"blizzflow/backend/events"
+	"blizzflow/backend/infrastructure/database"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+	"gorm.io/gorm"
+)
+
+func TestReorderServiceSuite(t *testing.T) {
+	gomega.RegisterFailHandler(ginkgo.Fail)
+	ginkgo.RunSpecs(t, "Reorder Service Test Suite")
+}
+
+const testDBPath = "test.db"
+
+var (
+	DB             *gorm.DB
+	reorderService *ReorderService
+	emitted        []string
+)
+
+var _ = ginkgo.BeforeSuite(func() {
+	os.Remove(testDBPath)
+	store, err := database.Open(database.DefaultOptions(testDBPath))
+	gomega.Expect(err).To(gomega.BeNil())
+	DB = store.DB()
+
+	dispatcher := events.NewDispatcher()
+	dispatcher.Bind(func(name string, data ...any) {
+		emitted = append(emitted, name)
+	})
+	reorderService = NewReorderService(repository.NewSettingRepository(DB), repository.NewUnitOfWork(DB), dispatcher)
+})
+
+var _ = ginkgo.AfterSuite(func() {
+	if DB != nil {
+		sqlDB, err := DB.DB()
+		if err == nil {
+			sqlDB.Close()
+		}
+	}
+	os.Remove(testDBPath)
+})
+
+var _ = ginkgo.Describe("Reorder Service", func() {
+	level := func(v float64) *float64 { return &v }
+
+	ginkgo.BeforeEach(func() {
+		DB.Exec("DELETE FROM sales")
+		DB.Exec("DELETE FROM item_units")
+		DB.Exec("DELETE FROM inventories")
+		DB.Exec("DELETE FROM categories")
+		DB.Exec("DELETE FROM settings")
+		DB.Exec("DELETE FROM stock_movements")
+		DB.Exec("DELETE FROM purchase_order_lines")
+		DB.Exec("DELETE FROM purchase_orders")
+		emitted = nil
+	})
+
+	ginkgo.It("should flag items at their reorder point and fill them up", func() {
+		low := &model.Inventory{Name: "Beans", Quantity: 3, ReorderPoint: level(5), MaxLevel: level(20)}
+		fine := &model.Inventory{Name: "Rice", Quantity: 10, ReorderPoint: level(5)}
+		archived := &model.Inventory{Name: "Old beans", ArchivedAt: &time.Time{}, ReorderPoint: level(5)}
+		DB.Create(low)
+		DB.Create(fine)
+		DB.Create(archived)
+
+		pantry := &model.Category{Name: "Pantry", Path: "/", ReorderPoint: level(4), ReorderQuantity: level(12)}
+		DB.Create(pantry)
+		DB.Model(pantry).Update("path", "/"+itoa(pantry.ID)+"/")
+		inherited := &model.Inventory{Name: "Lentils", Quantity: 2, CategoryID: &pantry.ID}
+		DB.Create(inherited)
+
+		suggestions, err := reorderService.Scan()
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(suggestions).To(gomega.HaveLen(2))
+		gomega.Expect(suggestions[0].Name).To(gomega.Equal("Beans"))
+		gomega.Expect(suggestions[0].Quantity).To(gomega.Equal(17.0))
+		gomega.Expect(suggestions[1].Name).To(gomega.Equal("Lentils"))
+		gomega.Expect(suggestions[1].ReorderPoint).To(gomega.Equal(4.0))
+		gomega.Expect(suggestions[1].Quantity).To(gomega.Equal(12.0))
+		gomega.Expect(emitted).To(gomega.Equal([]string{events.LowStock}))
+
+		suggestion, err := reorderService.Suggest(fine.ID)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(suggestion.Low).To(gomega.BeFalse())
+		_, err = reorderService.Suggest(9999)
+		gomega.Expect(err).To(gomega.Equal(ErrInventoryNotFound))
+	})
+
+	ginkgo.It("should leave stock in transit out of what is on hand", func() {
+		item := &model.Inventory{Name: "Flour", Quantity: 8, ReorderPoint: level(5), MaxLevel: level(20)}
+		DB.Create(item)
+		var transit model.Location
+		DB.Where("transit = ?", true).First(&transit)
+		DB.Create(&model.StockMovement{InventoryID: item.ID, Type: model.MovementTransfer, Quantity: -5, LocationID: 1})
+		DB.Create(&model.StockMovement{InventoryID: item.ID, Type: model.MovementTransfer, Quantity: 5, LocationID: transit.ID})
+
+		suggestions, err := reorderService.LowStock()
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(suggestions).To(gomega.HaveLen(1))
+		gomega.Expect(suggestions[0].OnHand).To(gomega.Equal(3.0))
+		gomega.Expect(suggestions[0].Low).To(gomega.BeTrue())
+		gomega.Expect(suggestions[0].Quantity).To(gomega.Equal(17.0))
+	})
+
+	ginkgo.It("should count what open orders still bring", func() {
+		item := &model.Inventory{Name: "Sugar", Quantity: 3, ReorderPoint: level(5), MaxLevel: level(20)}
+		DB.Create(item)
+		pack := &model.ItemUnit{InventoryID: item.ID, Name: "sack", Factor: 5}
+		DB.Create(pack)
+		sent := &model.PurchaseOrder{Number: "PO-1", SupplierID: 1, Status: model.PurchasePartial}
+		closed := &model.PurchaseOrder{Number: "PO-2", SupplierID: 1, Status: model.PurchaseClosed}
+		DB.Create(sent)
+		DB.Create(closed)
+		DB.Create(&model.PurchaseOrderLine{OrderID: sent.ID, InventoryID: item.ID, UnitID: &pack.ID, Name: "Sugar", Quantity: 2, Received: 1})
+		DB.Create(&model.PurchaseOrderLine{OrderID: closed.ID, InventoryID: item.ID, Name: "Sugar", Quantity: 10})
+
+		suggestion, err := reorderService.Suggest(item.ID)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(suggestion.OnHand).To(gomega.Equal(3.0))
+		gomega.Expect(suggestion.OnOrder).To(gomega.Equal(5.0))
+		gomega.Expect(suggestion.Low).To(gomega.BeFalse())
+		gomega.Expect(suggestion.Quantity).To(gomega.Equal(12.0))
+
+		suggestions, err := reorderService.LowStock()
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(suggestions).To(gomega.BeEmpty())
+
+		// Once the delivery is in, the next scan does not ask for it again
+		DB.Model(&model.PurchaseOrderLine{}).Where("order_id = ?", sent.ID).Update("received", 2)
+		DB.Model(item).Update("quantity", 8)
+		suggestion, err = reorderService.Suggest(item.ID)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(suggestion.OnOrder).To(gomega.BeZero())
+		gomega.Expect(suggestion.Quantity).To(gomega.Equal(12.0))
+	})
+
+	ginkgo.It("should suggest from sales velocity, lead time and safety stock", func() {
+		item := &model.Inventory{Name: "Soap", Quantity: 10, MinLevel: level(4)}
+		DB.Create(item)
+		DB.Create(&model.ItemUnit{InventoryID: item.ID, Name: "case", Factor: 12, DefaultPurchase: true})
+		DB.Create(&model.Sale{InventoryID: item.ID, Quantity: 60, CreatedAt: time.Now().AddDate(0, 0, -2)})
+		DB.Create(&model.Sale{InventoryID: item.ID, Quantity: 500, CreatedAt: time.Now().AddDate(0, 0, -40)})
+
+		suggestion, err := reorderService.Suggest(item.ID)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(suggestion.Velocity).To(gomega.Equal(2.0))
+		gomega.Expect(suggestion.LeadTimeDays).To(gomega.Equal(DefaultLeadTimeDays))
+		gomega.Expect(suggestion.ReorderPoint).To(gomega.Equal(18.0))
+		gomega.Expect(suggestion.Low).To(gomega.BeTrue())
+		// 18 + 60 - 10 = 68, rounded up to six cases of 12
+		gomega.Expect(suggestion.Packs).To(gomega.Equal(6.0))
+		gomega.Expect(suggestion.Quantity).To(gomega.Equal(72.0))
+
+		gomega.Expect(reorderService.SetPlanning(Planning{VelocityDays: 0})).To(gomega.Equal(ErrInvalidPlanning))
+		gomega.Expect(reorderService.SetPlanning(Planning{VelocityDays: 10, LeadTimeDays: 2})).To(gomega.Succeed())
+		planning, err := reorderService.GetPlanning()
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(*planning).To(gomega.Equal(Planning{VelocityDays: 10, LeadTimeDays: 2}))
+
+		suggestion, err = reorderService.Suggest(item.ID)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(suggestion.Velocity).To(gomega.Equal(6.0))
+		gomega.Expect(suggestion.ReorderPoint).To(gomega.Equal(16.0))
+	})
+
+	ginkgo.It("should check variants rather than their parent", func() {
+		parent := &model.Inventory{Name: "Shirt", ReorderPoint: level(5)}
+		DB.Create(parent)
+		DB.Create(&model.Inventory{Name: "Shirt - S", ParentID: &parent.ID, Quantity: 1, ReorderPoint: level(2)})
+
+		suggestions, err := reorderService.LowStock()
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(suggestions).To(gomega.HaveLen(1))
+		gomega.Expect(suggestions[0].Name).To(gomega.Equal("Shirt - S"))
+	})
+})
+
+func itoa(id uint) string {
+	return strconv.FormatUint(uint64(id), 10)
+}
@@ -0,0 +1,274 @@
+package retention_service
+
+import (
+	repository "blizzflow/backend/domain/repositories"
+	"blizzflow/config"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// DirName is the folder next to the database that receives purged rows
+	DirName = "retention"
+
+	timeColumn     = "created_at"
+	archivedColumn = "archived_at"
+	batchSize      = 500
+	timestamp      = "20060102T150405"
+)
+
+// Custom errors
+var (
+	ErrInvalidRule       = fmt.Errorf("invalid retention rule")
+	ErrDatabaseOperation = fmt.Errorf("database operation failed")
+)
+
+var tableName = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// DefaultRules apply when the config file has none.
+var DefaultRules = []config.RetentionRule{
+	{Table: "sessions", MaxAgeDays: 1},
+	{Table: "sales", MaxAgeDays: 7 * 365, Archive: true},
+}
+
+// RuleReport describes what one rule did.
+type RuleReport struct {
+	Table       string    `json:"table"`
+	Cutoff      time.Time `json:"cutoff"`
+	Archived    int       `json:"archived"`
+	Deleted     int64     `json:"deleted"`
+	ArchiveFile string    `json:"archiveFile,omitempty"`
+	Skipped     string    `json:"skipped,omitempty"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// Report is written next to the archives after every run.
+type Report struct {
+	StartedAt  time.Time    `json:"startedAt"`
+	FinishedAt time.Time    `json:"finishedAt"`
+	Rules      []RuleReport `json:"rules"`
+}
+
+type RetentionService struct {
+	db          *gorm.DB
+	sessionRepo repository.SessionRepo
+	rules       []config.RetentionRule
+	mu          sync.Mutex
+	dir         string
+	last        *Report
+	now         func() time.Time
+}
+
+// NewRetentionService purges rows by rules, archiving them to dir first where
+// the rule asks for it.
+func NewRetentionService(db *gorm.DB, sessionRepo repository.SessionRepo, rules []config.RetentionRule, dir string) *RetentionService {
+	if len(rules) == 0 {
+		rules = DefaultRules
+	}
+	return &RetentionService{
+		db:          db,
+		sessionRepo: sessionRepo,
+		rules:       rules,
+		dir:         dir,
+		now:         time.Now,
+	}
+}
+
+// DirFromConfig returns the archive folder: the configured one or the
+// retention folder next to dbFile.
+func DirFromConfig(cfg config.RetentionConfig, dbFile string) string {
+	if cfg.ArchiveDir != "" {
+		return cfg.ArchiveDir
+	}
+	return filepath.Join(filepath.Dir(dbFile), DirName)
+}
+
+// IntervalFromConfig returns how often Run is scheduled.
+func IntervalFromConfig(cfg config.RetentionConfig) time.Duration {
+	if cfg.IntervalHours > 0 {
+		return time.Duration(cfg.IntervalHours) * time.Hour
+	}
+	return 24 * time.Hour
+}
+
+// Retarget points the service at another archive folder, e.g. after the open
+// company changed.
+func (s *RetentionService) Retarget(dir string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dir = dir
+}
+
+// Run applies every rule once. A failing rule is reported and does not stop
+// the others.
+func (s *RetentionService) Run() (*Report, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	report := &Report{StartedAt: s.now()}
+	for _, rule := range s.rules {
+		result := s.apply(rule, report.StartedAt)
+		report.Rules = append(report.Rules, result)
+	}
+	report.FinishedAt = s.now()
+
+	if err := s.writeReport(report); err != nil {
+		return report, err
+	}
+	s.last = report
+	return report, nil
+}
+
+// LastReport returns the report of the most recent run, or nil.
+func (s *RetentionService) LastReport() *Report {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.last
+}
+
+func (s *RetentionService) apply(rule config.RetentionRule, now time.Time) RuleReport {
+	result := RuleReport{Table: rule.Table}
+	if !tableName.MatchString(rule.Table) || rule.MaxAgeDays <= 0 {
+		result.Error = ErrInvalidRule.Error()
+		return result
+	}
+	maxAge := time.Duration(rule.MaxAgeDays) * 24 * time.Hour
+	result.Cutoff = now.Add(-maxAge)
+
+	migrator := s.db.Migrator()
+	if !migrator.HasTable(rule.Table) {
+		result.Skipped = "table does not exist"
+		return result
+	}
+	if !migrator.HasColumn(rule.Table, timeColumn) {
+		result.Skipped = "table has no " + timeColumn + " column"
+		return result
+	}
+
+	// Sessions hold no history worth keeping
+	if rule.Table == "sessions" && !rule.Archive {
+		deleted, err := s.sessionRepo.CleanupExpiredSessions(maxAge)
+		if err != nil {
+			result.Error = err.Error()
+		}
+		result.Deleted = deleted
+		return result
+	}
+
+	if rule.Archive {
+		if err := s.archive(rule.Table, result.Cutoff, &result); err != nil {
+			result.Error = err.Error()
+			return result
+		}
+	}
+
+	query := s.db.Table(rule.Table).Where(timeColumn+" < ?", result.Cutoff)
+	if rule.Archive && migrator.HasColumn(rule.Table, archivedColumn) {
+		// Only rows whose export succeeded are purged
+		query = query.Where(archivedColumn + " IS NOT NULL")
+	}
+	res := query.Delete(nil)
+	if res.Error != nil {
+		result.Error = fmt.Errorf("failed to purge %s: %w", rule.Table, ErrDatabaseOperation).Error()
+		return result
+	}
+	result.Deleted = res.RowsAffected
+	return result
+}
+
+// archive writes the rows about to be purged to a gzip-compressed JSON Lines
+// file and marks them archived where the table supports it.
+func (s *RetentionService) archive(table string, cutoff time.Time, result *RuleReport) error {
+	hasArchived := s.db.Migrator().HasColumn(table, archivedColumn)
+	query := s.db.Table(table).Where(timeColumn+" < ?", cutoff)
+	if hasArchived {
+		// Rows archived by an earlier, interrupted run are already on disk
+		query = query.Where(archivedColumn + " IS NULL")
+	}
+
+	var rows []map[string]interface{}
+	if err := query.Order(timeColumn).Find(&rows).Error; err != nil {
+		return fmt.Errorf("failed to read %s: %w", table, ErrDatabaseOperation)
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.jsonl.gz", table, s.now().Format(timestamp))
+	path := filepath.Join(s.dir, name)
+	if err := writeRows(path, rows); err != nil {
+		return err
+	}
+	result.ArchiveFile = name
+	result.Archived = len(rows)
+
+	if !hasArchived {
+		return nil
+	}
+	ids := make([]interface{}, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row["id"])
+	}
+	archivedAt := s.now()
+	for start := 0; start < len(ids); start += batchSize {
+		end := min(start+batchSize, len(ids))
+		err := s.db.Table(table).Where("id IN ?", ids[start:end]).Update(archivedColumn, archivedAt).Error
+		if err != nil {
+			return fmt.Errorf("failed to mark %s archived: %w", table, ErrDatabaseOperation)
+		}
+	}
+	return nil
+}
+
+func writeRows(path string, rows []map[string]interface{}) error {
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	zw := gzip.NewWriter(file)
+	enc := json.NewEncoder(zw)
+	for _, row := range rows {
+		if err = enc.Encode(row); err != nil {
+			break
+		}
+	}
+	if closeErr := zw.Close(); err == nil {
+		err = closeErr
+	}
+	if syncErr := file.Sync(); err == nil {
+		err = syncErr
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s *RetentionService) writeReport(report *Report) error {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("report-%s.json", report.StartedAt.Format(timestamp))
+	return os.WriteFile(filepath.Join(s.dir, name), data, 0644)
+}
@@ -0,0 +1,133 @@
+package retention_service
+
+import (
+	"blizzflow/backend/domain/model"
+	repository "blizzflow/backend/domain/repositories"
+	"blizzflow/backend/infrastructure/database"
+	"blizzflow/config"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+	"gorm.io/gorm"
+)
+
+func TestRetentionServiceSuite(t *testing.T) {
+	gomega.RegisterFailHandler(ginkgo.Fail)
+	ginkgo.RunSpecs(t, "Retention Service Test Suite")
+}
+
+const testDBPath = "test.db"
+
+var DB *gorm.DB
+
+var _ = ginkgo.BeforeSuite(func() {
+	os.Remove(testDBPath)
+	store, err := database.Open(database.DefaultOptions(testDBPath))
+	gomega.Expect(err).To(gomega.BeNil())
+	DB = store.DB()
+})
+
+var _ = ginkgo.AfterSuite(func() {
+	if DB != nil {
+		sqlDB, err := DB.DB()
+		if err == nil {
+			sqlDB.Close()
+		}
+	}
+	os.Remove(testDBPath)
+})
+
+var _ = ginkgo.Describe("Retention Service", func() {
+	var (
+		dir     string
+		now     time.Time
+		service *RetentionService
+	)
+
+	newService := func(rules []config.RetentionRule) *RetentionService {
+		s := NewRetentionService(DB, repository.NewSessionRepository(DB), rules, dir)
+		s.now = func() time.Time { return now }
+		return s
+	}
+
+	ginkgo.BeforeEach(func() {
+		dir = ginkgo.GinkgoT().TempDir()
+		now = time.Now()
+		DB.Exec("DELETE FROM sessions")
+		DB.Exec("DELETE FROM sales")
+
+		old := now.AddDate(-8, 0, 0)
+		DB.Create(&model.Session{UserID: 1, CreatedAt: now.Add(-48 * time.Hour)})
+		DB.Create(&model.Session{UserID: 2, CreatedAt: now})
+		DB.Create(&model.Sale{InventoryID: 1, Quantity: 1, TotalPrice: 5, CreatedAt: old})
+		DB.Create(&model.Sale{InventoryID: 1, Quantity: 2, TotalPrice: 10, CreatedAt: now})
+
+		service = newService(nil)
+	})
+
+	ginkgo.It("should purge expired sessions", func() {
+		report, err := service.Run()
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(report.Rules[0].Table).To(gomega.Equal("sessions"))
+		gomega.Expect(report.Rules[0].Deleted).To(gomega.Equal(int64(1)))
+
+		var count int64
+		DB.Model(&model.Session{}).Count(&count)
+		gomega.Expect(count).To(gomega.Equal(int64(1)))
+	})
+
+	ginkgo.It("should archive old sales before deleting them", func() {
+		report, err := service.Run()
+		gomega.Expect(err).To(gomega.BeNil())
+
+		sales := report.Rules[1]
+		gomega.Expect(sales.Error).To(gomega.BeEmpty())
+		gomega.Expect(sales.Archived).To(gomega.Equal(1))
+		gomega.Expect(sales.Deleted).To(gomega.Equal(int64(1)))
+
+		file, err := os.Open(filepath.Join(dir, sales.ArchiveFile))
+		gomega.Expect(err).To(gomega.BeNil())
+		defer file.Close()
+		zr, err := gzip.NewReader(file)
+		gomega.Expect(err).To(gomega.BeNil())
+		data, _ := io.ReadAll(zr)
+		gomega.Expect(strings.Count(string(data), "\n")).To(gomega.Equal(1))
+
+		var remaining []model.Sale
+		DB.Find(&remaining)
+		gomega.Expect(remaining).To(gomega.HaveLen(1))
+		gomega.Expect(remaining[0].Quantity).To(gomega.Equal(2.0))
+
+		reports, _ := filepath.Glob(filepath.Join(dir, "report-*.json"))
+		gomega.Expect(reports).To(gomega.HaveLen(1))
+		gomega.Expect(service.LastReport()).To(gomega.Equal(report))
+	})
+
+	ginkgo.It("should purge sales archived by an interrupted run without exporting them again", func() {
+		archivedAt := now.Add(-time.Hour)
+		DB.Model(&model.Sale{}).Where("quantity = ?", 1).Update("archived_at", archivedAt)
+
+		report, err := service.Run()
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(report.Rules[1].Archived).To(gomega.BeZero())
+		gomega.Expect(report.Rules[1].Deleted).To(gomega.Equal(int64(1)))
+	})
+
+	ginkgo.It("should report missing tables and invalid rules", func() {
+		report, err := newService([]config.RetentionRule{
+			{Table: "audit_logs", MaxAgeDays: 30, Archive: true},
+			{Table: "sales; DROP TABLE users", MaxAgeDays: 30},
+		}).Run()
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(report.Rules[0].Skipped).NotTo(gomega.BeEmpty())
+		gomega.Expect(report.Rules[1].Error).To(gomega.Equal(ErrInvalidRule.Error()))
+		gomega.Expect(DB.Migrator().HasTable("users")).To(gomega.BeTrue())
+	})
+})
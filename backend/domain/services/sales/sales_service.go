@@ -3,63 +3,173 @@ package services
 import (
 	"blizzflow/backend/domain/model"
 	repository "blizzflow/backend/domain/repositories"
+	stock_service "blizzflow/backend/domain/services/stock"
+	"errors"
 	"fmt"
+	"math"
+	"time"
 )
 
 // Custom errors
 var (
 	ErrInvalidInventoryID = fmt.Errorf("invalid inventory ID")
-	ErrInvalidQuantity    = fmt.Errorf("invalid quantity")
-	ErrInsufficientStock  = fmt.Errorf("insufficient stock")
+	ErrInvalidQuantity    = stock_service.ErrInvalidQuantity
+	ErrInsufficientStock  = stock_service.ErrInsufficientStock
 	ErrInventoryNotFound  = fmt.Errorf("inventory not found")
+	ErrInventoryArchived  = fmt.Errorf("inventory is archived")
+	ErrVariantRequired    = stock_service.ErrVariantRequired
+	ErrUnitNotFound       = stock_service.ErrUnitNotFound
+	ErrLotExpired         = stock_service.ErrLotExpired
+	ErrLotNotFound        = stock_service.ErrLotNotFound
+	ErrLotRequired        = stock_service.ErrLotRequired
+	ErrInvalidLot         = stock_service.ErrInvalidLot
+	ErrSerialRequired     = stock_service.ErrSerialRequired
+	ErrSerialNotFound     = stock_service.ErrSerialNotFound
+	ErrSerialNotInStock   = stock_service.ErrSerialNotInStock
+	ErrLocationNotFound   = stock_service.ErrLocationNotFound
+	ErrLocationArchived   = stock_service.ErrLocationArchived
+	ErrInvalidPeriod      = fmt.Errorf("invalid period")
 	ErrDatabaseOperation  = fmt.Errorf("database operation failed")
 )
 
 type SalesService struct {
-	saleRepo      *repository.SaleRepository
-	inventoryRepo *repository.InventoryRepository
+	saleRepo      repository.SaleRepo
+	inventoryRepo repository.InventoryRepo
+	uow           repository.UnitOfWork
 }
 
-func NewSalesService(saleRepo *repository.SaleRepository, invRepo *repository.InventoryRepository) *SalesService {
+func NewSalesService(saleRepo repository.SaleRepo, invRepo repository.InventoryRepo, uow repository.UnitOfWork) *SalesService {
 	return &SalesService{
 		saleRepo:      saleRepo,
 		inventoryRepo: invRepo,
+		uow:           uow,
 	}
 }
 
-func (s *SalesService) CreateSale(inventoryID uint, quantity int) (*model.Sale, error) {
+// CreateSale sells quantity of an item, counted in the pack unitID or, for
+// nil, the item's base unit. The sale records the quantity in base units and
+// the cost of the goods sold under the configured costing method. Lot-tracked
+// items are sold first-expiry-first-out, never from expired lots. Serialized
+// items are sold with CreateSerialSale. Goods leave from the current
+// location.
+func (s *SalesService) CreateSale(inventoryID uint, quantity float64, unitID *uint) (*model.Sale, error) {
+	return s.sell(inventoryID, quantity, unitID, stock_service.Record)
+}
+
+// CreateLotSale is CreateSale taking the goods from a chosen lot instead of
+// the first to expire.
+func (s *SalesService) CreateLotSale(inventoryID, lotID uint, quantity float64, unitID *uint) (*model.Sale, error) {
+	return s.sell(inventoryID, quantity, unitID, func(repos *repository.Repositories, m *model.StockMovement) error {
+		return stock_service.RecordLots(repos, m, []stock_service.LotPick{{LotID: lotID, Quantity: -m.Quantity}})
+	})
+}
+
+// CreateSerialSale sells the one unit of a serialized item with the
+// scanned serial number to customer, which starts its warranty.
+func (s *SalesService) CreateSerialSale(inventoryID uint, serial, customer string) (*model.Sale, error) {
+	return s.sell(inventoryID, 1, nil, func(repos *repository.Repositories, m *model.StockMovement) error {
+		return stock_service.RecordSerials(repos, m, []string{serial}, customer)
+	})
+}
+
+// sell records a sale and books its stock out with record.
+func (s *SalesService) sell(inventoryID uint, quantity float64, unitID *uint, record func(*repository.Repositories, *model.StockMovement) error) (*model.Sale, error) {
 	if inventoryID == 0 {
 		return nil, ErrInvalidInventoryID
 	}
-	if quantity <= 0 {
+	if !(quantity > 0) {
 		return nil, ErrInvalidQuantity
 	}
 
-	inventory, err := s.inventoryRepo.GetByID(inventoryID)
+	var sale *model.Sale
+	// Record the sale and decrement stock together, or not at all
+	err := s.uow.Do(func(repos *repository.Repositories) error {
+		inventory, err := repos.Inventory.GetByID(inventoryID)
+		if err != nil {
+			return fmt.Errorf("failed to fetch inventory: %w", ErrInventoryNotFound)
+		}
+		if inventory.ArchivedAt != nil {
+			return ErrInventoryArchived
+		}
+		// A parent is sold through one of its variants
+		variants, err := repos.Inventory.CountVariants(inventoryID)
+		if err != nil {
+			return fmt.Errorf("failed to count variants: %w", ErrDatabaseOperation)
+		}
+		if variants > 0 {
+			return ErrVariantRequired
+		}
+
+		base, err := stock_service.Convert(repos, inventory, quantity, unitID)
+		if err != nil {
+			return err
+		}
+		location, err := stock_service.CurrentLocation(repos)
+		if err != nil {
+			return err
+		}
+
+		sale = &model.Sale{
+			InventoryID: inventoryID,
+			LocationID:  location.ID,
+			Quantity:    base,
+			TotalPrice:  math.Round(base*inventory.Price*100) / 100,
+		}
+
+		if err := repos.Sales.Create(sale); err != nil {
+			return fmt.Errorf("failed to create sale record: %w", ErrDatabaseOperation)
+		}
+
+		movement := &model.StockMovement{
+			InventoryID: inventoryID,
+			LocationID:  location.ID,
+			Type:        model.MovementSale,
+			Quantity:    -base,
+			Reference:   stock_service.Reference("sale", sale.ID),
+		}
+		err = record(repos, movement)
+		if err != nil && !isStockError(err) {
+			return fmt.Errorf("failed to update stock: %w", ErrDatabaseOperation)
+		} else if err != nil {
+			return err
+		}
+
+		// The ledger values the stock that left; that is the cost of the sale
+		sale.Cost = -movement.Cost
+		if err := repos.Sales.SetCost(sale.ID, sale.Cost); err != nil {
+			return fmt.Errorf("failed to update sale record: %w", ErrDatabaseOperation)
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch inventory: %w", ErrInventoryNotFound)
+		return nil, err
 	}
 
-	if inventory.Quantity < quantity {
-		return nil, fmt.Errorf("requested quantity %d exceeds available stock %d: %w",
-			quantity, inventory.Quantity, ErrInsufficientStock)
-	}
+	return sale, nil
+}
 
-	sale := &model.Sale{
-		InventoryID: inventoryID,
-		Quantity:    quantity,
-		TotalPrice:  float64(quantity) * inventory.Price,
+// SalesByItem totals the sales in [from, to) per item with their cost and
+// margin, best sellers first.
+// With byParent, variants are rolled up into their parent item. A location
+// narrows the totals to the sales made there; nil totals them all.
+func (s *SalesService) SalesByItem(from, to time.Time, byParent bool, locationID *uint) ([]repository.SaleTotal, error) {
+	if !from.Before(to) {
+		return nil, ErrInvalidPeriod
 	}
-
-	if err := s.saleRepo.Create(sale); err != nil {
-		return nil, fmt.Errorf("failed to create sale record: %w", ErrDatabaseOperation)
+	totals, err := s.saleRepo.Totals(from, to, byParent, locationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to total sales: %w", ErrDatabaseOperation)
 	}
-
-	// Update inventory quantity
-	inventory.Quantity -= quantity
-	if err := s.inventoryRepo.Update(inventory); err != nil {
-		return nil, fmt.Errorf("failed to update inventory: %w", ErrDatabaseOperation)
+	if totals == nil {
+		totals = []repository.SaleTotal{}
 	}
+	return totals, nil
+}
 
-	return sale, nil
+// isStockError reports whether err is about the stock itself rather than the
+// database, so the caller can tell the user. The stock service wraps every
+// database failure in its ErrDatabaseOperation; its other errors are about
+// the request.
+func isStockError(err error) bool {
+	return !errors.Is(err, stock_service.ErrDatabaseOperation)
 }
@@ -3,9 +3,13 @@ package services
 import (
 	"blizzflow/backend/domain/model"
 	repository "blizzflow/backend/domain/repositories"
+	stock_service "blizzflow/backend/domain/services/stock"
 	"blizzflow/backend/infrastructure/database"
+	"errors"
+	"fmt"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/onsi/ginkgo/v2"
 	"github.com/onsi/gomega"
@@ -27,12 +31,13 @@ func TestSalesServiceSuite(t *testing.T) {
 
 var _ = ginkgo.BeforeSuite(func() {
 	os.Remove(testDBPath)
-	database.InitDB(testDBPath)
-	DB = database.DB
+	store, err := database.Open(database.DefaultOptions(testDBPath))
+	gomega.Expect(err).To(gomega.BeNil())
+	DB = store.DB()
 
 	salesRepo = repository.NewSaleRepository(DB)
 	inventoryRepo = repository.NewInventoryRepository(DB)
-	salesService = NewSalesService(salesRepo, inventoryRepo)
+	salesService = NewSalesService(salesRepo, inventoryRepo, repository.NewUnitOfWork(DB))
 })
 
 var _ = ginkgo.AfterSuite(func() {
@@ -50,58 +55,336 @@ var _ = ginkgo.Describe("Sales Service", func() {
 
 	ginkgo.BeforeEach(func() {
 		DB.Exec("DELETE FROM sales")
+		DB.Exec("DELETE FROM serial_events")
+		DB.Exec("DELETE FROM serial_numbers")
+		DB.Exec("DELETE FROM lot_allocations")
+		DB.Exec("DELETE FROM lots")
+		DB.Exec("DELETE FROM cost_layers")
+		DB.Exec("DELETE FROM stock_movements")
 		DB.Exec("DELETE FROM inventories")
+		DB.Exec("DELETE FROM settings")
+		DB.Exec("DELETE FROM locations WHERE id > 2")
 
 		testInventory = &model.Inventory{
-			Name:     "Test Product",
-			Quantity: 100,
-			Price:    10.0,
+			Name:        "Test Product",
+			Quantity:    100,
+			Price:       10.0,
+			AverageCost: 6,
 		}
 		DB.Create(testInventory)
+		DB.Create(&model.StockMovement{InventoryID: testInventory.ID, Type: model.MovementOpening, Quantity: 100, Balance: 100})
 	})
 
 	ginkgo.Context("CreateSale", func() {
 		ginkgo.It("should create a sale successfully", func() {
-			sale, err := salesService.CreateSale(testInventory.ID, 5)
+			sale, err := salesService.CreateSale(testInventory.ID, 5, nil)
 
 			gomega.Expect(err).To(gomega.BeNil())
 			gomega.Expect(sale).NotTo(gomega.BeNil())
 			gomega.Expect(sale.InventoryID).To(gomega.Equal(testInventory.ID))
-			gomega.Expect(sale.Quantity).To(gomega.Equal(5))
+			gomega.Expect(sale.Quantity).To(gomega.Equal(5.0))
 			gomega.Expect(sale.TotalPrice).To(gomega.Equal(50.0))
 
 			// Verify inventory was updated
 			var updatedInventory model.Inventory
 			DB.First(&updatedInventory, testInventory.ID)
-			gomega.Expect(updatedInventory.Quantity).To(gomega.Equal(95))
+			gomega.Expect(updatedInventory.Quantity).To(gomega.Equal(95.0))
+
+			// And the sale is in the stock ledger
+			var movement model.StockMovement
+			DB.Where("reference = ?", fmt.Sprintf("sale:%d", sale.ID)).First(&movement)
+			gomega.Expect(movement.Type).To(gomega.Equal(model.MovementSale))
+			gomega.Expect(movement.Quantity).To(gomega.Equal(-5.0))
+			gomega.Expect(movement.Balance).To(gomega.Equal(95.0))
+		})
+
+		ginkgo.It("should store the cost of the goods sold", func() {
+			sale, err := salesService.CreateSale(testInventory.ID, 5, nil)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(sale.Cost).To(gomega.Equal(30.0))
+
+			// FIFO sells the stock from before costs were kept first, then
+			// the oldest receipt
+			DB.Create(&model.Setting{Key: stock_service.CostingMethodKey, Value: model.CostingFIFO})
+			repos := repository.NewRepositories(DB)
+			for _, cost := range []float64{70, 90} {
+				err := stock_service.Record(repos, &model.StockMovement{
+					InventoryID: testInventory.ID,
+					Type:        model.MovementReceipt,
+					Quantity:    10,
+					Cost:        cost,
+				})
+				gomega.Expect(err).To(gomega.BeNil())
+			}
+			sale, err = salesService.CreateSale(testInventory.ID, 100, nil)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(sale.Cost).To(gomega.Equal(95*6.0 + 5*7))
+
+			var stored model.Sale
+			DB.First(&stored, sale.ID)
+			gomega.Expect(stored.Cost).To(gomega.Equal(sale.Cost))
 		})
 
 		ginkgo.It("should return error for invalid inventory ID", func() {
-			sale, err := salesService.CreateSale(0, 5)
+			sale, err := salesService.CreateSale(0, 5, nil)
 
 			gomega.Expect(err).To(gomega.Equal(ErrInvalidInventoryID))
 			gomega.Expect(sale).To(gomega.BeNil())
 		})
 
 		ginkgo.It("should return error for invalid quantity", func() {
-			sale, err := salesService.CreateSale(testInventory.ID, 0)
+			sale, err := salesService.CreateSale(testInventory.ID, 0, nil)
 
 			gomega.Expect(err).To(gomega.Equal(ErrInvalidQuantity))
 			gomega.Expect(sale).To(gomega.BeNil())
 		})
 
 		ginkgo.It("should return error for insufficient stock", func() {
-			sale, err := salesService.CreateSale(testInventory.ID, 150)
+			sale, err := salesService.CreateSale(testInventory.ID, 150, nil)
 
 			gomega.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("insufficient stock")))
 			gomega.Expect(sale).To(gomega.BeNil())
 		})
 
+		ginkgo.It("should roll back the sale when the stock update fails", func() {
+			failing := NewSalesService(salesRepo, inventoryRepo, &failingStockUnitOfWork{repository.NewUnitOfWork(DB)})
+
+			sale, err := failing.CreateSale(testInventory.ID, 5, nil)
+			gomega.Expect(err).To(gomega.MatchError(ErrDatabaseOperation))
+			gomega.Expect(sale).To(gomega.BeNil())
+
+			var count int64
+			DB.Model(&model.Sale{}).Count(&count)
+			gomega.Expect(count).To(gomega.Equal(int64(0)))
+		})
+
 		ginkgo.It("should return error for non-existent inventory", func() {
-			sale, err := salesService.CreateSale(999, 5)
+			sale, err := salesService.CreateSale(999, 5, nil)
 
 			gomega.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("inventory not found")))
 			gomega.Expect(sale).To(gomega.BeNil())
 		})
 	})
+
+	ginkgo.Context("Units", func() {
+		ginkgo.It("should sell fractions and packs in base units", func() {
+			rice := &model.Inventory{Name: "Rice", Unit: "kg", Price: 3.99}
+			DB.Create(rice)
+			DB.Model(rice).Update("quantity", 10)
+			DB.Create(&model.StockMovement{InventoryID: rice.ID, Type: model.MovementOpening, Quantity: 10, Balance: 10})
+			bag := &model.ItemUnit{InventoryID: rice.ID, Name: "bag", Factor: 2.5}
+			DB.Create(bag)
+
+			sale, err := salesService.CreateSale(rice.ID, 0.75, nil)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(sale.Quantity).To(gomega.Equal(0.75))
+			gomega.Expect(sale.TotalPrice).To(gomega.Equal(2.99))
+
+			sale, err = salesService.CreateSale(rice.ID, 2, &bag.ID)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(sale.Quantity).To(gomega.Equal(5.0))
+
+			_, err = salesService.CreateSale(rice.ID, 2, &bag.ID)
+			gomega.Expect(err).To(gomega.MatchError(ErrInsufficientStock))
+			_, err = salesService.CreateSale(testInventory.ID, 1.5, nil)
+			gomega.Expect(err).To(gomega.MatchError(ErrInvalidQuantity))
+			_, err = salesService.CreateSale(testInventory.ID, 1, &bag.ID)
+			gomega.Expect(err).To(gomega.Equal(ErrUnitNotFound))
+
+			var current model.Inventory
+			DB.First(&current, rice.ID)
+			gomega.Expect(current.Quantity).To(gomega.Equal(4.25))
+		})
+	})
+
+	ginkgo.Context("Variants", func() {
+		var small, large *model.Inventory
+
+		ginkgo.BeforeEach(func() {
+			parent := &model.Inventory{Name: "T-shirt", Price: 15}
+			DB.Create(parent)
+			small = &model.Inventory{ParentID: &parent.ID, Name: "T-shirt - S", Price: 15}
+			large = &model.Inventory{ParentID: &parent.ID, Name: "T-shirt - L", Price: 18}
+			DB.Create(small)
+			DB.Create(large)
+			for _, variant := range []*model.Inventory{small, large} {
+				DB.Model(variant).Update("quantity", 10)
+				DB.Create(&model.StockMovement{InventoryID: variant.ID, Type: model.MovementOpening, Quantity: 10, Balance: 10})
+			}
+		})
+
+		ginkgo.It("should sell a variant rather than its parent", func() {
+			_, err := salesService.CreateSale(*small.ParentID, 1, nil)
+			gomega.Expect(err).To(gomega.Equal(ErrVariantRequired))
+
+			_, err = salesService.CreateSale(small.ID, 2, nil)
+			gomega.Expect(err).To(gomega.BeNil())
+			_, err = salesService.CreateSale(large.ID, 1, nil)
+			gomega.Expect(err).To(gomega.BeNil())
+		})
+
+		ginkgo.It("should total sales per item or per parent", func() {
+			salesService.CreateSale(small.ID, 2, nil)
+			salesService.CreateSale(large.ID, 1, nil)
+			salesService.CreateSale(testInventory.ID, 1, nil)
+			from, to := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+
+			totals, err := salesService.SalesByItem(from, to, false, nil)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(totals).To(gomega.HaveLen(3))
+			gomega.Expect(totals[0].Name).To(gomega.Equal("T-shirt - S"))
+
+			totals, err = salesService.SalesByItem(from, to, true, nil)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(totals).To(gomega.Equal([]repository.SaleTotal{
+				{InventoryID: *small.ParentID, Name: "T-shirt", Quantity: 3, Revenue: 48, Margin: 48},
+				{InventoryID: testInventory.ID, Name: "Test Product", Quantity: 1, Revenue: 10, Cost: 6, Margin: 4},
+			}))
+
+			_, err = salesService.SalesByItem(to, from, true, nil)
+			gomega.Expect(err).To(gomega.Equal(ErrInvalidPeriod))
+		})
+	})
+
+	ginkgo.Context("Lots", func() {
+		var milk *model.Inventory
+		var old, fresh *model.Lot
+
+		ginkgo.BeforeEach(func() {
+			milk = &model.Inventory{Name: "Milk", Price: 1.5, TrackLots: true}
+			DB.Create(milk)
+			yesterday := time.Now().AddDate(0, 0, -1)
+			nextWeek := time.Now().AddDate(0, 0, 7)
+			old = &model.Lot{InventoryID: milk.ID, Number: "OLD", ExpiresAt: &yesterday, Quantity: 4}
+			fresh = &model.Lot{InventoryID: milk.ID, Number: "FRESH", ExpiresAt: &nextWeek, Quantity: 6}
+			DB.Create(old)
+			DB.Create(fresh)
+			DB.Model(milk).Update("quantity", 10)
+			DB.Create(&model.StockMovement{InventoryID: milk.ID, Type: model.MovementOpening, Quantity: 10, Balance: 10})
+		})
+
+		ginkgo.It("should sell from lots that have not expired", func() {
+			_, err := salesService.CreateSale(milk.ID, 5, nil)
+			gomega.Expect(err).To(gomega.BeNil())
+			_, err = salesService.CreateLotSale(milk.ID, old.ID, 1, nil)
+			gomega.Expect(err).To(gomega.MatchError(ErrLotExpired))
+			_, err = salesService.CreateSale(milk.ID, 2, nil)
+			gomega.Expect(err).To(gomega.MatchError(ErrLotExpired))
+			_, err = salesService.CreateLotSale(milk.ID, fresh.ID, 1, nil)
+			gomega.Expect(err).To(gomega.BeNil())
+			// Picking the wrong lot is the user's mistake, not a database failure
+			_, err = salesService.CreateLotSale(testInventory.ID, fresh.ID, 1, nil)
+			gomega.Expect(err).To(gomega.MatchError(stock_service.ErrLotsNotTracked))
+			other := &model.Lot{InventoryID: testInventory.ID, LocationID: model.MainLocation, Number: "X1", Quantity: 1}
+			DB.Create(other)
+			_, err = salesService.CreateLotSale(milk.ID, other.ID, 1, nil)
+			gomega.Expect(err).To(gomega.MatchError(ErrLotNotFound))
+
+			var sold, expired model.Lot
+			DB.First(&sold, fresh.ID)
+			DB.First(&expired, old.ID)
+			gomega.Expect(sold.Quantity).To(gomega.BeZero())
+			gomega.Expect(expired.Quantity).To(gomega.Equal(4.0))
+
+			var sales int64
+			DB.Model(&model.Sale{}).Where("inventory_id = ?", milk.ID).Count(&sales)
+			gomega.Expect(sales).To(gomega.Equal(int64(2)))
+		})
+	})
+
+	ginkgo.Context("Serial numbers", func() {
+		ginkgo.It("should sell serialized items one scanned unit at a time", func() {
+			phone := &model.Inventory{Name: "Phone", Price: 300, TrackSerials: true, WarrantyMonths: 24}
+			DB.Create(phone)
+			repos := repository.NewRepositories(DB)
+			err := stock_service.RecordSerials(repos, &model.StockMovement{InventoryID: phone.ID, Type: model.MovementReceipt, Quantity: 2}, []string{"SN-1", "SN-2"}, "Acme")
+			gomega.Expect(err).To(gomega.BeNil())
+
+			_, err = salesService.CreateSale(phone.ID, 1, nil)
+			gomega.Expect(err).To(gomega.Equal(ErrSerialRequired))
+			sale, err := salesService.CreateSerialSale(phone.ID, "sn-2", "Carol")
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(sale.Quantity).To(gomega.Equal(1.0))
+			gomega.Expect(sale.TotalPrice).To(gomega.Equal(300.0))
+			_, err = salesService.CreateSerialSale(phone.ID, "SN-2", "Carol")
+			gomega.Expect(err).To(gomega.MatchError(ErrSerialNotInStock))
+			_, err = salesService.CreateSerialSale(phone.ID, "SN-3", "Carol")
+			gomega.Expect(err).To(gomega.MatchError(ErrSerialNotFound))
+
+			var unit model.SerialNumber
+			DB.Where("serial = ?", "SN-2").First(&unit)
+			gomega.Expect(unit.Status).To(gomega.Equal(model.SerialSold))
+			gomega.Expect(unit.WarrantyEndsAt).NotTo(gomega.BeNil())
+			gomega.Expect(unit.WarrantyEndsAt.Year()).To(gomega.Equal(unit.SoldAt.Year() + 2))
+			var event model.SerialEvent
+			DB.Where("serial_id = ? AND type = ?", unit.ID, model.MovementSale).First(&event)
+			gomega.Expect(event.Party).To(gomega.Equal("Carol"))
+			gomega.Expect(event.Reference).To(gomega.Equal(stock_service.Reference("sale", sale.ID)))
+		})
+	})
+
+	ginkgo.Context("Locations", func() {
+		ginkgo.It("should sell from the current location and total sales per location", func() {
+			branch := &model.Location{Name: "Branch"}
+			DB.Create(branch)
+
+			_, err := salesService.CreateSale(testInventory.ID, 2, nil)
+			gomega.Expect(err).To(gomega.BeNil())
+			DB.Create(&model.Setting{Key: stock_service.CurrentLocationKey, Value: fmt.Sprint(branch.ID)})
+			// Everything is at Main; the branch holds nothing to sell
+			_, err = salesService.CreateSale(testInventory.ID, 1, nil)
+			gomega.Expect(err).To(gomega.MatchError(ErrInsufficientStock))
+
+			DB.Create(&model.StockMovement{InventoryID: testInventory.ID, LocationID: branch.ID, Type: model.MovementReceipt, Quantity: 3})
+			sale, err := salesService.CreateSale(testInventory.ID, 3, nil)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(sale.LocationID).To(gomega.Equal(branch.ID))
+
+			from, to := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+			totals, err := salesService.SalesByItem(from, to, false, &branch.ID)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(totals).To(gomega.HaveLen(1))
+			gomega.Expect(totals[0].Quantity).To(gomega.Equal(3.0))
+			totals, _ = salesService.SalesByItem(from, to, false, nil)
+			gomega.Expect(totals[0].Quantity).To(gomega.Equal(5.0))
+		})
+	})
 })
+
+var _ = ginkgo.DescribeTable("isStockError",
+	func(err error, want bool) {
+		gomega.Expect(isStockError(err)).To(gomega.Equal(want))
+	},
+	ginkgo.Entry("insufficient stock", fmt.Errorf("at Main: %w", ErrInsufficientStock), true),
+	ginkgo.Entry("an invalid quantity", ErrInvalidQuantity, true),
+	ginkgo.Entry("an invalid lot", ErrInvalidLot, true),
+	ginkgo.Entry("a lot required", ErrLotRequired, true),
+	ginkgo.Entry("a variant required", ErrVariantRequired, true),
+	ginkgo.Entry("an archived location", ErrLocationArchived, true),
+	ginkgo.Entry("a database failure", fmt.Errorf("failed to update inventory: %w", stock_service.ErrDatabaseOperation), false),
+)
+
+// failingStockUnitOfWork hands out repositories whose stock updates fail,
+// to simulate a crash between writing the sale and decrementing stock.
+type failingStockUnitOfWork struct {
+	repository.UnitOfWork
+}
+
+func (u *failingStockUnitOfWork) Do(fn func(repos *repository.Repositories) error) error {
+	return u.UnitOfWork.Do(func(repos *repository.Repositories) error {
+		repos.Inventory = &failingInventoryRepo{repos.Inventory}
+		return fn(repos)
+	})
+}
+
+type failingInventoryRepo struct {
+	repository.InventoryRepo
+}
+
+func (r *failingInventoryRepo) Update(*model.Inventory) error {
+	return errors.New("disk I/O error")
+}
+
+func (r *failingInventoryRepo) SetQuantity(uint, float64) error {
+	return errors.New("disk I/O error")
+}
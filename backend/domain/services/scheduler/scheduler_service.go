@@ -0,0 +1,369 @@
+package scheduler_service
+
+import (
+	"blizzflow/backend/domain/model"
+	repository "blizzflow/backend/domain/repositories"
+	"blizzflow/backend/events"
+	"blizzflow/backend/internal/cron"
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// historyLimit is how many runs are kept per job
+const historyLimit = 100
+
+// Custom errors
+var (
+	ErrJobNotFound       = fmt.Errorf("job not found")
+	ErrJobExists         = fmt.Errorf("job already registered")
+	ErrJobRunning        = fmt.Errorf("job is already running")
+	ErrInvalidJob        = fmt.Errorf("invalid job")
+	ErrDatabaseOperation = fmt.Errorf("database operation failed")
+)
+
+// Schedule computes when a job runs next.
+type Schedule interface {
+	Next(after time.Time) time.Time
+	String() string
+}
+
+type interval time.Duration
+
+// Every runs a job at a fixed interval.
+func Every(d time.Duration) Schedule {
+	return interval(d)
+}
+
+func (i interval) Next(after time.Time) time.Time {
+	return after.Add(time.Duration(i))
+}
+
+func (i interval) String() string {
+	return "every " + time.Duration(i).String()
+}
+
+// Cron runs a job on a five-field cron expression, see package cron.
+func Cron(expr string) (Schedule, error) {
+	return cron.Parse(expr)
+}
+
+// Job is a unit of background work.
+type Job struct {
+	Name     string
+	Schedule Schedule
+	// Jitter delays each run by a random amount up to this duration, so jobs
+	// sharing a schedule do not all hit the database at once
+	Jitter time.Duration
+	// Timeout cancels a run that takes too long; zero means no limit
+	Timeout time.Duration
+	Run     func(ctx context.Context) error
+}
+
+// JobStatus is the state of a job as shown in the UI.
+type JobStatus struct {
+	Name           string     `json:"name"`
+	Schedule       string     `json:"schedule"`
+	Running        bool       `json:"running"`
+	LastRunAt      *time.Time `json:"lastRunAt"`
+	NextRunAt      time.Time  `json:"nextRunAt"`
+	LastError      string     `json:"lastError"`
+	LastDurationMs int64      `json:"lastDurationMs"`
+}
+
+type entry struct {
+	job     Job
+	next    time.Time
+	running bool
+	state   model.ScheduledJob
+}
+
+// setNext updates the in-memory and persisted next run. The state gets its
+// own copy, as it is saved outside the lock.
+func (e *entry) setNext(next time.Time) {
+	e.next = next
+	e.state.NextRunAt = &next
+}
+
+type SchedulerService struct {
+	jobRepo    repository.JobRepo
+	dispatcher *events.Dispatcher
+	mu         sync.Mutex
+	jobs       map[string]*entry
+	wake       chan struct{}
+	ctx        context.Context
+	cancel     context.CancelFunc
+	wg         sync.WaitGroup
+	now        func() time.Time
+	jitter     func(max time.Duration) time.Duration
+}
+
+func NewSchedulerService(jobRepo repository.JobRepo, dispatcher *events.Dispatcher) *SchedulerService {
+	return &SchedulerService{
+		jobRepo:    jobRepo,
+		dispatcher: dispatcher,
+		jobs:       map[string]*entry{},
+		wake:       make(chan struct{}, 1),
+		now:        time.Now,
+		jitter: func(max time.Duration) time.Duration {
+			return time.Duration(rand.Int63n(int64(max)))
+		},
+	}
+}
+
+// Register adds a job. A next run persisted by an earlier session is kept,
+// so a job that was due while the application was closed runs once soon
+// after start.
+func (s *SchedulerService) Register(job Job) error {
+	if job.Name == "" || job.Schedule == nil || job.Run == nil {
+		return ErrInvalidJob
+	}
+
+	state, err := s.jobRepo.GetState(job.Name)
+	if err != nil {
+		return fmt.Errorf("failed to load job state: %w", ErrDatabaseOperation)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.jobs[job.Name]; ok {
+		return fmt.Errorf("%q: %w", job.Name, ErrJobExists)
+	}
+	e := &entry{job: job, state: model.ScheduledJob{Name: job.Name}}
+	if state != nil {
+		e.state = *state
+	}
+	if e.state.NextRunAt != nil {
+		e.next = *e.state.NextRunAt
+	} else {
+		e.setNext(s.nextRun(job, s.now()))
+		if err := s.jobRepo.SaveState(&e.state); err != nil {
+			return fmt.Errorf("failed to save job state: %w", ErrDatabaseOperation)
+		}
+	}
+	s.jobs[job.Name] = e
+	s.signal()
+	return nil
+}
+
+// Start runs due jobs until ctx is cancelled or Stop is called.
+func (s *SchedulerService) Start(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil {
+		return
+	}
+	s.ctx, s.cancel = context.WithCancel(ctx)
+
+	s.wg.Add(1)
+	go s.loop(s.ctx)
+}
+
+// Stop cancels running jobs through their context and waits for them to
+// return.
+func (s *SchedulerService) Stop() {
+	s.mu.Lock()
+	cancel := s.cancel
+	s.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+	}
+	s.wg.Wait()
+}
+
+// ListJobs returns the state of every job, ordered by name.
+func (s *SchedulerService) ListJobs() []JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make([]JobStatus, 0, len(s.jobs))
+	for _, e := range s.jobs {
+		statuses = append(statuses, JobStatus{
+			Name:           e.job.Name,
+			Schedule:       e.job.Schedule.String(),
+			Running:        e.running,
+			LastRunAt:      e.state.LastRunAt,
+			NextRunAt:      e.next,
+			LastError:      e.state.LastError,
+			LastDurationMs: e.state.LastDurationMs,
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// JobHistory returns the latest runs of a job, newest first.
+func (s *SchedulerService) JobHistory(name string, limit int) ([]model.JobRun, error) {
+	if limit <= 0 || limit > historyLimit {
+		limit = historyLimit
+	}
+	runs, err := s.jobRepo.ListRuns(name, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load job history: %w", ErrDatabaseOperation)
+	}
+	return runs, nil
+}
+
+// RunNow starts a job immediately without changing its schedule.
+func (s *SchedulerService) RunNow(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.jobs[name]
+	if !ok {
+		return fmt.Errorf("%q: %w", name, ErrJobNotFound)
+	}
+	if s.ctx == nil {
+		return fmt.Errorf("scheduler not started: %w", ErrInvalidJob)
+	}
+	return s.start(s.ctx, e)
+}
+
+func (s *SchedulerService) loop(ctx context.Context) {
+	defer s.wg.Done()
+	for {
+		timer := time.NewTimer(s.untilNext())
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-s.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+		s.runDue(ctx)
+	}
+}
+
+func (s *SchedulerService) untilNext() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	wait := time.Minute
+	now := s.now()
+	for _, e := range s.jobs {
+		if d := e.next.Sub(now); d < wait {
+			wait = d
+		}
+	}
+	return max(wait, 0)
+}
+
+func (s *SchedulerService) runDue(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for _, e := range s.jobs {
+		if e.next.After(now) {
+			continue
+		}
+		// Single flight: a run still going when the next one is due
+		// swallows it
+		e.setNext(s.nextRun(e.job, now))
+		if e.running {
+			log.Printf("Job %s still running, skipping a run", e.job.Name)
+			if err := s.jobRepo.SaveState(&e.state); err != nil {
+				log.Printf("Failed to save state of job %s: %v", e.job.Name, err)
+			}
+			continue
+		}
+		s.start(ctx, e)
+	}
+}
+
+// start launches a run of e. The caller holds s.mu.
+func (s *SchedulerService) start(ctx context.Context, e *entry) error {
+	if e.running {
+		return fmt.Errorf("%q: %w", e.job.Name, ErrJobRunning)
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	e.running = true
+
+	s.wg.Add(1)
+	go s.execute(ctx, e)
+	return nil
+}
+
+func (s *SchedulerService) execute(ctx context.Context, e *entry) {
+	defer s.wg.Done()
+
+	job := e.job
+	run := &model.JobRun{Job: job.Name, StartedAt: s.now(), Status: model.JobRunning}
+	if err := s.jobRepo.CreateRun(run); err != nil {
+		log.Printf("Failed to record run of job %s: %v", job.Name, err)
+	}
+
+	runCtx := ctx
+	if job.Timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, job.Timeout)
+		defer cancel()
+	}
+	err := safeRun(runCtx, job.Run)
+
+	finished := s.now()
+	run.FinishedAt = &finished
+	run.Status = model.JobSucceeded
+	if err != nil {
+		run.Status = model.JobFailed
+		run.Error = err.Error()
+		log.Printf("Job %s failed: %v", job.Name, err)
+	}
+	if run.ID != 0 {
+		if err := s.jobRepo.UpdateRun(run); err != nil {
+			log.Printf("Failed to record run of job %s: %v", job.Name, err)
+		}
+		s.jobRepo.PruneRuns(job.Name, historyLimit)
+	}
+
+	s.mu.Lock()
+	e.running = false
+	e.state.LastRunAt = &run.StartedAt
+	e.state.LastError = run.Error
+	e.state.LastDurationMs = finished.Sub(run.StartedAt).Milliseconds()
+	state := e.state
+	s.mu.Unlock()
+
+	if err := s.jobRepo.SaveState(&state); err != nil {
+		log.Printf("Failed to save state of job %s: %v", job.Name, err)
+	}
+	s.dispatcher.Emit(events.JobFinished, run)
+}
+
+func (s *SchedulerService) nextRun(job Job, after time.Time) time.Time {
+	next := job.Schedule.Next(after)
+	if next.IsZero() {
+		// A cron expression that never matches; check again in a year
+		next = after.AddDate(1, 0, 0)
+	}
+	if job.Jitter > 0 {
+		next = next.Add(s.jitter(job.Jitter))
+	}
+	return next
+}
+
+func (s *SchedulerService) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// safeRun turns a panicking job into a failed run instead of a crash.
+func safeRun(ctx context.Context, run func(ctx context.Context) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return run(ctx)
+}
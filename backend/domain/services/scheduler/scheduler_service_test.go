@@ -0,0 +1,194 @@
+package scheduler_service
+
+import (
+	"blizzflow/backend/domain/model"
+	repository "blizzflow/backend/domain/repositories"
+	"blizzflow/backend/events"
+	"blizzflow/backend/infrastructure/database"
+	"context"
+	"errors"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+	"gorm.io/gorm"
+)
+
+func TestSchedulerServiceSuite(t *testing.T) {
+	gomega.RegisterFailHandler(ginkgo.Fail)
+	ginkgo.RunSpecs(t, "Scheduler Service Test Suite")
+}
+
+const testDBPath = "test.db"
+
+var (
+	DB      *gorm.DB
+	jobRepo *repository.JobRepository
+)
+
+var _ = ginkgo.BeforeSuite(func() {
+	os.Remove(testDBPath)
+	store, err := database.Open(database.DefaultOptions(testDBPath))
+	gomega.Expect(err).To(gomega.BeNil())
+	DB = store.DB()
+	jobRepo = repository.NewJobRepository(DB)
+})
+
+var _ = ginkgo.AfterSuite(func() {
+	if DB != nil {
+		sqlDB, err := DB.DB()
+		if err == nil {
+			sqlDB.Close()
+		}
+	}
+	os.Remove(testDBPath)
+})
+
+var _ = ginkgo.Describe("Cron schedules", func() {
+	base := time.Date(2024, 3, 15, 10, 7, 30, 0, time.UTC) // a Friday
+
+	next := func(expr string) time.Time {
+		schedule, err := Cron(expr)
+		gomega.Expect(err).To(gomega.BeNil())
+		return schedule.Next(base)
+	}
+
+	ginkgo.It("should find the next matching minute", func() {
+		gomega.Expect(next("*/15 * * * *")).To(gomega.Equal(time.Date(2024, 3, 15, 10, 15, 0, 0, time.UTC)))
+		gomega.Expect(next("0 9-17 * * mon-fri")).To(gomega.Equal(time.Date(2024, 3, 15, 11, 0, 0, 0, time.UTC)))
+		gomega.Expect(next("30 2 * * *")).To(gomega.Equal(time.Date(2024, 3, 16, 2, 30, 0, 0, time.UTC)))
+		gomega.Expect(next("@weekly")).To(gomega.Equal(time.Date(2024, 3, 17, 0, 0, 0, 0, time.UTC)))
+		gomega.Expect(next("0 0 29 feb *")).To(gomega.Equal(time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)))
+	})
+
+	ginkgo.It("should match either day field when both are restricted", func() {
+		// The 1st of the month or any Sunday
+		gomega.Expect(next("0 0 1 * 7")).To(gomega.Equal(time.Date(2024, 3, 17, 0, 0, 0, 0, time.UTC)))
+	})
+
+	ginkgo.It("should reject invalid expressions", func() {
+		for _, expr := range []string{"", "* * * *", "60 * * * *", "* * * * mon-sun-x", "*/0 * * * *", "5-1 * * * *"} {
+			_, err := Cron(expr)
+			gomega.Expect(err).To(gomega.HaveOccurred(), expr)
+		}
+	})
+})
+
+var _ = ginkgo.Describe("Scheduler Service", func() {
+	var (
+		scheduler *SchedulerService
+		emitted   atomic.Int32
+	)
+
+	ginkgo.BeforeEach(func() {
+		DB.Exec("DELETE FROM scheduled_jobs")
+		DB.Exec("DELETE FROM job_runs")
+
+		emitted.Store(0)
+		dispatcher := events.NewDispatcher()
+		dispatcher.Bind(func(name string, data ...any) {
+			if name == events.JobFinished {
+				emitted.Add(1)
+			}
+		})
+		scheduler = NewSchedulerService(jobRepo, dispatcher)
+	})
+
+	ginkgo.AfterEach(func() {
+		scheduler.Stop()
+	})
+
+	ginkgo.It("should run interval jobs and record their history", func() {
+		var runs atomic.Int32
+		gomega.Expect(scheduler.Register(Job{
+			Name:     "tick",
+			Schedule: Every(20 * time.Millisecond),
+			Run: func(ctx context.Context) error {
+				if runs.Add(1) == 1 {
+					return errors.New("first run fails")
+				}
+				return nil
+			},
+		})).To(gomega.Succeed())
+		scheduler.Start(context.Background())
+
+		gomega.Eventually(runs.Load).Should(gomega.BeNumerically(">=", 3))
+		gomega.Eventually(emitted.Load).Should(gomega.BeNumerically(">=", 3))
+		scheduler.Stop()
+
+		history, err := scheduler.JobHistory("tick", 0)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(len(history)).To(gomega.BeNumerically(">=", 3))
+		oldest := history[len(history)-1]
+		gomega.Expect(oldest.Status).To(gomega.Equal(model.JobFailed))
+		gomega.Expect(oldest.Error).To(gomega.Equal("first run fails"))
+
+		jobs := scheduler.ListJobs()
+		gomega.Expect(jobs).To(gomega.HaveLen(1))
+		gomega.Expect(jobs[0].LastRunAt).NotTo(gomega.BeNil())
+		gomega.Expect(jobs[0].Schedule).To(gomega.Equal("every 20ms"))
+	})
+
+	ginkgo.It("should never run a job twice at the same time", func() {
+		var active, overlaps atomic.Int32
+		release := make(chan struct{})
+		gomega.Expect(scheduler.Register(Job{
+			Name:     "slow",
+			Schedule: Every(5 * time.Millisecond),
+			Run: func(ctx context.Context) error {
+				if active.Add(1) > 1 {
+					overlaps.Add(1)
+				}
+				defer active.Add(-1)
+				<-release
+				return nil
+			},
+		})).To(gomega.Succeed())
+		scheduler.Start(context.Background())
+
+		gomega.Eventually(active.Load).Should(gomega.Equal(int32(1)))
+		gomega.Expect(scheduler.RunNow("slow")).To(gomega.MatchError(ErrJobRunning))
+		time.Sleep(30 * time.Millisecond)
+		close(release)
+
+		gomega.Expect(overlaps.Load()).To(gomega.BeZero())
+	})
+
+	ginkgo.It("should cancel running jobs on stop", func() {
+		cancelled := make(chan struct{})
+		gomega.Expect(scheduler.Register(Job{
+			Name:     "long",
+			Schedule: Every(time.Hour),
+			Run: func(ctx context.Context) error {
+				<-ctx.Done()
+				close(cancelled)
+				return ctx.Err()
+			},
+		})).To(gomega.Succeed())
+		scheduler.Start(context.Background())
+		gomega.Expect(scheduler.RunNow("long")).To(gomega.Succeed())
+
+		scheduler.Stop()
+		gomega.Eventually(cancelled).Should(gomega.BeClosed())
+	})
+
+	ginkgo.It("should keep the persisted next run across restarts", func() {
+		job := Job{Name: "nightly", Schedule: Every(24 * time.Hour), Jitter: time.Minute, Run: func(ctx context.Context) error { return nil }}
+		gomega.Expect(scheduler.Register(job)).To(gomega.Succeed())
+		first := scheduler.ListJobs()[0].NextRunAt
+
+		restarted := NewSchedulerService(jobRepo, events.NewDispatcher())
+		gomega.Expect(restarted.Register(job)).To(gomega.Succeed())
+		gomega.Expect(restarted.ListJobs()[0].NextRunAt).To(gomega.BeTemporally("==", first))
+	})
+
+	ginkgo.It("should reject duplicate and incomplete jobs", func() {
+		job := Job{Name: "dup", Schedule: Every(time.Hour), Run: func(ctx context.Context) error { return nil }}
+		gomega.Expect(scheduler.Register(job)).To(gomega.Succeed())
+		gomega.Expect(scheduler.Register(job)).To(gomega.MatchError(ErrJobExists))
+		gomega.Expect(scheduler.Register(Job{Name: "empty"})).To(gomega.MatchError(ErrInvalidJob))
+	})
+})
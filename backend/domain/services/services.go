@@ -1,9 +1,22 @@
 package services
 
 import (
+	adjustment_service "blizzflow/backend/domain/services/adjustment"
+	archive_service "blizzflow/backend/domain/services/archive"
 	auth_service "blizzflow/backend/domain/services/auth"
+	backup_service "blizzflow/backend/domain/services/backup"
+	catalog_service "blizzflow/backend/domain/services/catalog"
+	company_service "blizzflow/backend/domain/services/company"
+	health_service "blizzflow/backend/domain/services/health"
+	inventory_service "blizzflow/backend/domain/services/inventory"
 	license_service "blizzflow/backend/domain/services/license"
+	location_service "blizzflow/backend/domain/services/location"
+	purchase_service "blizzflow/backend/domain/services/purchase"
+	reorder_service "blizzflow/backend/domain/services/reorder"
+	retention_service "blizzflow/backend/domain/services/retention"
+	scheduler_service "blizzflow/backend/domain/services/scheduler"
 	session_service "blizzflow/backend/domain/services/session"
+	stock_service "blizzflow/backend/domain/services/stock"
 	user_service "blizzflow/backend/domain/services/user"
 )
 
@@ -26,3 +39,68 @@ var NewUserService = user_service.NewUserService
 type LicenseService = license_service.LicenseService
 
 var NewLicenseService = license_service.NewLicenseService
+
+// Export BackupService
+type BackupService = backup_service.BackupService
+
+var NewBackupService = backup_service.NewBackupService
+
+// Export HealthService
+type HealthService = health_service.HealthService
+
+var NewHealthService = health_service.NewHealthService
+
+// Export CompanyService
+type CompanyService = company_service.CompanyService
+
+var NewCompanyService = company_service.NewCompanyService
+
+// Export ArchiveService
+type ArchiveService = archive_service.ArchiveService
+
+var NewArchiveService = archive_service.NewArchiveService
+
+// Export RetentionService
+type RetentionService = retention_service.RetentionService
+
+var NewRetentionService = retention_service.NewRetentionService
+
+// Export SchedulerService
+type SchedulerService = scheduler_service.SchedulerService
+
+var NewSchedulerService = scheduler_service.NewSchedulerService
+
+// Export InventoryService
+type InventoryService = inventory_service.InventoryService
+
+var NewInventoryService = inventory_service.NewInventoryService
+
+// Export StockService
+type StockService = stock_service.StockService
+
+var NewStockService = stock_service.NewStockService
+
+// Export AdjustmentService
+type AdjustmentService = adjustment_service.AdjustmentService
+
+var NewAdjustmentService = adjustment_service.NewAdjustmentService
+
+// Export CatalogService
+type CatalogService = catalog_service.CatalogService
+
+var NewCatalogService = catalog_service.NewCatalogService
+
+// Export ReorderService
+type ReorderService = reorder_service.ReorderService
+
+var NewReorderService = reorder_service.NewReorderService
+
+// Export PurchaseService
+type PurchaseService = purchase_service.PurchaseService
+
+var NewPurchaseService = purchase_service.NewPurchaseService
+
+// Export LocationService
+type LocationService = location_service.LocationService
+
+var NewLocationService = location_service.NewLocationService
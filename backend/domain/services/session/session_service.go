@@ -2,11 +2,9 @@ package session_service
 
 import (
 	"blizzflow/backend/domain/model"
-	"errors"
+	repository "blizzflow/backend/domain/repositories"
 	"fmt"
 	"time"
-
-	"gorm.io/gorm"
 )
 
 // Custom errors
@@ -17,11 +15,11 @@ var (
 )
 
 type SessionService struct {
-	db *gorm.DB
+	sessionRepo repository.SessionRepo
 }
 
-func NewSessionService(db *gorm.DB) *SessionService {
-	return &SessionService{db: db}
+func NewSessionService(sessionRepo repository.SessionRepo) *SessionService {
+	return &SessionService{sessionRepo: sessionRepo}
 }
 
 func (s *SessionService) CreateSession(userID uint) (*model.Session, error) {
@@ -30,7 +28,7 @@ func (s *SessionService) CreateSession(userID uint) (*model.Session, error) {
 		CreatedAt: time.Now(),
 	}
 
-	if err := s.db.Create(session).Error; err != nil {
+	if err := s.sessionRepo.CreateSession(session); err != nil {
 		return nil, fmt.Errorf("failed to create session: %w", ErrDatabaseOperation)
 	}
 	return session, nil
@@ -41,14 +39,14 @@ func (s *SessionService) GetSession(sessionID uint) (*model.Session, error) {
 		return nil, ErrInvalidSessionID
 	}
 
-	var session model.Session
-	if err := s.db.First(&session, sessionID).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, ErrSessionNotFound
-		}
+	session, err := s.sessionRepo.GetSession(sessionID)
+	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve session: %w", ErrDatabaseOperation)
 	}
-	return &session, nil
+	if session == nil {
+		return nil, ErrSessionNotFound
+	}
+	return session, nil
 }
 
 func (s *SessionService) DeleteSession(sessionID uint) error {
@@ -56,7 +54,7 @@ func (s *SessionService) DeleteSession(sessionID uint) error {
 		return ErrInvalidSessionID
 	}
 
-	if err := s.db.Delete(&model.Session{}, sessionID).Error; err != nil {
+	if err := s.sessionRepo.DeleteSession(sessionID); err != nil {
 		return fmt.Errorf("failed to delete session: %w", ErrDatabaseOperation)
 	}
 	return nil
@@ -67,12 +65,9 @@ func (s *SessionService) ValidateSession(sessionID uint) (bool, error) {
 		return false, ErrInvalidSessionID
 	}
 
-	var session model.Session
-	if err := s.db.First(&session, sessionID).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return false, nil
-		}
+	session, err := s.sessionRepo.GetSession(sessionID)
+	if err != nil {
 		return false, fmt.Errorf("failed to validate session: %w", ErrDatabaseOperation)
 	}
-	return true, nil
+	return session != nil, nil
 }
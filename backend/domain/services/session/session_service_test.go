@@ -1,6 +1,7 @@
 package session_service
 
 import (
+	repository "blizzflow/backend/domain/repositories"
 	"blizzflow/backend/infrastructure/database"
 	"os"
 	"testing"
@@ -24,9 +25,10 @@ var (
 
 var _ = ginkgo.BeforeSuite(func() {
 	os.Remove(testDBPath)
-	database.InitDB(testDBPath)
-	DB = database.DB
-	sessionService = NewSessionService(DB)
+	store, err := database.Open(database.DefaultOptions(testDBPath))
+	gomega.Expect(err).To(gomega.BeNil())
+	DB = store.DB()
+	sessionService = NewSessionService(repository.NewSessionRepository(DB))
 })
 
 var _ = ginkgo.AfterSuite(func() {
@@ -0,0 +1,305 @@
+package stock_service
+
+import (
+	"blizzflow/backend/domain/model"
+	repository "blizzflow/backend/domain/repositories"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CostingMethodKey is the setting that picks how stock leaving is valued.
+const CostingMethodKey = "stock.costing_method"
+
+// DefaultCostingMethod applies until a manager picks another.
+const DefaultCostingMethod = model.CostingAverage
+
+var costingMethods = map[string]bool{
+	model.CostingFIFO:     true,
+	model.CostingAverage:  true,
+	model.CostingStandard: true,
+}
+
+// ValuationReport is the stock held at a point in time, at one location or
+// all of them, and its value at cost.
+type ValuationReport struct {
+	AsOf       time.Time              `json:"asOf"`
+	LocationID *uint                  `json:"locationId"`
+	Method     string                 `json:"method"`
+	Items      []repository.Valuation `json:"items"`
+	Total      float64                `json:"total"`
+}
+
+// CostingMethod returns the configured costing method.
+func CostingMethod(repos *repository.Repositories) (string, error) {
+	method, err := repos.Settings.Get(CostingMethodKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to read setting: %w", ErrDatabaseOperation)
+	}
+	if !costingMethods[method] {
+		return DefaultCostingMethod, nil
+	}
+	return method, nil
+}
+
+// UnitCost returns what one base unit of an item costs under the configured
+// method: the oldest open layer for FIFO, the moving average or the standard
+// cost.
+func UnitCost(repos *repository.Repositories, item *model.Inventory) (float64, error) {
+	method, err := CostingMethod(repos)
+	if err != nil {
+		return 0, err
+	}
+	switch method {
+	case model.CostingStandard:
+		return item.StandardCost, nil
+	case model.CostingFIFO:
+		layers, err := repos.StockMovements.OpenLayers(item.ID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read cost layers: %w", ErrDatabaseOperation)
+		}
+		if len(layers) > 0 {
+			return layers[0].UnitCost, nil
+		}
+	}
+	return item.AverageCost, nil
+}
+
+// Revalue books the change in value when an item's standard cost moves to
+// cost. Only standard costing holds stock at its standard cost, so other
+// methods have nothing to revalue. The caller stores the new standard cost.
+func Revalue(repos *repository.Repositories, item *model.Inventory, cost float64) error {
+	if !(cost >= 0) || math.IsInf(cost, 0) {
+		return ErrInvalidCost
+	}
+	method, err := CostingMethod(repos)
+	if err != nil {
+		return err
+	}
+	if method != model.CostingStandard || cost == item.StandardCost {
+		return nil
+	}
+	onHand, err := repos.StockMovements.Sum(item.ID)
+	if err != nil {
+		return fmt.Errorf("failed to read stock ledger: %w", ErrDatabaseOperation)
+	}
+	change := roundCost(onHand * (cost - item.StandardCost))
+	if change == 0 {
+		return nil
+	}
+	return Record(repos, &model.StockMovement{
+		InventoryID: item.ID,
+		Type:        model.MovementRevaluation,
+		Cost:        change,
+		Reason:      fmt.Sprintf("standard cost %v -> %v", item.StandardCost, cost),
+	})
+}
+
+// value sets the cost of a movement and keeps the item's costs and cost
+// layers up to date. Inbound stock brings its own cost when the movement
+// has one, e.g. a purchase, and is otherwise taken in at the average cost.
+// Layers and the average are kept whatever the method, so switching methods
+// starts from real history. Transfers only move stock between locations:
+// both sides carry it at its unit cost and leave the costs alone. It
+// returns the layer to open once the movement is stored.
+func value(repos *repository.Repositories, item *model.Inventory, onHand float64, m *model.StockMovement) (*model.CostLayer, error) {
+	if m.Type == model.MovementRevaluation || m.Quantity == 0 {
+		return nil, nil
+	}
+	if m.Type == model.MovementTransfer {
+		unitCost, err := UnitCost(repos, item)
+		if err != nil {
+			return nil, err
+		}
+		m.Cost = roundCost(m.Quantity * unitCost)
+		return nil, nil
+	}
+	method, err := CostingMethod(repos)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.Quantity > 0 {
+		if err := cover(repos, item, onHand); err != nil {
+			return nil, err
+		}
+		unitCost := item.AverageCost
+		if unitCost == 0 {
+			unitCost = item.StandardCost
+		}
+		lastCost := item.LastCost
+		if m.Cost > 0 {
+			unitCost = m.Cost / m.Quantity
+			lastCost = unitCost
+		}
+		held := max(onHand, 0)
+		average := (held*item.AverageCost + m.Quantity*unitCost) / (held + m.Quantity)
+		if err := repos.Inventory.SetCosts(item.ID, lastCost, average); err != nil {
+			return nil, fmt.Errorf("failed to update costs: %w", ErrDatabaseOperation)
+		}
+
+		m.Cost = roundCost(m.Quantity * unitCost)
+		if method == model.CostingStandard {
+			m.Cost = roundCost(m.Quantity * item.StandardCost)
+		}
+		return &model.CostLayer{
+			InventoryID: item.ID,
+			Quantity:    m.Quantity,
+			Remaining:   m.Quantity,
+			UnitCost:    unitCost,
+		}, nil
+	}
+
+	fifo, err := consume(repos, item, onHand, -m.Quantity)
+	if err != nil {
+		return nil, err
+	}
+	switch method {
+	case model.CostingFIFO:
+		m.Cost = -roundCost(fifo)
+	case model.CostingStandard:
+		m.Cost = -roundCost(-m.Quantity * item.StandardCost)
+	default:
+		m.Cost = -roundCost(-m.Quantity * item.AverageCost)
+	}
+	return nil, nil
+}
+
+// consume takes quantity out of an item's oldest layers and returns its
+// cost. Stock no layer covers came in before costs were kept and goes first,
+// at the average cost.
+func consume(repos *repository.Repositories, item *model.Inventory, onHand, quantity float64) (float64, error) {
+	layers, err := repos.StockMovements.OpenLayers(item.ID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read cost layers: %w", ErrDatabaseOperation)
+	}
+	legacy := min(uncovered(layers, onHand), quantity)
+	cost := legacy * item.AverageCost
+	quantity = model.RoundQuantity(quantity-legacy, model.MaxPrecision)
+
+	for _, layer := range layers {
+		if quantity <= 0 {
+			break
+		}
+		taken := min(quantity, layer.Remaining)
+		cost += taken * layer.UnitCost
+		quantity = model.RoundQuantity(quantity-taken, model.MaxPrecision)
+		remaining := model.RoundQuantity(layer.Remaining-taken, model.MaxPrecision)
+		if err := repos.StockMovements.ConsumeLayer(layer.ID, remaining); err != nil {
+			return 0, fmt.Errorf("failed to update cost layer: %w", ErrDatabaseOperation)
+		}
+	}
+	return cost, nil
+}
+
+// cover opens a layer at the average cost for stock held before costs were
+// kept, so it stays older than the stock coming in and keeps its cost.
+func cover(repos *repository.Repositories, item *model.Inventory, onHand float64) error {
+	layers, err := repos.StockMovements.OpenLayers(item.ID)
+	if err != nil {
+		return fmt.Errorf("failed to read cost layers: %w", ErrDatabaseOperation)
+	}
+	legacy := uncovered(layers, onHand)
+	if legacy == 0 {
+		return nil
+	}
+	err = repos.StockMovements.CreateLayer(&model.CostLayer{
+		InventoryID: item.ID,
+		Quantity:    legacy,
+		Remaining:   legacy,
+		UnitCost:    item.AverageCost,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to open cost layer: %w", ErrDatabaseOperation)
+	}
+	return nil
+}
+
+// uncovered returns how much of onHand no open layer accounts for.
+func uncovered(layers []model.CostLayer, onHand float64) float64 {
+	for _, layer := range layers {
+		onHand -= layer.Remaining
+	}
+	return max(model.RoundQuantity(onHand, model.MaxPrecision), 0)
+}
+
+func roundCost(cost float64) float64 {
+	return math.Round(cost*100) / 100
+}
+
+// GetCostingMethod returns how stock leaving is valued.
+func (s *StockService) GetCostingMethod() (string, error) {
+	var method string
+	err := s.uow.Do(func(repos *repository.Repositories) error {
+		var err error
+		method, err = CostingMethod(repos)
+		return err
+	})
+	return method, err
+}
+
+// SetCostingMethod switches the costing method for movements from now on;
+// recorded movements keep their value. Only a manager may switch.
+func (s *StockService) SetCostingMethod(sessionID uint, method string) error {
+	if !costingMethods[method] {
+		return ErrInvalidCostingMethod
+	}
+	userID, err := s.userID(sessionID)
+	if err != nil {
+		return err
+	}
+	return s.uow.Do(func(repos *repository.Repositories) error {
+		user, err := repos.Users.GetUserByID(userID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrSessionNotFound
+		} else if err != nil {
+			return fmt.Errorf("failed to fetch user: %w", ErrDatabaseOperation)
+		}
+		if !user.IsManager() {
+			return ErrNotManager
+		}
+		if err := repos.Settings.Set(CostingMethodKey, method); err != nil {
+			return fmt.Errorf("failed to save setting: %w", ErrDatabaseOperation)
+		}
+		return nil
+	})
+}
+
+// Valuation values the stock held at asOf from the ledger, so a past date
+// reports what was held then at what it cost then. A zero asOf is now. A
+// location narrows the report to the stock held there; nil reports the
+// total.
+func (s *StockService) Valuation(asOf time.Time, locationID *uint) (*ValuationReport, error) {
+	if asOf.IsZero() {
+		asOf = time.Now()
+	}
+	report := &ValuationReport{AsOf: asOf, LocationID: locationID}
+	err := s.uow.Do(func(repos *repository.Repositories) error {
+		var err error
+		if report.Method, err = CostingMethod(repos); err != nil {
+			return err
+		}
+		if report.Items, err = repos.StockMovements.Valuation(asOf, locationID); err != nil {
+			return fmt.Errorf("failed to value stock: %w", ErrDatabaseOperation)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if report.Items == nil {
+		report.Items = []repository.Valuation{}
+	}
+	for i := range report.Items {
+		item := &report.Items[i]
+		item.Quantity = model.RoundQuantity(item.Quantity, model.MaxPrecision)
+		item.Value = roundCost(item.Value)
+		report.Total += item.Value
+	}
+	report.Total = roundCost(report.Total)
+	return report, nil
+}
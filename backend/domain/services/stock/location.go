@@ -0,0 +1,136 @@
+package stock_service
+
+import (
+	"blizzflow/backend/domain/model"
+	repository "blizzflow/backend/domain/repositories"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"gorm.io/gorm"
+)
+
+// CurrentLocationKey is the setting naming the location this till works
+// at. Sales, receipts and counts that name no location happen there.
+const CurrentLocationKey = "stock.location"
+
+// CurrentLocation returns the location movements that name none happen at:
+// the configured one while it is active, and the oldest active location
+// otherwise.
+func CurrentLocation(repos *repository.Repositories) (*model.Location, error) {
+	value, err := repos.Settings.Get(CurrentLocationKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read setting: %w", ErrDatabaseOperation)
+	}
+	if id, err := strconv.ParseUint(value, 10, 0); err == nil {
+		location, err := repos.Locations.GetByID(uint(id))
+		if err == nil && !location.Transit && location.ArchivedAt == nil {
+			return location, nil
+		} else if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("failed to fetch location: %w", ErrDatabaseOperation)
+		}
+	}
+	location, err := repos.Locations.First()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch location: %w", ErrDatabaseOperation)
+	}
+	if location == nil {
+		return nil, ErrLocationNotFound
+	}
+	return location, nil
+}
+
+// Locate returns the active location with the given ID to sell or receive
+// at, or the current location for nil.
+func Locate(repos *repository.Repositories, id *uint) (*model.Location, error) {
+	if id == nil {
+		return CurrentLocation(repos)
+	}
+	location, err := repos.Locations.GetByID(*id)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && location.Transit) {
+		return nil, ErrLocationNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to fetch location: %w", ErrDatabaseOperation)
+	}
+	if location.ArchivedAt != nil {
+		return nil, ErrLocationArchived
+	}
+	return location, nil
+}
+
+// locate fills in the location of a movement that names none and checks
+// that goods only come into active locations.
+func locate(repos *repository.Repositories, m *model.StockMovement) error {
+	if m.LocationID == 0 {
+		location, err := CurrentLocation(repos)
+		if err != nil {
+			return err
+		}
+		m.LocationID = location.ID
+		return nil
+	}
+	location, err := repos.Locations.GetByID(m.LocationID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrLocationNotFound
+	} else if err != nil {
+		return fmt.Errorf("failed to fetch location: %w", ErrDatabaseOperation)
+	}
+	if location.ArchivedAt != nil && m.Quantity > 0 {
+		return ErrLocationArchived
+	}
+	return nil
+}
+
+// Move records out, a transfer of goods leaving its location, and the
+// movement that brings the same goods into location to. Picks choose the
+// lots the goods leave from and serials the units that move. Lots keep
+// their numbers and expiry dates at the destination. It returns the
+// movement into to.
+func Move(repos *repository.Repositories, out *model.StockMovement, to uint, picks []LotPick, serials []string) (*model.StockMovement, error) {
+	if out.Type != model.MovementTransfer || !(out.Quantity < 0) || out.LocationID == 0 || out.LocationID == to {
+		return nil, ErrInvalidMovement
+	}
+	in := &model.StockMovement{
+		InventoryID: out.InventoryID,
+		LocationID:  to,
+		Type:        model.MovementTransfer,
+		Quantity:    -out.Quantity,
+		Reference:   out.Reference,
+		Reason:      out.Reason,
+		UserID:      out.UserID,
+	}
+	if len(serials) > 0 {
+		if err := RecordSerials(repos, out, serials, ""); err != nil {
+			return nil, err
+		}
+		return in, RecordSerials(repos, in, serials, "")
+	}
+	if err := RecordLots(repos, out, picks); err != nil {
+		return nil, err
+	}
+
+	allocations, err := repos.Lots.ListAllocations([]uint{out.ID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list lot allocations: %w", ErrDatabaseOperation)
+	}
+	if len(allocations) == 0 {
+		return in, Record(repos, in)
+	}
+	item, err := repos.Inventory.GetByID(out.InventoryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch inventory: %w", ErrDatabaseOperation)
+	}
+	into := make([]LotPick, 0, len(allocations))
+	for _, allocation := range allocations {
+		lot, err := repos.Lots.GetByID(allocation.LotID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch lot: %w", ErrDatabaseOperation)
+		}
+		opened, err := OpenLot(repos, item, to, LotInput{Number: lot.Number, ExpiresAt: lot.ExpiresAt})
+		if err != nil {
+			return nil, err
+		}
+		into = append(into, LotPick{LotID: opened.ID, Quantity: -allocation.Quantity})
+	}
+	return in, RecordLots(repos, in, into)
+}
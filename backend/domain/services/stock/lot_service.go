@@ -0,0 +1,292 @@
+package stock_service
+
+import (
+	"blizzflow/backend/domain/model"
+	repository "blizzflow/backend/domain/repositories"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const maxLotNumberLength = 50
+
+// LotInput names the lot goods arrive in. ExpiresAt is the last day the lot
+// may be sold; only its date counts. Nil is for goods that keep.
+type LotInput struct {
+	Number    string     `json:"number"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+// LotPick takes part of a movement, in base units, from or into a lot.
+type LotPick struct {
+	LotID    uint    `json:"lotId"`
+	Quantity float64 `json:"quantity"`
+}
+
+// ExpiringLot is a lot with stock left that is expired or about to.
+type ExpiringLot struct {
+	model.Lot
+	Name     string `json:"name"`
+	SKU      string `json:"sku"`
+	Unit     string `json:"unit"`
+	DaysLeft int    `json:"daysLeft"`
+	Expired  bool   `json:"expired"`
+}
+
+// Expired reports whether a lot is past its expiry date on the day of now.
+func Expired(lot *model.Lot, now time.Time) bool {
+	return lot.ExpiresAt != nil && today(now).After(*lot.ExpiresAt)
+}
+
+// OpenLot returns the item's lot at a location with the given number,
+// creating it on first receipt. A lot keeps the expiry date it was first
+// received with.
+func OpenLot(repos *repository.Repositories, item *model.Inventory, locationID uint, input LotInput) (*model.Lot, error) {
+	if !item.TrackLots {
+		return nil, ErrLotsNotTracked
+	}
+	number := strings.TrimSpace(input.Number)
+	if number == "" || len(number) > maxLotNumberLength {
+		return nil, ErrInvalidLot
+	}
+	var expires *time.Time
+	if input.ExpiresAt != nil {
+		date := today(*input.ExpiresAt)
+		expires = &date
+	}
+
+	lot, err := repos.Lots.FindByNumber(item.ID, locationID, number)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch lot: %w", ErrDatabaseOperation)
+	}
+	if lot != nil {
+		if (lot.ExpiresAt == nil) != (expires == nil) || (expires != nil && !lot.ExpiresAt.Equal(*expires)) {
+			return nil, fmt.Errorf("lot %s expires on another date: %w", lot.Number, ErrInvalidLot)
+		}
+		return lot, nil
+	}
+	lot = &model.Lot{InventoryID: item.ID, LocationID: locationID, Number: number, ExpiresAt: expires}
+	if err := repos.Lots.Create(lot); err != nil {
+		return nil, fmt.Errorf("failed to create lot: %w", ErrDatabaseOperation)
+	}
+	return lot, nil
+}
+
+// allocate spreads a movement of a lot-tracked item over its lots at the
+// movement's location, which holds onHand. Goods coming in go into the
+// picked lots; receipts must name lots for all of them, while anything
+// else may leave stock outside lots. Goods going out come from the picked
+// lots and then first-expiry-first-out, lots without an expiry date and
+// stock outside lots last. Sales never touch expired lots.
+func allocate(repos *repository.Repositories, item *model.Inventory, onHand float64, m *model.StockMovement, picks []LotPick) ([]model.LotAllocation, error) {
+	if !item.TrackLots {
+		if len(picks) > 0 {
+			return nil, ErrLotsNotTracked
+		}
+		return nil, nil
+	}
+	if m.Quantity == 0 {
+		return nil, nil
+	}
+
+	now := time.Now()
+	sign := math.Copysign(1, m.Quantity)
+	var allocations []model.LotAllocation
+	taken := make(map[uint]float64)
+	var picked float64
+	for _, pick := range picks {
+		if !(pick.Quantity > 0) || math.IsInf(pick.Quantity, 0) {
+			return nil, ErrInvalidQuantity
+		}
+		lot, err := repos.Lots.GetByID(pick.LotID)
+		if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && (lot.InventoryID != item.ID || lot.LocationID != m.LocationID)) {
+			return nil, ErrLotNotFound
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to fetch lot: %w", ErrDatabaseOperation)
+		}
+		if sign < 0 {
+			if m.Type == model.MovementSale && Expired(lot, now) {
+				return nil, fmt.Errorf("lot %s: %w", lot.Number, ErrLotExpired)
+			}
+			if taken[lot.ID]+pick.Quantity > lot.Quantity+1e-9 {
+				return nil, fmt.Errorf("lot %s holds %v: %w", lot.Number, lot.Quantity, ErrInsufficientStock)
+			}
+		}
+		taken[lot.ID] += pick.Quantity
+		picked += pick.Quantity
+		allocations = append(allocations, model.LotAllocation{LotID: lot.ID, Quantity: sign * pick.Quantity})
+	}
+
+	need := math.Abs(m.Quantity) - picked
+	if need < -1e-9 {
+		return nil, ErrInvalidQuantity
+	}
+	if sign > 0 {
+		if m.Type == model.MovementReceipt && need > 1e-9 {
+			return nil, ErrLotRequired
+		}
+		return allocations, nil
+	}
+
+	lots, err := repos.Lots.ListByInventory(item.ID, &m.LocationID, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list lots: %w", ErrDatabaseOperation)
+	}
+	var inLots float64
+	for i := range lots {
+		lot := &lots[i]
+		inLots += lot.Quantity
+		available := lot.Quantity - taken[lot.ID]
+		if need <= 1e-9 || available <= 0 || (m.Type == model.MovementSale && Expired(lot, now)) {
+			continue
+		}
+		quantity := math.Min(available, need)
+		allocations = append(allocations, model.LotAllocation{LotID: lot.ID, Quantity: -quantity})
+		need -= quantity
+	}
+	// What no lot covers comes from stock held outside lots, which Record
+	// already checked is there unless expired lots were passed over
+	if need > math.Max(onHand-inLots, 0)+1e-9 {
+		return nil, ErrLotExpired
+	}
+	return allocations, nil
+}
+
+// applyAllocations stores the allocations of a recorded movement and moves
+// the lots' quantities with them.
+func applyAllocations(repos *repository.Repositories, unit *model.Unit, m *model.StockMovement, allocations []model.LotAllocation) error {
+	for i := range allocations {
+		allocation := &allocations[i]
+		allocation.MovementID = m.ID
+		allocation.Quantity = unit.Round(allocation.Quantity)
+		if err := repos.Lots.CreateAllocation(allocation); err != nil {
+			return fmt.Errorf("failed to allocate lot: %w", ErrDatabaseOperation)
+		}
+		lot, err := repos.Lots.GetByID(allocation.LotID)
+		if err != nil {
+			return fmt.Errorf("failed to fetch lot: %w", ErrDatabaseOperation)
+		}
+		if err := repos.Lots.SetQuantity(lot.ID, unit.Round(lot.Quantity+allocation.Quantity)); err != nil {
+			return fmt.Errorf("failed to update lot: %w", ErrDatabaseOperation)
+		}
+	}
+	return nil
+}
+
+// ReceiveLot is ReceiveStock for a lot-tracked item: the goods go into the
+// named lot at the current location, which is opened on its first
+// receipt.
+func (s *StockService) ReceiveLot(sessionID, inventoryID uint, quantity float64, unitID *uint, cost float64, lot LotInput, reference, reason string) (*model.StockMovement, error) {
+	if !(quantity > 0) {
+		return nil, ErrInvalidQuantity
+	}
+	if !(cost >= 0) || math.IsInf(cost, 0) {
+		return nil, ErrInvalidCost
+	}
+	userID, err := s.userID(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	movement := &model.StockMovement{
+		InventoryID: inventoryID,
+		Type:        model.MovementReceipt,
+		Cost:        quantity * cost,
+		Reference:   strings.TrimSpace(reference),
+		Reason:      strings.TrimSpace(reason),
+		UserID:      &userID,
+	}
+	err = s.uow.Do(func(repos *repository.Repositories) error {
+		item, err := repos.Inventory.GetByID(inventoryID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInventoryNotFound
+		} else if err != nil {
+			return fmt.Errorf("failed to fetch inventory: %w", ErrDatabaseOperation)
+		}
+		if movement.Quantity, err = Convert(repos, item, quantity, unitID); err != nil {
+			return err
+		}
+		location, err := CurrentLocation(repos)
+		if err != nil {
+			return err
+		}
+		movement.LocationID = location.ID
+		opened, err := OpenLot(repos, item, location.ID, lot)
+		if err != nil {
+			return err
+		}
+		return RecordLots(repos, movement, []LotPick{{LotID: opened.ID, Quantity: movement.Quantity}})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return movement, nil
+}
+
+// ListLots returns an item's lots at every location, first to expire
+// first, with the empty ones only when asked for.
+func (s *StockService) ListLots(inventoryID uint, includeEmpty bool) ([]model.Lot, error) {
+	var lots []model.Lot
+	err := s.uow.Do(func(repos *repository.Repositories) error {
+		var err error
+		if lots, err = repos.Lots.ListByInventory(inventoryID, nil, includeEmpty); err != nil {
+			return fmt.Errorf("failed to list lots: %w", ErrDatabaseOperation)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return lots, nil
+}
+
+// ExpiringLots returns the lots with stock left that expire within the
+// next days days, today included, along with those already expired.
+func (s *StockService) ExpiringLots(days int) ([]ExpiringLot, error) {
+	if days < 0 {
+		return nil, ErrInvalidLot
+	}
+	now := time.Now()
+	start := today(now)
+
+	result := []ExpiringLot{}
+	err := s.uow.Do(func(repos *repository.Repositories) error {
+		lots, err := repos.Lots.ListExpiring(start.AddDate(0, 0, days+1))
+		if err != nil {
+			return fmt.Errorf("failed to list lots: %w", ErrDatabaseOperation)
+		}
+		items := make(map[uint]*model.Inventory)
+		for _, lot := range lots {
+			item, ok := items[lot.InventoryID]
+			if !ok {
+				if item, err = repos.Inventory.GetByID(lot.InventoryID); err != nil {
+					return fmt.Errorf("failed to fetch inventory: %w", ErrDatabaseOperation)
+				}
+				items[lot.InventoryID] = item
+			}
+			result = append(result, ExpiringLot{
+				Lot:      lot,
+				Name:     item.Name,
+				SKU:      item.SKU,
+				Unit:     item.Unit,
+				DaysLeft: int(math.Round(lot.ExpiresAt.Sub(start).Hours() / 24)),
+				Expired:  Expired(&lot, now),
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// today returns the date of t as midnight UTC, the way expiry dates are
+// kept.
+func today(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,272 @@
+package stock_service
+
+import (
+	"blizzflow/backend/domain/model"
+	repository "blizzflow/backend/domain/repositories"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+const (
+	maxSerialLength = 64
+	maxPartyLength  = 200
+)
+
+// serialMove is the units a movement of a serialized item moves and who
+// they came from or went to.
+type serialMove struct {
+	serials []string
+	party   string
+}
+
+// SerialHistory is a unit of a serialized item with everything that
+// happened to it, oldest first.
+type SerialHistory struct {
+	model.SerialNumber
+	Name string `json:"name"`
+	SKU  string `json:"sku"`
+	// UnderWarranty reports whether a sold unit's warranty runs today
+	UnderWarranty bool                `json:"underWarranty"`
+	Events        []model.SerialEvent `json:"events"`
+}
+
+// WarrantyEnd returns the last day a unit of item sold at soldAt is under
+// warranty, or nil for items sold without one.
+func WarrantyEnd(item *model.Inventory, soldAt time.Time) *time.Time {
+	if item.WarrantyMonths <= 0 {
+		return nil
+	}
+	end := today(soldAt).AddDate(0, item.WarrantyMonths, 0)
+	return &end
+}
+
+// serialize checks the units a movement of a serialized item names: one
+// serial for every unit, each unknown or written off for goods coming in,
+// sold for a return and in stock at the movement's location for goods
+// going out. It returns them as they are before the movement, new ones
+// without an ID.
+func serialize(repos *repository.Repositories, item *model.Inventory, m *model.StockMovement, move *serialMove) ([]model.SerialNumber, error) {
+	var serials []string
+	if move != nil {
+		serials = move.serials
+		if len(move.party) > maxPartyLength {
+			return nil, ErrInvalidSerial
+		}
+	}
+	if !item.TrackSerials {
+		if len(serials) > 0 {
+			return nil, ErrSerialsNotTracked
+		}
+		return nil, nil
+	}
+	if m.Quantity == 0 {
+		if len(serials) > 0 {
+			return nil, ErrInvalidQuantity
+		}
+		return nil, nil
+	}
+	if len(serials) == 0 {
+		return nil, ErrSerialRequired
+	}
+	if math.Abs(m.Quantity) != float64(len(serials)) {
+		return nil, fmt.Errorf("%d serial numbers for %v units: %w", len(serials), math.Abs(m.Quantity), ErrInvalidQuantity)
+	}
+
+	seen := make(map[string]bool, len(serials))
+	units := make([]model.SerialNumber, 0, len(serials))
+	for _, serial := range serials {
+		serial = strings.TrimSpace(serial)
+		key := strings.ToUpper(serial)
+		if serial == "" || len(serial) > maxSerialLength || seen[key] {
+			return nil, ErrInvalidSerial
+		}
+		seen[key] = true
+
+		unit, err := repos.Serials.Find(item.ID, serial)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch serial number: %w", ErrDatabaseOperation)
+		}
+		switch {
+		case m.Type == model.MovementReturn:
+			if unit == nil || unit.Status != model.SerialSold {
+				return nil, fmt.Errorf("serial %s: %w", serial, ErrSerialNotSold)
+			}
+		case m.Quantity > 0:
+			if unit == nil {
+				unit = &model.SerialNumber{InventoryID: item.ID, Serial: serial}
+			} else if unit.Status != model.SerialRemoved {
+				return nil, fmt.Errorf("serial %s: %w", serial, ErrDuplicateSerial)
+			}
+		case unit == nil:
+			return nil, fmt.Errorf("serial %s: %w", serial, ErrSerialNotFound)
+		case unit.Status != model.SerialInStock || unit.LocationID == nil || *unit.LocationID != m.LocationID:
+			return nil, fmt.Errorf("serial %s: %w", serial, ErrSerialNotInStock)
+		}
+		units = append(units, *unit)
+	}
+	return units, nil
+}
+
+// applySerials moves the units of a recorded movement and notes the
+// movement in their history. A sale starts a unit's warranty; a return
+// ends it.
+func applySerials(repos *repository.Repositories, item *model.Inventory, m *model.StockMovement, units []model.SerialNumber, move *serialMove) error {
+	for i := range units {
+		unit := &units[i]
+		switch {
+		case m.Quantity > 0:
+			location := m.LocationID
+			unit.Status = model.SerialInStock
+			unit.LocationID = &location
+			unit.SoldAt = nil
+			unit.WarrantyEndsAt = nil
+		case m.Type == model.MovementSale:
+			soldAt := m.CreatedAt
+			unit.Status = model.SerialSold
+			unit.LocationID = nil
+			unit.SoldAt = &soldAt
+			unit.WarrantyEndsAt = WarrantyEnd(item, soldAt)
+		default:
+			unit.Status = model.SerialRemoved
+			unit.LocationID = nil
+		}
+
+		save := repos.Serials.Update
+		if unit.ID == 0 {
+			save = repos.Serials.Create
+		}
+		if err := save(unit); err != nil {
+			return fmt.Errorf("failed to save serial number: %w", ErrDatabaseOperation)
+		}
+		event := &model.SerialEvent{
+			SerialID:   unit.ID,
+			MovementID: m.ID,
+			Type:       m.Type,
+			Party:      move.party,
+			Reference:  m.Reference,
+		}
+		if err := repos.Serials.CreateEvent(event); err != nil {
+			return fmt.Errorf("failed to record serial history: %w", ErrDatabaseOperation)
+		}
+	}
+	return nil
+}
+
+// ReceiveSerials is ReceiveStock for a serialized item: one unit comes in
+// at the current location for each serial, at cost apiece, from the given
+// supplier.
+func (s *StockService) ReceiveSerials(sessionID, inventoryID uint, serials []string, cost float64, supplier, reference, reason string) (*model.StockMovement, error) {
+	if len(serials) == 0 {
+		return nil, ErrSerialRequired
+	}
+	if !(cost >= 0) || math.IsInf(cost, 0) {
+		return nil, ErrInvalidCost
+	}
+	quantity := float64(len(serials))
+	return s.recordSerials(sessionID, serials, supplier, &model.StockMovement{
+		InventoryID: inventoryID,
+		Type:        model.MovementReceipt,
+		Quantity:    quantity,
+		Cost:        quantity * cost,
+		Reference:   strings.TrimSpace(reference),
+		Reason:      strings.TrimSpace(reason),
+	})
+}
+
+// ReturnSerial is ReturnStock for a serialized item: the sold unit comes
+// back into stock and its warranty ends.
+func (s *StockService) ReturnSerial(sessionID, inventoryID uint, serial, customer, reference, reason string) (*model.StockMovement, error) {
+	return s.recordSerials(sessionID, []string{serial}, customer, &model.StockMovement{
+		InventoryID: inventoryID,
+		Type:        model.MovementReturn,
+		Quantity:    1,
+		Reference:   strings.TrimSpace(reference),
+		Reason:      strings.TrimSpace(reason),
+	})
+}
+
+// ListSerials returns an item's units in the given status, or all of them
+// for an empty one.
+func (s *StockService) ListSerials(inventoryID uint, status string) ([]model.SerialNumber, error) {
+	switch status {
+	case "", model.SerialInStock, model.SerialSold, model.SerialRemoved:
+	default:
+		return nil, ErrInvalidSerial
+	}
+	var serials []model.SerialNumber
+	err := s.uow.Do(func(repos *repository.Repositories) error {
+		var err error
+		if serials, err = repos.Serials.ListByInventory(inventoryID, status); err != nil {
+			return fmt.Errorf("failed to list serial numbers: %w", ErrDatabaseOperation)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return serials, nil
+}
+
+// SerialHistory looks a serial number up across all items, ignoring case,
+// and returns each unit that carries it with where it came from, who it
+// was sold to, what came back and whether it is still under warranty.
+func (s *StockService) SerialHistory(serial string) ([]SerialHistory, error) {
+	serial = strings.TrimSpace(serial)
+	if serial == "" || len(serial) > maxSerialLength {
+		return nil, ErrInvalidSerial
+	}
+	now := time.Now()
+
+	var result []SerialHistory
+	err := s.uow.Do(func(repos *repository.Repositories) error {
+		units, err := repos.Serials.FindAll(serial)
+		if err != nil {
+			return fmt.Errorf("failed to look up serial number: %w", ErrDatabaseOperation)
+		}
+		if len(units) == 0 {
+			return ErrSerialNotFound
+		}
+		for _, unit := range units {
+			item, err := repos.Inventory.GetByID(unit.InventoryID)
+			if err != nil {
+				return fmt.Errorf("failed to fetch inventory: %w", ErrDatabaseOperation)
+			}
+			events, err := repos.Serials.ListEvents(unit.ID)
+			if err != nil {
+				return fmt.Errorf("failed to list serial history: %w", ErrDatabaseOperation)
+			}
+			result = append(result, SerialHistory{
+				SerialNumber:  unit,
+				Name:          item.Name,
+				SKU:           item.SKU,
+				UnderWarranty: unit.WarrantyEndsAt != nil && !today(now).After(*unit.WarrantyEndsAt),
+				Events:        events,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// recordSerials books movement, whose quantity is in base units, for the
+// named units.
+func (s *StockService) recordSerials(sessionID uint, serials []string, party string, movement *model.StockMovement) (*model.StockMovement, error) {
+	userID, err := s.userID(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	movement.UserID = &userID
+
+	err = s.uow.Do(func(repos *repository.Repositories) error {
+		return RecordSerials(repos, movement, serials, party)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return movement, nil
+}
@@ -0,0 +1,442 @@
+package stock_service
+
+import (
+	"blizzflow/backend/domain/model"
+	repository "blizzflow/backend/domain/repositories"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 500
+)
+
+// Custom errors
+var (
+	ErrInvalidMovement      = fmt.Errorf("invalid stock movement")
+	ErrInvalidQuantity      = fmt.Errorf("invalid quantity")
+	ErrInsufficientStock    = fmt.Errorf("insufficient stock")
+	ErrInventoryNotFound    = fmt.Errorf("inventory not found")
+	ErrVariantRequired      = fmt.Errorf("item has variants; pick one")
+	ErrUnitNotFound         = fmt.Errorf("unit not found")
+	ErrInvalidCost          = fmt.Errorf("invalid cost")
+	ErrInvalidCostingMethod = fmt.Errorf("invalid costing method")
+	ErrNotManager           = fmt.Errorf("only a manager can do this")
+	ErrLotRequired          = fmt.Errorf("lot-tracked items are received into a lot")
+	ErrLotNotFound          = fmt.Errorf("lot not found")
+	ErrLotExpired           = fmt.Errorf("lot has expired")
+	ErrInvalidLot           = fmt.Errorf("invalid lot")
+	ErrLotsNotTracked       = fmt.Errorf("item is not tracked in lots")
+	ErrSerialRequired       = fmt.Errorf("serialized items are booked by serial number")
+	ErrSerialNotFound       = fmt.Errorf("serial number not found")
+	ErrSerialNotInStock     = fmt.Errorf("serial number is not in stock")
+	ErrSerialNotSold        = fmt.Errorf("serial number was not sold")
+	ErrDuplicateSerial      = fmt.Errorf("serial number already in use")
+	ErrInvalidSerial        = fmt.Errorf("invalid serial number")
+	ErrSerialsNotTracked    = fmt.Errorf("item is not serialized")
+	ErrLocationNotFound     = fmt.Errorf("location not found")
+	ErrLocationArchived     = fmt.Errorf("location is archived")
+	ErrSessionNotFound      = fmt.Errorf("session not found")
+	ErrDatabaseOperation    = fmt.Errorf("database operation failed")
+)
+
+var movementTypes = map[string]bool{
+	model.MovementOpening:     true,
+	model.MovementSale:        true,
+	model.MovementReturn:      true,
+	model.MovementReceipt:     true,
+	model.MovementAdjustment:  true,
+	model.MovementTransfer:    true,
+	model.MovementStocktake:   true,
+	model.MovementRevaluation: true,
+}
+
+// LedgerPage is one page of an item's movements, newest first.
+type LedgerPage struct {
+	Movements []model.StockMovement `json:"movements"`
+	Total     int64                 `json:"total"`
+	Page      int                   `json:"page"`
+	PageSize  int                   `json:"pageSize"`
+}
+
+// Discrepancy is an item whose cached quantity disagreed with its ledger.
+type Discrepancy struct {
+	InventoryID uint    `json:"inventoryId"`
+	Name        string  `json:"name"`
+	Cached      float64 `json:"cached"`
+	Ledger      float64 `json:"ledger"`
+}
+
+// ReconcileReport lists the cached quantities Reconcile corrected.
+type ReconcileReport struct {
+	CheckedAt time.Time     `json:"checkedAt"`
+	Items     int           `json:"items"`
+	Fixed     []Discrepancy `json:"fixed"`
+}
+
+// Reference names a document in StockMovement.Reference.
+func Reference(document string, id uint) string {
+	return fmt.Sprintf("%s:%d", document, id)
+}
+
+// Convert turns a quantity of one of the item's packs into base units. A nil
+// unit is the base unit itself. The result must fit the precision of the
+// base unit, so half a piece is refused.
+func Convert(repos *repository.Repositories, item *model.Inventory, quantity float64, unitID *uint) (float64, error) {
+	if math.IsNaN(quantity) || math.IsInf(quantity, 0) {
+		return 0, ErrInvalidQuantity
+	}
+	if unitID != nil {
+		pack, err := repos.Units.GetPack(*unitID)
+		if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && pack.InventoryID != item.ID) {
+			return 0, ErrUnitNotFound
+		} else if err != nil {
+			return 0, fmt.Errorf("failed to fetch unit: %w", ErrDatabaseOperation)
+		}
+		quantity *= pack.Factor
+	}
+
+	unit, err := BaseUnit(repos, item)
+	if err != nil {
+		return 0, err
+	}
+	if !unit.Fits(quantity) {
+		return 0, fmt.Errorf("%v %s is finer than the unit allows: %w", quantity, unit.Code, ErrInvalidQuantity)
+	}
+	return unit.Round(quantity), nil
+}
+
+// BaseUnit returns the unit an item is stocked in.
+func BaseUnit(repos *repository.Repositories, item *model.Inventory) (*model.Unit, error) {
+	unit, err := repos.Units.Get(item.Unit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch unit: %w", ErrDatabaseOperation)
+	}
+	if unit == nil {
+		return nil, fmt.Errorf("unit %q: %w", item.Unit, ErrUnitNotFound)
+	}
+	return unit, nil
+}
+
+// Record appends m to the ledger inside the caller's unit of work and
+// refreshes the item's cached quantity. Quantities are in the base unit,
+// kept to its precision, and never take stock below zero at the movement's
+// location, the current one unless it names another. Items with variants
+// are stocked through them, lot-tracked items through their lots (see
+// allocate) and serialized items only through RecordSerials. Every
+// movement is valued at cost; see value.
+func Record(repos *repository.Repositories, m *model.StockMovement) error {
+	return post(repos, m, nil, nil)
+}
+
+// RecordLots is Record with the lots a movement fills or draws from picked
+// by the caller.
+func RecordLots(repos *repository.Repositories, m *model.StockMovement, picks []LotPick) error {
+	return post(repos, m, picks, nil)
+}
+
+// RecordSerials is Record for a serialized item, naming each unit the
+// movement moves and the party they came from or went to; see serialize.
+func RecordSerials(repos *repository.Repositories, m *model.StockMovement, serials []string, party string) error {
+	return post(repos, m, nil, &serialMove{serials: serials, party: strings.TrimSpace(party)})
+}
+
+func post(repos *repository.Repositories, m *model.StockMovement, picks []LotPick, move *serialMove) error {
+	if !movementTypes[m.Type] {
+		return fmt.Errorf("type %q: %w", m.Type, ErrInvalidMovement)
+	}
+	switch {
+	case m.Type == model.MovementRevaluation:
+		if m.Quantity != 0 {
+			return ErrInvalidQuantity
+		}
+	// A stocktake that found what was expected is still worth recording
+	case m.Quantity == 0 && m.Type != model.MovementStocktake:
+		return ErrInvalidQuantity
+	}
+
+	item, err := repos.Inventory.GetByID(m.InventoryID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrInventoryNotFound
+	} else if err != nil {
+		return fmt.Errorf("failed to fetch inventory: %w", ErrDatabaseOperation)
+	}
+	if variants, err := repos.Inventory.CountVariants(m.InventoryID); err != nil {
+		return fmt.Errorf("failed to count variants: %w", ErrDatabaseOperation)
+	} else if variants > 0 {
+		return ErrVariantRequired
+	}
+	unit, err := BaseUnit(repos, item)
+	if err != nil {
+		return err
+	}
+	if !unit.Fits(m.Quantity) {
+		return ErrInvalidQuantity
+	}
+	if err := locate(repos, m); err != nil {
+		return err
+	}
+	onHand, err := repos.StockMovements.Sum(m.InventoryID)
+	if err != nil {
+		return fmt.Errorf("failed to read stock ledger: %w", ErrDatabaseOperation)
+	}
+	atLocation, err := repos.StockMovements.SumAt(m.InventoryID, m.LocationID)
+	if err != nil {
+		return fmt.Errorf("failed to read stock ledger: %w", ErrDatabaseOperation)
+	}
+
+	m.Quantity = unit.Round(m.Quantity)
+	m.Balance = unit.Round(onHand + m.Quantity)
+	if m.Balance < 0 || unit.Round(atLocation+m.Quantity) < 0 {
+		return fmt.Errorf("requested quantity %v exceeds available stock %v: %w", -m.Quantity, atLocation, ErrInsufficientStock)
+	}
+	allocations, err := allocate(repos, item, atLocation, m, picks)
+	if err != nil {
+		return err
+	}
+	units, err := serialize(repos, item, m, move)
+	if err != nil {
+		return err
+	}
+	layer, err := value(repos, item, onHand, m)
+	if err != nil {
+		return err
+	}
+	if err := repos.StockMovements.Create(m); err != nil {
+		return fmt.Errorf("failed to record stock movement: %w", ErrDatabaseOperation)
+	}
+	if err := applyAllocations(repos, unit, m, allocations); err != nil {
+		return err
+	}
+	if err := applySerials(repos, item, m, units, move); err != nil {
+		return err
+	}
+	if layer != nil {
+		layer.MovementID = &m.ID
+		if err := repos.StockMovements.CreateLayer(layer); err != nil {
+			return fmt.Errorf("failed to open cost layer: %w", ErrDatabaseOperation)
+		}
+	}
+	if err := repos.Inventory.SetQuantity(m.InventoryID, m.Balance); err != nil {
+		return fmt.Errorf("failed to update inventory: %w", ErrDatabaseOperation)
+	}
+	return nil
+}
+
+type StockService struct {
+	movementRepo  repository.StockMovementRepo
+	inventoryRepo repository.InventoryRepo
+	sessionRepo   repository.SessionRepo
+	uow           repository.UnitOfWork
+}
+
+func NewStockService(
+	movementRepo repository.StockMovementRepo,
+	inventoryRepo repository.InventoryRepo,
+	sessionRepo repository.SessionRepo,
+	uow repository.UnitOfWork,
+) *StockService {
+	return &StockService{
+		movementRepo:  movementRepo,
+		inventoryRepo: inventoryRepo,
+		sessionRepo:   sessionRepo,
+		uow:           uow,
+	}
+}
+
+// ReceiveStock books goods that arrived, e.g. against a delivery note. The
+// quantity is in the given pack of the item, or its base unit for nil, and
+// cost is what one of those cost. A zero cost takes the goods in at the
+// item's average cost.
+func (s *StockService) ReceiveStock(sessionID, inventoryID uint, quantity float64, unitID *uint, cost float64, reference, reason string) (*model.StockMovement, error) {
+	if !(quantity > 0) {
+		return nil, ErrInvalidQuantity
+	}
+	if !(cost >= 0) || math.IsInf(cost, 0) {
+		return nil, ErrInvalidCost
+	}
+	return s.record(sessionID, quantity, unitID, &model.StockMovement{
+		InventoryID: inventoryID,
+		Type:        model.MovementReceipt,
+		Cost:        quantity * cost,
+		Reference:   strings.TrimSpace(reference),
+		Reason:      strings.TrimSpace(reason),
+	})
+}
+
+// ReturnStock books goods a customer brought back, in the given pack or the
+// base unit.
+func (s *StockService) ReturnStock(sessionID, inventoryID uint, quantity float64, unitID *uint, reference, reason string) (*model.StockMovement, error) {
+	if !(quantity > 0) {
+		return nil, ErrInvalidQuantity
+	}
+	return s.record(sessionID, quantity, unitID, &model.StockMovement{
+		InventoryID: inventoryID,
+		Type:        model.MovementReturn,
+		Reference:   strings.TrimSpace(reference),
+		Reason:      strings.TrimSpace(reason),
+	})
+}
+
+// RecordStocktake books a physical count at the current location, in the
+// given pack or the base unit; the movement holds the difference to what
+// the ledger has there.
+func (s *StockService) RecordStocktake(sessionID, inventoryID uint, counted float64, unitID *uint, reason string) (*model.StockMovement, error) {
+	if !(counted >= 0) {
+		return nil, ErrInvalidQuantity
+	}
+	userID, err := s.userID(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	movement := &model.StockMovement{
+		InventoryID: inventoryID,
+		Type:        model.MovementStocktake,
+		UserID:      &userID,
+		Reason:      strings.TrimSpace(reason),
+	}
+	err = s.uow.Do(func(repos *repository.Repositories) error {
+		base, err := convert(repos, inventoryID, counted, unitID)
+		if err != nil {
+			return err
+		}
+		location, err := CurrentLocation(repos)
+		if err != nil {
+			return err
+		}
+		onHand, err := repos.StockMovements.SumAt(inventoryID, location.ID)
+		if err != nil {
+			return fmt.Errorf("failed to read stock ledger: %w", ErrDatabaseOperation)
+		}
+		movement.LocationID = location.ID
+		movement.Quantity = base - onHand
+		return Record(repos, movement)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return movement, nil
+}
+
+// GetLedger returns one page of an item's movements, newest first. Page
+// numbers start at 1.
+func (s *StockService) GetLedger(inventoryID uint, page, pageSize int) (*LedgerPage, error) {
+	page = max(page, 1)
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+	pageSize = min(pageSize, MaxPageSize)
+
+	movements, total, err := s.movementRepo.ListByInventory(inventoryID, (page-1)*pageSize, pageSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stock ledger: %w", ErrDatabaseOperation)
+	}
+	if movements == nil {
+		movements = []model.StockMovement{}
+	}
+	return &LedgerPage{Movements: movements, Total: total, Page: page, PageSize: pageSize}, nil
+}
+
+// GetOnHand returns an item's quantity as the ledger has it.
+func (s *StockService) GetOnHand(inventoryID uint) (float64, error) {
+	onHand, err := s.movementRepo.Sum(inventoryID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read stock ledger: %w", ErrDatabaseOperation)
+	}
+	return onHand, nil
+}
+
+// Reconcile compares every cached quantity with the ledger and corrects the
+// cache where they differ.
+func (s *StockService) Reconcile() (*ReconcileReport, error) {
+	report := &ReconcileReport{CheckedAt: time.Now(), Fixed: []Discrepancy{}}
+	err := s.uow.Do(func(repos *repository.Repositories) error {
+		items, err := repos.Inventory.All()
+		if err != nil {
+			return fmt.Errorf("failed to list inventory: %w", ErrDatabaseOperation)
+		}
+		balances, err := repos.StockMovements.OnHand()
+		if err != nil {
+			return fmt.Errorf("failed to read stock ledger: %w", ErrDatabaseOperation)
+		}
+		// Sums of fractions carry float noise that is not a discrepancy
+		ledger := make(map[uint]float64, len(balances))
+		for _, b := range balances {
+			ledger[b.InventoryID] = model.RoundQuantity(b.Quantity, model.MaxPrecision)
+		}
+
+		report.Items = len(items)
+		for _, item := range items {
+			if model.RoundQuantity(item.Quantity, model.MaxPrecision) == ledger[item.ID] {
+				continue
+			}
+			report.Fixed = append(report.Fixed, Discrepancy{
+				InventoryID: item.ID,
+				Name:        item.Name,
+				Cached:      item.Quantity,
+				Ledger:      ledger[item.ID],
+			})
+			if err := repos.Inventory.SetQuantity(item.ID, ledger[item.ID]); err != nil {
+				return fmt.Errorf("failed to update inventory: %w", ErrDatabaseOperation)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// record books quantity, given in a pack of the item or its base unit, with
+// the sign of the movement's type.
+func (s *StockService) record(sessionID uint, quantity float64, unitID *uint, movement *model.StockMovement) (*model.StockMovement, error) {
+	userID, err := s.userID(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	movement.UserID = &userID
+
+	err = s.uow.Do(func(repos *repository.Repositories) error {
+		base, err := convert(repos, movement.InventoryID, quantity, unitID)
+		if err != nil {
+			return err
+		}
+		movement.Quantity = base
+		return Record(repos, movement)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return movement, nil
+}
+
+// convert looks up an item and converts quantity to its base unit.
+func convert(repos *repository.Repositories, inventoryID uint, quantity float64, unitID *uint) (float64, error) {
+	item, err := repos.Inventory.GetByID(inventoryID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, ErrInventoryNotFound
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to fetch inventory: %w", ErrDatabaseOperation)
+	}
+	return Convert(repos, item, quantity, unitID)
+}
+
+// userID resolves the user a frontend session belongs to.
+func (s *StockService) userID(sessionID uint) (uint, error) {
+	session, err := s.sessionRepo.GetSession(sessionID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch session: %w", ErrDatabaseOperation)
+	}
+	if session == nil {
+		return 0, ErrSessionNotFound
+	}
+	return session.UserID, nil
+}
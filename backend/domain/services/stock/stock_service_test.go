@@ -0,0 +1,501 @@
+package stock_service
+
+import (
+	"blizzflow/backend/domain/model"
+	repository "blizzflow/backend/domain/repositories"
+	"blizzflow/backend/infrastructure/database"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+	"gorm.io/gorm"
+)
+
+func TestStockServiceSuite(t *testing.T) {
+	gomega.RegisterFailHandler(ginkgo.Fail)
+	ginkgo.RunSpecs(t, "Stock Service Test Suite")
+}
+
+const testDBPath = "test.db"
+
+var (
+	DB           *gorm.DB
+	stockService *StockService
+)
+
+var _ = ginkgo.BeforeSuite(func() {
+	os.Remove(testDBPath)
+	store, err := database.Open(database.DefaultOptions(testDBPath))
+	gomega.Expect(err).To(gomega.BeNil())
+	DB = store.DB()
+	stockService = NewStockService(
+		repository.NewStockMovementRepository(DB),
+		repository.NewInventoryRepository(DB),
+		repository.NewSessionRepository(DB),
+		repository.NewUnitOfWork(DB),
+	)
+})
+
+var _ = ginkgo.AfterSuite(func() {
+	if DB != nil {
+		sqlDB, err := DB.DB()
+		if err == nil {
+			sqlDB.Close()
+		}
+	}
+	os.Remove(testDBPath)
+})
+
+var _ = ginkgo.Describe("Stock Service", func() {
+	var (
+		item    *model.Inventory
+		session *model.Session
+	)
+
+	quantity := func() float64 {
+		var current model.Inventory
+		DB.First(&current, item.ID)
+		return current.Quantity
+	}
+
+	ginkgo.BeforeEach(func() {
+		DB.Exec("DELETE FROM serial_events")
+		DB.Exec("DELETE FROM serial_numbers")
+		DB.Exec("DELETE FROM lot_allocations")
+		DB.Exec("DELETE FROM lots")
+		DB.Exec("DELETE FROM cost_layers")
+		DB.Exec("DELETE FROM stock_movements")
+		DB.Exec("DELETE FROM inventories")
+		DB.Exec("DELETE FROM sessions")
+		DB.Exec("DELETE FROM users")
+		DB.Exec("DELETE FROM settings")
+		DB.Exec("DELETE FROM locations WHERE id > 2")
+
+		item = &model.Inventory{Name: "Flour", Price: 1.2}
+		DB.Create(item)
+		session = &model.Session{UserID: 7}
+		DB.Create(session)
+	})
+
+	ginkgo.It("should record receipts and returns in the ledger", func() {
+		received, err := stockService.ReceiveStock(session.ID, item.ID, 10, nil, 0, "delivery:118", "")
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(received.Balance).To(gomega.Equal(10.0))
+		gomega.Expect(*received.UserID).To(gomega.Equal(uint(7)))
+
+		_, err = stockService.ReceiveStock(session.ID, item.ID, 2, nil, 0, "", "short delivery made up")
+		gomega.Expect(err).To(gomega.BeNil())
+		_, err = stockService.ReturnStock(session.ID, item.ID, 1, nil, "sale:3", "wrong size")
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(quantity()).To(gomega.Equal(13.0))
+
+		ledger, err := stockService.GetLedger(item.ID, 1, 2)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(ledger.Total).To(gomega.Equal(int64(3)))
+		gomega.Expect(ledger.Movements).To(gomega.HaveLen(2))
+		gomega.Expect(ledger.Movements[0].Type).To(gomega.Equal(model.MovementReturn))
+		gomega.Expect(ledger.Movements[1].Reason).To(gomega.Equal("short delivery made up"))
+	})
+
+	ginkgo.It("should never take stock below zero", func() {
+		_, err := stockService.ReceiveStock(session.ID, item.ID, 3, nil, 0, "", "")
+		gomega.Expect(err).To(gomega.BeNil())
+
+		err = Record(repository.NewRepositories(DB), &model.StockMovement{
+			InventoryID: item.ID,
+			Type:        model.MovementAdjustment,
+			Quantity:    -4,
+		})
+		gomega.Expect(err).To(gomega.MatchError(ErrInsufficientStock))
+		gomega.Expect(quantity()).To(gomega.Equal(3.0))
+
+		_, err = stockService.ReceiveStock(session.ID, 9999, 1, nil, 0, "", "")
+		gomega.Expect(err).To(gomega.Equal(ErrInventoryNotFound))
+	})
+
+	ginkgo.It("should book stocktakes as the difference to the ledger", func() {
+		stockService.ReceiveStock(session.ID, item.ID, 10, nil, 0, "", "")
+
+		counted, err := stockService.RecordStocktake(session.ID, item.ID, 8, nil, "monthly count")
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(counted.Quantity).To(gomega.Equal(-2.0))
+		gomega.Expect(counted.Balance).To(gomega.Equal(8.0))
+
+		unchanged, err := stockService.RecordStocktake(session.ID, item.ID, 8, nil, "")
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(unchanged.Quantity).To(gomega.BeZero())
+	})
+
+	ginkgo.It("should convert packs and fractions to the base unit", func() {
+		sack := &model.ItemUnit{InventoryID: item.ID, Name: "sack", Factor: 25}
+		DB.Create(sack)
+		DB.Model(item).Update("unit", "kg")
+
+		received, err := stockService.ReceiveStock(session.ID, item.ID, 2, &sack.ID, 0, "", "")
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(received.Quantity).To(gomega.Equal(50.0))
+		_, err = stockService.ReturnStock(session.ID, item.ID, 0.1, nil, "", "")
+		gomega.Expect(err).To(gomega.BeNil())
+		_, err = stockService.ReturnStock(session.ID, item.ID, 0.2, nil, "", "")
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(quantity()).To(gomega.Equal(50.3))
+
+		_, err = stockService.ReceiveStock(session.ID, item.ID, 0.0005, nil, 0, "", "")
+		gomega.Expect(err).To(gomega.MatchError(ErrInvalidQuantity))
+
+		counted, err := stockService.RecordStocktake(session.ID, item.ID, 1.5, &sack.ID, "")
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(counted.Quantity).To(gomega.Equal(-12.8))
+		gomega.Expect(counted.Balance).To(gomega.Equal(37.5))
+
+		other := &model.ItemUnit{InventoryID: 9999, Name: "case", Factor: 6}
+		DB.Create(other)
+		_, err = stockService.ReceiveStock(session.ID, item.ID, 1, &other.ID, 0, "", "")
+		gomega.Expect(err).To(gomega.Equal(ErrUnitNotFound))
+
+		DB.Model(item).Update("unit", "pc")
+		_, err = stockService.ReceiveStock(session.ID, item.ID, 0.5, nil, 0, "", "")
+		gomega.Expect(err).To(gomega.MatchError(ErrInvalidQuantity))
+	})
+
+	ginkgo.It("should require a session", func() {
+		_, err := stockService.ReceiveStock(9999, item.ID, 1, nil, 0, "", "")
+		gomega.Expect(err).To(gomega.Equal(ErrSessionNotFound))
+	})
+
+	ginkgo.It("should refuse to change recorded movements", func() {
+		movement, _ := stockService.ReceiveStock(session.ID, item.ID, 5, nil, 0, "", "")
+		err := DB.Model(movement).Update("quantity", 50).Error
+		gomega.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("cannot be changed")))
+	})
+
+	ginkgo.It("should reconcile cached quantities with the ledger", func() {
+		stockService.ReceiveStock(session.ID, item.ID, 6, nil, 0, "", "")
+		DB.Model(&model.Inventory{}).Where("id = ?", item.ID).Update("quantity", 60)
+
+		report, err := stockService.Reconcile()
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(report.Fixed).To(gomega.HaveLen(1))
+		gomega.Expect(report.Fixed[0].Cached).To(gomega.Equal(60.0))
+		gomega.Expect(report.Fixed[0].Ledger).To(gomega.Equal(6.0))
+		gomega.Expect(quantity()).To(gomega.Equal(6.0))
+
+		onHand, err := stockService.GetOnHand(item.ID)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(onHand).To(gomega.Equal(6.0))
+	})
+
+	ginkgo.Context("Costs", func() {
+		current := func() *model.Inventory {
+			var inventory model.Inventory
+			DB.First(&inventory, item.ID)
+			return &inventory
+		}
+
+		ginkgo.It("should value stock at its moving average cost", func() {
+			repos := repository.NewRepositories(DB)
+			err := Record(repos, &model.StockMovement{
+				InventoryID: item.ID,
+				Type:        model.MovementReceipt,
+				Quantity:    10,
+				Cost:        20,
+				CreatedAt:   time.Now().AddDate(0, 0, -7),
+			})
+			gomega.Expect(err).To(gomega.BeNil())
+			_, err = stockService.ReceiveStock(session.ID, item.ID, 10, nil, 4, "", "")
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(current().AverageCost).To(gomega.Equal(3.0))
+			gomega.Expect(current().LastCost).To(gomega.Equal(4.0))
+
+			sold := &model.StockMovement{InventoryID: item.ID, Type: model.MovementSale, Quantity: -5}
+			gomega.Expect(Record(repos, sold)).To(gomega.Succeed())
+			gomega.Expect(sold.Cost).To(gomega.Equal(-15.0))
+
+			report, err := stockService.Valuation(time.Time{}, nil)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(report.Method).To(gomega.Equal(model.CostingAverage))
+			gomega.Expect(report.Items).To(gomega.HaveLen(1))
+			gomega.Expect(report.Items[0].Quantity).To(gomega.Equal(15.0))
+			gomega.Expect(report.Total).To(gomega.Equal(45.0))
+
+			report, err = stockService.Valuation(time.Now().AddDate(0, 0, -3), nil)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(report.Items[0].Quantity).To(gomega.Equal(10.0))
+			gomega.Expect(report.Total).To(gomega.Equal(20.0))
+
+			_, err = stockService.ReceiveStock(session.ID, item.ID, 1, nil, -1, "", "")
+			gomega.Expect(err).To(gomega.Equal(ErrInvalidCost))
+		})
+
+		ginkgo.It("should let a manager switch to FIFO costing", func() {
+			manager := &model.User{Username: "boss", PasswordHash: "x", Role: model.RoleManager}
+			DB.Create(manager)
+			managerSession := &model.Session{UserID: manager.ID}
+			DB.Create(managerSession)
+			clerk := &model.User{Username: "clerk", PasswordHash: "x", Role: model.RoleStaff}
+			DB.Create(clerk)
+			clerkSession := &model.Session{UserID: clerk.ID}
+			DB.Create(clerkSession)
+
+			gomega.Expect(stockService.SetCostingMethod(clerkSession.ID, model.CostingFIFO)).To(gomega.Equal(ErrNotManager))
+			gomega.Expect(stockService.SetCostingMethod(managerSession.ID, "lifo")).To(gomega.Equal(ErrInvalidCostingMethod))
+			gomega.Expect(stockService.SetCostingMethod(managerSession.ID, model.CostingFIFO)).To(gomega.Succeed())
+			method, err := stockService.GetCostingMethod()
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(method).To(gomega.Equal(model.CostingFIFO))
+
+			stockService.ReceiveStock(session.ID, item.ID, 4, nil, 1, "", "")
+			stockService.ReceiveStock(session.ID, item.ID, 4, nil, 2, "", "")
+			counted, err := stockService.RecordStocktake(session.ID, item.ID, 2, nil, "")
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(counted.Cost).To(gomega.Equal(-8.0))
+
+			cost, err := UnitCost(repository.NewRepositories(DB), current())
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(cost).To(gomega.Equal(2.0))
+		})
+
+		ginkgo.It("should revalue stock when the standard cost changes", func() {
+			DB.Create(&model.Setting{Key: CostingMethodKey, Value: model.CostingStandard})
+			DB.Model(item).Update("standard_cost", 5)
+
+			received, err := stockService.ReceiveStock(session.ID, item.ID, 4, nil, 6, "", "")
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(received.Cost).To(gomega.Equal(20.0))
+			gomega.Expect(current().LastCost).To(gomega.Equal(6.0))
+
+			repos := repository.NewRepositories(DB)
+			gomega.Expect(Revalue(repos, current(), 7)).To(gomega.Succeed())
+			report, err := stockService.Valuation(time.Time{}, nil)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(report.Total).To(gomega.Equal(28.0))
+
+			ledger, _ := stockService.GetLedger(item.ID, 1, 1)
+			gomega.Expect(ledger.Movements[0].Type).To(gomega.Equal(model.MovementRevaluation))
+			gomega.Expect(ledger.Movements[0].Quantity).To(gomega.BeZero())
+		})
+	})
+
+	ginkgo.Context("Lots", func() {
+		expires := func(days int) *time.Time {
+			at := time.Now().AddDate(0, 0, days)
+			return &at
+		}
+		remaining := func() map[string]float64 {
+			lots, err := stockService.ListLots(item.ID, false)
+			gomega.Expect(err).To(gomega.BeNil())
+			left := make(map[string]float64)
+			for _, lot := range lots {
+				left[lot.Number] = lot.Quantity
+			}
+			return left
+		}
+		sell := func(quantity float64, picks ...LotPick) error {
+			return RecordLots(repository.NewRepositories(DB), &model.StockMovement{
+				InventoryID: item.ID,
+				Type:        model.MovementSale,
+				Quantity:    -quantity,
+			}, picks)
+		}
+
+		ginkgo.BeforeEach(func() {
+			DB.Model(item).Update("track_lots", true)
+		})
+
+		ginkgo.It("should receive into lots and sell the first to expire first", func() {
+			_, err := stockService.ReceiveStock(session.ID, item.ID, 5, nil, 0, "", "")
+			gomega.Expect(err).To(gomega.Equal(ErrLotRequired))
+
+			_, err = stockService.ReceiveLot(session.ID, item.ID, 5, nil, 1, LotInput{Number: "A1", ExpiresAt: expires(10)}, "", "")
+			gomega.Expect(err).To(gomega.BeNil())
+			_, err = stockService.ReceiveLot(session.ID, item.ID, 5, nil, 1, LotInput{Number: "B1", ExpiresAt: expires(3)}, "", "")
+			gomega.Expect(err).To(gomega.BeNil())
+			_, err = stockService.ReceiveLot(session.ID, item.ID, 2, nil, 1, LotInput{Number: "C1"}, "", "")
+			gomega.Expect(err).To(gomega.BeNil())
+			_, err = stockService.ReceiveLot(session.ID, item.ID, 1, nil, 1, LotInput{Number: "a1", ExpiresAt: expires(10)}, "", "")
+			gomega.Expect(err).To(gomega.BeNil())
+			_, err = stockService.ReceiveLot(session.ID, item.ID, 1, nil, 1, LotInput{Number: "A1", ExpiresAt: expires(11)}, "", "")
+			gomega.Expect(err).To(gomega.MatchError(ErrInvalidLot))
+			gomega.Expect(remaining()).To(gomega.Equal(map[string]float64{"A1": 6, "B1": 5, "C1": 2}))
+
+			gomega.Expect(sell(7)).To(gomega.Succeed())
+			gomega.Expect(remaining()).To(gomega.Equal(map[string]float64{"A1": 4, "C1": 2}))
+
+			// An override takes the lot asked for
+			lots, _ := stockService.ListLots(item.ID, false)
+			gomega.Expect(lots[1].Number).To(gomega.Equal("C1"))
+			gomega.Expect(sell(2, LotPick{LotID: lots[1].ID, Quantity: 2})).To(gomega.Succeed())
+			gomega.Expect(sell(1, LotPick{LotID: lots[1].ID, Quantity: 1})).To(gomega.MatchError(ErrInsufficientStock))
+			gomega.Expect(remaining()).To(gomega.Equal(map[string]float64{"A1": 4}))
+			gomega.Expect(quantity()).To(gomega.Equal(4.0))
+
+			lots, _ = stockService.ListLots(item.ID, true)
+			gomega.Expect(lots).To(gomega.HaveLen(3))
+		})
+
+		ginkgo.It("should never sell expired lots but let them be written off", func() {
+			stockService.ReceiveLot(session.ID, item.ID, 3, nil, 1, LotInput{Number: "OLD", ExpiresAt: expires(-1)}, "", "")
+			stockService.ReceiveLot(session.ID, item.ID, 2, nil, 1, LotInput{Number: "NEW", ExpiresAt: expires(30)}, "", "")
+			lots, _ := stockService.ListLots(item.ID, false)
+
+			expiring, err := stockService.ExpiringLots(7)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(expiring).To(gomega.HaveLen(1))
+			gomega.Expect(expiring[0].Number).To(gomega.Equal("OLD"))
+			gomega.Expect(expiring[0].Name).To(gomega.Equal("Flour"))
+			gomega.Expect(expiring[0].Expired).To(gomega.BeTrue())
+			gomega.Expect(expiring[0].DaysLeft).To(gomega.Equal(-1))
+			expiring, _ = stockService.ExpiringLots(30)
+			gomega.Expect(expiring).To(gomega.HaveLen(2))
+			gomega.Expect(expiring[1].DaysLeft).To(gomega.Equal(30))
+
+			gomega.Expect(sell(1, LotPick{LotID: lots[0].ID, Quantity: 1})).To(gomega.MatchError(ErrLotExpired))
+			gomega.Expect(sell(2)).To(gomega.Succeed())
+			gomega.Expect(sell(1)).To(gomega.MatchError(ErrLotExpired))
+
+			err = RecordLots(repository.NewRepositories(DB), &model.StockMovement{
+				InventoryID: item.ID,
+				Type:        model.MovementAdjustment,
+				Quantity:    -3,
+				Reason:      "expired",
+			}, nil)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(quantity()).To(gomega.BeZero())
+		})
+
+		ginkgo.It("should sell stock held outside lots last", func() {
+			DB.Model(item).Update("track_lots", false)
+			stockService.ReceiveStock(session.ID, item.ID, 4, nil, 0, "", "")
+			DB.Model(item).Update("track_lots", true)
+			stockService.ReceiveLot(session.ID, item.ID, 2, nil, 1, LotInput{Number: "L1", ExpiresAt: expires(5)}, "", "")
+
+			gomega.Expect(sell(3)).To(gomega.Succeed())
+			gomega.Expect(remaining()).To(gomega.BeEmpty())
+			gomega.Expect(quantity()).To(gomega.Equal(3.0))
+		})
+	})
+
+	ginkgo.Context("Serial numbers", func() {
+		var phone *model.Inventory
+
+		ginkgo.BeforeEach(func() {
+			phone = &model.Inventory{Name: "Phone", Price: 300, TrackSerials: true, WarrantyMonths: 12}
+			DB.Create(phone)
+		})
+
+		sell := func(serial, customer string) error {
+			return RecordSerials(repository.NewRepositories(DB), &model.StockMovement{
+				InventoryID: phone.ID,
+				Type:        model.MovementSale,
+				Quantity:    -1,
+				Reference:   "sale:1",
+			}, []string{serial}, customer)
+		}
+
+		ginkgo.It("should book every unit by its serial number", func() {
+			_, err := stockService.ReceiveStock(session.ID, phone.ID, 2, nil, 100, "", "")
+			gomega.Expect(err).To(gomega.Equal(ErrSerialRequired))
+			_, err = stockService.ReceiveSerials(session.ID, phone.ID, []string{"IMEI-1", "imei-1"}, 100, "Acme", "", "")
+			gomega.Expect(err).To(gomega.Equal(ErrInvalidSerial))
+			_, err = stockService.ReceiveSerials(session.ID, item.ID, []string{"F-1"}, 1, "", "", "")
+			gomega.Expect(err).To(gomega.Equal(ErrSerialsNotTracked))
+
+			movement, err := stockService.ReceiveSerials(session.ID, phone.ID, []string{"IMEI-1", " IMEI-2 "}, 100, "Acme", "DN-7", "")
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(movement.Quantity).To(gomega.Equal(2.0))
+			gomega.Expect(movement.Cost).To(gomega.Equal(200.0))
+			_, err = stockService.ReceiveSerials(session.ID, phone.ID, []string{"imei-2"}, 100, "Acme", "", "")
+			gomega.Expect(err).To(gomega.MatchError(ErrDuplicateSerial))
+
+			inStock, err := stockService.ListSerials(phone.ID, model.SerialInStock)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(inStock).To(gomega.HaveLen(2))
+			gomega.Expect(inStock[1].Serial).To(gomega.Equal("IMEI-2"))
+
+			gomega.Expect(sell("IMEI-1", "Bob")).To(gomega.Succeed())
+			gomega.Expect(sell("imei-1", "Bob")).To(gomega.MatchError(ErrSerialNotInStock))
+			gomega.Expect(sell("IMEI-9", "Bob")).To(gomega.MatchError(ErrSerialNotFound))
+			gomega.Expect(RecordSerials(repository.NewRepositories(DB), &model.StockMovement{
+				InventoryID: phone.ID,
+				Type:        model.MovementAdjustment,
+				Quantity:    -1,
+			}, []string{"IMEI-1", "IMEI-2"}, "")).To(gomega.MatchError(ErrInvalidQuantity))
+			var current model.Inventory
+			DB.First(&current, phone.ID)
+			gomega.Expect(current.Quantity).To(gomega.Equal(1.0))
+		})
+
+		ginkgo.It("should keep the history and warranty of a unit", func() {
+			stockService.ReceiveSerials(session.ID, phone.ID, []string{"IMEI-1", "IMEI-2"}, 100, "Acme", "DN-7", "")
+			gomega.Expect(sell("IMEI-1", "Bob")).To(gomega.Succeed())
+
+			history, err := stockService.SerialHistory("imei-1")
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(history).To(gomega.HaveLen(1))
+			unit := history[0]
+			gomega.Expect(unit.Name).To(gomega.Equal("Phone"))
+			gomega.Expect(unit.Status).To(gomega.Equal(model.SerialSold))
+			gomega.Expect(unit.UnderWarranty).To(gomega.BeTrue())
+			gomega.Expect(*unit.WarrantyEndsAt).To(gomega.Equal(today(*unit.SoldAt).AddDate(1, 0, 0)))
+			gomega.Expect(unit.Events).To(gomega.HaveLen(2))
+			gomega.Expect(unit.Events[0].Type).To(gomega.Equal(model.MovementReceipt))
+			gomega.Expect(unit.Events[0].Party).To(gomega.Equal("Acme"))
+			gomega.Expect(unit.Events[0].Reference).To(gomega.Equal("DN-7"))
+			gomega.Expect(unit.Events[1].Party).To(gomega.Equal("Bob"))
+
+			_, err = stockService.ReturnSerial(session.ID, phone.ID, "IMEI-2", "Bob", "", "")
+			gomega.Expect(err).To(gomega.MatchError(ErrSerialNotSold))
+			_, err = stockService.ReturnSerial(session.ID, phone.ID, "IMEI-1", "Bob", "RMA-3", "faulty")
+			gomega.Expect(err).To(gomega.BeNil())
+
+			history, _ = stockService.SerialHistory("IMEI-1")
+			gomega.Expect(history[0].Status).To(gomega.Equal(model.SerialInStock))
+			gomega.Expect(history[0].WarrantyEndsAt).To(gomega.BeNil())
+			gomega.Expect(history[0].UnderWarranty).To(gomega.BeFalse())
+			gomega.Expect(history[0].Events).To(gomega.HaveLen(3))
+			gomega.Expect(history[0].Events[2].Type).To(gomega.Equal(model.MovementReturn))
+
+			_, err = stockService.SerialHistory("nothing")
+			gomega.Expect(err).To(gomega.Equal(ErrSerialNotFound))
+		})
+	})
+
+	ginkgo.Context("Locations", func() {
+		ginkgo.It("should keep stock per location and value each one", func() {
+			branch := &model.Location{Name: "Branch"}
+			DB.Create(branch)
+			repos := repository.NewRepositories(DB)
+
+			_, err := stockService.ReceiveStock(session.ID, item.ID, 6, nil, 2, "", "")
+			gomega.Expect(err).To(gomega.BeNil())
+			err = Record(repos, &model.StockMovement{InventoryID: item.ID, LocationID: branch.ID, Type: model.MovementReceipt, Quantity: 4, Cost: 8})
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(quantity()).To(gomega.Equal(10.0))
+
+			// The total would cover it, but the branch only holds 4
+			err = Record(repos, &model.StockMovement{InventoryID: item.ID, LocationID: branch.ID, Type: model.MovementSale, Quantity: -5})
+			gomega.Expect(err).To(gomega.MatchError(ErrInsufficientStock))
+			err = Record(repos, &model.StockMovement{InventoryID: item.ID, LocationID: 99, Type: model.MovementSale, Quantity: -1})
+			gomega.Expect(err).To(gomega.Equal(ErrLocationNotFound))
+
+			// Counts happen at the current location
+			DB.Create(&model.Setting{Key: CurrentLocationKey, Value: fmt.Sprint(branch.ID)})
+			counted, err := stockService.RecordStocktake(session.ID, item.ID, 3, nil, "")
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(counted.LocationID).To(gomega.Equal(branch.ID))
+			gomega.Expect(counted.Quantity).To(gomega.Equal(-1.0))
+
+			report, err := stockService.Valuation(time.Time{}, &branch.ID)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(report.Items[0].Quantity).To(gomega.Equal(3.0))
+			gomega.Expect(report.Total).To(gomega.Equal(6.0))
+			report, err = stockService.Valuation(time.Time{}, nil)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(report.Items[0].Quantity).To(gomega.Equal(9.0))
+			gomega.Expect(report.Total).To(gomega.Equal(18.0))
+		})
+	})
+})
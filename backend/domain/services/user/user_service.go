@@ -2,24 +2,23 @@ package user_service
 
 import (
 	"blizzflow/backend/domain/model"
+	repository "blizzflow/backend/domain/repositories"
 	"errors"
 
 	"golang.org/x/crypto/bcrypt"
-	"gorm.io/gorm"
 )
 
 type UserService struct {
-	db *gorm.DB
+	userRepo repository.UserRepo
 }
 
-func NewUserService(db *gorm.DB) *UserService {
-	return &UserService{db: db}
+func NewUserService(userRepo repository.UserRepo) *UserService {
+	return &UserService{userRepo: userRepo}
 }
 
 func (s *UserService) CreateUser(username, password string) (*model.User, error) {
 	// Check existing user
-	var existingUser model.User
-	if err := s.db.Where("username = ?", username).First(&existingUser).Error; err == nil {
+	if _, err := s.userRepo.GetUserByUsername(username); err == nil {
 		return nil, errors.New("username already exists")
 	}
 
@@ -34,34 +33,26 @@ func (s *UserService) CreateUser(username, password string) (*model.User, error)
 		PasswordHash: string(hashedPassword),
 	}
 
-	if err := s.db.Create(user).Error; err != nil {
+	if err := s.userRepo.CreateUser(user); err != nil {
 		return nil, err
 	}
 	return user, nil
 }
 
 func (s *UserService) GetUserByID(userID uint) (*model.User, error) {
-	var user model.User
-	if err := s.db.First(&user, userID).Error; err != nil {
-		return nil, err
-	}
-	return &user, nil
+	return s.userRepo.GetUserByID(userID)
 }
 
 func (s *UserService) GetUserByUsername(username string) (*model.User, error) {
-	var user model.User
-	if err := s.db.Where("username = ?", username).First(&user).Error; err != nil {
-		return nil, err
-	}
-	return &user, nil
+	return s.userRepo.GetUserByUsername(username)
 }
 
 func (s *UserService) UpdateUser(user *model.User) error {
-	return s.db.Save(user).Error
+	return s.userRepo.UpdateUser(user)
 }
 
 func (s *UserService) DeleteUser(userID uint) error {
-	return s.db.Delete(&model.User{}, userID).Error
+	return s.userRepo.DeleteUser(userID)
 }
 
 // func (s *UserService) ValidatePassword(user *model.User, password string) bool {
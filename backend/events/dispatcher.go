@@ -0,0 +1,42 @@
+package events
+
+import "sync"
+
+// Event names emitted to the frontend by backend services
+const (
+	DatabaseCorruption = "database:corruption"
+	CompanySwitched    = "company:switched"
+	JobFinished        = "scheduler:job-finished"
+	LowStock           = "inventory:low-stock"
+)
+
+// EmitFunc publishes an event, e.g. application.App.EmitEvent.
+type EmitFunc func(name string, data ...any)
+
+// Dispatcher lets services emit events before the application exists. Events
+// emitted before Bind are dropped.
+type Dispatcher struct {
+	mu   sync.RWMutex
+	emit EmitFunc
+}
+
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{}
+}
+
+// Bind connects the dispatcher to the application's event emitter.
+func (d *Dispatcher) Bind(emit EmitFunc) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.emit = emit
+}
+
+func (d *Dispatcher) Emit(name string, data ...any) {
+	d.mu.RLock()
+	emit := d.emit
+	d.mu.RUnlock()
+
+	if emit != nil {
+		emit(name, data...)
+	}
+}
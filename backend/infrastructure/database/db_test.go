@@ -1,11 +1,16 @@
 package database
 
 import (
+	"blizzflow/backend/infrastructure/database/migrations"
+	"blizzflow/config"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/onsi/ginkgo/v2"
 	"github.com/onsi/gomega"
+	"gorm.io/gorm"
 )
 
 func TestDatabaseSuite(t *testing.T) {
@@ -16,57 +21,331 @@ func TestDatabaseSuite(t *testing.T) {
 const testDBPath = "test.db"
 
 var _ = ginkgo.Describe("Database Connection", func() {
+	var store *Store
+
 	ginkgo.BeforeEach(func() {
-		DB = nil
 		os.Remove(testDBPath)
 	})
 
 	ginkgo.AfterEach(func() {
-		if DB != nil {
-			sqlDB, err := DB.DB()
-			if err == nil {
-				sqlDB.Close()
-			}
-			DB = nil
+		if store != nil {
+			store.Close()
+			store = nil
 		}
 	})
 
 	ginkgo.It("should initialize database successfully", func() {
-		err := InitDB(testDBPath)
+		var err error
+		store, err = Open(DefaultOptions(testDBPath))
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		gomega.Expect(store.DB()).NotTo(gomega.BeNil())
+		gomega.Expect(store.Path()).To(gomega.Equal(testDBPath))
+	})
+
+	ginkgo.It("should open several databases side by side", func() {
+		otherPath := filepath.Join(ginkgo.GinkgoT().TempDir(), "other.db")
+
+		var err error
+		store, err = Open(DefaultOptions(testDBPath))
 		gomega.Expect(err).NotTo(gomega.HaveOccurred())
-		gomega.Expect(DB).NotTo(gomega.BeNil())
+		other, err := Open(DefaultOptions(otherPath))
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		defer other.Close()
+
+		gomega.Expect(other.DB().Exec("INSERT INTO users (username, password_hash) VALUES ('other', 'hash')").Error).To(gomega.Succeed())
+
+		var count int64
+		store.DB().Table("users").Count(&count)
+		gomega.Expect(count).To(gomega.BeZero())
+	})
+
+	ginkgo.It("should reopen a different file under the same handle", func() {
+		otherPath := filepath.Join(ginkgo.GinkgoT().TempDir(), "other.db")
+
+		var err error
+		store, err = Open(DefaultOptions(testDBPath))
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		db := store.DB()
+		gomega.Expect(db.Exec("INSERT INTO users (username, password_hash) VALUES ('first', 'hash')").Error).To(gomega.Succeed())
+
+		gomega.Expect(store.Reopen(DefaultOptions(otherPath))).To(gomega.Succeed())
+		gomega.Expect(store.Path()).To(gomega.Equal(otherPath))
+
+		// The migrated schema is there, the first file's rows are not
+		var count int64
+		gomega.Expect(db.Table("users").Count(&count).Error).To(gomega.Succeed())
+		gomega.Expect(count).To(gomega.BeZero())
 	})
 
-	ginkgo.It("should handle database initialization errors", func() {
-		err := InitDB(testDBPath)
+	ginkgo.It("should keep the current file when reopening fails", func() {
+		var err error
+		store, err = Open(DefaultOptions(testDBPath))
 		gomega.Expect(err).NotTo(gomega.HaveOccurred())
 
-		err = InitDB(testDBPath)
-		gomega.Expect(err).To(gomega.MatchError("database already initialized"))
+		notADatabase := filepath.Join(ginkgo.GinkgoT().TempDir(), "broken.db")
+		gomega.Expect(os.WriteFile(notADatabase, []byte("not a database at all, just text"), 0644)).To(gomega.Succeed())
+
+		gomega.Expect(store.Reopen(DefaultOptions(notADatabase))).NotTo(gomega.Succeed())
+		gomega.Expect(store.Path()).To(gomega.Equal(testDBPath))
+		gomega.Expect(store.DB().Exec("SELECT 1").Error).To(gomega.Succeed())
 	})
 
 	ginkgo.It("should handle database closure errors", func() {
 		// First create a valid DB
-		err := InitDB(testDBPath)
+		var err error
+		store, err = Open(DefaultOptions(testDBPath))
 		gomega.Expect(err).NotTo(gomega.HaveOccurred())
 
 		// Close it properly
-		err = CloseDB()
+		err = store.Close()
 		gomega.Expect(err).NotTo(gomega.HaveOccurred())
 
 		// Try closing again
-		err = CloseDB()
-		gomega.Expect(err).NotTo(gomega.HaveOccurred()) // Should handle nil DB gracefully
+		err = store.Close()
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+
+		err = store.DB().Exec("SELECT 1").Error
+		gomega.Expect(err).To(gomega.MatchError(ErrStoreClosed))
 	})
 })
 
-var _ = ginkgo.AfterSuite(func() {
-	if DB != nil {
-		sqlDB, err := DB.DB()
-		if err == nil {
-			sqlDB.Close()
+var _ = ginkgo.Describe("Connection Tuning", func() {
+	var DB *gorm.DB
+
+	openTestDB := func() error {
+		store, err := Open(DefaultOptions(testDBPath))
+		if err != nil {
+			return err
+		}
+		ginkgo.DeferCleanup(store.Close)
+		DB = store.DB()
+		return nil
+	}
+
+	ginkgo.BeforeEach(func() {
+		os.Remove(testDBPath)
+	})
+
+	ginkgo.It("should apply WAL, busy timeout and foreign keys", func() {
+		gomega.Expect(openTestDB()).To(gomega.Succeed())
+
+		var journalMode string
+		DB.Raw("PRAGMA journal_mode").Scan(&journalMode)
+		gomega.Expect(journalMode).To(gomega.Equal("wal"))
+
+		var busyTimeout, foreignKeys int
+		DB.Raw("PRAGMA busy_timeout").Scan(&busyTimeout)
+		DB.Raw("PRAGMA foreign_keys").Scan(&foreignKeys)
+		gomega.Expect(busyTimeout).To(gomega.Equal(5000))
+		gomega.Expect(foreignKeys).To(gomega.Equal(1))
+	})
+
+	ginkgo.It("should ignore unknown pragma values from the config", func() {
+		opts := OptionsFromConfig(config.DatabaseConfig{JournalMode: "wal; DROP TABLE users", Synchronous: "full"}, testDBPath)
+		gomega.Expect(opts.JournalMode).To(gomega.Equal("WAL"))
+		gomega.Expect(opts.Synchronous).To(gomega.Equal("FULL"))
+	})
+
+	ginkgo.It("should pass a quick check on a healthy database", func() {
+		gomega.Expect(openTestDB()).To(gomega.Succeed())
+
+		result, err := QuickCheck(DB)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		gomega.Expect(result.OK).To(gomega.BeTrue())
+	})
+})
+
+var _ = ginkgo.Describe("Schema Migrations", func() {
+	var (
+		store *Store
+		DB    *gorm.DB
+	)
+
+	ginkgo.BeforeEach(func() {
+		os.Remove(testDBPath)
+		os.RemoveAll(BackupDirName)
+
+		var err error
+		store, err = Open(DefaultOptions(testDBPath))
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		DB = store.DB()
+	})
+
+	ginkgo.AfterEach(func() {
+		store.Close()
+		os.RemoveAll(BackupDirName)
+	})
+
+	ginkgo.It("should record the latest schema version", func() {
+		version, err := SchemaVersion(DB)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		gomega.Expect(version).To(gomega.Equal(migrations.Latest()))
+	})
+
+	ginkgo.It("should revert and reapply migrations", func() {
+		gomega.Expect(MigrateDown(DB, 0)).To(gomega.Succeed())
+		gomega.Expect(DB.Migrator().HasTable("users")).To(gomega.BeFalse())
+
+		version, err := SchemaVersion(DB)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		gomega.Expect(version).To(gomega.Equal(0))
+
+		gomega.Expect(MigrateUp(DB, "")).To(gomega.Succeed())
+		gomega.Expect(DB.Migrator().HasTable("users")).To(gomega.BeTrue())
+	})
+
+	ginkgo.It("should refuse a database newer than the binary", func() {
+		DB.Create(&SchemaMigration{Version: migrations.Latest() + 1, Name: "future", AppliedAt: time.Now()})
+
+		err := MigrateUp(DB, testDBPath)
+		gomega.Expect(err).To(gomega.MatchError(ErrSchemaTooNew))
+	})
+
+	ginkgo.It("should back up an existing database before upgrading", func() {
+		gomega.Expect(MigrateDown(DB, 0)).To(gomega.Succeed())
+		DB.Exec("CREATE TABLE legacy (id INTEGER PRIMARY KEY)")
+
+		gomega.Expect(MigrateUp(DB, testDBPath)).To(gomega.Succeed())
+
+		backups, err := filepath.Glob(filepath.Join(BackupDirName, "pre-migrate-v0-*.db"))
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		gomega.Expect(backups).To(gomega.HaveLen(1))
+	})
+})
+
+var _ = ginkgo.Describe("Encryption", func() {
+	ginkgo.AfterEach(func() {
+		os.Remove(KeyInfoPath(testDBPath))
+	})
+
+	ginkgo.It("should derive the same key from the same passphrase", func() {
+		info, key, err := NewKeyInfo(KeyModePassphrase, "owner secret")
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		gomega.Expect(key).To(gomega.HaveLen(32))
+
+		unlocked, err := info.Unlock("owner secret")
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		gomega.Expect(unlocked).To(gomega.Equal(key))
+
+		_, err = info.Unlock("")
+		gomega.Expect(err).To(gomega.Equal(ErrPassphraseRequired))
+	})
+
+	ginkgo.It("should read back the key info sidecar", func() {
+		key, err := UnlockKey(testDBPath, "")
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		gomega.Expect(key).To(gomega.BeNil())
+
+		info, key, err := NewKeyInfo(KeyModePassphrase, "owner secret")
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		gomega.Expect(saveKeyInfo(KeyInfoPath(testDBPath), info)).To(gomega.Succeed())
+
+		unlocked, err := UnlockKey(testDBPath, "owner secret")
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		gomega.Expect(unlocked).To(gomega.Equal(key))
+	})
+
+	ginkgo.It("should refuse to encrypt without SQLCipher", func() {
+		if SupportsEncryption() {
+			ginkgo.Skip("built against SQLCipher")
 		}
-		DB = nil
+		err := EncryptDatabase(testDBPath, KeyModePassphrase, "owner secret")
+		gomega.Expect(err).To(gomega.Equal(ErrEncryptionUnsupported))
+	})
+})
+
+var _ = ginkgo.Describe("Encryption with SQLCipher", func() {
+	ginkgo.BeforeEach(func() {
+		if !SupportsEncryption() {
+			// CI sets this for the SQLCipher build, so a broken link fails
+			// instead of skipping
+			if os.Getenv("BLIZZFLOW_TEST_SQLCIPHER") != "" {
+				ginkgo.Fail("SQLCipher is not linked into this build")
+			}
+			ginkgo.Skip("built without SQLCipher")
+		}
+
+		os.Remove(testDBPath)
+		os.RemoveAll(BackupDirName)
+		store, err := Open(DefaultOptions(testDBPath))
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		gomega.Expect(store.DB().Exec("INSERT INTO settings (key, value) VALUES ('shop.name', 'Corner Shop')").Error).To(gomega.Succeed())
+		gomega.Expect(store.Close()).To(gomega.Succeed())
+		useTempMachineKey()
+	})
+
+	ginkgo.AfterEach(func() {
+		os.Remove(KeyInfoPath(testDBPath))
+		os.RemoveAll(BackupDirName)
+	})
+
+	shopName := func(passphrase string) (string, error) {
+		key, err := UnlockKey(testDBPath, passphrase)
+		if err != nil {
+			return "", err
+		}
+		opts := DefaultOptions(testDBPath)
+		opts.Key = key
+		store, err := Open(opts)
+		if err != nil {
+			return "", err
+		}
+		defer store.Close()
+
+		var name string
+		err = store.DB().Raw("SELECT value FROM settings WHERE key = 'shop.name'").Scan(&name).Error
+		return name, err
 	}
+
+	ginkgo.It("should encrypt a database in place and open it with the passphrase", func() {
+		gomega.Expect(EncryptDatabase(testDBPath, KeyModePassphrase, "owner secret")).To(gomega.Succeed())
+
+		header := make([]byte, 16)
+		file, err := os.Open(testDBPath)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		_, err = file.Read(header)
+		file.Close()
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		gomega.Expect(string(header)).NotTo(gomega.Equal("SQLite format 3\x00"))
+
+		name, err := shopName("owner secret")
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		gomega.Expect(name).To(gomega.Equal("Corner Shop"))
+
+		_, err = shopName("wrong secret")
+		gomega.Expect(err).To(gomega.MatchError(ErrWrongKey))
+		_, err = Open(DefaultOptions(testDBPath))
+		gomega.Expect(err).To(gomega.HaveOccurred())
+
+		err = EncryptDatabase(testDBPath, KeyModePassphrase, "owner secret")
+		gomega.Expect(err).To(gomega.Equal(ErrAlreadyEncrypted))
+	})
+
+	ginkgo.It("should rekey a database and switch between key modes", func() {
+		err := RotateKey(testDBPath, "owner secret", KeyModePassphrase, "new secret")
+		gomega.Expect(err).To(gomega.Equal(ErrNotEncrypted))
+
+		gomega.Expect(EncryptDatabase(testDBPath, KeyModePassphrase, "owner secret")).To(gomega.Succeed())
+		err = RotateKey(testDBPath, "wrong secret", KeyModePassphrase, "new secret")
+		gomega.Expect(err).To(gomega.MatchError(ErrWrongKey))
+
+		gomega.Expect(RotateKey(testDBPath, "owner secret", KeyModePassphrase, "new secret")).To(gomega.Succeed())
+		_, err = shopName("owner secret")
+		gomega.Expect(err).To(gomega.MatchError(ErrWrongKey))
+		name, err := shopName("new secret")
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		gomega.Expect(name).To(gomega.Equal("Corner Shop"))
+
+		gomega.Expect(RotateKey(testDBPath, "new secret", KeyModeMachine, "")).To(gomega.Succeed())
+		info, err := LoadKeyInfo(testDBPath)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		gomega.Expect(info.Mode).To(gomega.Equal(KeyModeMachine))
+		name, err = shopName("")
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		gomega.Expect(name).To(gomega.Equal("Corner Shop"))
+	})
+})
+
+var _ = ginkgo.AfterSuite(func() {
 	os.Remove(testDBPath)
 })
@@ -0,0 +1,95 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+
+	"github.com/mattn/go-sqlite3"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// ErrWrongKey is returned when an encrypted database cannot be read with the
+// key it was opened with.
+var ErrWrongKey = errors.New("database key is wrong or the file is not a database")
+
+// connector opens connections through mattn's driver and prepares each new
+// connection, e.g. by setting the encryption key, before the pool uses it.
+type connector struct {
+	driver *sqlite3.SQLiteDriver
+	dsn    string
+	opts   Options
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.driver.Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.setup(ctx, conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (c *connector) Driver() driver.Driver {
+	return c.driver
+}
+
+func (c *connector) setup(ctx context.Context, conn driver.Conn) error {
+	execer, ok := conn.(driver.ExecerContext)
+	if !ok {
+		return errors.New("sqlite connection does not support Exec")
+	}
+
+	// The key must be set before anything reads the file
+	if len(c.opts.Key) > 0 {
+		if _, err := execer.ExecContext(ctx, keyPragma("key", c.opts.Key), nil); err != nil {
+			return err
+		}
+	}
+
+	for _, pragma := range c.opts.pragmas() {
+		if _, err := execer.ExecContext(ctx, pragma, nil); err != nil {
+			return fmt.Errorf("%s: %w", pragma, err)
+		}
+	}
+	return nil
+}
+
+func openSQL(opts Options) *sql.DB {
+	sqlDB := sql.OpenDB(&connector{driver: &sqlite3.SQLiteDriver{}, dsn: opts.dsn(), opts: opts})
+	if opts.MaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(opts.MaxOpenConns)
+		sqlDB.SetMaxIdleConns(opts.MaxOpenConns)
+	}
+	return sqlDB
+}
+
+// OpenFile opens a database file without touching its schema. Use it for
+// side databases such as a backup being validated.
+func OpenFile(opts Options) (*gorm.DB, error) {
+	sqlDB := openSQL(opts)
+	db, err := openGorm(sqlDB)
+	if err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+
+	// SQLCipher only notices a wrong key on the first read
+	if len(opts.Key) > 0 {
+		if err := db.Exec("SELECT count(*) FROM sqlite_master").Error; err != nil {
+			sqlDB.Close()
+			return nil, fmt.Errorf("%v: %w", err, ErrWrongKey)
+		}
+	}
+	return db, nil
+}
+
+func openGorm(pool gorm.ConnPool) (*gorm.DB, error) {
+	return gorm.Open(sqlite.New(sqlite.Config{Conn: pool}), &gorm.Config{})
+}
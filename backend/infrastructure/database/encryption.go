@@ -0,0 +1,293 @@
+package database
+
+import (
+	"blizzflow/backend/internal/secure"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Encryption at rest relies on SQLCipher. The SQLite amalgamation bundled
+// with mattn/go-sqlite3 has no codec, so production builds use
+// `-tags libsqlite3` and link libsqlcipher instead (see SQLCIPHER in the
+// Taskfiles). SupportsEncryption reports which kind of binary is running.
+//
+// The key itself never touches the disk in the clear. A sidecar
+// <db>.keyinfo file records how to recover it: either a salt for deriving it
+// from the owner passphrase or the key sealed to this machine.
+
+const (
+	keyInfoSuffix  = ".keyinfo"
+	keyInfoVersion = 1
+
+	// EnvDBPassphrase supplies the owner passphrase at startup
+	EnvDBPassphrase = "BLIZZFLOW_DB_PASSPHRASE"
+	// EnvDBNewPassphrase supplies the new passphrase when rotating the key
+	EnvDBNewPassphrase = "BLIZZFLOW_DB_NEW_PASSPHRASE"
+)
+
+// Custom errors
+var (
+	ErrEncryptionUnsupported = errors.New("this build of blizzflow cannot encrypt databases (SQLCipher not available)")
+	ErrAlreadyEncrypted      = errors.New("database is already encrypted")
+	ErrNotEncrypted          = errors.New("database is not encrypted")
+	ErrPassphraseRequired    = errors.New("database passphrase required")
+	ErrUnknownKeyMode        = errors.New("unknown key mode")
+)
+
+// KeyMode selects where the database key comes from.
+type KeyMode string
+
+const (
+	KeyModePassphrase KeyMode = "passphrase"
+	KeyModeMachine    KeyMode = "machine"
+)
+
+// KeyInfo is stored next to an encrypted database.
+type KeyInfo struct {
+	Version   int       `json:"version"`
+	Mode      KeyMode   `json:"mode"`
+	Salt      []byte    `json:"salt,omitempty"`
+	SealedKey []byte    `json:"sealed_key,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// KeyInfoPath returns the sidecar key file for dbFile.
+func KeyInfoPath(dbFile string) string {
+	return dbFile + keyInfoSuffix
+}
+
+// LoadKeyInfo reads the key sidecar. It returns nil for a plaintext database.
+func LoadKeyInfo(dbFile string) (*KeyInfo, error) {
+	data, err := os.ReadFile(KeyInfoPath(dbFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var info KeyInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("invalid key info: %w", err)
+	}
+	return &info, nil
+}
+
+func saveKeyInfo(path string, info *KeyInfo) error {
+	data, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// NewKeyInfo creates a fresh key and the sidecar that recovers it.
+func NewKeyInfo(mode KeyMode, passphrase string) (*KeyInfo, []byte, error) {
+	info := &KeyInfo{Version: keyInfoVersion, Mode: mode, CreatedAt: time.Now()}
+
+	switch mode {
+	case KeyModePassphrase:
+		salt, err := secure.NewSalt()
+		if err != nil {
+			return nil, nil, err
+		}
+		info.Salt = salt
+		key, err := info.Unlock(passphrase)
+		if err != nil {
+			return nil, nil, err
+		}
+		return info, key, nil
+	case KeyModeMachine:
+		key, err := secure.NewKey()
+		if err != nil {
+			return nil, nil, err
+		}
+		if info.SealedKey, err = secure.SealForMachine(key); err != nil {
+			return nil, nil, err
+		}
+		return info, key, nil
+	}
+	return nil, nil, fmt.Errorf("%q: %w", mode, ErrUnknownKeyMode)
+}
+
+// Unlock recovers the database key. The passphrase is ignored for
+// machine-sealed keys.
+func (k *KeyInfo) Unlock(passphrase string) ([]byte, error) {
+	switch k.Mode {
+	case KeyModePassphrase:
+		if passphrase == "" {
+			return nil, ErrPassphraseRequired
+		}
+		return secure.DeriveKey(passphrase, k.Salt)
+	case KeyModeMachine:
+		return secure.OpenForMachine(k.SealedKey)
+	}
+	return nil, fmt.Errorf("%q: %w", k.Mode, ErrUnknownKeyMode)
+}
+
+// UnlockKey returns the key for dbFile, or nil when it is not encrypted.
+func UnlockKey(dbFile, passphrase string) ([]byte, error) {
+	info, err := LoadKeyInfo(dbFile)
+	if err != nil || info == nil {
+		return nil, err
+	}
+	return info.Unlock(passphrase)
+}
+
+// SupportsEncryption reports whether the linked SQLite has the SQLCipher codec.
+func SupportsEncryption() bool {
+	db, err := OpenFile(Options{Path: ":memory:"})
+	if err != nil {
+		return false
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		defer sqlDB.Close()
+	}
+
+	var version string
+	db.Raw("PRAGMA cipher_version").Scan(&version)
+	return version != ""
+}
+
+// EncryptDatabase converts a plaintext database into an encrypted one in
+// place. The database must not be open elsewhere.
+func EncryptDatabase(dbFile string, mode KeyMode, passphrase string) error {
+	if !SupportsEncryption() {
+		return ErrEncryptionUnsupported
+	}
+	if info, err := LoadKeyInfo(dbFile); err != nil {
+		return err
+	} else if info != nil {
+		return ErrAlreadyEncrypted
+	}
+
+	info, key, err := NewKeyInfo(mode, passphrase)
+	if err != nil {
+		return err
+	}
+
+	encrypted := dbFile + ".encrypting"
+	os.Remove(encrypted)
+	if err := exportEncrypted(dbFile, encrypted, key); err != nil {
+		os.Remove(encrypted)
+		return err
+	}
+	if err := checkIntegrity(Options{Path: encrypted, Key: key}); err != nil {
+		os.Remove(encrypted)
+		return err
+	}
+
+	// Write the key info first: if we crash before the swap, the plaintext
+	// file is still readable and the sidecar can simply be deleted.
+	if err := saveKeyInfo(KeyInfoPath(dbFile), info); err != nil {
+		os.Remove(encrypted)
+		return err
+	}
+	for _, suffix := range []string{"-journal", "-wal", "-shm"} {
+		os.Remove(dbFile + suffix)
+	}
+	return os.Rename(encrypted, dbFile)
+}
+
+// RotateKey re-encrypts the database under a new key, optionally switching
+// between passphrase and machine keys. The database must not be open
+// elsewhere.
+func RotateKey(dbFile, oldPassphrase string, mode KeyMode, newPassphrase string) error {
+	if !SupportsEncryption() {
+		return ErrEncryptionUnsupported
+	}
+	oldInfo, err := LoadKeyInfo(dbFile)
+	if err != nil {
+		return err
+	}
+	if oldInfo == nil {
+		return ErrNotEncrypted
+	}
+	oldKey, err := oldInfo.Unlock(oldPassphrase)
+	if err != nil {
+		return err
+	}
+	newInfo, newKey, err := NewKeyInfo(mode, newPassphrase)
+	if err != nil {
+		return err
+	}
+
+	db, err := OpenFile(Options{Path: dbFile, Key: oldKey})
+	if err != nil {
+		return err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	// rekey must run on the only open connection
+	sqlDB.SetMaxOpenConns(1)
+	err = db.Exec(keyPragma("rekey", newKey)).Error
+	sqlDB.Close()
+	if err != nil {
+		return err
+	}
+	return saveKeyInfo(KeyInfoPath(dbFile), newInfo)
+}
+
+func exportEncrypted(src, dst string, key []byte) error {
+	db, err := OpenFile(Options{Path: src})
+	if err != nil {
+		return err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	defer sqlDB.Close()
+	// ATTACH is per connection, so keep everything on one
+	sqlDB.SetMaxOpenConns(1)
+
+	attach := fmt.Sprintf(`ATTACH DATABASE ? AS encrypted KEY "x'%s'"`, hex.EncodeToString(key))
+	if err := db.Exec(attach, dst).Error; err != nil {
+		return err
+	}
+	if err := db.Exec("SELECT sqlcipher_export('encrypted')").Error; err != nil {
+		return err
+	}
+
+	var userVersion int
+	db.Raw("PRAGMA user_version").Scan(&userVersion)
+	if err := db.Exec(fmt.Sprintf("PRAGMA encrypted.user_version = %d", userVersion)).Error; err != nil {
+		return err
+	}
+	return db.Exec("DETACH DATABASE encrypted").Error
+}
+
+func checkIntegrity(opts Options) error {
+	db, err := OpenFile(opts)
+	if err != nil {
+		return err
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		defer sqlDB.Close()
+	}
+
+	var result string
+	if err := db.Raw("PRAGMA integrity_check").Scan(&result).Error; err != nil {
+		return err
+	}
+	if result != "ok" {
+		return fmt.Errorf("integrity check failed: %s", result)
+	}
+	return nil
+}
+
+// keyPragma formats a raw-key PRAGMA so SQLCipher skips its own key
+// derivation; the key was already stretched by DeriveKey.
+func keyPragma(name string, key []byte) string {
+	return fmt.Sprintf(`PRAGMA %s = "x'%s'"`, name, hex.EncodeToString(key))
+}
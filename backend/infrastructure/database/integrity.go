@@ -0,0 +1,29 @@
+package database
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// IntegrityResult is the outcome of a SQLite consistency check.
+type IntegrityResult struct {
+	CheckedAt time.Time `json:"checkedAt"`
+	OK        bool      `json:"ok"`
+	Problems  []string  `json:"problems"`
+}
+
+// QuickCheck runs PRAGMA quick_check, which verifies the b-tree structure
+// without the slower index cross-checks of integrity_check.
+func QuickCheck(db *gorm.DB) (*IntegrityResult, error) {
+	var rows []string
+	if err := db.Raw("PRAGMA quick_check").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	result := &IntegrityResult{CheckedAt: time.Now(), OK: len(rows) == 1 && rows[0] == "ok"}
+	if !result.OK {
+		result.Problems = rows
+	}
+	return result, nil
+}
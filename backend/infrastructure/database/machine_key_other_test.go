@@ -0,0 +1,19 @@
+//go:build !windows
+
+package database
+
+import (
+	"blizzflow/backend/internal/secure"
+	"path/filepath"
+
+	"github.com/onsi/ginkgo/v2"
+)
+
+// useTempMachineKey keeps machine-sealed test keys out of the user's config
+// directory.
+func useTempMachineKey() {
+	path := filepath.Join(ginkgo.GinkgoT().TempDir(), "machine.key")
+	defaultPath := secure.MachineKeyPath
+	secure.MachineKeyPath = func() (string, error) { return path, nil }
+	ginkgo.DeferCleanup(func() { secure.MachineKeyPath = defaultPath })
+}
@@ -0,0 +1,7 @@
+//go:build windows
+
+package database
+
+// useTempMachineKey has nothing to redirect on Windows, where DPAPI seals
+// keys without a key file.
+func useTempMachineKey() {}
@@ -0,0 +1,157 @@
+package database
+
+import (
+	"blizzflow/backend/infrastructure/database/migrations"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Custom errors
+var (
+	ErrSchemaTooNew     = errors.New("database schema is newer than this version of blizzflow")
+	ErrUnknownMigration = errors.New("unknown schema version")
+	ErrNoDownMigration  = errors.New("migration cannot be reverted")
+)
+
+// BackupDirName is the folder next to the database that holds backups.
+const BackupDirName = "backups"
+
+// SchemaMigration records an applied migration.
+type SchemaMigration struct {
+	Version   int       `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// SchemaVersion returns the latest applied migration, or 0 for a database
+// that has never been migrated.
+func SchemaVersion(db *gorm.DB) (int, error) {
+	if !db.Migrator().HasTable(&SchemaMigration{}) {
+		return 0, nil
+	}
+	var version int
+	err := db.Model(&SchemaMigration{}).Select("COALESCE(MAX(version), 0)").Scan(&version).Error
+	return version, err
+}
+
+// MigrateUp applies all pending migrations in order, each in its own
+// transaction. When dbFile is set and the database already holds data, a copy
+// is written to the backups folder before the first migration runs.
+func MigrateUp(db *gorm.DB, dbFile string) error {
+	if err := db.AutoMigrate(&SchemaMigration{}); err != nil {
+		return err
+	}
+
+	current, err := SchemaVersion(db)
+	if err != nil {
+		return err
+	}
+	latest := migrations.Latest()
+	if current > latest {
+		return fmt.Errorf("schema version %d, supported %d: %w", current, latest, ErrSchemaTooNew)
+	}
+
+	var pending []migrations.Migration
+	for _, m := range migrations.All() {
+		if m.Version > current {
+			pending = append(pending, m)
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	if dbFile != "" && hasUserTables(db) {
+		backup, err := backupBeforeMigrate(db, dbFile, current)
+		if err != nil {
+			return fmt.Errorf("failed to back up database before migrating: %w", err)
+		}
+		log.Printf("Database backed up to %s", backup)
+	}
+
+	for _, m := range pending {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{
+				Version:   m.Version,
+				Name:      m.Name,
+				AppliedAt: time.Now(),
+			}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %w", m.Version, m.Name, err)
+		}
+		log.Printf("Applied migration %d (%s)", m.Version, m.Name)
+	}
+	return nil
+}
+
+// MigrateDown reverts applied migrations, newest first, until the schema is at
+// target. A target of 0 reverts everything.
+func MigrateDown(db *gorm.DB, target int) error {
+	current, err := SchemaVersion(db)
+	if err != nil {
+		return err
+	}
+	if target < 0 || target > current {
+		return fmt.Errorf("cannot migrate down from %d to %d: %w", current, target, ErrUnknownMigration)
+	}
+
+	all := migrations.All()
+	for i := len(all) - 1; i >= 0; i-- {
+		m := all[i]
+		if m.Version <= target || m.Version > current {
+			continue
+		}
+		if m.Down == nil {
+			return fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, ErrNoDownMigration)
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Down(tx); err != nil {
+				return err
+			}
+			return tx.Delete(&SchemaMigration{}, m.Version).Error
+		})
+		if err != nil {
+			return fmt.Errorf("reverting migration %d (%s) failed: %w", m.Version, m.Name, err)
+		}
+		log.Printf("Reverted migration %d (%s)", m.Version, m.Name)
+	}
+	return nil
+}
+
+func hasUserTables(db *gorm.DB) bool {
+	var count int64
+	db.Raw("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' AND name <> ?",
+		SchemaMigration{}.TableName()).Scan(&count)
+	return count > 0
+}
+
+// backupBeforeMigrate writes a consistent copy of the database with
+// VACUUM INTO and returns its path.
+func backupBeforeMigrate(db *gorm.DB, dbFile string, version int) (string, error) {
+	dir := filepath.Join(filepath.Dir(dbFile), BackupDirName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	name := fmt.Sprintf("pre-migrate-v%d-%s.db", version, time.Now().Format("20060102T150405"))
+	path := filepath.Join(dir, name)
+	if err := db.Exec("VACUUM INTO ?", path).Error; err != nil {
+		return "", err
+	}
+	return path, nil
+}
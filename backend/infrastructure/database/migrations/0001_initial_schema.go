@@ -0,0 +1,96 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Snapshot of the schema that AutoMigrate produced before versioned
+// migrations existed. Existing databases already have these tables, so the
+// migration is a no-op for them and only records version 1.
+
+type userV1 struct {
+	ID           uint   `gorm:"primaryKey"`
+	Username     string `gorm:"unique;not null"`
+	PasswordHash string `gorm:"not null"`
+}
+
+func (userV1) TableName() string { return "users" }
+
+type sessionV1 struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"not null"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+func (sessionV1) TableName() string { return "sessions" }
+
+type securityQuestionV1 struct {
+	ID       uint   `gorm:"primaryKey"`
+	UserID   uint   `gorm:"not null"`
+	Question string `gorm:"not null"`
+	Answer   string `gorm:"not null"`
+}
+
+func (securityQuestionV1) TableName() string { return "security_questions" }
+
+type licenseV1 struct {
+	ID          uint      `gorm:"primaryKey"`
+	Key         string    `gorm:"unique;not null"`
+	Username    string    `gorm:"not null"`
+	ExpiresAt   time.Time `gorm:"not null"`
+	Fingerprint string    `gorm:"not null"`
+	CreatedAt   time.Time `gorm:"autoCreateTime"`
+}
+
+func (licenseV1) TableName() string { return "licenses" }
+
+type inventoryV1 struct {
+	ID        uint      `gorm:"primaryKey"`
+	Name      string    `gorm:"not null"`
+	Quantity  int       `gorm:"not null"`
+	Price     float64   `gorm:"not null"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+func (inventoryV1) TableName() string { return "inventories" }
+
+type saleV1 struct {
+	ID          uint      `gorm:"primaryKey"`
+	InventoryID uint      `gorm:"not null"`
+	Quantity    int       `gorm:"not null"`
+	TotalPrice  float64   `gorm:"not null"`
+	CreatedAt   time.Time `gorm:"autoCreateTime"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime"`
+}
+
+func (saleV1) TableName() string { return "sales" }
+
+func init() {
+	register(Migration{
+		Version: 1,
+		Name:    "initial_schema",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(
+				&userV1{},
+				&sessionV1{},
+				&securityQuestionV1{},
+				&licenseV1{},
+				&inventoryV1{},
+				&saleV1{},
+			)
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(
+				&saleV1{},
+				&inventoryV1{},
+				&licenseV1{},
+				&securityQuestionV1{},
+				&sessionV1{},
+				&userV1{},
+			)
+		},
+	})
+}
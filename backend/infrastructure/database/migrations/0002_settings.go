@@ -0,0 +1,31 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Per-company key/value settings. They live in the company database so they
+// travel with its backups.
+
+type settingV2 struct {
+	Key       string    `gorm:"primaryKey"`
+	Value     string    `gorm:"not null"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+func (settingV2) TableName() string { return "settings" }
+
+func init() {
+	register(Migration{
+		Version: 2,
+		Name:    "settings",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&settingV2{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&settingV2{})
+		},
+	})
+}
@@ -0,0 +1,35 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Sales are marked once the retention job has exported them; only marked
+// sales are ever purged.
+
+type saleV3 struct {
+	ArchivedAt *time.Time `gorm:"index"`
+}
+
+func (saleV3) TableName() string { return "sales" }
+
+func init() {
+	register(Migration{
+		Version: 3,
+		Name:    "sale_archived_at",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Migrator().AddColumn(&saleV3{}, "ArchivedAt"); err != nil {
+				return err
+			}
+			return tx.Migrator().CreateIndex(&saleV3{}, "ArchivedAt")
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropIndex(&saleV3{}, "ArchivedAt"); err != nil {
+				return err
+			}
+			return tx.Migrator().DropColumn(&saleV3{}, "ArchivedAt")
+		},
+	})
+}
@@ -0,0 +1,43 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// State and run history of the background job scheduler.
+
+type scheduledJobV4 struct {
+	Name           string `gorm:"primaryKey"`
+	LastRunAt      *time.Time
+	NextRunAt      *time.Time
+	LastError      string
+	LastDurationMs int64
+}
+
+func (scheduledJobV4) TableName() string { return "scheduled_jobs" }
+
+type jobRunV4 struct {
+	ID         uint      `gorm:"primaryKey"`
+	Job        string    `gorm:"not null;index"`
+	StartedAt  time.Time `gorm:"not null"`
+	FinishedAt *time.Time
+	Status     string `gorm:"not null"`
+	Error      string
+}
+
+func (jobRunV4) TableName() string { return "job_runs" }
+
+func init() {
+	register(Migration{
+		Version: 4,
+		Name:    "scheduler",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&scheduledJobV4{}, &jobRunV4{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&jobRunV4{}, &scheduledJobV4{})
+		},
+	})
+}
@@ -0,0 +1,100 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Inventory items gain the fields the catalog screens list, filter and search
+// by. Full-text search uses an FTS5 index kept in sync by triggers. FTS5 is
+// only compiled into mattn/go-sqlite3 with `-tags sqlite_fts5` (or when
+// linking a system SQLite that has it); without it the index is not created
+// and searches fall back to LIKE. A database indexed by such a build can no
+// longer be written to by a build without FTS5, as the triggers need it.
+
+type inventoryV5 struct {
+	SKU        string     `gorm:"not null;default:'';index"`
+	Barcode    string     `gorm:"not null;default:'';index"`
+	Category   string     `gorm:"not null;default:'';index"`
+	ArchivedAt *time.Time `gorm:"index"`
+}
+
+func (inventoryV5) TableName() string { return "inventories" }
+
+var inventoryV5Fields = []string{"SKU", "Barcode", "Category", "ArchivedAt"}
+
+var inventorySearchV5 = []string{
+	`CREATE VIRTUAL TABLE inventory_search USING fts5(
+		name, sku, barcode,
+		content='inventories', content_rowid='id',
+		tokenize='unicode61 remove_diacritics 2'
+	)`,
+	`CREATE TRIGGER inventory_search_insert AFTER INSERT ON inventories BEGIN
+		INSERT INTO inventory_search(rowid, name, sku, barcode) VALUES (new.id, new.name, new.sku, new.barcode);
+	END`,
+	`CREATE TRIGGER inventory_search_delete AFTER DELETE ON inventories BEGIN
+		INSERT INTO inventory_search(inventory_search, rowid, name, sku, barcode) VALUES ('delete', old.id, old.name, old.sku, old.barcode);
+	END`,
+	`CREATE TRIGGER inventory_search_update AFTER UPDATE OF name, sku, barcode ON inventories BEGIN
+		INSERT INTO inventory_search(inventory_search, rowid, name, sku, barcode) VALUES ('delete', old.id, old.name, old.sku, old.barcode);
+		INSERT INTO inventory_search(rowid, name, sku, barcode) VALUES (new.id, new.name, new.sku, new.barcode);
+	END`,
+	`INSERT INTO inventory_search(inventory_search) VALUES ('rebuild')`,
+}
+
+func init() {
+	register(Migration{
+		Version: 5,
+		Name:    "inventory_catalog",
+		Up: func(tx *gorm.DB) error {
+			for _, field := range inventoryV5Fields {
+				if err := tx.Migrator().AddColumn(&inventoryV5{}, field); err != nil {
+					return err
+				}
+				if err := tx.Migrator().CreateIndex(&inventoryV5{}, field); err != nil {
+					return err
+				}
+			}
+
+			var fts5 int64
+			if err := tx.Raw("SELECT count(*) FROM pragma_module_list WHERE name = 'fts5'").Scan(&fts5).Error; err != nil {
+				return err
+			}
+			if fts5 == 0 {
+				return nil
+			}
+			for _, stmt := range inventorySearchV5 {
+				if err := tx.Exec(stmt).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, stmt := range []string{
+				"DROP TRIGGER IF EXISTS inventory_search_update",
+				"DROP TRIGGER IF EXISTS inventory_search_delete",
+				"DROP TRIGGER IF EXISTS inventory_search_insert",
+				"DROP TABLE IF EXISTS inventory_search",
+			} {
+				if err := tx.Exec(stmt).Error; err != nil {
+					return err
+				}
+			}
+			// Dropping a column rebuilds the table, which loses any index
+			// still on it
+			for _, field := range inventoryV5Fields {
+				if err := tx.Migrator().DropIndex(&inventoryV5{}, field); err != nil {
+					return err
+				}
+			}
+			for _, field := range inventoryV5Fields {
+				if err := tx.Migrator().DropColumn(&inventoryV5{}, field); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
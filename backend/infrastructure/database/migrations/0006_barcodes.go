@@ -0,0 +1,152 @@
+package migrations
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Items can carry several barcodes. The single barcode column stays as the
+// primary one; existing values move into the new table and items without a
+// barcode get an internal one. SKUs become unique, ignoring case; duplicates
+// left by imports keep the SKU on their oldest item only.
+
+type barcodeV6 struct {
+	ID          uint      `gorm:"primaryKey"`
+	InventoryID uint      `gorm:"not null;index"`
+	Code        string    `gorm:"not null;uniqueIndex"`
+	Symbology   string    `gorm:"not null"`
+	CreatedAt   time.Time `gorm:"autoCreateTime"`
+}
+
+func (barcodeV6) TableName() string { return "barcodes" }
+
+type inventoryBarcodeV6 struct {
+	ID      uint
+	Barcode string
+}
+
+func (inventoryBarcodeV6) TableName() string { return "inventories" }
+
+// Symbologies as the barcode package named them when this migration was
+// written.
+const (
+	ean13V6    = "ean13"
+	upcaV6     = "upca"
+	code128V6  = "code128"
+	internalV6 = "internal"
+)
+
+// parseBarcodeV6 normalizes an existing barcode the way barcode.Parse did
+// when this migration was written: GS1 codes are EAN-13, with UPC-A
+// widened to it and prefix 20 marking the internal codes, and other
+// printable codes are Code 128. Codes that do not parse are kept as typed,
+// as nothing checked them before.
+func parseBarcodeV6(raw string) (string, string) {
+	code := strings.TrimSpace(raw)
+	if code != "" && strings.Trim(code, "0123456789") == "" && (len(code) == 12 || len(code) == 13) {
+		switch {
+		case checkDigitV6(code[:len(code)-1]) != code[len(code)-1]:
+			return raw, code128V6
+		case len(code) == 12:
+			return "0" + code, upcaV6
+		case strings.HasPrefix(code, "20"):
+			return code, internalV6
+		}
+		return code, ean13V6
+	}
+	if code == "" || len(code) > 48 {
+		return raw, code128V6
+	}
+	for i := 0; i < len(code); i++ {
+		if code[i] < 0x20 || code[i] > 0x7e {
+			return raw, code128V6
+		}
+	}
+	return code, code128V6
+}
+
+// internalCodeV6 builds the internal code given to an item without a
+// barcode.
+func internalCodeV6(id uint) string {
+	body := fmt.Sprintf("20%010d", uint64(id)%10_000_000_000)
+	return body + string(checkDigitV6(body))
+}
+
+// checkDigitV6 computes the GS1 mod-10 check digit for the digits
+// preceding it.
+func checkDigitV6(digits string) byte {
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if (len(digits)-1-i)%2 == 0 {
+			d *= 3
+		}
+		sum += d
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+func init() {
+	register(Migration{
+		Version: 6,
+		Name:    "barcodes",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&barcodeV6{}); err != nil {
+				return err
+			}
+
+			var items []inventoryBarcodeV6
+			if err := tx.Order("id").Find(&items).Error; err != nil {
+				return err
+			}
+			for _, item := range items {
+				code, symbology := internalCodeV6(item.ID), internalV6
+				if item.Barcode != "" {
+					code, symbology = parseBarcodeV6(item.Barcode)
+				}
+
+				var taken int64
+				if err := tx.Model(&barcodeV6{}).Where("code = ?", code).Count(&taken).Error; err != nil {
+					return err
+				}
+				if taken > 0 {
+					code = ""
+				} else if err := tx.Create(&barcodeV6{InventoryID: item.ID, Code: code, Symbology: symbology}).Error; err != nil {
+					return err
+				}
+				if code != item.Barcode {
+					if err := tx.Model(&item).Update("barcode", code).Error; err != nil {
+						return err
+					}
+				}
+			}
+
+			for _, stmt := range []string{
+				"DROP INDEX IF EXISTS idx_inventories_sku",
+				`UPDATE inventories SET sku = '' WHERE sku <> '' AND id NOT IN (
+					SELECT min(id) FROM inventories WHERE sku <> '' GROUP BY sku COLLATE NOCASE
+				)`,
+				"CREATE UNIQUE INDEX idx_inventories_sku ON inventories(sku COLLATE NOCASE) WHERE sku <> ''",
+			} {
+				if err := tx.Exec(stmt).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, stmt := range []string{
+				"DROP INDEX IF EXISTS idx_inventories_sku",
+				"CREATE INDEX idx_inventories_sku ON inventories(sku)",
+			} {
+				if err := tx.Exec(stmt).Error; err != nil {
+					return err
+				}
+			}
+			return tx.Migrator().DropTable(&barcodeV6{})
+		},
+	})
+}
@@ -0,0 +1,57 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Stock changes are recorded in an append-only ledger; inventories.quantity
+// becomes a cache of it. Every item with stock gets an opening movement so
+// the ledger adds up from the start. A trigger rejects edits to movements;
+// mistakes are corrected by further movements.
+
+type stockMovementV7 struct {
+	ID          uint   `gorm:"primaryKey"`
+	InventoryID uint   `gorm:"not null;index"`
+	Type        string `gorm:"not null;index"`
+	Quantity    int    `gorm:"not null"`
+	Balance     int    `gorm:"not null"`
+	Reference   string `gorm:"not null;default:'';index"`
+	UserID      *uint
+	Reason      string    `gorm:"not null;default:''"`
+	CreatedAt   time.Time `gorm:"autoCreateTime;index"`
+}
+
+func (stockMovementV7) TableName() string { return "stock_movements" }
+
+func init() {
+	register(Migration{
+		Version: 7,
+		Name:    "stock_movements",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&stockMovementV7{}); err != nil {
+				return err
+			}
+			for _, stmt := range []string{
+				`INSERT INTO stock_movements (inventory_id, type, quantity, balance, reference, reason, created_at)
+					SELECT id, 'opening', quantity, quantity, '', 'opening balance', CURRENT_TIMESTAMP
+					FROM inventories WHERE quantity <> 0 ORDER BY id`,
+				`CREATE TRIGGER stock_movements_append_only BEFORE UPDATE ON stock_movements BEGIN
+					SELECT RAISE(ABORT, 'stock movements cannot be changed');
+				END`,
+			} {
+				if err := tx.Exec(stmt).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Exec("DROP TRIGGER IF EXISTS stock_movements_append_only").Error; err != nil {
+				return err
+			}
+			return tx.Migrator().DropTable(&stockMovementV7{})
+		},
+	})
+}
@@ -0,0 +1,80 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Users get a role so managers can approve large stock adjustments. The
+// oldest user of an existing database, who set it up, becomes its owner.
+// Adjustments are requested with a reason code from a configurable list,
+// seeded with common ones.
+
+type userV8 struct {
+	Role string `gorm:"not null;default:'staff'"`
+}
+
+func (userV8) TableName() string { return "users" }
+
+type adjustmentReasonV8 struct {
+	Code      string    `gorm:"primaryKey"`
+	Label     string    `gorm:"not null"`
+	Direction string    `gorm:"not null;default:'either'"`
+	Active    bool      `gorm:"not null;default:true"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+func (adjustmentReasonV8) TableName() string { return "adjustment_reasons" }
+
+type stockAdjustmentV8 struct {
+	ID           uint    `gorm:"primaryKey"`
+	InventoryID  uint    `gorm:"not null;index"`
+	ReasonCode   string  `gorm:"not null;index"`
+	Quantity     int     `gorm:"not null"`
+	UnitCost     float64 `gorm:"not null"`
+	Note         string  `gorm:"not null;default:''"`
+	PhotoPath    string  `gorm:"not null;default:''"`
+	Status       string  `gorm:"not null;index"`
+	RequestedBy  uint    `gorm:"not null"`
+	ApprovedBy   *uint
+	DecisionNote string `gorm:"not null;default:''"`
+	MovementID   *uint
+	CreatedAt    time.Time  `gorm:"autoCreateTime"`
+	DecidedAt    *time.Time `gorm:"index"`
+}
+
+func (stockAdjustmentV8) TableName() string { return "stock_adjustments" }
+
+var adjustmentReasonsV8 = []adjustmentReasonV8{
+	{Code: "damage", Label: "Damaged", Direction: "decrease", Active: true},
+	{Code: "theft", Label: "Theft", Direction: "decrease", Active: true},
+	{Code: "expiry", Label: "Expired", Direction: "decrease", Active: true},
+	{Code: "found", Label: "Found stock", Direction: "increase", Active: true},
+	{Code: "correction", Label: "Correction", Direction: "either", Active: true},
+}
+
+func init() {
+	register(Migration{
+		Version: 8,
+		Name:    "stock_adjustments",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Migrator().AddColumn(&userV8{}, "Role"); err != nil {
+				return err
+			}
+			if err := tx.Exec("UPDATE users SET role = 'owner' WHERE id = (SELECT min(id) FROM users)").Error; err != nil {
+				return err
+			}
+			if err := tx.AutoMigrate(&adjustmentReasonV8{}, &stockAdjustmentV8{}); err != nil {
+				return err
+			}
+			return tx.Create(&adjustmentReasonsV8).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropTable(&stockAdjustmentV8{}, &adjustmentReasonV8{}); err != nil {
+				return err
+			}
+			return tx.Migrator().DropColumn(&userV8{}, "Role")
+		},
+	})
+}
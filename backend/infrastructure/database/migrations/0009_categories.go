@@ -0,0 +1,156 @@
+package migrations
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// The free-text category of an item becomes a reference into a category
+// tree, next to brands and tags. Each distinct category text, ignoring case,
+// turns into a top-level category. Category names are unique among siblings,
+// brand and tag names overall, all ignoring case.
+
+type categoryV9 struct {
+	ID              uint   `gorm:"primaryKey"`
+	ParentID        *uint  `gorm:"index"`
+	Name            string `gorm:"not null"`
+	Path            string `gorm:"not null;index"`
+	Depth           int    `gorm:"not null"`
+	TaxClass        string `gorm:"not null;default:''"`
+	ReorderPoint    *int
+	ReorderQuantity *int
+	CreatedAt       time.Time `gorm:"autoCreateTime"`
+	UpdatedAt       time.Time `gorm:"autoUpdateTime"`
+}
+
+func (categoryV9) TableName() string { return "categories" }
+
+type brandV9 struct {
+	ID        uint      `gorm:"primaryKey"`
+	Name      string    `gorm:"not null"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+func (brandV9) TableName() string { return "brands" }
+
+type tagV9 struct {
+	ID        uint      `gorm:"primaryKey"`
+	Name      string    `gorm:"not null"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+func (tagV9) TableName() string { return "tags" }
+
+type inventoryTagV9 struct {
+	InventoryID uint `gorm:"primaryKey"`
+	TagID       uint `gorm:"primaryKey;index"`
+}
+
+func (inventoryTagV9) TableName() string { return "inventory_tags" }
+
+type inventoryV9 struct {
+	CategoryID      *uint  `gorm:"index"`
+	BrandID         *uint  `gorm:"index"`
+	TaxClass        string `gorm:"not null;default:''"`
+	ReorderPoint    *int
+	ReorderQuantity *int
+}
+
+func (inventoryV9) TableName() string { return "inventories" }
+
+var (
+	inventoryV9Fields  = []string{"CategoryID", "BrandID", "TaxClass", "ReorderPoint", "ReorderQuantity"}
+	inventoryV9Indexes = []string{"CategoryID", "BrandID"}
+)
+
+var taxonomyIndexesV9 = []string{
+	"CREATE UNIQUE INDEX idx_categories_sibling_name ON categories(ifnull(parent_id, 0), name COLLATE NOCASE)",
+	"CREATE UNIQUE INDEX idx_brands_name ON brands(name COLLATE NOCASE)",
+	"CREATE UNIQUE INDEX idx_tags_name ON tags(name COLLATE NOCASE)",
+}
+
+func init() {
+	register(Migration{
+		Version: 9,
+		Name:    "categories",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&categoryV9{}, &brandV9{}, &tagV9{}, &inventoryTagV9{}); err != nil {
+				return err
+			}
+			for _, stmt := range taxonomyIndexesV9 {
+				if err := tx.Exec(stmt).Error; err != nil {
+					return err
+				}
+			}
+			for _, field := range inventoryV9Fields {
+				if err := tx.Migrator().AddColumn(&inventoryV9{}, field); err != nil {
+					return err
+				}
+			}
+			for _, field := range inventoryV9Indexes {
+				if err := tx.Migrator().CreateIndex(&inventoryV9{}, field); err != nil {
+					return err
+				}
+			}
+
+			var names []string
+			err := tx.Raw(`SELECT min(category) FROM inventories WHERE category <> ''
+				GROUP BY category COLLATE NOCASE ORDER BY category COLLATE NOCASE`).Scan(&names).Error
+			if err != nil {
+				return err
+			}
+			for _, name := range names {
+				category := categoryV9{Name: name}
+				if err := tx.Create(&category).Error; err != nil {
+					return err
+				}
+				path := fmt.Sprintf("/%d/", category.ID)
+				if err := tx.Model(&category).Update("path", path).Error; err != nil {
+					return err
+				}
+				err := tx.Exec("UPDATE inventories SET category_id = ? WHERE category = ? COLLATE NOCASE", category.ID, name).Error
+				if err != nil {
+					return err
+				}
+			}
+
+			// SQLite drops a column in place only once nothing indexes it
+			for _, stmt := range []string{
+				"DROP INDEX IF EXISTS idx_inventories_category",
+				"ALTER TABLE inventories DROP COLUMN category",
+			} {
+				if err := tx.Exec(stmt).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			// Items keep the name of their category, without its ancestors
+			for _, stmt := range []string{
+				"ALTER TABLE inventories ADD COLUMN category text NOT NULL DEFAULT ''",
+				"UPDATE inventories SET category = ifnull((SELECT name FROM categories WHERE categories.id = inventories.category_id), '')",
+				"CREATE INDEX idx_inventories_category ON inventories(category)",
+			} {
+				if err := tx.Exec(stmt).Error; err != nil {
+					return err
+				}
+			}
+
+			for _, field := range inventoryV9Indexes {
+				if err := tx.Migrator().DropIndex(&inventoryV9{}, field); err != nil {
+					return err
+				}
+			}
+			for _, field := range inventoryV9Fields {
+				column := tx.NamingStrategy.ColumnName("", field)
+				if err := tx.Exec("ALTER TABLE inventories DROP COLUMN " + column).Error; err != nil {
+					return err
+				}
+			}
+			return tx.Migrator().DropTable(&inventoryTagV9{}, &tagV9{}, &brandV9{}, &categoryV9{})
+		},
+	})
+}
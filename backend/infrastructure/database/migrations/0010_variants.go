@@ -0,0 +1,73 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// Items can have variants, e.g. one shirt in several sizes and colours. A
+// variant is an item of its own with a parent; the parent defines the
+// attributes and their options, and each variant picks one option per
+// attribute.
+
+type inventoryV10 struct {
+	ParentID *uint `gorm:"index"`
+}
+
+func (inventoryV10) TableName() string { return "inventories" }
+
+type variantAttributeV10 struct {
+	ID          uint   `gorm:"primaryKey"`
+	InventoryID uint   `gorm:"not null;index"`
+	Name        string `gorm:"not null"`
+	Position    int    `gorm:"not null"`
+}
+
+func (variantAttributeV10) TableName() string { return "variant_attributes" }
+
+type variantOptionV10 struct {
+	ID          uint   `gorm:"primaryKey"`
+	AttributeID uint   `gorm:"not null;index"`
+	Value       string `gorm:"not null"`
+	Position    int    `gorm:"not null"`
+}
+
+func (variantOptionV10) TableName() string { return "variant_options" }
+
+type variantValueV10 struct {
+	InventoryID uint `gorm:"primaryKey"`
+	OptionID    uint `gorm:"primaryKey;index"`
+}
+
+func (variantValueV10) TableName() string { return "variant_values" }
+
+func init() {
+	register(Migration{
+		Version: 10,
+		Name:    "variants",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&variantAttributeV10{}, &variantOptionV10{}, &variantValueV10{}); err != nil {
+				return err
+			}
+			for _, stmt := range []string{
+				"CREATE UNIQUE INDEX idx_variant_attributes_name ON variant_attributes(inventory_id, name COLLATE NOCASE)",
+				"CREATE UNIQUE INDEX idx_variant_options_value ON variant_options(attribute_id, value COLLATE NOCASE)",
+			} {
+				if err := tx.Exec(stmt).Error; err != nil {
+					return err
+				}
+			}
+			if err := tx.Migrator().AddColumn(&inventoryV10{}, "ParentID"); err != nil {
+				return err
+			}
+			return tx.Migrator().CreateIndex(&inventoryV10{}, "ParentID")
+		},
+		Down: func(tx *gorm.DB) error {
+			// SQLite drops a column in place only once nothing indexes it
+			if err := tx.Migrator().DropIndex(&inventoryV10{}, "ParentID"); err != nil {
+				return err
+			}
+			if err := tx.Exec("ALTER TABLE inventories DROP COLUMN parent_id").Error; err != nil {
+				return err
+			}
+			return tx.Migrator().DropTable(&variantValueV10{}, &variantOptionV10{}, &variantAttributeV10{})
+		},
+	})
+}
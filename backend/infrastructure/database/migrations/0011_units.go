@@ -0,0 +1,153 @@
+package migrations
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Items get a base unit with a precision so they can be stocked and sold in
+// fractions, e.g. 0.75 kg, and packs such as a case of 24 that convert to
+// it. Every quantity column becomes real; existing items are counted in
+// pieces. A barcode can stand for a pack.
+
+type unitV11 struct {
+	Code      string    `gorm:"primaryKey"`
+	Name      string    `gorm:"not null"`
+	Precision int       `gorm:"not null"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+func (unitV11) TableName() string { return "units" }
+
+type itemUnitV11 struct {
+	ID              uint      `gorm:"primaryKey"`
+	InventoryID     uint      `gorm:"not null;index"`
+	Name            string    `gorm:"not null"`
+	Factor          float64   `gorm:"not null"`
+	DefaultPurchase bool      `gorm:"not null"`
+	DefaultSale     bool      `gorm:"not null"`
+	CreatedAt       time.Time `gorm:"autoCreateTime"`
+}
+
+func (itemUnitV11) TableName() string { return "item_units" }
+
+type inventoryV11 struct {
+	Unit string `gorm:"not null;default:'pc'"`
+}
+
+func (inventoryV11) TableName() string { return "inventories" }
+
+type barcodeV11 struct {
+	UnitID *uint `gorm:"index"`
+}
+
+func (barcodeV11) TableName() string { return "barcodes" }
+
+var unitsV11 = []unitV11{
+	{Code: "pc", Name: "Piece", Precision: 0},
+	{Code: "kg", Name: "Kilogram", Precision: 3},
+	{Code: "g", Name: "Gram", Precision: 0},
+	{Code: "l", Name: "Litre", Precision: 3},
+	{Code: "ml", Name: "Millilitre", Precision: 0},
+	{Code: "m", Name: "Metre", Precision: 2},
+}
+
+// quantityColumnsV11 lists the columns that change type, by table.
+var quantityColumnsV11 = []struct {
+	table, column string
+	notNull       bool
+}{
+	{"inventories", "quantity", true},
+	{"inventories", "reorder_point", false},
+	{"inventories", "reorder_quantity", false},
+	{"categories", "reorder_point", false},
+	{"categories", "reorder_quantity", false},
+	{"stock_movements", "quantity", true},
+	{"stock_movements", "balance", true},
+	{"sales", "quantity", true},
+	{"stock_adjustments", "quantity", true},
+}
+
+const appendOnlyTriggerV11 = `CREATE TRIGGER stock_movements_append_only BEFORE UPDATE ON stock_movements BEGIN
+	SELECT RAISE(ABORT, 'stock movements cannot be changed');
+END`
+
+// retypeColumnsV11 changes the type of every quantity column. SQLite cannot
+// alter a column's type, so each is copied into a new column that takes its
+// name; rebuilding the tables instead would lose their triggers. The ledger's
+// append-only trigger is lifted for the copy.
+func retypeColumnsV11(tx *gorm.DB, typ string, convert string) error {
+	if err := tx.Exec("DROP TRIGGER IF EXISTS stock_movements_append_only").Error; err != nil {
+		return err
+	}
+	for _, c := range quantityColumnsV11 {
+		constraint := ""
+		if c.notNull {
+			constraint = " NOT NULL DEFAULT 0"
+		}
+		for _, stmt := range []string{
+			fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s_new %s%s", c.table, c.column, typ, constraint),
+			fmt.Sprintf("UPDATE %s SET %s_new = "+convert, c.table, c.column, c.column),
+			fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", c.table, c.column),
+			fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s_new TO %s", c.table, c.column, c.column),
+		} {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Exec(appendOnlyTriggerV11).Error
+}
+
+func init() {
+	register(Migration{
+		Version: 11,
+		Name:    "units",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&unitV11{}, &itemUnitV11{}); err != nil {
+				return err
+			}
+			if err := tx.Create(&unitsV11).Error; err != nil {
+				return err
+			}
+			err := tx.Exec("CREATE UNIQUE INDEX idx_item_units_name ON item_units(inventory_id, name COLLATE NOCASE)").Error
+			if err != nil {
+				return err
+			}
+			if err := tx.Migrator().AddColumn(&inventoryV11{}, "Unit"); err != nil {
+				return err
+			}
+			if err := tx.Migrator().AddColumn(&barcodeV11{}, "UnitID"); err != nil {
+				return err
+			}
+			if err := tx.Migrator().CreateIndex(&barcodeV11{}, "UnitID"); err != nil {
+				return err
+			}
+			return retypeColumnsV11(tx, "real", "%s")
+		},
+		Down: func(tx *gorm.DB) error {
+			// Fractions are rounded to whole pieces; packs and their barcodes
+			// are forgotten
+			if err := retypeColumnsV11(tx, "integer", "round(%s)"); err != nil {
+				return err
+			}
+			if err := tx.Exec("DELETE FROM barcodes WHERE unit_id IS NOT NULL").Error; err != nil {
+				return err
+			}
+			if err := tx.Migrator().DropIndex(&barcodeV11{}, "UnitID"); err != nil {
+				return err
+			}
+			for _, stmt := range []string{
+				"ALTER TABLE barcodes DROP COLUMN unit_id",
+				"ALTER TABLE inventories DROP COLUMN unit",
+			} {
+				if err := tx.Exec(stmt).Error; err != nil {
+					return err
+				}
+			}
+			return tx.Migrator().DropTable(&itemUnitV11{}, &unitV11{})
+		},
+	})
+}
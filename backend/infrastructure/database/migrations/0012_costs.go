@@ -0,0 +1,84 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Items get a last purchase, average and standard cost, and every ledger
+// movement carries its value at cost, so stock can be valued as of any date.
+// FIFO costing consumes cost layers opened by inbound movements. Sales store
+// the cost of the goods sold. Existing stock has no known cost and is valued
+// at zero.
+
+type costLayerV12 struct {
+	ID          uint      `gorm:"primaryKey"`
+	InventoryID uint      `gorm:"not null;index"`
+	MovementID  *uint     `gorm:"index"`
+	Quantity    float64   `gorm:"not null"`
+	Remaining   float64   `gorm:"not null"`
+	UnitCost    float64   `gorm:"not null"`
+	CreatedAt   time.Time `gorm:"autoCreateTime"`
+}
+
+func (costLayerV12) TableName() string { return "cost_layers" }
+
+type inventoryV12 struct {
+	LastCost     float64 `gorm:"not null;default:0"`
+	AverageCost  float64 `gorm:"not null;default:0"`
+	StandardCost float64 `gorm:"not null;default:0"`
+}
+
+func (inventoryV12) TableName() string { return "inventories" }
+
+type stockMovementV12 struct {
+	Cost float64 `gorm:"not null;default:0"`
+}
+
+func (stockMovementV12) TableName() string { return "stock_movements" }
+
+type saleV12 struct {
+	Cost float64 `gorm:"not null;default:0"`
+}
+
+func (saleV12) TableName() string { return "sales" }
+
+func init() {
+	register(Migration{
+		Version: 12,
+		Name:    "costs",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&costLayerV12{}); err != nil {
+				return err
+			}
+			for _, column := range []string{"LastCost", "AverageCost", "StandardCost"} {
+				if err := tx.Migrator().AddColumn(&inventoryV12{}, column); err != nil {
+					return err
+				}
+			}
+			if err := tx.Migrator().AddColumn(&stockMovementV12{}, "Cost"); err != nil {
+				return err
+			}
+			return tx.Migrator().AddColumn(&saleV12{}, "Cost")
+		},
+		Down: func(tx *gorm.DB) error {
+			// Revaluations only carry a value, which is forgotten
+			if err := tx.Exec("DELETE FROM stock_movements WHERE type = 'revaluation'").Error; err != nil {
+				return err
+			}
+			for _, stmt := range []string{
+				"ALTER TABLE sales DROP COLUMN cost",
+				"ALTER TABLE stock_movements DROP COLUMN cost",
+				"ALTER TABLE inventories DROP COLUMN standard_cost",
+				"ALTER TABLE inventories DROP COLUMN average_cost",
+				"ALTER TABLE inventories DROP COLUMN last_cost",
+			} {
+				if err := tx.Exec(stmt).Error; err != nil {
+					return err
+				}
+			}
+			return tx.Migrator().DropTable(&costLayerV12{})
+		},
+	})
+}
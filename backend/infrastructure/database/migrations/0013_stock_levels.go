@@ -0,0 +1,53 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// Items and categories get a minimum level that serves as safety stock, a
+// maximum level orders fill up to and a lead time in days, next to the
+// reorder point and quantity. Like those, they are inherited from the
+// category when empty.
+
+type inventoryV13 struct {
+	MinLevel     *float64
+	MaxLevel     *float64
+	LeadTimeDays *int
+}
+
+func (inventoryV13) TableName() string { return "inventories" }
+
+type categoryV13 struct {
+	MinLevel     *float64
+	MaxLevel     *float64
+	LeadTimeDays *int
+}
+
+func (categoryV13) TableName() string { return "categories" }
+
+var levelColumnsV13 = []string{"MinLevel", "MaxLevel", "LeadTimeDays"}
+
+func init() {
+	register(Migration{
+		Version: 13,
+		Name:    "stock_levels",
+		Up: func(tx *gorm.DB) error {
+			for _, model := range []interface{}{&inventoryV13{}, &categoryV13{}} {
+				for _, column := range levelColumnsV13 {
+					if err := tx.Migrator().AddColumn(model, column); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, table := range []string{"inventories", "categories"} {
+				for _, column := range []string{"min_level", "max_level", "lead_time_days"} {
+					if err := tx.Exec("ALTER TABLE " + table + " DROP COLUMN " + column).Error; err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		},
+	})
+}
@@ -0,0 +1,97 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Stock is bought from suppliers through purchase orders. A supplier item
+// holds the supplier's code and cost for one of our items; supplier names
+// are unique ignoring case, as is each item per supplier.
+
+type supplierV14 struct {
+	ID               uint       `gorm:"primaryKey"`
+	Name             string     `gorm:"not null"`
+	ContactName      string     `gorm:"not null;default:''"`
+	Email            string     `gorm:"not null;default:''"`
+	Phone            string     `gorm:"not null;default:''"`
+	Address          string     `gorm:"not null;default:''"`
+	PaymentTermsDays int        `gorm:"not null;default:0"`
+	Notes            string     `gorm:"not null;default:''"`
+	ArchivedAt       *time.Time `gorm:"index"`
+	CreatedAt        time.Time  `gorm:"autoCreateTime"`
+	UpdatedAt        time.Time  `gorm:"autoUpdateTime"`
+}
+
+func (supplierV14) TableName() string { return "suppliers" }
+
+type supplierItemV14 struct {
+	ID           uint   `gorm:"primaryKey"`
+	SupplierID   uint   `gorm:"not null;index"`
+	InventoryID  uint   `gorm:"not null;index"`
+	SupplierSKU  string `gorm:"not null;default:''"`
+	UnitID       *uint
+	Cost         float64 `gorm:"not null"`
+	LeadTimeDays *int
+	Preferred    bool      `gorm:"not null"`
+	UpdatedAt    time.Time `gorm:"autoUpdateTime"`
+}
+
+func (supplierItemV14) TableName() string { return "supplier_items" }
+
+type purchaseOrderV14 struct {
+	ID         uint   `gorm:"primaryKey"`
+	Number     string `gorm:"not null;index"`
+	SupplierID uint   `gorm:"not null;index"`
+	Status     string `gorm:"not null;index"`
+	Notes      string `gorm:"not null;default:''"`
+	ExpectedAt *time.Time
+	CreatedBy  uint `gorm:"not null"`
+	SentAt     *time.Time
+	ClosedAt   *time.Time
+	CreatedAt  time.Time `gorm:"autoCreateTime"`
+	UpdatedAt  time.Time `gorm:"autoUpdateTime"`
+}
+
+func (purchaseOrderV14) TableName() string { return "purchase_orders" }
+
+type purchaseOrderLineV14 struct {
+	ID          uint `gorm:"primaryKey"`
+	OrderID     uint `gorm:"not null;index"`
+	InventoryID uint `gorm:"not null;index"`
+	UnitID      *uint
+	Name        string  `gorm:"not null"`
+	SupplierSKU string  `gorm:"not null;default:''"`
+	Quantity    float64 `gorm:"not null"`
+	Received    float64 `gorm:"not null;default:0"`
+	UnitCost    float64 `gorm:"not null"`
+	Position    int     `gorm:"not null"`
+}
+
+func (purchaseOrderLineV14) TableName() string { return "purchase_order_lines" }
+
+func init() {
+	register(Migration{
+		Version: 14,
+		Name:    "purchasing",
+		Up: func(tx *gorm.DB) error {
+			err := tx.AutoMigrate(&supplierV14{}, &supplierItemV14{}, &purchaseOrderV14{}, &purchaseOrderLineV14{})
+			if err != nil {
+				return err
+			}
+			for _, stmt := range []string{
+				"CREATE UNIQUE INDEX idx_suppliers_name ON suppliers(name COLLATE NOCASE)",
+				"CREATE UNIQUE INDEX idx_supplier_items_item ON supplier_items(supplier_id, inventory_id)",
+			} {
+				if err := tx.Exec(stmt).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&purchaseOrderLineV14{}, &purchaseOrderV14{}, &supplierItemV14{}, &supplierV14{})
+		},
+	})
+}
@@ -0,0 +1,48 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Deliveries are booked against purchase orders as goods receipts, one line
+// per order line with what was expected, received and refused as damaged.
+
+type goodsReceiptV15 struct {
+	ID         uint      `gorm:"primaryKey"`
+	OrderID    uint      `gorm:"not null;index"`
+	Reference  string    `gorm:"not null;default:''"`
+	Notes      string    `gorm:"not null;default:''"`
+	ReceivedBy uint      `gorm:"not null"`
+	ReceivedAt time.Time `gorm:"not null;index"`
+	CreatedAt  time.Time `gorm:"autoCreateTime"`
+}
+
+func (goodsReceiptV15) TableName() string { return "goods_receipts" }
+
+type goodsReceiptLineV15 struct {
+	ID          uint    `gorm:"primaryKey"`
+	ReceiptID   uint    `gorm:"not null;index"`
+	OrderLineID uint    `gorm:"not null;index"`
+	InventoryID uint    `gorm:"not null;index"`
+	Expected    float64 `gorm:"not null"`
+	Received    float64 `gorm:"not null"`
+	Damaged     float64 `gorm:"not null;default:0"`
+	MovementID  *uint
+}
+
+func (goodsReceiptLineV15) TableName() string { return "goods_receipt_lines" }
+
+func init() {
+	register(Migration{
+		Version: 15,
+		Name:    "goods_receipts",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&goodsReceiptV15{}, &goodsReceiptLineV15{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&goodsReceiptLineV15{}, &goodsReceiptV15{})
+		},
+	})
+}
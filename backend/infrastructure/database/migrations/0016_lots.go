@@ -0,0 +1,72 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Lot-tracked items keep their stock in lots with an expiry date. Each
+// movement of such an item is allocated to the lots it filled or drew from;
+// lot numbers are unique per item, ignoring case. Goods receipts note the
+// lot a delivery line went into.
+
+type inventoryV16 struct {
+	TrackLots bool `gorm:"not null;default:false"`
+}
+
+func (inventoryV16) TableName() string { return "inventories" }
+
+type lotV16 struct {
+	ID          uint       `gorm:"primaryKey"`
+	InventoryID uint       `gorm:"not null;index"`
+	Number      string     `gorm:"not null"`
+	ExpiresAt   *time.Time `gorm:"index"`
+	Quantity    float64    `gorm:"not null;default:0"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime"`
+}
+
+func (lotV16) TableName() string { return "lots" }
+
+type lotAllocationV16 struct {
+	ID         uint    `gorm:"primaryKey"`
+	LotID      uint    `gorm:"not null;index"`
+	MovementID uint    `gorm:"not null;index"`
+	Quantity   float64 `gorm:"not null"`
+}
+
+func (lotAllocationV16) TableName() string { return "lot_allocations" }
+
+type goodsReceiptLineV16 struct {
+	LotID *uint
+}
+
+func (goodsReceiptLineV16) TableName() string { return "goods_receipt_lines" }
+
+func init() {
+	register(Migration{
+		Version: 16,
+		Name:    "lots",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Migrator().AddColumn(&inventoryV16{}, "TrackLots"); err != nil {
+				return err
+			}
+			if err := tx.Migrator().AddColumn(&goodsReceiptLineV16{}, "LotID"); err != nil {
+				return err
+			}
+			if err := tx.AutoMigrate(&lotV16{}, &lotAllocationV16{}); err != nil {
+				return err
+			}
+			return tx.Exec("CREATE UNIQUE INDEX idx_lots_number ON lots(inventory_id, number COLLATE NOCASE)").Error
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropTable(&lotAllocationV16{}, &lotV16{}); err != nil {
+				return err
+			}
+			if err := tx.Exec("ALTER TABLE goods_receipt_lines DROP COLUMN lot_id").Error; err != nil {
+				return err
+			}
+			return tx.Exec("ALTER TABLE inventories DROP COLUMN track_lots").Error
+		},
+	})
+}
@@ -0,0 +1,74 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Serialized items book each unit by its serial number, unique per item
+// ignoring case, and keep the movements every unit took part in. Items
+// carry how many months of warranty a sale comes with.
+
+type inventoryV17 struct {
+	TrackSerials   bool `gorm:"not null;default:false"`
+	WarrantyMonths int  `gorm:"not null;default:0"`
+}
+
+func (inventoryV17) TableName() string { return "inventories" }
+
+type serialNumberV17 struct {
+	ID             uint   `gorm:"primaryKey"`
+	InventoryID    uint   `gorm:"not null;index"`
+	Serial         string `gorm:"not null"`
+	Status         string `gorm:"not null"`
+	SoldAt         *time.Time
+	WarrantyEndsAt *time.Time
+	CreatedAt      time.Time `gorm:"autoCreateTime"`
+	UpdatedAt      time.Time `gorm:"autoUpdateTime"`
+}
+
+func (serialNumberV17) TableName() string { return "serial_numbers" }
+
+type serialEventV17 struct {
+	ID         uint      `gorm:"primaryKey"`
+	SerialID   uint      `gorm:"not null;index"`
+	MovementID uint      `gorm:"not null;index"`
+	Type       string    `gorm:"not null"`
+	Party      string    `gorm:"not null;default:''"`
+	Reference  string    `gorm:"not null;default:''"`
+	CreatedAt  time.Time `gorm:"autoCreateTime"`
+}
+
+func (serialEventV17) TableName() string { return "serial_events" }
+
+func init() {
+	register(Migration{
+		Version: 17,
+		Name:    "serial_numbers",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Migrator().AddColumn(&inventoryV17{}, "TrackSerials"); err != nil {
+				return err
+			}
+			if err := tx.Migrator().AddColumn(&inventoryV17{}, "WarrantyMonths"); err != nil {
+				return err
+			}
+			if err := tx.AutoMigrate(&serialNumberV17{}, &serialEventV17{}); err != nil {
+				return err
+			}
+			if err := tx.Exec("CREATE UNIQUE INDEX idx_serial_numbers_item ON serial_numbers(inventory_id, serial COLLATE NOCASE)").Error; err != nil {
+				return err
+			}
+			return tx.Exec("CREATE INDEX idx_serial_numbers_serial ON serial_numbers(serial COLLATE NOCASE)").Error
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropTable(&serialEventV17{}, &serialNumberV17{}); err != nil {
+				return err
+			}
+			if err := tx.Exec("ALTER TABLE inventories DROP COLUMN warranty_months").Error; err != nil {
+				return err
+			}
+			return tx.Exec("ALTER TABLE inventories DROP COLUMN track_serials").Error
+		},
+	})
+}
@@ -0,0 +1,131 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Stock is kept at locations. The stock held so far goes to a main location
+// and a transit location holds goods sent between locations. Ledger rows,
+// sales, goods receipts and lots note their location; serialized units note
+// it while in stock. Lot numbers are unique per item and location. Transfers
+// move stock between locations.
+
+type locationV18 struct {
+	ID         uint       `gorm:"primaryKey"`
+	Name       string     `gorm:"not null"`
+	Transit    bool       `gorm:"not null;default:false"`
+	ArchivedAt *time.Time `gorm:"index"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime"`
+	UpdatedAt  time.Time  `gorm:"autoUpdateTime"`
+}
+
+func (locationV18) TableName() string { return "locations" }
+
+type transferV18 struct {
+	ID         uint   `gorm:"primaryKey"`
+	Number     string `gorm:"not null;index"`
+	FromID     uint   `gorm:"not null;index"`
+	ToID       uint   `gorm:"not null;index"`
+	Status     string `gorm:"not null;index"`
+	Notes      string `gorm:"not null;default:''"`
+	SentBy     uint   `gorm:"not null"`
+	SentAt     time.Time
+	ReceivedBy *uint
+	ReceivedAt *time.Time
+	CreatedAt  time.Time `gorm:"autoCreateTime"`
+	UpdatedAt  time.Time `gorm:"autoUpdateTime"`
+}
+
+func (transferV18) TableName() string { return "transfers" }
+
+type transferLineV18 struct {
+	ID                uint    `gorm:"primaryKey"`
+	TransferID        uint    `gorm:"not null;index"`
+	InventoryID       uint    `gorm:"not null;index"`
+	Sent              float64 `gorm:"not null"`
+	Received          float64 `gorm:"not null;default:0"`
+	Serials           string  `gorm:"not null;default:''"`
+	TransitMovementID uint    `gorm:"not null"`
+}
+
+func (transferLineV18) TableName() string { return "transfer_lines" }
+
+// locatedV18 is the column the located tables gain.
+type locatedV18 struct {
+	LocationID uint `gorm:"not null;default:1"`
+}
+
+type serialNumberV18 struct {
+	LocationID *uint
+}
+
+func (serialNumberV18) TableName() string { return "serial_numbers" }
+
+var locatedTablesV18 = []string{"stock_movements", "sales", "goods_receipts", "lots"}
+
+func init() {
+	register(Migration{
+		Version: 18,
+		Name:    "locations",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&locationV18{}, &transferV18{}, &transferLineV18{}); err != nil {
+				return err
+			}
+			if err := tx.Exec("CREATE UNIQUE INDEX idx_locations_name ON locations(name COLLATE NOCASE)").Error; err != nil {
+				return err
+			}
+			locations := []locationV18{{ID: 1, Name: "Main"}, {Name: "In transit", Transit: true}}
+			if err := tx.Create(&locations).Error; err != nil {
+				return err
+			}
+
+			for _, table := range locatedTablesV18 {
+				if err := tx.Table(table).Migrator().AddColumn(&locatedV18{}, "LocationID"); err != nil {
+					return err
+				}
+			}
+			if err := tx.Migrator().AddColumn(&serialNumberV18{}, "LocationID"); err != nil {
+				return err
+			}
+			if err := tx.Exec("UPDATE serial_numbers SET location_id = 1 WHERE status = 'in_stock'").Error; err != nil {
+				return err
+			}
+			for _, index := range []string{
+				"CREATE INDEX idx_stock_movements_location ON stock_movements(inventory_id, location_id)",
+				"CREATE INDEX idx_sales_location ON sales(location_id)",
+				"CREATE INDEX idx_lots_location ON lots(location_id)",
+				"DROP INDEX idx_lots_number",
+				"CREATE UNIQUE INDEX idx_lots_number ON lots(inventory_id, location_id, number COLLATE NOCASE)",
+			} {
+				if err := tx.Exec(index).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropTable(&transferLineV18{}, &transferV18{}, &locationV18{}); err != nil {
+				return err
+			}
+			for _, statement := range []string{
+				"DROP INDEX idx_lots_number",
+				"DROP INDEX idx_lots_location",
+				"DROP INDEX idx_sales_location",
+				"DROP INDEX idx_stock_movements_location",
+				"ALTER TABLE serial_numbers DROP COLUMN location_id",
+			} {
+				if err := tx.Exec(statement).Error; err != nil {
+					return err
+				}
+			}
+			for _, table := range locatedTablesV18 {
+				if err := tx.Exec("ALTER TABLE " + table + " DROP COLUMN location_id").Error; err != nil {
+					return err
+				}
+			}
+			return tx.Exec("CREATE UNIQUE INDEX idx_lots_number ON lots(inventory_id, number COLLATE NOCASE)").Error
+		},
+	})
+}
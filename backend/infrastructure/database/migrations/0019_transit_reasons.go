@@ -0,0 +1,38 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Goods lost or found on a transfer are booked as stock adjustments under
+// their own reason codes, so they show up in the shrinkage report.
+
+type adjustmentReasonV19 struct {
+	Code      string    `gorm:"primaryKey"`
+	Label     string    `gorm:"not null"`
+	Direction string    `gorm:"not null;default:'either'"`
+	Active    bool      `gorm:"not null;default:true"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+func (adjustmentReasonV19) TableName() string { return "adjustment_reasons" }
+
+var transitReasonsV19 = []adjustmentReasonV19{
+	{Code: "transit_loss", Label: "Lost in transit", Direction: "decrease", Active: true},
+	{Code: "transit_surplus", Label: "Surplus on transfer", Direction: "increase", Active: true},
+}
+
+func init() {
+	register(Migration{
+		Version: 19,
+		Name:    "transit_reasons",
+		Up: func(tx *gorm.DB) error {
+			return tx.Create(&transitReasonsV19).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Where("code IN ?", []string{"transit_loss", "transit_surplus"}).Delete(&adjustmentReasonV19{}).Error
+		},
+	})
+}
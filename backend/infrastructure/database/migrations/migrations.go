@@ -0,0 +1,41 @@
+package migrations
+
+import (
+	"sort"
+
+	"gorm.io/gorm"
+)
+
+// Migration is a single, ordered schema change. Up and Down run inside their
+// own transaction. Migrations must not reference the live structs in the
+// model package, as those keep changing after the migration was written;
+// declare a snapshot of the table shape next to the migration instead.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+var registry []Migration
+
+func register(m Migration) {
+	registry = append(registry, m)
+}
+
+// All returns every known migration ordered by version.
+func All() []Migration {
+	all := make([]Migration, len(registry))
+	copy(all, registry)
+	sort.Slice(all, func(i, j int) bool { return all[i].Version < all[j].Version })
+	return all
+}
+
+// Latest returns the highest schema version this binary knows about.
+func Latest() int {
+	all := All()
+	if len(all) == 0 {
+		return 0
+	}
+	return all[len(all)-1].Version
+}
@@ -0,0 +1,104 @@
+package database
+
+import (
+	"blizzflow/config"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// Options controls how a database file is opened and tuned.
+type Options struct {
+	Path string
+	// Key unlocks a SQLCipher-encrypted database. Nil opens a plaintext file.
+	Key []byte
+
+	// JournalMode is WAL by default so readers never block the writer
+	JournalMode string
+	Synchronous string
+	BusyTimeout time.Duration
+	ForeignKeys bool
+	// TxLock "immediate" takes the write lock when a transaction begins, so
+	// two writers queue on the busy timeout instead of failing with
+	// "database is locked" when a read lock cannot be upgraded.
+	TxLock       string
+	MaxOpenConns int
+}
+
+// Settings accepted from the config file; they end up in PRAGMA statements
+var (
+	journalModes     = map[string]bool{"DELETE": true, "TRUNCATE": true, "PERSIST": true, "MEMORY": true, "WAL": true, "OFF": true}
+	synchronousModes = map[string]bool{"OFF": true, "NORMAL": true, "FULL": true, "EXTRA": true}
+)
+
+// DefaultOptions returns the tuned settings used when nothing is configured.
+func DefaultOptions(path string) Options {
+	return Options{
+		Path:         path,
+		JournalMode:  "WAL",
+		Synchronous:  "NORMAL",
+		BusyTimeout:  5 * time.Second,
+		ForeignKeys:  true,
+		TxLock:       "immediate",
+		MaxOpenConns: 4,
+	}
+}
+
+// OptionsFromConfig overlays the config file on DefaultOptions.
+func OptionsFromConfig(cfg config.DatabaseConfig, path string) Options {
+	opts := DefaultOptions(path)
+	if mode := strings.ToUpper(cfg.JournalMode); journalModes[mode] {
+		opts.JournalMode = mode
+	} else if mode != "" {
+		log.Printf("Ignoring unknown journal_mode %q", cfg.JournalMode)
+	}
+	if sync := strings.ToUpper(cfg.Synchronous); synchronousModes[sync] {
+		opts.Synchronous = sync
+	} else if sync != "" {
+		log.Printf("Ignoring unknown synchronous mode %q", cfg.Synchronous)
+	}
+	if cfg.BusyTimeoutMs > 0 {
+		opts.BusyTimeout = time.Duration(cfg.BusyTimeoutMs) * time.Millisecond
+	}
+	if cfg.ForeignKeys != nil {
+		opts.ForeignKeys = *cfg.ForeignKeys
+	}
+	if cfg.MaxOpenConns > 0 {
+		opts.MaxOpenConns = cfg.MaxOpenConns
+	}
+	return opts
+}
+
+// IntegrityCheckInterval returns how often the background quick_check runs.
+func IntegrityCheckInterval(cfg config.DatabaseConfig) time.Duration {
+	if cfg.IntegrityCheckMinutes > 0 {
+		return time.Duration(cfg.IntegrityCheckMinutes) * time.Minute
+	}
+	return time.Hour
+}
+
+func (o Options) dsn() string {
+	if o.TxLock == "" {
+		return o.Path
+	}
+	return o.Path + "?_txlock=" + o.TxLock
+}
+
+// pragmas run on every new connection, after the encryption key.
+func (o Options) pragmas() []string {
+	var pragmas []string
+	if o.BusyTimeout > 0 {
+		pragmas = append(pragmas, fmt.Sprintf("PRAGMA busy_timeout = %d", o.BusyTimeout.Milliseconds()))
+	}
+	if o.JournalMode != "" {
+		pragmas = append(pragmas, "PRAGMA journal_mode = "+o.JournalMode)
+	}
+	if o.Synchronous != "" {
+		pragmas = append(pragmas, "PRAGMA synchronous = "+o.Synchronous)
+	}
+	if o.ForeignKeys {
+		pragmas = append(pragmas, "PRAGMA foreign_keys = ON")
+	}
+	return pragmas
+}
@@ -0,0 +1,198 @@
+package database
+
+import (
+	"blizzflow/backend/infrastructure/datadir"
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"path/filepath"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// ErrStoreClosed is returned when a closed store is used.
+var ErrStoreClosed = errors.New("database is closed")
+
+// Store owns the open database. Services hold on to Store.DB(), which stays
+// valid across Reopen: only the connection pool underneath it is swapped.
+type Store struct {
+	mu   sync.Mutex
+	db   *gorm.DB
+	pool *switchPool
+	opts Options
+}
+
+// Open opens and migrates the database described by opts.
+func Open(opts Options) (*Store, error) {
+	sqlDB, err := openMigrated(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := &switchPool{current: sqlDB}
+	db, err := openGorm(pool)
+	if err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+	return &Store{db: db, pool: pool, opts: opts}, nil
+}
+
+// DB returns the handle repositories are built from.
+func (s *Store) DB() *gorm.DB {
+	return s.db
+}
+
+// Path returns the file currently open.
+func (s *Store) Path() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.opts.Path
+}
+
+// Options returns the options the current file was opened with.
+func (s *Store) Options() Options {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.opts
+}
+
+// Reopen switches the store to another file, e.g. a different company or a
+// restored backup. The new file is opened and migrated before the old one is
+// closed, so a failure leaves the current database in place.
+func (s *Store) Reopen(opts Options) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sqlDB, err := openMigrated(opts)
+	if err != nil {
+		return err
+	}
+	old := s.pool.swap(sqlDB)
+	s.opts = opts
+	if old == nil {
+		return nil
+	}
+	return old.Close()
+}
+
+// Close closes the database. Closing twice is harmless.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old := s.pool.swap(nil)
+	if old == nil {
+		return nil
+	}
+	return old.Close()
+}
+
+// Migrate brings the schema up to the latest version known to this binary.
+func (s *Store) Migrate() error {
+	return MigrateUp(s.db, s.Path())
+}
+
+func openMigrated(opts Options) (*sql.DB, error) {
+	if err := datadir.Ensure(filepath.Dir(opts.Path)); err != nil {
+		return nil, err
+	}
+
+	db, err := OpenFile(opts)
+	if err != nil {
+		return nil, err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	// Migrate after successful connection
+	if err := MigrateUp(db, opts.Path); err != nil {
+		log.Printf("Failed to migrate database: %v", err)
+		sqlDB.Close()
+		return nil, err
+	}
+	log.Println("Database migration completed successfully")
+	return sqlDB, nil
+}
+
+// switchPool is the gorm connection pool behind a Store. It forwards to the
+// current *sql.DB so the file can be changed under a long-lived *gorm.DB.
+// Transactions already begun keep the connection they started on.
+type switchPool struct {
+	mu      sync.RWMutex
+	current *sql.DB
+}
+
+func (p *switchPool) swap(next *sql.DB) *sql.DB {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	old := p.current
+	p.current = next
+	return old
+}
+
+func (p *switchPool) get() (*sql.DB, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.current == nil {
+		return nil, ErrStoreClosed
+	}
+	return p.current, nil
+}
+
+func (p *switchPool) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	db, err := p.get()
+	if err != nil {
+		return nil, err
+	}
+	return db.PrepareContext(ctx, query)
+}
+
+func (p *switchPool) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	db, err := p.get()
+	if err != nil {
+		return nil, err
+	}
+	return db.ExecContext(ctx, query, args...)
+}
+
+func (p *switchPool) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	db, err := p.get()
+	if err != nil {
+		return nil, err
+	}
+	return db.QueryContext(ctx, query, args...)
+}
+
+func (p *switchPool) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	db, err := p.get()
+	if err != nil {
+		// sql.Row has no exported constructor, so let a closed *sql.DB
+		// report the error on Scan
+		db = closedDB
+	}
+	return db.QueryRowContext(ctx, query, args...)
+}
+
+func (p *switchPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	db, err := p.get()
+	if err != nil {
+		return nil, err
+	}
+	return db.BeginTx(ctx, opts)
+}
+
+// GetDBConn lets gorm's DB() reach the current pool.
+func (p *switchPool) GetDBConn() (*sql.DB, error) {
+	return p.get()
+}
+
+var closedDB = func() *sql.DB {
+	db := sql.OpenDB(&connector{})
+	db.Close()
+	return db
+}()
@@ -0,0 +1,218 @@
+package datadir
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	RegistryFileName = "companies.json"
+	LicenseFileName  = "license.blizz"
+	DefaultCompanyID = "default"
+
+	// companiesDirName holds one folder per company created after the first
+	companiesDirName = "companies"
+)
+
+// Custom errors
+var (
+	ErrCompanyNotFound     = errors.New("company not found")
+	ErrCompanyExists       = errors.New("a company with this name already exists")
+	ErrInvalidCompanyName  = errors.New("invalid company name")
+	ErrCannotRemoveCurrent = errors.New("the open company cannot be removed")
+)
+
+var slugInvalid = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Company is one shop: a database file with its own license and settings.
+// DataFile and LicenseFile are relative to the data directory unless they are
+// absolute, which only the default company's legacy license uses.
+type Company struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	DataFile     string    `json:"data_file"`
+	LicenseFile  string    `json:"license_file"`
+	CreatedAt    time.Time `json:"created_at"`
+	LastOpenedAt time.Time `json:"last_opened_at,omitempty"`
+}
+
+// Registry lists the companies in a data directory and remembers which one
+// was open last. It is stored as companies.json next to the default database.
+type Registry struct {
+	mu        sync.Mutex
+	dir       string
+	Current   string    `json:"current"`
+	Companies []Company `json:"companies"`
+}
+
+// LoadRegistry reads the registry of dir. A missing registry is created with
+// a single default company for the existing data.db, whose license stays at
+// legacyLicense so current installs keep their activation.
+func LoadRegistry(dir, legacyLicense string) (*Registry, error) {
+	r := &Registry{dir: dir}
+
+	data, err := os.ReadFile(filepath.Join(dir, RegistryFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		if legacyLicense == "" {
+			legacyLicense = LicenseFileName
+		}
+		r.Current = DefaultCompanyID
+		r.Companies = []Company{{
+			ID:          DefaultCompanyID,
+			Name:        "My Company",
+			DataFile:    DBFileName,
+			LicenseFile: legacyLicense,
+			CreatedAt:   time.Now(),
+		}}
+		return r, r.save()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, r); err != nil {
+		return nil, fmt.Errorf("invalid company registry: %w", err)
+	}
+	if len(r.Companies) == 0 {
+		return nil, fmt.Errorf("company registry is empty: %w", ErrCompanyNotFound)
+	}
+	if _, err := r.find(r.Current); err != nil {
+		r.Current = r.Companies[0].ID
+	}
+	return r, nil
+}
+
+// List returns a copy of the registered companies.
+func (r *Registry) List() []Company {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Company(nil), r.Companies...)
+}
+
+// Get returns the company with id.
+func (r *Registry) Get(id string) (Company, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, err := r.find(id)
+	if err != nil {
+		return Company{}, err
+	}
+	return *c, nil
+}
+
+// CurrentCompany returns the company that was opened last.
+func (r *Registry) CurrentCompany() Company {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, _ := r.find(r.Current)
+	return *c
+}
+
+// Add registers a new company with its own folder under companies/.
+func (r *Registry) Add(name string) (Company, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	name = strings.TrimSpace(name)
+	id := strings.Trim(slugInvalid.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if id == "" {
+		return Company{}, ErrInvalidCompanyName
+	}
+	for _, c := range r.Companies {
+		if c.ID == id || strings.EqualFold(c.Name, name) {
+			return Company{}, ErrCompanyExists
+		}
+	}
+
+	folder := filepath.Join(companiesDirName, id)
+	c := Company{
+		ID:          id,
+		Name:        name,
+		DataFile:    filepath.Join(folder, DBFileName),
+		LicenseFile: filepath.Join(folder, LicenseFileName),
+		CreatedAt:   time.Now(),
+	}
+	r.Companies = append(r.Companies, c)
+	if err := r.save(); err != nil {
+		r.Companies = r.Companies[:len(r.Companies)-1]
+		return Company{}, err
+	}
+	return c, nil
+}
+
+// Remove unregisters a company. Its files are left on disk.
+func (r *Registry) Remove(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if id == r.Current {
+		return ErrCannotRemoveCurrent
+	}
+	for i, c := range r.Companies {
+		if c.ID == id {
+			r.Companies = append(r.Companies[:i], r.Companies[i+1:]...)
+			return r.save()
+		}
+	}
+	return ErrCompanyNotFound
+}
+
+// SetCurrent records id as the open company.
+func (r *Registry) SetCurrent(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c, err := r.find(id)
+	if err != nil {
+		return err
+	}
+	c.LastOpenedAt = time.Now()
+	r.Current = id
+	return r.save()
+}
+
+// DataPath returns the absolute database path of c.
+func (r *Registry) DataPath(c Company) string {
+	return r.resolve(c.DataFile)
+}
+
+// LicensePath returns the absolute license path of c.
+func (r *Registry) LicensePath(c Company) string {
+	return r.resolve(c.LicenseFile)
+}
+
+func (r *Registry) resolve(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(r.dir, path)
+}
+
+func (r *Registry) find(id string) (*Company, error) {
+	for i := range r.Companies {
+		if r.Companies[i].ID == id {
+			return &r.Companies[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%q: %w", id, ErrCompanyNotFound)
+}
+
+func (r *Registry) save() error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(r.dir, RegistryFileName)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
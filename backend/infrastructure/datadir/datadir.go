@@ -0,0 +1,216 @@
+package datadir
+
+import (
+	"blizzflow/config"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/adrg/xdg"
+)
+
+const (
+	AppName    = "blizzflow"
+	DBFileName = "data.db"
+	EnvDataDir = "BLIZZFLOW_DATA_DIR"
+
+	// migratedSuffix is appended to a legacy database once it has been moved
+	// out of a cloud-synced folder so it is never picked up again.
+	migratedSuffix = ".migrated"
+)
+
+// Custom errors
+var (
+	ErrEmptyDataDir  = errors.New("data directory cannot be empty")
+	ErrAlreadyExists = errors.New("database already exists at destination")
+)
+
+// Allow overriding the environment and home lookups in tests
+var (
+	Getenv      = os.Getenv
+	UserHomeDir = os.UserHomeDir
+	BaseDataDir = func() string { return xdg.DataHome }
+)
+
+// cloudSyncFolders are path segments of folders that are synchronised by a
+// desktop cloud client. A live SQLite file inside them can be corrupted when
+// the client uploads it mid-write.
+var cloudSyncFolders = []string{
+	"onedrive",
+	"dropbox",
+	"google drive",
+	"googledrive",
+	"icloud drive",
+	"iclouddrive",
+	"mobile documents",
+	"box sync",
+	"pcloud drive",
+}
+
+// Resolve returns the directory that holds the application data. The
+// command-line flag wins, followed by BLIZZFLOW_DATA_DIR, the config file and
+// finally the per-user data directory of the platform.
+func Resolve(flagValue string, cfg *config.Config) string {
+	if dir := strings.TrimSpace(flagValue); dir != "" {
+		return expand(dir)
+	}
+	if dir := strings.TrimSpace(Getenv(EnvDataDir)); dir != "" {
+		return expand(dir)
+	}
+	if cfg != nil {
+		if dir := strings.TrimSpace(cfg.DataDir); dir != "" {
+			return expand(dir)
+		}
+	}
+	return Default()
+}
+
+// Default returns the platform data directory for the application, e.g.
+// ~/.local/share/blizzflow on Linux or %LOCALAPPDATA%\blizzflow on Windows.
+func Default() string {
+	return filepath.Join(BaseDataDir(), AppName)
+}
+
+// DBPath returns the database file inside dir.
+func DBPath(dir string) string {
+	return filepath.Join(dir, DBFileName)
+}
+
+// Ensure creates the data directory if it does not exist yet.
+func Ensure(dir string) error {
+	if dir == "" {
+		return ErrEmptyDataDir
+	}
+	return os.MkdirAll(dir, 0755)
+}
+
+// IsCloudSynced reports whether path lies inside a folder managed by a
+// cloud sync client such as OneDrive or Dropbox.
+func IsCloudSynced(path string) bool {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+	for _, segment := range strings.Split(filepath.ToSlash(abs), "/") {
+		segment = strings.ToLower(segment)
+		for _, folder := range cloudSyncFolders {
+			// OneDrive for business uses "OneDrive - <Company>"
+			if segment == folder || strings.HasPrefix(segment, folder+" - ") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// LegacyDBPaths lists the locations earlier releases stored the database in.
+func LegacyDBPaths() []string {
+	home, err := UserHomeDir()
+	if err != nil {
+		return nil
+	}
+	return []string{
+		filepath.Join(home, "OneDrive", "Documents", AppName, DBFileName),
+	}
+}
+
+// MigrateFromCloud moves the first legacy database that lives in a
+// cloud-synced folder to dbPath. It does nothing when dbPath already exists
+// or no legacy database is found, and returns the path it migrated from.
+func MigrateFromCloud(dbPath string, legacyPaths []string) (string, error) {
+	if _, err := os.Stat(dbPath); err == nil {
+		return "", nil
+	}
+
+	for _, legacy := range legacyPaths {
+		if !IsCloudSynced(legacy) {
+			continue
+		}
+		if _, err := os.Stat(legacy); err != nil {
+			continue
+		}
+
+		if err := Ensure(filepath.Dir(dbPath)); err != nil {
+			return "", err
+		}
+		if err := moveDatabase(legacy, dbPath); err != nil {
+			return "", fmt.Errorf("failed to migrate %s: %w", legacy, err)
+		}
+		log.Printf("Moved database out of cloud-synced folder: %s -> %s", legacy, dbPath)
+		return legacy, nil
+	}
+	return "", nil
+}
+
+// moveDatabase copies the database together with its journal files and then
+// renames the originals so the sync client no longer touches a live file.
+func moveDatabase(src, dst string) error {
+	if _, err := os.Stat(dst); err == nil {
+		return ErrAlreadyExists
+	}
+
+	for _, suffix := range []string{"", "-journal", "-wal", "-shm"} {
+		if _, err := os.Stat(src + suffix); err != nil {
+			continue
+		}
+		if err := copyFile(src+suffix, dst+suffix); err != nil {
+			return err
+		}
+	}
+
+	for _, suffix := range []string{"", "-journal", "-wal", "-shm"} {
+		if _, err := os.Stat(src + suffix); err != nil {
+			continue
+		}
+		if err := os.Rename(src+suffix, src+suffix+migratedSuffix); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp := dst + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
+func expand(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") || strings.HasPrefix(path, `~\`) {
+		if home, err := UserHomeDir(); err == nil {
+			path = filepath.Join(home, path[1:])
+		}
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
@@ -0,0 +1,127 @@
+package datadir
+
+import (
+	"blizzflow/config"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+)
+
+func TestDataDirSuite(t *testing.T) {
+	gomega.RegisterFailHandler(ginkgo.Fail)
+	ginkgo.RunSpecs(t, "Data Directory Test Suite")
+}
+
+var _ = ginkgo.Describe("Data Directory", func() {
+	var (
+		tempDir         string
+		originalGetenv  func(string) string
+		originalBaseDir func() string
+	)
+
+	ginkgo.BeforeEach(func() {
+		var err error
+		tempDir, err = os.MkdirTemp("", "blizzflow-datadir-*")
+		gomega.Expect(err).To(gomega.BeNil())
+
+		originalGetenv = Getenv
+		originalBaseDir = BaseDataDir
+		Getenv = func(string) string { return "" }
+		BaseDataDir = func() string { return filepath.Join(tempDir, "xdg") }
+	})
+
+	ginkgo.AfterEach(func() {
+		Getenv = originalGetenv
+		BaseDataDir = originalBaseDir
+		os.RemoveAll(tempDir)
+	})
+
+	ginkgo.Context("Resolve", func() {
+		ginkgo.It("should default to the platform data directory", func() {
+			dir := Resolve("", &config.Config{})
+			gomega.Expect(dir).To(gomega.Equal(filepath.Join(tempDir, "xdg", AppName)))
+		})
+
+		ginkgo.It("should prefer flag over env over config", func() {
+			cfg := &config.Config{DataDir: filepath.Join(tempDir, "cfg")}
+			gomega.Expect(Resolve("", cfg)).To(gomega.Equal(filepath.Join(tempDir, "cfg")))
+
+			Getenv = func(key string) string {
+				if key == EnvDataDir {
+					return filepath.Join(tempDir, "env")
+				}
+				return ""
+			}
+			gomega.Expect(Resolve("", cfg)).To(gomega.Equal(filepath.Join(tempDir, "env")))
+			gomega.Expect(Resolve(filepath.Join(tempDir, "flag"), cfg)).To(gomega.Equal(filepath.Join(tempDir, "flag")))
+		})
+	})
+
+	ginkgo.Context("IsCloudSynced", func() {
+		ginkgo.It("should detect cloud sync folders", func() {
+			gomega.Expect(IsCloudSynced(filepath.Join("home", "OneDrive", "Documents", "data.db"))).To(gomega.BeTrue())
+			gomega.Expect(IsCloudSynced(filepath.Join("home", "OneDrive - Contoso", "data.db"))).To(gomega.BeTrue())
+			gomega.Expect(IsCloudSynced(filepath.Join("home", "Dropbox", "data.db"))).To(gomega.BeTrue())
+			gomega.Expect(IsCloudSynced(filepath.Join("home", ".local", "share", "blizzflow", "data.db"))).To(gomega.BeFalse())
+		})
+	})
+
+	ginkgo.Context("MigrateFromCloud", func() {
+		ginkgo.It("should move a legacy database out of OneDrive", func() {
+			legacy := filepath.Join(tempDir, "OneDrive", "Documents", AppName, DBFileName)
+			gomega.Expect(os.MkdirAll(filepath.Dir(legacy), 0755)).To(gomega.Succeed())
+			gomega.Expect(os.WriteFile(legacy, []byte("sqlite"), 0644)).To(gomega.Succeed())
+
+			target := DBPath(filepath.Join(tempDir, "data"))
+			moved, err := MigrateFromCloud(target, []string{legacy})
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(moved).To(gomega.Equal(legacy))
+
+			data, err := os.ReadFile(target)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(string(data)).To(gomega.Equal("sqlite"))
+
+			_, err = os.Stat(legacy)
+			gomega.Expect(os.IsNotExist(err)).To(gomega.BeTrue())
+			_, err = os.Stat(legacy + migratedSuffix)
+			gomega.Expect(err).To(gomega.BeNil())
+		})
+
+		ginkgo.It("should not touch an existing database", func() {
+			legacy := filepath.Join(tempDir, "OneDrive", DBFileName)
+			gomega.Expect(os.MkdirAll(filepath.Dir(legacy), 0755)).To(gomega.Succeed())
+			gomega.Expect(os.WriteFile(legacy, []byte("old"), 0644)).To(gomega.Succeed())
+
+			target := filepath.Join(tempDir, DBFileName)
+			gomega.Expect(os.WriteFile(target, []byte("new"), 0644)).To(gomega.Succeed())
+
+			moved, err := MigrateFromCloud(target, []string{legacy})
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(moved).To(gomega.BeEmpty())
+
+			data, _ := os.ReadFile(target)
+			gomega.Expect(string(data)).To(gomega.Equal("new"))
+		})
+	})
+
+	ginkgo.Context("AcquireLock", func() {
+		ginkgo.It("should refuse a second lock on the same database", func() {
+			dbPath := filepath.Join(tempDir, DBFileName)
+
+			lock, err := AcquireLock(dbPath)
+			gomega.Expect(err).To(gomega.BeNil())
+
+			_, err = AcquireLock(dbPath)
+			gomega.Expect(err).To(gomega.MatchError(ErrDatabaseInUse))
+
+			gomega.Expect(lock.Release()).To(gomega.Succeed())
+
+			lock, err = AcquireLock(dbPath)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(lock.Release()).To(gomega.Succeed())
+		})
+	})
+})
@@ -0,0 +1,56 @@
+package datadir
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrDatabaseInUse is returned when another process already holds the
+// database open.
+var ErrDatabaseInUse = errors.New("database is already open in another process")
+
+// Lock is an exclusive, process-wide lock on a database file. It is held
+// through an OS file lock on a sidecar file, so it is released automatically
+// if the process dies. The sidecar file itself is left in place on release;
+// deleting it would let two processes lock different inodes.
+type Lock struct {
+	file *os.File
+	path string
+}
+
+// LockPath returns the sidecar lock file used for dbPath.
+func LockPath(dbPath string) string {
+	return dbPath + ".lock"
+}
+
+// AcquireLock takes the exclusive lock for dbPath or returns ErrDatabaseInUse.
+func AcquireLock(dbPath string) (*Lock, error) {
+	path := LockPath(dbPath)
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := lockFile(file); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%s: %w", dbPath, ErrDatabaseInUse)
+	}
+
+	// Record the owner to make a stuck lock easier to diagnose
+	file.Truncate(0)
+	fmt.Fprintf(file, "%d\n", os.Getpid())
+
+	return &Lock{file: file, path: path}, nil
+}
+
+// Release drops the lock. It is safe to call more than once.
+func (l *Lock) Release() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	unlockFile(l.file)
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
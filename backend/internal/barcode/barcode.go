@@ -0,0 +1,156 @@
+// Package barcode validates the codes a scanner reports and decodes the
+// variable-measure EAN-13 codes printed by deli and produce scales.
+//
+// Numeric codes are GS1 codes and must carry a valid check digit: EAN-13,
+// and UPC-A, which is stored as the equivalent EAN-13 with a leading zero.
+// EAN-13 codes starting with 2 are for restricted, in-store circulation:
+// prefix 20 holds the internal codes this application generates, 21 and 22
+// embed a price and 23 to 25 embed a weight. Anything else printable is
+// taken as Code 128.
+package barcode
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type Symbology string
+
+const (
+	EAN13    Symbology = "ean13"
+	UPCA     Symbology = "upca"
+	Code128  Symbology = "code128"
+	Internal Symbology = "internal"
+)
+
+// InternalPrefix starts every generated in-store code
+const InternalPrefix = "20"
+
+const maxCode128Length = 48
+
+var (
+	ErrInvalidBarcode = errors.New("invalid barcode")
+	ErrCheckDigit     = errors.New("barcode check digit does not match")
+)
+
+// Measure says what the value field of a variable-measure code holds.
+type Measure string
+
+const (
+	MeasurePrice  Measure = "price"
+	MeasureWeight Measure = "weight"
+)
+
+// VariablePrefixes maps restricted-circulation prefixes to what their codes
+// embed. Codes use the layout PP IIIII VVVVV C: prefix, item number, value
+// and check digit.
+var VariablePrefixes = map[string]Measure{
+	"21": MeasurePrice,
+	"22": MeasurePrice,
+	"23": MeasureWeight,
+	"24": MeasureWeight,
+	"25": MeasureWeight,
+}
+
+// Code is a validated barcode.
+type Code struct {
+	// Value is the normalized code: UPC-A is widened to EAN-13
+	Value     string
+	Symbology Symbology
+}
+
+// Variable is a decoded variable-measure code.
+type Variable struct {
+	// Template is the code with its value zeroed, under which the item is
+	// registered
+	Template string
+	Measure  Measure
+	// Value is in cents for prices and grams for weights
+	Value int
+}
+
+// Parse validates a scanned or typed code and tells its symbology.
+func Parse(raw string) (Code, error) {
+	code := strings.TrimSpace(raw)
+	if code == "" {
+		return Code{}, ErrInvalidBarcode
+	}
+
+	if isDigits(code) && (len(code) == 12 || len(code) == 13) {
+		if CheckDigit(code[:len(code)-1]) != code[len(code)-1] {
+			return Code{}, fmt.Errorf("%q: %w", code, ErrCheckDigit)
+		}
+		if len(code) == 12 {
+			return Code{Value: "0" + code, Symbology: UPCA}, nil
+		}
+		if strings.HasPrefix(code, InternalPrefix) {
+			return Code{Value: code, Symbology: Internal}, nil
+		}
+		return Code{Value: code, Symbology: EAN13}, nil
+	}
+
+	if len(code) > maxCode128Length {
+		return Code{}, fmt.Errorf("%q is too long: %w", code, ErrInvalidBarcode)
+	}
+	for i := 0; i < len(code); i++ {
+		if code[i] < 0x20 || code[i] > 0x7e {
+			return Code{}, fmt.Errorf("%q: %w", code, ErrInvalidBarcode)
+		}
+	}
+	return Code{Value: code, Symbology: Code128}, nil
+}
+
+// ParseVariable decodes a variable-measure EAN-13 code. It reports false for
+// any other code.
+func ParseVariable(code string) (Variable, bool) {
+	if len(code) != 13 || !isDigits(code) || CheckDigit(code[:12]) != code[12] {
+		return Variable{}, false
+	}
+	measure, ok := VariablePrefixes[code[:2]]
+	if !ok {
+		return Variable{}, false
+	}
+
+	value := 0
+	for _, c := range code[7:12] {
+		value = value*10 + int(c-'0')
+	}
+	return Variable{Template: Template(code), Measure: measure, Value: value}, true
+}
+
+// Template zeroes the value field of a variable-measure code. Items sold by
+// weight or price are registered under their template.
+func Template(code string) string {
+	body := code[:7] + "00000"
+	return body + string(CheckDigit(body))
+}
+
+// InternalCode builds the in-store code for a sequence number.
+func InternalCode(seq uint64) string {
+	body := fmt.Sprintf("%s%010d", InternalPrefix, seq%10_000_000_000)
+	return body + string(CheckDigit(body))
+}
+
+// CheckDigit computes the GS1 mod-10 check digit for the digits preceding it.
+func CheckDigit(digits string) byte {
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		// Weights alternate 3, 1 from the rightmost digit
+		if (len(digits)-1-i)%2 == 0 {
+			d *= 3
+		}
+		sum += d
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
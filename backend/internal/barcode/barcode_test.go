@@ -0,0 +1,68 @@
+package barcode
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+)
+
+func TestBarcodeSuite(t *testing.T) {
+	gomega.RegisterFailHandler(ginkgo.Fail)
+	ginkgo.RunSpecs(t, "Barcode Test Suite")
+}
+
+var _ = ginkgo.Describe("Barcode", func() {
+	ginkgo.DescribeTable("Parse",
+		func(raw string, want Code) {
+			code, err := Parse(raw)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(code).To(gomega.Equal(want))
+		},
+		ginkgo.Entry("EAN-13", "4006381333931", Code{Value: "4006381333931", Symbology: EAN13}),
+		ginkgo.Entry("EAN-13 with spaces around it", " 4006381333931\n", Code{Value: "4006381333931", Symbology: EAN13}),
+		ginkgo.Entry("UPC-A widened to EAN-13", "036000291452", Code{Value: "0036000291452", Symbology: UPCA}),
+		ginkgo.Entry("an internal code", "2000000000046", Code{Value: "2000000000046", Symbology: Internal}),
+		ginkgo.Entry("a variable-measure code", "2300456007508", Code{Value: "2300456007508", Symbology: EAN13}),
+		ginkgo.Entry("8 digits are not checked", "96385075", Code{Value: "96385075", Symbology: Code128}),
+		ginkgo.Entry("14 digits are not checked", "00012345678901", Code{Value: "00012345678901", Symbology: Code128}),
+		ginkgo.Entry("letters", "SOAP-BOX-12", Code{Value: "SOAP-BOX-12", Symbology: Code128}),
+	)
+
+	ginkgo.DescribeTable("Parse rejects",
+		func(raw string, want error) {
+			_, err := Parse(raw)
+			gomega.Expect(err).To(gomega.MatchError(want))
+		},
+		ginkgo.Entry("an EAN-13 with a wrong check digit", "4006381333932", ErrCheckDigit),
+		ginkgo.Entry("a UPC-A with a wrong check digit", "036000291453", ErrCheckDigit),
+		ginkgo.Entry("an empty code", "  ", ErrInvalidBarcode),
+		ginkgo.Entry("a control character", "AB\tC", ErrInvalidBarcode),
+		ginkgo.Entry("a code past 48 characters", strings.Repeat("A", 49), ErrInvalidBarcode),
+	)
+
+	ginkgo.DescribeTable("ParseVariable",
+		func(code string, want Variable, ok bool) {
+			variable, found := ParseVariable(code)
+			gomega.Expect(found).To(gomega.Equal(ok))
+			gomega.Expect(variable).To(gomega.Equal(want))
+		},
+		ginkgo.Entry("a price in cents", "2100123012503", Variable{Template: "2100123000005", Measure: MeasurePrice, Value: 1250}, true),
+		ginkgo.Entry("a weight in grams", "2300456007508", Variable{Template: "2300456000004", Measure: MeasureWeight, Value: 750}, true),
+		ginkgo.Entry("a wrong check digit", "2300456007509", Variable{}, false),
+		ginkgo.Entry("an internal code", "2000000000046", Variable{}, false),
+		ginkgo.Entry("an unassigned prefix", "2900123012509", Variable{}, false),
+		ginkgo.Entry("an ordinary EAN-13", "4006381333931", Variable{}, false),
+		ginkgo.Entry("a UPC-A", "036000291452", Variable{}, false),
+		ginkgo.Entry("8 digits", "96385074", Variable{}, false),
+		ginkgo.Entry("14 digits", "00012345678905", Variable{}, false),
+	)
+
+	ginkgo.It("should compute check digits and internal codes", func() {
+		gomega.Expect(CheckDigit("400638133393")).To(gomega.Equal(byte('1')))
+		gomega.Expect(CheckDigit("03600029145")).To(gomega.Equal(byte('2')))
+		gomega.Expect(InternalCode(4)).To(gomega.Equal("2000000000046"))
+		gomega.Expect(Template("2200123012342")).To(gomega.Equal("2200123000002"))
+	})
+})
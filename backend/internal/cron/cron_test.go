@@ -0,0 +1,73 @@
+package cron
+
+import (
+	"testing"
+	"time"
+
+	"github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+)
+
+func TestCronSuite(t *testing.T) {
+	gomega.RegisterFailHandler(ginkgo.Fail)
+	ginkgo.RunSpecs(t, "Cron Test Suite")
+}
+
+// at is a time in 2024 UTC; 1 January 2024 was a Monday.
+func at(month time.Month, day, hour, minute int) time.Time {
+	return time.Date(2024, month, day, hour, minute, 0, 0, time.UTC)
+}
+
+var _ = ginkgo.Describe("Cron", func() {
+	ginkgo.DescribeTable("Next",
+		func(expr string, from, want time.Time) {
+			schedule, err := Parse(expr)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(schedule.Next(from)).To(gomega.Equal(want))
+		},
+		ginkgo.Entry("every minute is strictly after", "* * * * *", at(1, 1, 10, 0).Add(30*time.Second), at(1, 1, 10, 1)),
+		ginkgo.Entry("a fixed time later today", "30 14 * * *", at(3, 5, 9, 0), at(3, 5, 14, 30)),
+		ginkgo.Entry("a fixed time tomorrow", "30 14 * * *", at(3, 5, 14, 30), at(3, 6, 14, 30)),
+		ginkgo.Entry("a range of hours", "0 9-11 * * *", at(3, 5, 11, 0), at(3, 6, 9, 0)),
+		ginkgo.Entry("a step over all minutes", "*/15 * * * *", at(3, 5, 9, 16), at(3, 5, 9, 30)),
+		ginkgo.Entry("a step over a range", "0 8-18/4 * * *", at(3, 5, 12, 1), at(3, 5, 16, 0)),
+		ginkgo.Entry("a step from a start value", "5/20 * * * *", at(3, 5, 9, 26), at(3, 5, 9, 45)),
+		ginkgo.Entry("a list", "0 6,18 * * *", at(3, 5, 7, 0), at(3, 5, 18, 0)),
+		ginkgo.Entry("weekday names", "0 9 * * mon-fri", at(3, 8, 10, 0), at(3, 11, 9, 0)),
+		ginkgo.Entry("7 as Sunday", "0 0 * * 7", at(3, 5, 0, 0), at(3, 10, 0, 0)),
+		ginkgo.Entry("month names", "0 0 1 jun *", at(3, 5, 0, 0), at(6, 1, 0, 0)),
+		ginkgo.Entry("day of month or day of week", "0 0 13 * fri", at(9, 1, 0, 0), at(9, 6, 0, 0)),
+		ginkgo.Entry("day of month with any weekday", "0 0 13 * *", at(9, 1, 0, 0), at(9, 13, 0, 0)),
+		ginkgo.Entry("rolling over into the next month", "0 0 31 * *", at(4, 1, 0, 0), at(5, 31, 0, 0)),
+		ginkgo.Entry("rolling over into the next year", "@yearly", at(12, 31, 23, 59), time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)),
+		ginkgo.Entry("a leap day", "0 0 29 2 *", at(3, 1, 0, 0), time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)),
+		ginkgo.Entry("a day that never comes", "0 0 30 2 *", at(1, 1, 0, 0), time.Time{}),
+	)
+
+	ginkgo.DescribeTable("invalid expressions",
+		func(expr string) {
+			_, err := Parse(expr)
+			gomega.Expect(err).To(gomega.MatchError(ErrInvalidExpression))
+		},
+		ginkgo.Entry("too few fields", "* * * *"),
+		ginkgo.Entry("too many fields", "* * * * * *"),
+		ginkgo.Entry("an unknown macro", "@sometimes"),
+		ginkgo.Entry("a minute out of range", "60 * * * *"),
+		ginkgo.Entry("an hour out of range", "0 24 * * *"),
+		ginkgo.Entry("day zero", "0 0 0 * *"),
+		ginkgo.Entry("month 13", "0 0 1 13 *"),
+		ginkgo.Entry("weekday 8", "0 0 * * 8"),
+		ginkgo.Entry("a reversed range", "0 18-6 * * *"),
+		ginkgo.Entry("a zero step", "*/0 * * * *"),
+		ginkgo.Entry("a bad step", "*/x * * * *"),
+		ginkgo.Entry("an unknown name", "0 0 * * fun"),
+		ginkgo.Entry("an empty list item", "0,,5 * * * *"),
+	)
+
+	ginkgo.It("should keep the expression it was given", func() {
+		schedule, err := Parse("@Daily")
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(schedule.String()).To(gomega.Equal("@Daily"))
+		gomega.Expect(schedule.Next(at(3, 5, 9, 0))).To(gomega.Equal(at(3, 6, 0, 0)))
+	})
+})
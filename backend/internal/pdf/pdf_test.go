@@ -0,0 +1,89 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+)
+
+func TestPDFSuite(t *testing.T) {
+	gomega.RegisterFailHandler(ginkgo.Fail)
+	ginkgo.RunSpecs(t, "PDF Test Suite")
+}
+
+var _ = ginkgo.Describe("PDF", func() {
+	ginkgo.It("should point every xref entry at its object", func() {
+		doc := New()
+		doc.Text(40, 60, 12, true, "Invoice (copy)")
+		doc.Line(40, 70, 555, 70)
+		doc.AddPage()
+		doc.TextRight(555, 60, 10, false, "Total € 12,50")
+		out := doc.Bytes()
+
+		gomega.Expect(bytes.HasPrefix(out, []byte("%PDF-1.4\n"))).To(gomega.BeTrue())
+		gomega.Expect(bytes.HasSuffix(out, []byte("%%EOF\n"))).To(gomega.BeTrue())
+
+		tail := string(out[bytes.LastIndex(out, []byte("startxref\n")):])
+		xref, err := strconv.Atoi(strings.Fields(tail)[1])
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(string(out[xref:])).To(gomega.HavePrefix("xref\n0 9\n0000000000 65535 f \n"))
+
+		entries := strings.Split(string(out[xref:]), "\n")[3:11]
+		for i, entry := range entries {
+			gomega.Expect(entry).To(gomega.HaveSuffix(" 00000 n "))
+			offset, err := strconv.Atoi(entry[:10])
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(string(out[offset:])).To(gomega.HavePrefix(fmt.Sprintf("%d 0 obj\n", i+1)))
+		}
+		gomega.Expect(string(out)).To(gomega.ContainSubstring("/Kids [5 0 R 7 0 R] /Count 2"))
+		gomega.Expect(string(out)).To(gomega.ContainSubstring("trailer\n<< /Size 9 /Root 1 0 R >>"))
+	})
+
+	ginkgo.It("should give a document without pages an empty one", func() {
+		out := string(New().Bytes())
+		gomega.Expect(out).To(gomega.ContainSubstring("/Count 1"))
+		gomega.Expect(out).To(gomega.ContainSubstring("<< /Length 0 >>\nstream\nendstream"))
+	})
+
+	ginkgo.DescribeTable("escape",
+		func(text, want string) {
+			gomega.Expect(escape(text)).To(gomega.Equal(want))
+		},
+		ginkgo.Entry("plain text", "Invoice 42", "Invoice 42"),
+		ginkgo.Entry("parentheses", "Net (30 days)", `Net \(30 days\)`),
+		ginkgo.Entry("a backslash", `C:\docs`, `C:\\docs`),
+		ginkgo.Entry("the euro sign", "€ 5", "\x80 5"),
+		ginkgo.Entry("Latin-1 letters", "Müller", "M\xfcller"),
+		ginkgo.Entry("characters outside WinAnsi", "✓ 東京\n", "? ???"),
+	)
+
+	ginkgo.It("should escape text as it draws it", func() {
+		doc := New()
+		doc.Text(10, 20, 9, false, `(a\b) €`)
+		gomega.Expect(doc.pages[0].String()).To(gomega.Equal("BT /F1 9.00 Tf 10.00 821.89 Td (\\(a\\\\b\\) \x80) Tj ET\n"))
+	})
+
+	ginkgo.DescribeTable("Fit",
+		func(text string, width float64, want string) {
+			fitted := Fit(text, 10, width)
+			gomega.Expect(fitted).To(gomega.Equal(want))
+		},
+		ginkgo.Entry("text that fits", "Hello", 30.0, "Hello"),
+		ginkgo.Entry("text exactly as wide", "Hello", 22.78, "Hello"),
+		ginkgo.Entry("text that is cut", "Hello world", 30.0, "Hell..."),
+		ginkgo.Entry("a width below the ellipsis", "Hello", 5.0, "..."),
+		ginkgo.Entry("characters outside ASCII", "€€€€€€", 25.0, "€€..."),
+		ginkgo.Entry("empty text", "", 0.0, ""),
+	)
+
+	ginkgo.It("should measure text in Helvetica widths", func() {
+		gomega.Expect(Width("Hello", 10)).To(gomega.BeNumerically("~", 22.78, 1e-9))
+		gomega.Expect(Width("...", 10)).To(gomega.BeNumerically("~", 8.34, 1e-9))
+		gomega.Expect(Width("€", 1000)).To(gomega.BeNumerically("~", 556, 1e-9))
+	})
+})
@@ -0,0 +1,18 @@
+package secure
+
+import "errors"
+
+// ErrMachineKey is returned when a key sealed on another machine or by
+// another user account is opened.
+var ErrMachineKey = errors.New("key was sealed on a different machine or user account")
+
+// SealForMachine encrypts data so only the current user on this machine can
+// open it again. The mechanism is platform specific.
+func SealForMachine(data []byte) ([]byte, error) {
+	return sealForMachine(data)
+}
+
+// OpenForMachine reverses SealForMachine.
+func OpenForMachine(data []byte) ([]byte, error) {
+	return openForMachine(data)
+}
@@ -0,0 +1,79 @@
+//go:build !windows
+
+package secure
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Without DPAPI the key is sealed with a random machine key kept in a file
+// only the current user can read.
+
+// ErrCorruptMachineKey is returned when the machine key file exists but does
+// not hold a key. It is never replaced, as that would lose every key sealed
+// with it.
+var ErrCorruptMachineKey = errors.New("machine key file is corrupt")
+
+// MachineKeyPath returns the location of the machine key. Tests override it.
+var MachineKeyPath = func() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "blizzflow", "machine.key"), nil
+}
+
+func sealForMachine(data []byte) ([]byte, error) {
+	key, err := machineKey(true)
+	if err != nil {
+		return nil, err
+	}
+	return Seal(key, data)
+}
+
+func openForMachine(data []byte) ([]byte, error) {
+	key, err := machineKey(false)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := Open(key, data)
+	if err != nil {
+		return nil, ErrMachineKey
+	}
+	return plaintext, nil
+}
+
+func machineKey(create bool) ([]byte, error) {
+	path, err := MachineKeyPath()
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := os.ReadFile(path)
+	if err == nil {
+		if len(key) != KeySize {
+			return nil, fmt.Errorf("%s: %w", path, ErrCorruptMachineKey)
+		}
+		return key, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if !create {
+		return nil, ErrMachineKey
+	}
+
+	if key, err = NewKey(); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, key, 0600); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
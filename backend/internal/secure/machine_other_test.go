@@ -0,0 +1,71 @@
+//go:build !windows
+
+package secure
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("Machine Key", func() {
+	var path string
+
+	ginkgo.BeforeEach(func() {
+		path = filepath.Join(ginkgo.GinkgoT().TempDir(), "blizzflow", "machine.key")
+		defaultPath := MachineKeyPath
+		MachineKeyPath = func() (string, error) { return path, nil }
+		ginkgo.DeferCleanup(func() { MachineKeyPath = defaultPath })
+	})
+
+	ginkgo.It("should create the key on first seal and reuse it", func() {
+		_, err := OpenForMachine([]byte("anything"))
+		gomega.Expect(err).To(gomega.Equal(ErrMachineKey))
+
+		sealed, err := SealForMachine([]byte("database key"))
+		gomega.Expect(err).To(gomega.BeNil())
+		info, err := os.Stat(path)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(info.Size()).To(gomega.Equal(int64(KeySize)))
+		gomega.Expect(info.Mode().Perm()).To(gomega.Equal(os.FileMode(0600)))
+
+		again, err := SealForMachine([]byte("other key"))
+		gomega.Expect(err).To(gomega.BeNil())
+
+		plaintext, err := OpenForMachine(sealed)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(string(plaintext)).To(gomega.Equal("database key"))
+		plaintext, err = OpenForMachine(again)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(string(plaintext)).To(gomega.Equal("other key"))
+	})
+
+	ginkgo.It("should not open a key sealed with another machine key", func() {
+		sealed, err := SealForMachine([]byte("database key"))
+		gomega.Expect(err).To(gomega.BeNil())
+
+		key, err := NewKey()
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(os.WriteFile(path, key, 0600)).To(gomega.Succeed())
+
+		_, err = OpenForMachine(sealed)
+		gomega.Expect(err).To(gomega.Equal(ErrMachineKey))
+	})
+
+	ginkgo.It("should never replace a corrupt key file", func() {
+		gomega.Expect(os.MkdirAll(filepath.Dir(path), 0700)).To(gomega.Succeed())
+		gomega.Expect(os.WriteFile(path, []byte("truncated"), 0600)).To(gomega.Succeed())
+
+		_, err := SealForMachine([]byte("database key"))
+		gomega.Expect(errors.Is(err, ErrCorruptMachineKey)).To(gomega.BeTrue())
+		_, err = OpenForMachine([]byte("anything"))
+		gomega.Expect(errors.Is(err, ErrCorruptMachineKey)).To(gomega.BeTrue())
+
+		data, err := os.ReadFile(path)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(string(data)).To(gomega.Equal("truncated"))
+	})
+})
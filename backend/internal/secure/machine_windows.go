@@ -0,0 +1,43 @@
+//go:build windows
+
+package secure
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// On Windows the key is protected with DPAPI, bound to the user account.
+
+func sealForMachine(data []byte) ([]byte, error) {
+	in := newBlob(data)
+	var out windows.DataBlob
+	if err := windows.CryptProtectData(in, nil, nil, 0, nil, windows.CRYPTPROTECT_UI_FORBIDDEN, &out); err != nil {
+		return nil, err
+	}
+	return takeBlob(&out), nil
+}
+
+func openForMachine(data []byte) ([]byte, error) {
+	in := newBlob(data)
+	var out windows.DataBlob
+	if err := windows.CryptUnprotectData(in, nil, nil, 0, nil, windows.CRYPTPROTECT_UI_FORBIDDEN, &out); err != nil {
+		return nil, ErrMachineKey
+	}
+	return takeBlob(&out), nil
+}
+
+func newBlob(data []byte) *windows.DataBlob {
+	if len(data) == 0 {
+		return &windows.DataBlob{}
+	}
+	return &windows.DataBlob{Size: uint32(len(data)), Data: &data[0]}
+}
+
+func takeBlob(blob *windows.DataBlob) []byte {
+	defer windows.LocalFree(windows.Handle(unsafe.Pointer(blob.Data)))
+	out := make([]byte, blob.Size)
+	copy(out, unsafe.Slice(blob.Data, blob.Size))
+	return out
+}
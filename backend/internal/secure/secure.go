@@ -0,0 +1,146 @@
+package secure
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	KeySize  = 32 // AES-256
+	SaltSize = 16
+
+	// argon2id parameters, tuned to take well under a second on a
+	// low-end shop laptop
+	argonTime    = 1
+	argonMemory  = 64 * 1024
+	argonThreads = 4
+)
+
+// magic prefixes every sealed payload so a wrong file is detected early
+var magic = []byte("BLZSEAL1")
+
+// Custom errors
+var (
+	ErrEmptyPassphrase = errors.New("passphrase cannot be empty")
+	ErrNotSealed       = errors.New("data is not encrypted by blizzflow")
+	ErrDecrypt         = errors.New("decryption failed: wrong passphrase or corrupted data")
+)
+
+// NewSalt returns a random salt for DeriveKey.
+func NewSalt() ([]byte, error) {
+	salt := make([]byte, SaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+// NewKey returns a random key.
+func NewKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// DeriveKey stretches a passphrase into an AES-256 key with argon2id.
+func DeriveKey(passphrase string, salt []byte) ([]byte, error) {
+	if passphrase == "" {
+		return nil, ErrEmptyPassphrase
+	}
+	return argon2.IDKey([]byte(passphrase), salt, argonTime, argonMemory, argonThreads, KeySize), nil
+}
+
+// SealWithPassphrase encrypts plaintext with a key derived from passphrase.
+// The output carries the salt, so only the passphrase is needed to open it.
+func SealWithPassphrase(passphrase string, plaintext []byte) ([]byte, error) {
+	salt, err := NewSalt()
+	if err != nil {
+		return nil, err
+	}
+	key, err := DeriveKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed, err := Seal(key, plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, len(magic)+SaltSize+len(sealed))
+	out = append(out, magic...)
+	out = append(out, salt...)
+	return append(out, sealed...), nil
+}
+
+// OpenWithPassphrase reverses SealWithPassphrase.
+func OpenWithPassphrase(passphrase string, data []byte) ([]byte, error) {
+	if !IsSealed(data) {
+		return nil, ErrNotSealed
+	}
+	data = data[len(magic):]
+	if len(data) < SaltSize {
+		return nil, ErrDecrypt
+	}
+
+	key, err := DeriveKey(passphrase, data[:SaltSize])
+	if err != nil {
+		return nil, err
+	}
+	return Open(key, data[SaltSize:])
+}
+
+// IsSealed reports whether data was produced by SealWithPassphrase.
+func IsSealed(data []byte) bool {
+	return bytes.HasPrefix(data, magic)
+}
+
+// Seal encrypts plaintext with AES-GCM and prepends the nonce.
+func Seal(key, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// Open decrypts data produced by Seal.
+func Open(key, data []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonceSize := gcm.NonceSize()
+	if len(data) < nonceSize {
+		return nil, ErrDecrypt
+	}
+
+	nonce, ciphertext := data[:nonceSize], data[nonceSize:]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
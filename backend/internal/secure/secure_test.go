@@ -0,0 +1,97 @@
+package secure
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+)
+
+func TestSecureSuite(t *testing.T) {
+	gomega.RegisterFailHandler(ginkgo.Fail)
+	ginkgo.RunSpecs(t, "Secure Test Suite")
+}
+
+var _ = ginkgo.Describe("Secure", func() {
+	ginkgo.It("should derive the same key from the same passphrase and salt", func() {
+		salt, err := NewSalt()
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(salt).To(gomega.HaveLen(SaltSize))
+
+		key, err := DeriveKey("owner secret", salt)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(key).To(gomega.HaveLen(KeySize))
+
+		again, err := DeriveKey("owner secret", salt)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(again).To(gomega.Equal(key))
+
+		other, err := DeriveKey("other secret", salt)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(other).NotTo(gomega.Equal(key))
+
+		_, err = DeriveKey("", salt)
+		gomega.Expect(err).To(gomega.Equal(ErrEmptyPassphrase))
+	})
+
+	ginkgo.It("should open what it sealed with the same key only", func() {
+		key, err := NewKey()
+		gomega.Expect(err).To(gomega.BeNil())
+		sealed, err := Seal(key, []byte("database key"))
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(bytes.Contains(sealed, []byte("database key"))).To(gomega.BeFalse())
+
+		plaintext, err := Open(key, sealed)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(string(plaintext)).To(gomega.Equal("database key"))
+
+		other, err := NewKey()
+		gomega.Expect(err).To(gomega.BeNil())
+		_, err = Open(other, sealed)
+		gomega.Expect(err).To(gomega.Equal(ErrDecrypt))
+
+		sealed[len(sealed)-1] ^= 1
+		_, err = Open(key, sealed)
+		gomega.Expect(err).To(gomega.Equal(ErrDecrypt))
+
+		_, err = Open(key, sealed[:4])
+		gomega.Expect(err).To(gomega.Equal(ErrDecrypt))
+	})
+
+	ginkgo.It("should use a fresh nonce for every seal", func() {
+		key, err := NewKey()
+		gomega.Expect(err).To(gomega.BeNil())
+		first, err := Seal(key, []byte("database key"))
+		gomega.Expect(err).To(gomega.BeNil())
+		second, err := Seal(key, []byte("database key"))
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(first).NotTo(gomega.Equal(second))
+	})
+
+	ginkgo.It("should open a passphrase-sealed payload with the passphrase only", func() {
+		sealed, err := SealWithPassphrase("owner secret", []byte("database key"))
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(IsSealed(sealed)).To(gomega.BeTrue())
+
+		plaintext, err := OpenWithPassphrase("owner secret", sealed)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(string(plaintext)).To(gomega.Equal("database key"))
+
+		_, err = OpenWithPassphrase("wrong secret", sealed)
+		gomega.Expect(err).To(gomega.Equal(ErrDecrypt))
+		_, err = OpenWithPassphrase("", sealed)
+		gomega.Expect(err).To(gomega.Equal(ErrEmptyPassphrase))
+		_, err = SealWithPassphrase("", []byte("database key"))
+		gomega.Expect(err).To(gomega.Equal(ErrEmptyPassphrase))
+	})
+
+	ginkgo.It("should reject data it did not seal", func() {
+		gomega.Expect(IsSealed([]byte("SQLite format 3\x00"))).To(gomega.BeFalse())
+		_, err := OpenWithPassphrase("owner secret", []byte("SQLite format 3\x00"))
+		gomega.Expect(err).To(gomega.Equal(ErrNotSealed))
+
+		_, err = OpenWithPassphrase("owner secret", append([]byte("BLZSEAL1"), 1, 2, 3))
+		gomega.Expect(err).To(gomega.Equal(ErrDecrypt))
+	})
+})
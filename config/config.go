@@ -13,6 +13,54 @@ var (
 
 type Config struct {
 	SomeConfig string `json:"config_data"`
+	// DataDir overrides the directory holding data.db. Empty means the
+	// platform default, see datadir.Resolve.
+	DataDir   string          `json:"data_dir"`
+	Database  DatabaseConfig  `json:"database"`
+	Backup    BackupConfig    `json:"backup"`
+	Retention RetentionConfig `json:"retention"`
+}
+
+// DatabaseConfig tunes the SQLite connection. Zero values fall back to the
+// defaults in database.DefaultOptions.
+type DatabaseConfig struct {
+	JournalMode   string `json:"journal_mode"`
+	Synchronous   string `json:"synchronous"`
+	BusyTimeoutMs int    `json:"busy_timeout_ms"`
+	ForeignKeys   *bool  `json:"foreign_keys"`
+	MaxOpenConns  int    `json:"max_open_conns"`
+	// IntegrityCheckMinutes is the interval of the background quick_check
+	IntegrityCheckMinutes int `json:"integrity_check_minutes"`
+}
+
+// BackupConfig controls scheduled database snapshots.
+type BackupConfig struct {
+	// Dir defaults to the backups folder next to the database
+	Dir string `json:"dir"`
+	// MirrorDir receives a second copy of every backup, e.g. a USB drive
+	MirrorDir  string `json:"mirror_dir"`
+	Compress   bool   `json:"compress"`
+	Encrypt    bool   `json:"encrypt"`
+	KeepHourly int    `json:"keep_hourly"`
+	KeepDaily  int    `json:"keep_daily"`
+	KeepWeekly int    `json:"keep_weekly"`
+}
+
+// RetentionConfig controls how long old rows are kept before they are
+// archived and purged.
+type RetentionConfig struct {
+	// ArchiveDir defaults to the retention folder next to the database
+	ArchiveDir    string          `json:"archive_dir"`
+	IntervalHours int             `json:"interval_hours"`
+	Rules         []RetentionRule `json:"rules"`
+}
+
+// RetentionRule purges rows of Table older than MaxAgeDays. With Archive set
+// the rows are exported to a compressed file first.
+type RetentionRule struct {
+	Table      string `json:"table"`
+	MaxAgeDays int    `json:"max_age_days"`
+	Archive    bool   `json:"archive"`
 }
 
 func LoadConfig() *Config {
@@ -3,19 +3,38 @@ package main
 import (
 	license_handler "blizzflow/backend/domain/handlers/license"
 	repository "blizzflow/backend/domain/repositories"
+	adjustment_service "blizzflow/backend/domain/services/adjustment"
+	archive_service "blizzflow/backend/domain/services/archive"
 	auth_service "blizzflow/backend/domain/services/auth"
+	backup_service "blizzflow/backend/domain/services/backup"
+	catalog_service "blizzflow/backend/domain/services/catalog"
+	company_service "blizzflow/backend/domain/services/company"
+	health_service "blizzflow/backend/domain/services/health"
+	inventory_service "blizzflow/backend/domain/services/inventory"
 	license_service "blizzflow/backend/domain/services/license"
+	location_service "blizzflow/backend/domain/services/location"
+	purchase_service "blizzflow/backend/domain/services/purchase"
+	reorder_service "blizzflow/backend/domain/services/reorder"
+	retention_service "blizzflow/backend/domain/services/retention"
+	sales_service "blizzflow/backend/domain/services/sales"
+	scheduler_service "blizzflow/backend/domain/services/scheduler"
 	session_service "blizzflow/backend/domain/services/session"
+	stock_service "blizzflow/backend/domain/services/stock"
 	user_service "blizzflow/backend/domain/services/user"
+	"blizzflow/backend/events"
 	"blizzflow/backend/infrastructure/database"
+	"blizzflow/backend/infrastructure/datadir"
+	"blizzflow/config"
+	"context"
 	"embed"
+	"flag"
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
 	"time"
 
 	"github.com/wailsapp/wails/v3/pkg/application"
-	"gorm.io/gorm"
 )
 
 //go:embed all:frontend/dist
@@ -30,31 +49,144 @@ const (
 )
 
 func main() {
+	dataDirFlag := flag.String("data-dir", "", "directory holding the blizzflow database (overrides "+datadir.EnvDataDir+")")
+	encryptDBFlag := flag.String("encrypt-db", "", "encrypt the database in place with a \"passphrase\" or \"machine\" key")
+	rotateKeyFlag := flag.String("rotate-db-key", "", "re-encrypt the database with a new \"passphrase\" or \"machine\" key")
+	flag.Parse()
+
 	// get app dir
 	appDir, _ := os.UserConfigDir()
-	homeDir, _ := os.UserHomeDir()
+	cfg := config.LoadConfig()
+
+	// Resolve the data directory and move the database out of cloud-synced folders
+	dataDir := datadir.Resolve(*dataDirFlag, cfg)
+	if err := datadir.Ensure(dataDir); err != nil {
+		log.Fatalf("Failed to create data directory %s: %v", dataDir, err)
+	}
+	dbPath := datadir.DBPath(dataDir)
+	if _, err := datadir.MigrateFromCloud(dbPath, datadir.LegacyDBPaths()); err != nil {
+		log.Fatalf("Failed to migrate database: %v", err)
+	}
+
+	// Open the company that was used last
+	legacyLicensePath := filepath.Join(appDir, "blizzflow", "license.blizz")
+	registry, err := datadir.LoadRegistry(dataDir, legacyLicensePath)
+	if err != nil {
+		log.Fatalf("Failed to load companies: %v", err)
+	}
+	company := registry.CurrentCompany()
+	dbPath = registry.DataPath(company)
+	if err := datadir.Ensure(filepath.Dir(dbPath)); err != nil {
+		log.Fatalf("Failed to create company directory: %v", err)
+	}
+	if datadir.IsCloudSynced(dbPath) {
+		log.Printf("Warning: database %s is inside a cloud-synced folder", dbPath)
+	}
+
+	// Refuse to open a database that another instance is using
+	dbLock, err := datadir.AcquireLock(dbPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer dbLock.Release()
+
+	// Swap in a backup that was restored during the previous run
+	if restored, err := backup_service.ApplyPendingRestore(dbPath); err != nil {
+		dbLock.Release()
+		log.Fatalf("Failed to apply restored backup: %v", err)
+	} else if restored {
+		log.Println("Restored database from backup")
+	}
+
+	// Encryption changes need exclusive access, so they run before opening
+	passphrase := os.Getenv(database.EnvDBPassphrase)
+	if *encryptDBFlag != "" {
+		if err := database.EncryptDatabase(dbPath, database.KeyMode(*encryptDBFlag), passphrase); err != nil {
+			dbLock.Release()
+			log.Fatalf("Failed to encrypt database: %v", err)
+		}
+		log.Println("Database encrypted")
+	}
+	if *rotateKeyFlag != "" {
+		newPassphrase := os.Getenv(database.EnvDBNewPassphrase)
+		if err := database.RotateKey(dbPath, passphrase, database.KeyMode(*rotateKeyFlag), newPassphrase); err != nil {
+			dbLock.Release()
+			log.Fatalf("Failed to rotate database key: %v", err)
+		}
+		passphrase = newPassphrase
+		log.Println("Database key rotated")
+	}
+
+	dbKey, err := database.UnlockKey(dbPath, passphrase)
+	if err != nil {
+		dbLock.Release()
+		log.Fatalf("Failed to unlock database: %v", err)
+	}
+
+	dbOptions := database.OptionsFromConfig(cfg.Database, dbPath)
+	dbOptions.Key = dbKey
+	store, err := database.Open(dbOptions)
+	if err != nil {
+		dbLock.Release()
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer store.Close()
+	db := store.DB()
 
-	var db *gorm.DB // Initialize your database connection here
-	db_path := filepath.Join(homeDir, "OneDrive", "Documents", "blizzflow", "data.db")
-	os.MkdirAll(filepath.Dir(db_path), 0755)
 	// Initialize repositories
-	database.InitDB(db_path)
-	db = database.DB
 	userRepo := repository.NewUserRepository(db)
 	sessionRepo := repository.NewSessionRepository(db)
 	securityQuestionsRepo := repository.NewSecurityQuestionRepository(db)
+	uow := repository.NewUnitOfWork(db)
 
 	// Initialize services
-	userService := user_service.NewUserService(db)
-	sessionService := session_service.NewSessionService(db)
-	authService := auth_service.NewAuthService(userRepo, sessionRepo, securityQuestionsRepo)
+	userService := user_service.NewUserService(userRepo)
+	sessionService := session_service.NewSessionService(sessionRepo)
+	authService := auth_service.NewAuthService(userRepo, sessionRepo, securityQuestionsRepo, uow)
 	licenseService := license_service.NewLicenseService(repository.NewLicenseRepository(db))
+	backupService := backup_service.NewBackupService(db, dbPath, companyBackupOptions(cfg, company, dbPath, dbKey))
+	stockService := stock_service.NewStockService(repository.NewStockMovementRepository(db), repository.NewInventoryRepository(db), sessionRepo, uow)
+	categoryRepo := repository.NewCategoryRepository(db)
+	inventoryService := inventory_service.NewInventoryService(repository.NewInventoryRepository(db), repository.NewBarcodeRepository(db), categoryRepo, uow)
+	salesService := sales_service.NewSalesService(repository.NewSaleRepository(db), repository.NewInventoryRepository(db), uow)
+	catalogService := catalog_service.NewCatalogService(categoryRepo, repository.NewBrandRepository(db), repository.NewTagRepository(db), uow)
+	settingRepo := repository.NewSettingRepository(db)
+	adjustmentService := adjustment_service.NewAdjustmentService(repository.NewAdjustmentRepository(db), userRepo, sessionRepo, settingRepo, uow)
+	archiveService := archive_service.NewArchiveService(db)
+	dispatcher := events.NewDispatcher()
+	schedulerService := scheduler_service.NewSchedulerService(repository.NewJobRepository(db), dispatcher)
+	retentionService := retention_service.NewRetentionService(db, sessionRepo, cfg.Retention.Rules, companyRetentionDir(cfg, company, dbPath))
+	healthService := health_service.NewHealthService(db, backupService, dispatcher)
+	reorderService := reorder_service.NewReorderService(settingRepo, uow, dispatcher)
+	purchaseService := purchase_service.NewPurchaseService(
+		repository.NewSupplierRepository(db),
+		repository.NewPurchaseOrderRepository(db),
+		sessionRepo,
+		reorderService,
+		uow,
+	)
+	locationService := location_service.NewLocationService(repository.NewLocationRepository(db), sessionRepo, uow)
+	companyService := company_service.NewCompanyService(
+		store,
+		registry,
+		dbLock,
+		database.OptionsFromConfig(cfg.Database, ""),
+		sessionRepo,
+		settingRepo,
+		dispatcher,
+	)
+	defer companyService.Close()
 
 	// Initialize license handler
 
-	licensePath := filepath.Join(appDir, "blizzflow", "license.blizz")
-	os.MkdirAll(filepath.Dir(licensePath), 0755)
-	licenseHandler := license_handler.NewLicenseHandler(licensePath)
+	licenseHandler := license_handler.NewLicenseHandler(registry.LicensePath(company))
+
+	// Point the per-company services at the company that was opened
+	companyService.OnSwitch(func(c datadir.Company, opts database.Options) {
+		licenseHandler.SetPath(registry.LicensePath(c))
+		backupService.Retarget(opts.Path, companyBackupOptions(cfg, c, opts.Path, opts.Key))
+		retentionService.Retarget(companyRetentionDir(cfg, c, opts.Path))
+	})
 
 	app := application.New(application.Options{
 		Name:        "blizzflow",
@@ -65,6 +197,20 @@ func main() {
 			application.NewService(authService),
 			application.NewService(licenseService),
 			application.NewService(licenseHandler),
+			application.NewService(backupService),
+			application.NewService(healthService),
+			application.NewService(companyService),
+			application.NewService(archiveService),
+			application.NewService(retentionService),
+			application.NewService(schedulerService),
+			application.NewService(inventoryService),
+			application.NewService(catalogService),
+			application.NewService(stockService),
+			application.NewService(salesService),
+			application.NewService(adjustmentService),
+			application.NewService(reorderService),
+			application.NewService(purchaseService),
+			application.NewService(locationService),
 		},
 		Assets: application.AssetOptions{
 			Handler: application.AssetFileServerFS(assets),
@@ -74,6 +220,8 @@ func main() {
 		},
 	})
 
+	dispatcher.Bind(app.EmitEvent)
+
 	// Create a new window with the necessary options.
 	app.NewWebviewWindowWithOptions(application.WebviewWindowOptions{
 		Title:               "Blizzflow",
@@ -95,11 +243,116 @@ func main() {
 		}
 	}()
 
+	// Register background jobs; they stop with the application
+	jobs := []scheduler_service.Job{
+		{
+			Name:     "backup",
+			Schedule: scheduler_service.Every(15 * time.Minute),
+			Jitter:   time.Minute,
+			Run: func(ctx context.Context) error {
+				_, err := backupService.RunScheduled()
+				return err
+			},
+		},
+		{
+			Name:     "retention",
+			Schedule: scheduler_service.Every(retention_service.IntervalFromConfig(cfg.Retention)),
+			Jitter:   5 * time.Minute,
+			Run: func(ctx context.Context) error {
+				report, err := retentionService.Run()
+				if err != nil {
+					return err
+				}
+				for _, rule := range report.Rules {
+					if rule.Error != "" {
+						return fmt.Errorf("retention rule for %s failed: %s", rule.Table, rule.Error)
+					}
+				}
+				return nil
+			},
+		},
+		{
+			Name:     "integrity-check",
+			Schedule: scheduler_service.Every(database.IntegrityCheckInterval(cfg.Database)),
+			Jitter:   time.Minute,
+			Run: func(ctx context.Context) error {
+				report, err := healthService.CheckIntegrity()
+				if err != nil {
+					return err
+				}
+				if !report.OK {
+					return fmt.Errorf("integrity check failed: %v", report.Problems)
+				}
+				return nil
+			},
+		},
+		{
+			Name:     "stock-reconcile",
+			Schedule: scheduler_service.Every(24 * time.Hour),
+			Jitter:   10 * time.Minute,
+			Run: func(ctx context.Context) error {
+				report, err := stockService.Reconcile()
+				if err != nil {
+					return err
+				}
+				for _, d := range report.Fixed {
+					log.Printf("Stock of %s (%d) was %v, ledger says %v; corrected", d.Name, d.InventoryID, d.Cached, d.Ledger)
+				}
+				return nil
+			},
+		},
+		{
+			Name:     "low-stock",
+			Schedule: scheduler_service.Every(time.Hour),
+			Jitter:   5 * time.Minute,
+			Run: func(ctx context.Context) error {
+				_, err := reorderService.Scan()
+				return err
+			},
+		},
+	}
+	for _, job := range jobs {
+		if err := schedulerService.Register(job); err != nil {
+			log.Printf("Failed to register job %s: %v", job.Name, err)
+		}
+	}
+	schedulerService.Start(context.Background())
+	app.OnShutdown(schedulerService.Stop)
+
 	// Run the application. This blocks until the application has been exited.
-	err := app.Run()
+	err = app.Run()
 
 	// If an error occurred while running the application, log it and exit.
 	if err != nil {
+		store.Close()
+		dbLock.Release()
 		log.Fatal(err)
 	}
 }
+
+// companyBackupOptions keeps the backups of each company apart when a shared
+// backup or mirror directory is configured.
+func companyBackupOptions(cfg *config.Config, c datadir.Company, dbPath string, key []byte) backup_service.Options {
+	opts := backup_service.OptionsFromConfig(cfg.Backup, dbPath)
+	opts.DatabaseKey = key
+	if c.ID == datadir.DefaultCompanyID {
+		return opts
+	}
+	if cfg.Backup.Dir != "" {
+		opts.Dir = filepath.Join(opts.Dir, c.ID)
+	}
+	if opts.MirrorDir != "" {
+		opts.MirrorDir = filepath.Join(opts.MirrorDir, c.ID)
+	}
+	return opts
+}
+
+// companyRetentionDir keeps the purged rows of each company apart when a
+// shared archive directory is configured.
+func companyRetentionDir(cfg *config.Config, c datadir.Company, dbPath string) string {
+	dir := retention_service.DirFromConfig(cfg.Retention, dbPath)
+	if c.ID != datadir.DefaultCompanyID && cfg.Retention.ArchiveDir != "" {
+		dir = filepath.Join(dir, c.ID)
+	}
+	return dir
+}